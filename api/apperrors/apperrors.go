@@ -0,0 +1,150 @@
+// Package apperrors defines the errors services return to describe what went wrong in terms a client can act on.
+//
+// Every error carries a Kind, which decides the HTTP status, and a stable machine-readable Code.
+// Errors of any other type are treated as internal errors and are never shown to clients.
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+type Kind int
+
+const (
+	Internal Kind = iota
+	BadRequest
+	UnsupportedMediaType
+	PayloadTooLarge
+	Validation
+	Unauthorized
+	Forbidden
+	NotFound
+	Conflict
+	RateLimited
+)
+
+var kindNames = map[Kind]string{
+	Internal:             "internal",
+	BadRequest:           "bad_request",
+	UnsupportedMediaType: "unsupported_media_type",
+	PayloadTooLarge:      "payload_too_large",
+	Validation:           "validation",
+	Unauthorized:         "unauthorized",
+	Forbidden:            "forbidden",
+	NotFound:             "not_found",
+	Conflict:             "conflict",
+	RateLimited:          "rate_limited",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Status returns the HTTP status code for errors of kind k.
+func (k Kind) Status() int {
+	switch k {
+	case BadRequest:
+		return http.StatusBadRequest
+	case UnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case PayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case Validation:
+		return http.StatusUnprocessableEntity
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case RateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Error struct {
+	Kind Kind
+	// Code identifies the error for clients, e.g. "user_already_exists". It must not change once published.
+	Code string
+	// Message is safe to show to the client.
+	Message string
+	Fields  []FieldError
+	// RetryAfter is set on RateLimited errors.
+	RetryAfter time.Duration
+	// Err is the underlying cause. It is kept for logging and errors.Is, but never sent to the client.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a copy of e with err as its cause.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func New(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func NewValidation(code string, message string, fields ...FieldError) *Error {
+	return &Error{Kind: Validation, Code: code, Message: message, Fields: fields}
+}
+
+func NewUnauthorized(code string, message string) *Error {
+	return New(Unauthorized, code, message)
+}
+
+func NewForbidden(code string, message string) *Error {
+	return New(Forbidden, code, message)
+}
+
+func NewNotFound(code string, message string) *Error {
+	return New(NotFound, code, message)
+}
+
+func NewConflict(code string, message string) *Error {
+	return New(Conflict, code, message)
+}
+
+func NewRateLimited(code string, message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: RateLimited, Code: code, Message: message, RetryAfter: retryAfter}
+}
+
+// internalError is what clients see in place of an unexpected error.
+var internalError = New(Internal, "internal_error", "something went wrong, please try again later")
+
+// From returns the *Error in err's chain, or a generic internal error wrapping err if there is none.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return internalError.Wrap(err)
+}
+
+// Is reports whether err is an *Error with the given code.
+func Is(err error, code string) bool {
+	var appErr *Error
+	return errors.As(err, &appErr) && appErr.Code == code
+}
@@ -0,0 +1,49 @@
+package apperrors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestFromFindsWrappedError(t *testing.T) {
+	notFound := NewNotFound("user_not_found", "user does not exist").Wrap(sql.ErrNoRows)
+	err := fmt.Errorf("loading profile: %w", notFound)
+
+	appErr := From(err)
+	if appErr.Code != "user_not_found" || appErr.Kind.Status() != http.StatusNotFound {
+		t.Fatalf("unexpected error %+v", appErr)
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatal("the cause must stay reachable through errors.Is")
+	}
+}
+
+func TestFromHidesUnexpectedErrors(t *testing.T) {
+	cause := errors.New("pq: connection refused")
+
+	appErr := From(cause)
+	if appErr.Kind != Internal || appErr.Kind.Status() != http.StatusInternalServerError {
+		t.Fatalf("unexpected error %+v", appErr)
+	}
+
+	if appErr.Message == cause.Error() {
+		t.Fatal("the raw error must not be shown to clients")
+	}
+
+	if !errors.Is(appErr, cause) {
+		t.Fatal("the cause must be kept for logging")
+	}
+}
+
+func TestWrapDoesNotModifyTheOriginal(t *testing.T) {
+	base := NewConflict("user_already_exists", "user already exists")
+	_ = base.Wrap(errors.New("cause"))
+
+	if base.Err != nil {
+		t.Fatal("Wrap must return a copy")
+	}
+}
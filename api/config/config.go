@@ -0,0 +1,472 @@
+// Package config loads and validates the application configuration.
+//
+// Values are resolved in increasing order of precedence: built-in defaults, an optional env file
+// (.env unless -config or CONFIG_FILE name another one), the process environment and command line flags.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	App        AppConfig
+	HTTP       HTTPConfig
+	Database   DatabaseConfig
+	Mail       MailConfig
+	SMTPServer SMTPServerConfig
+	Auth       AuthConfig
+	RateLimit  RateLimitConfig
+	Log        LogConfig
+	CORS       CORSConfig
+	Export     ExportConfig
+}
+
+type AppConfig struct {
+	Name string
+	// URL is the public base URL used to build links in emails.
+	URL         string
+	AutoMigrate bool
+	// ShutdownTimeout bounds how long in-flight requests and background jobs get to finish on shutdown.
+	ShutdownTimeout time.Duration
+	// ShutdownDrainDelay is how long readiness fails before the server stops accepting connections,
+	// so that load balancers stop routing to the instance first.
+	ShutdownDrainDelay time.Duration
+}
+
+type HTTPConfig struct {
+	Port           int
+	RequestTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+}
+
+type DatabaseConfig struct {
+	Host            string
+	Port            int
+	User            string
+	Password        string
+	Name            string
+	SSLMode         string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DSN returns the lib/pq connection string.
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
+}
+
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// ReturnPath is the envelope sender of outgoing mail, where bounces and complaints are sent. It should
+	// be an address handled by the SMTP server; From is used when it is empty.
+	ReturnPath string
+}
+
+// SMTPServerConfig is the SMTP server receiving bounces and complaints. It only runs when Port is set.
+type SMTPServerConfig struct {
+	Port int
+	// Domain is the name the server greets clients with.
+	Domain          string
+	MaxMessageBytes int
+}
+
+type AuthConfig struct {
+	JWTSecret string
+	TokenTTL  time.Duration
+}
+
+// CORSConfig is the cross-origin policy of the dashboard API. Public endpoints such as tracking
+// use a fixed policy that accepts any origin without credentials.
+type CORSConfig struct {
+	// AllowedOrigins may contain wildcard subdomains such as https://*.example.com, or "*".
+	AllowedOrigins   []string
+	AllowCredentials bool
+	ExposedHeaders   []string
+	MaxAge           time.Duration
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn or error.
+	Level string
+	// Format is json or text.
+	Format string
+}
+
+type RateLimitConfig struct {
+	// Store is either "memory" or "postgres". Use postgres when running more than one instance.
+	Store string
+}
+
+type ExportConfig struct {
+	// Dir holds the files of background exports. Use a shared volume when running more than one instance.
+	Dir string
+	// LinkTTL is how long the download link of a finished export works; its file is deleted afterwards.
+	LinkTTL time.Duration
+}
+
+// minJWTSecretLength is the shortest accepted JWT secret; HS256 keys should carry at least 256 bits.
+const minJWTSecretLength = 32
+
+func defaults() map[string]string {
+	return map[string]string{
+		"APP_NAME":               "Appname",
+		"APP_URL":                "http://localhost:9000",
+		"AUTO_MIGRATE":           "true",
+		"SHUTDOWN_TIMEOUT":       "30s",
+		"SHUTDOWN_DRAIN_DELAY":   "0s",
+		"HTTP_PORT":              "9000",
+		"HTTP_REQUEST_TIMEOUT":   "30s",
+		"HTTP_READ_TIMEOUT":      "15s",
+		"HTTP_WRITE_TIMEOUT":     "60s",
+		"HTTP_IDLE_TIMEOUT":      "120s",
+		"DB_HOST":                "localhost",
+		"DB_PORT":                "5432",
+		"DB_SSLMODE":             "disable",
+		"DB_MAX_OPEN_CONNS":      "10",
+		"DB_MAX_IDLE_CONNS":      "5",
+		"DB_CONN_MAX_LIFETIME":   "30m",
+		"DB_CONN_MAX_IDLE_TIME":  "5m",
+		"MAIL_HOST":              "sandbox.smtp.mailtrap.io",
+		"MAIL_PORT":              "2525",
+		"MAIL_FROM":              "sender@example.com",
+		"SMTP_SERVER_PORT":       "0",
+		"SMTP_SERVER_DOMAIN":     "localhost",
+		"SMTP_SERVER_MAX_SIZE":   "10485760",
+		"JWT_TTL":                "24h",
+		"RATE_LIMIT_STORE":       "memory",
+		"LOG_LEVEL":              "info",
+		"LOG_FORMAT":             "json",
+		"CORS_ALLOWED_ORIGINS":   "*",
+		"CORS_ALLOW_CREDENTIALS": "false",
+		"CORS_EXPOSED_HEADERS":   "X-Request-ID, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset",
+		"CORS_MAX_AGE":           "10m",
+		"EXPORT_DIR":             "exports",
+		"EXPORT_LINK_TTL":        "24h",
+	}
+}
+
+// Load resolves the configuration from args (without the program name) and the environment and validates it.
+// It returns the arguments left after flag parsing, such as a subcommand.
+func Load(args []string) (*Config, []string, error) {
+	return load(args, os.LookupEnv)
+}
+
+func load(args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	flags := flag.NewFlagSet("email-marketing-service", flag.ContinueOnError)
+	configFile := flags.String("config", "", "path to an env file with configuration values (default .env)")
+	port := flags.String("port", "", "HTTP port to listen on")
+	autoMigrate := flags.String("auto-migrate", "", "apply pending migrations on startup (true or false)")
+	rateLimitStore := flags.String("rate-limit-store", "", "rate limit storage: memory or postgres")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	values := defaults()
+
+	path := *configFile
+	required := path != ""
+	if path == "" {
+		if fromEnv, ok := lookupEnv("CONFIG_FILE"); ok && fromEnv != "" {
+			path, required = fromEnv, true
+		} else {
+			path = ".env"
+		}
+	}
+
+	fileValues, err := godotenv.Read(path)
+	if err != nil {
+		if required || !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("reading config file %s: %w", path, err)
+		}
+	}
+
+	for key, value := range fileValues {
+		values[key] = value
+	}
+
+	for _, key := range knownKeys {
+		if value, ok := lookupEnv(key); ok {
+			values[key] = value
+		}
+	}
+
+	for key, value := range map[string]string{
+		"HTTP_PORT":        *port,
+		"AUTO_MIGRATE":     *autoMigrate,
+		"RATE_LIMIT_STORE": *rateLimitStore,
+	} {
+		if value != "" {
+			values[key] = value
+		}
+	}
+
+	cfg, err := parse(values)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// knownKeys lists every environment variable read by the configuration.
+var knownKeys = []string{
+	"APP_NAME", "APP_URL", "AUTO_MIGRATE", "SHUTDOWN_TIMEOUT", "SHUTDOWN_DRAIN_DELAY",
+	"HTTP_PORT", "HTTP_REQUEST_TIMEOUT", "HTTP_READ_TIMEOUT", "HTTP_WRITE_TIMEOUT", "HTTP_IDLE_TIMEOUT",
+	"DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_SSLMODE",
+	"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONN_MAX_IDLE_TIME",
+	"MAIL_HOST", "MAIL_PORT", "MAIL_USERNAME", "MAIL_PASSWORD", "MAIL_FROM", "MAIL_RETURN_PATH",
+	"SMTP_SERVER_PORT", "SMTP_SERVER_DOMAIN", "SMTP_SERVER_MAX_SIZE",
+	"JWT_KEY", "JWT_TTL",
+	"RATE_LIMIT_STORE",
+	"LOG_LEVEL", "LOG_FORMAT",
+	"CORS_ALLOWED_ORIGINS", "CORS_ALLOW_CREDENTIALS", "CORS_EXPOSED_HEADERS", "CORS_MAX_AGE",
+	"EXPORT_DIR", "EXPORT_LINK_TTL",
+}
+
+// parser collects the first conversion error so that parse can read every value in one go.
+type parser struct {
+	values map[string]string
+	err    error
+}
+
+func (p *parser) string(key string) string {
+	return strings.TrimSpace(p.values[key])
+}
+
+// list splits a comma separated value, dropping empty entries.
+func (p *parser) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(p.string(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (p *parser) int(key string) int {
+	n, err := strconv.Atoi(p.string(key))
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s must be an integer, got %q", key, p.values[key])
+	}
+	return n
+}
+
+func (p *parser) bool(key string) bool {
+	b, err := strconv.ParseBool(p.string(key))
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s must be true or false, got %q", key, p.values[key])
+	}
+	return b
+}
+
+func (p *parser) duration(key string) time.Duration {
+	d, err := time.ParseDuration(p.string(key))
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("%s must be a duration such as 30s, got %q", key, p.values[key])
+	}
+	return d
+}
+
+func parse(values map[string]string) (*Config, error) {
+	p := &parser{values: values}
+
+	cfg := &Config{
+		App: AppConfig{
+			Name:               p.string("APP_NAME"),
+			URL:                strings.TrimRight(p.string("APP_URL"), "/"),
+			AutoMigrate:        p.bool("AUTO_MIGRATE"),
+			ShutdownTimeout:    p.duration("SHUTDOWN_TIMEOUT"),
+			ShutdownDrainDelay: p.duration("SHUTDOWN_DRAIN_DELAY"),
+		},
+		HTTP: HTTPConfig{
+			Port:           p.int("HTTP_PORT"),
+			RequestTimeout: p.duration("HTTP_REQUEST_TIMEOUT"),
+			ReadTimeout:    p.duration("HTTP_READ_TIMEOUT"),
+			WriteTimeout:   p.duration("HTTP_WRITE_TIMEOUT"),
+			IdleTimeout:    p.duration("HTTP_IDLE_TIMEOUT"),
+		},
+		Database: DatabaseConfig{
+			Host:            p.string("DB_HOST"),
+			Port:            p.int("DB_PORT"),
+			User:            p.string("DB_USER"),
+			Password:        p.values["DB_PASSWORD"],
+			Name:            p.string("DB_NAME"),
+			SSLMode:         p.string("DB_SSLMODE"),
+			MaxOpenConns:    p.int("DB_MAX_OPEN_CONNS"),
+			MaxIdleConns:    p.int("DB_MAX_IDLE_CONNS"),
+			ConnMaxLifetime: p.duration("DB_CONN_MAX_LIFETIME"),
+			ConnMaxIdleTime: p.duration("DB_CONN_MAX_IDLE_TIME"),
+		},
+		Mail: MailConfig{
+			Host:       p.string("MAIL_HOST"),
+			Port:       p.int("MAIL_PORT"),
+			Username:   p.string("MAIL_USERNAME"),
+			Password:   p.values["MAIL_PASSWORD"],
+			From:       p.string("MAIL_FROM"),
+			ReturnPath: p.string("MAIL_RETURN_PATH"),
+		},
+		SMTPServer: SMTPServerConfig{
+			Port:            p.int("SMTP_SERVER_PORT"),
+			Domain:          p.string("SMTP_SERVER_DOMAIN"),
+			MaxMessageBytes: p.int("SMTP_SERVER_MAX_SIZE"),
+		},
+		Auth: AuthConfig{
+			JWTSecret: p.values["JWT_KEY"],
+			TokenTTL:  p.duration("JWT_TTL"),
+		},
+		RateLimit: RateLimitConfig{
+			Store: p.string("RATE_LIMIT_STORE"),
+		},
+		Log: LogConfig{
+			Level:  strings.ToLower(p.string("LOG_LEVEL")),
+			Format: strings.ToLower(p.string("LOG_FORMAT")),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   p.list("CORS_ALLOWED_ORIGINS"),
+			AllowCredentials: p.bool("CORS_ALLOW_CREDENTIALS"),
+			ExposedHeaders:   p.list("CORS_EXPOSED_HEADERS"),
+			MaxAge:           p.duration("CORS_MAX_AGE"),
+		},
+		Export: ExportConfig{
+			Dir:     p.string("EXPORT_DIR"),
+			LinkTTL: p.duration("EXPORT_LINK_TTL"),
+		},
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return cfg, nil
+}
+
+// Validate reports every invalid setting at once so that a misconfigured deployment fails on the first boot.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if strings.TrimSpace(c.Auth.JWTSecret) == "" {
+		problems = append(problems, "JWT_KEY must be set")
+	} else if len(c.Auth.JWTSecret) < minJWTSecretLength {
+		problems = append(problems, fmt.Sprintf("JWT_KEY must be at least %d characters", minJWTSecretLength))
+	}
+
+	if c.Auth.TokenTTL <= 0 {
+		problems = append(problems, "JWT_TTL must be positive")
+	}
+
+	if appURL, err := url.Parse(c.App.URL); err != nil || appURL.Scheme == "" || appURL.Host == "" {
+		problems = append(problems, "APP_URL must be an absolute URL")
+	}
+
+	if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
+		problems = append(problems, "HTTP_PORT must be between 1 and 65535")
+	}
+
+	if c.HTTP.RequestTimeout <= 0 {
+		problems = append(problems, "HTTP_REQUEST_TIMEOUT must be positive")
+	}
+
+	if c.App.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT must be positive")
+	}
+
+	if c.App.ShutdownDrainDelay < 0 {
+		problems = append(problems, "SHUTDOWN_DRAIN_DELAY must not be negative")
+	}
+
+	if c.Database.Host == "" || c.Database.User == "" || c.Database.Name == "" {
+		problems = append(problems, "DB_HOST, DB_USER and DB_NAME must be set")
+	}
+
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		problems = append(problems, "DB_PORT must be between 1 and 65535")
+	}
+
+	if c.Database.MaxOpenConns < 1 || c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, "DB_MAX_OPEN_CONNS must be positive and at least DB_MAX_IDLE_CONNS")
+	}
+
+	if c.Mail.Host == "" || c.Mail.From == "" {
+		problems = append(problems, "MAIL_HOST and MAIL_FROM must be set")
+	}
+
+	if c.Mail.Port < 1 || c.Mail.Port > 65535 {
+		problems = append(problems, "MAIL_PORT must be between 1 and 65535")
+	}
+
+	if c.SMTPServer.Port < 0 || c.SMTPServer.Port > 65535 {
+		problems = append(problems, "SMTP_SERVER_PORT must be between 0 and 65535")
+	}
+
+	if c.SMTPServer.Port != 0 && (c.SMTPServer.Domain == "" || c.SMTPServer.MaxMessageBytes < 1) {
+		problems = append(problems, "SMTP_SERVER_DOMAIN and a positive SMTP_SERVER_MAX_SIZE must be set to run the SMTP server")
+	}
+
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		problems = append(problems, "RATE_LIMIT_STORE must be memory or postgres")
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, "LOG_LEVEL must be debug, info, warn or error")
+	}
+
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		problems = append(problems, "LOG_FORMAT must be json or text")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				problems = append(problems, "CORS_ALLOWED_ORIGINS can not contain * when CORS_ALLOW_CREDENTIALS is true")
+			}
+			continue
+		}
+
+		parsed, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Path != "" {
+			problems = append(problems, fmt.Sprintf("CORS_ALLOWED_ORIGINS entry %q must look like https://app.example.com or https://*.example.com", origin))
+		}
+	}
+
+	if c.CORS.MaxAge < 0 {
+		problems = append(problems, "CORS_MAX_AGE must not be negative")
+	}
+
+	if c.Export.Dir == "" {
+		problems = append(problems, "EXPORT_DIR must be set")
+	}
+
+	if c.Export.LinkTTL <= 0 {
+		problems = append(problems, "EXPORT_LINK_TTL must be positive")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func validEnv() map[string]string {
+	return map[string]string{
+		"DB_USER": "postgres",
+		"DB_NAME": "email_marketing",
+		"JWT_KEY": strings.Repeat("k", minJWTSecretLength),
+	}
+}
+
+func TestLoadAppliesDefaults(t *testing.T) {
+	cfg, args, err := load(nil, envFrom(validEnv()))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if len(args) != 0 {
+		t.Fatalf("unexpected remaining args %v", args)
+	}
+
+	if cfg.HTTP.Port != 9000 || cfg.Database.Port != 5432 || cfg.Auth.TokenTTL != 24*time.Hour {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+
+	if !cfg.App.AutoMigrate || cfg.RateLimit.Store != "memory" {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+}
+
+func TestLoadRefusesEmptyJWTSecret(t *testing.T) {
+	env := validEnv()
+	delete(env, "JWT_KEY")
+
+	_, _, err := load(nil, envFrom(env))
+	if err == nil || !strings.Contains(err.Error(), "JWT_KEY must be set") {
+		t.Fatalf("expected a JWT_KEY error, got %v", err)
+	}
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	env := validEnv()
+	env["JWT_KEY"] = "short"
+	env["HTTP_PORT"] = "0"
+	env["RATE_LIMIT_STORE"] = "redis"
+
+	_, _, err := load(nil, envFrom(env))
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+
+	for _, want := range []string{"JWT_KEY", "HTTP_PORT", "RATE_LIMIT_STORE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedValues(t *testing.T) {
+	env := validEnv()
+	env["DB_PORT"] = "five"
+
+	_, _, err := load(nil, envFrom(env))
+	if err == nil || !strings.Contains(err.Error(), "DB_PORT") {
+		t.Fatalf("expected a DB_PORT error, got %v", err)
+	}
+}
+
+func TestLoadValidatesCORSOrigins(t *testing.T) {
+	env := validEnv()
+	env["CORS_ALLOWED_ORIGINS"] = "https://app.example.com, https://*.example.com"
+	env["CORS_ALLOW_CREDENTIALS"] = "true"
+
+	cfg, _, err := load(nil, envFrom(env))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 2 || cfg.CORS.AllowedOrigins[1] != "https://*.example.com" {
+		t.Fatalf("unexpected origins %q", cfg.CORS.AllowedOrigins)
+	}
+
+	for _, origins := range []string{"*", "app.example.com", "https://app.example.com/dashboard"} {
+		env["CORS_ALLOWED_ORIGINS"] = origins
+		if _, _, err := load(nil, envFrom(env)); err == nil || !strings.Contains(err.Error(), "CORS_ALLOWED_ORIGINS") {
+			t.Errorf("origins %q: expected a CORS_ALLOWED_ORIGINS error, got %v", origins, err)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.env")
+	contents := "HTTP_PORT=8000\nDB_HOST=file-host\nMAIL_FROM=file@example.com\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := validEnv()
+	env["DB_HOST"] = "env-host"
+
+	cfg, args, err := load([]string{"-config", path, "-port", "7000", "migrate", "up"}, envFrom(env))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.HTTP.Port != 7000 {
+		t.Errorf("flag should win over the file, got port %d", cfg.HTTP.Port)
+	}
+
+	if cfg.Database.Host != "env-host" {
+		t.Errorf("environment should win over the file, got host %s", cfg.Database.Host)
+	}
+
+	if cfg.Mail.From != "file@example.com" {
+		t.Errorf("file should win over defaults, got %s", cfg.Mail.From)
+	}
+
+	if strings.Join(args, " ") != "migrate up" {
+		t.Errorf("unexpected remaining args %v", args)
+	}
+}
+
+func TestLoadFailsOnMissingExplicitFile(t *testing.T) {
+	_, _, err := load([]string{"-config", filepath.Join(t.TempDir(), "missing.env")}, envFrom(validEnv()))
+	if err == nil {
+		t.Fatal("expected an error for a missing config file")
+	}
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type CampaignController struct {
+	campaignService *services.CampaignService
+}
+
+func NewCampaignController(campaignService *services.CampaignService) *CampaignController {
+	return &CampaignController{
+		campaignService: campaignService,
+	}
+}
+
+func (c *CampaignController) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.Campaign
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.AccountId = accountId
+
+	result, err := c.campaignService.CreateCampaign(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *CampaignController) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.campaignService.ListCampaigns(r.Context(), accountId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *CampaignController) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.campaignService.GetCampaign(r.Context(), &model.Campaign{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *CampaignController) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.Campaign
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.UUID = mux.Vars(r)["uuid"]
+	reqdata.AccountId = accountId
+
+	result, err := c.campaignService.UpdateCampaign(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *CampaignController) PreviewAudience(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.campaignService.PreviewAudience(r.Context(), &model.Campaign{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
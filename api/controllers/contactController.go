@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type ContactController struct {
+	contactService *services.ContactService
+}
+
+func NewContactController(contactService *services.ContactService) *ContactController {
+	return &ContactController{
+		contactService: contactService,
+	}
+}
+
+func (c *ContactController) CreateContact(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.Contact
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.AccountId = accountId
+
+	result, err := c.contactService.CreateContact(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ContactController) ListContacts(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	page := model.ContactPage{AccountId: accountId, Tags: r.URL.Query()["tag"]}
+
+	if page.Limit, err = queryInt(r, "limit"); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	if page.Offset, err = queryInt(r, "offset"); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.contactService.ListContacts(r.Context(), page)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+// ContactActivity returns the timeline of a contact. Repeat type to keep only some event types and pass the
+// next_cursor of a page as cursor to get the following one. Like every contact route, the path names the
+// contact by its uuid, since numeric ids are never exposed.
+func (c *ContactController) ContactActivity(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	query := model.ActivityQuery{
+		AccountId:   accountId,
+		ContactUUID: mux.Vars(r)["uuid"],
+		Types:       r.URL.Query()["type"],
+		Cursor:      r.URL.Query().Get("cursor"),
+	}
+
+	if query.Limit, err = queryInt(r, "limit"); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.contactService.Activity(r.Context(), query)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ContactController) GetContact(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.contactService.GetContact(r.Context(), &model.Contact{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ContactController) UpdateContact(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.UpdateContact
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.UUID = mux.Vars(r)["uuid"]
+	reqdata.AccountId = accountId
+
+	result, err := c.contactService.UpdateContact(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ContactController) DeleteContact(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	err = c.contactService.DeleteContact(r.Context(), &model.Contact{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "contact deleted successfully")
+}
+
+func (c *ContactController) CreateList(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ContactList
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.AccountId = accountId
+
+	result, err := c.contactService.CreateList(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ContactController) ListLists(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.contactService.ListLists(r.Context(), accountId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ContactController) DeleteList(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	err = c.contactService.DeleteList(r.Context(), &model.ContactList{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "list deleted successfully")
+}
+
+func (c *ContactController) AddToList(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ListMembers
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	added, err := c.contactService.AddToList(r.Context(), &model.ContactList{UUID: mux.Vars(r)["uuid"], AccountId: accountId}, &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, map[string]int{"added": added})
+}
+
+func (c *ContactController) RemoveFromList(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ListMembers
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	removed, err := c.contactService.RemoveFromList(r.Context(), &model.ContactList{UUID: mux.Vars(r)["uuid"], AccountId: accountId}, &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, map[string]int{"removed": removed})
+}
+
+func (c *ContactController) TagContacts(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ContactTags
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	added, err := c.contactService.TagContacts(r.Context(), accountId, &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, map[string]int{"added": added})
+}
+
+func (c *ContactController) UntagContacts(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ContactTags
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	removed, err := c.contactService.UntagContacts(r.Context(), accountId, &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, map[string]int{"removed": removed})
+}
+
+func (c *ContactController) ListTags(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.contactService.ListTags(r.Context(), accountId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"net/http"
+)
+
+type EventController struct {
+	eventService *services.EventService
+}
+
+func NewEventController(eventService *services.EventService) *EventController {
+	return &EventController{
+		eventService: eventService,
+	}
+}
+
+// TrackEvents records a batch of custom events and tells for each whether it was accepted, a duplicate or
+// rejected.
+func (c *EventController) TrackEvents(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.EventBatch
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.eventService.TrackEvents(r.Context(), accountId, &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"email-marketing-service/api/export"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// downloadWriteTimeout replaces the server write timeout for downloads, which can take longer than an API call.
+const downloadWriteTimeout = 30 * time.Minute
+
+type ExportController struct {
+	exportService *services.ExportService
+}
+
+func NewExportController(exportService *services.ExportService) *ExportController {
+	return &ExportController{
+		exportService: exportService,
+	}
+}
+
+// downloadHeaders prepares the response for an export file.
+func downloadHeaders(w http.ResponseWriter, d *model.Export) {
+	// not every writer supports deadlines; those keep the server write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(downloadWriteTimeout))
+
+	w.Header().Set("Content-Type", export.ContentType(d.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, d.Type, time.Now().UTC().Format("20060102-150405"), d.Format))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+// DownloadExport streams an export of up to 10000 rows in the response.
+func (c *ExportController) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ExportRequest
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	d := &model.Export{
+		AccountId:     accountId,
+		ExportRequest: reqdata,
+		IP:            utils.ClientIP(r),
+		UserAgent:     r.UserAgent(),
+	}
+
+	if err := c.exportService.PrepareDownload(r.Context(), d); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	downloadHeaders(w, d)
+	w.WriteHeader(http.StatusOK)
+
+	if err := c.exportService.WriteDownload(r.Context(), d, w); err != nil {
+		// the status has been sent, so the client is left with a truncated file
+		slog.ErrorContext(r.Context(), "export download failed", "export", d.UUID, "error", err)
+	}
+}
+
+// CreateExport queues an export to run in the background.
+func (c *ExportController) CreateExport(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ExportRequest
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.exportService.CreateExport(r.Context(), &model.Export{
+		AccountId:     accountId,
+		ExportRequest: reqdata,
+		IP:            utils.ClientIP(r),
+		UserAgent:     r.UserAgent(),
+	})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ExportController) ListExports(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.exportService.ListExports(r.Context(), accountId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *ExportController) GetExport(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.exportService.GetExport(r.Context(), &model.Export{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+// DownloadExportFile serves the file of a background export to the holder of its download link.
+func (c *ExportController) DownloadExportFile(w http.ResponseWriter, r *http.Request) {
+	d, file, err := c.exportService.OpenExportFile(r.Context(), r.URL.Query().Get("token"), utils.ClientIP(r), r.UserAgent())
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	defer file.Close()
+
+	downloadHeaders(w, d)
+	http.ServeContent(w, r, "", *d.CompletedAt, file)
+}
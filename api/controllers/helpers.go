@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var errInvalidClaims = apperrors.NewUnauthorized("invalid_token", "invalid jwt claims")
+
+// authUserId returns the id of the authenticated user from the claims set by the JWT middleware.
+func authUserId(r *http.Request) (int, error) {
+	claims, ok := r.Context().Value("jwtclaims").(jwt.MapClaims)
+	if !ok {
+		return 0, errInvalidClaims
+	}
+
+	sub, ok := claims["sub"].(float64)
+	if !ok {
+		return 0, errInvalidClaims
+	}
+
+	return int(sub), nil
+}
+
+// authAccountId returns the id of the account the request acts on, as selected by the TeamGuard
+// middleware. It is the account of the authenticated user unless the user acts as a team member.
+func authAccountId(r *http.Request) (int, error) {
+	access, err := authAccess(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return access.AccountId, nil
+}
+
+// authAccess returns the account the request acts on and the role of the authenticated user in it.
+func authAccess(r *http.Request) (*model.AccountAccess, error) {
+	access, ok := services.AccountAccessFrom(r.Context())
+	if !ok {
+		return nil, errInvalidClaims
+	}
+
+	return access, nil
+}
+
+// queryInt returns the integer query parameter key, or 0 if it is not set.
+func queryInt(r *http.Request, key string) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, apperrors.New(apperrors.BadRequest, "invalid_query_parameter", fmt.Sprintf("%s must be a whole number", key))
+	}
+
+	return n, nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"email-marketing-service/api/apperrors"
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+// hostedPageBase holds what the pages served to contacts, such as signup forms and the preference center,
+// have in common. Pages are built with newHostedPage.
+var hostedPageBase = template.Must(template.New("base").Parse(`
+{{- define "style" -}}
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <style>
+        body { font-family: Arial, sans-serif; max-width: 28rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+        label { display: block; margin-top: 1rem; }
+        input[type=text], input[type=email], input[type=number], input[type=date], select { width: 100%; padding: .5rem; box-sizing: border-box; }
+        fieldset { margin-top: 1.5rem; border: 1px solid #ddd; }
+        button { margin-top: 1.5rem; padding: .6rem 1.2rem; }
+        .error { color: #b00020; }
+        .hint { color: #666; font-size: .9rem; }
+        .hp { position: absolute; left: -10000px; }
+    </style>
+{{- end -}}
+{{- define "errors" -}}
+    {{range .}}<p class="error">{{.}}</p>{{end}}
+{{- end -}}
+`))
+
+func newHostedPage(name string, text string) *template.Template {
+	return template.Must(template.Must(hostedPageBase.Clone()).New(name).Parse(text))
+}
+
+// renderHostedPage writes a page served to contacts. Scripts and external resources are blocked; the page
+// may be framed since signup forms are meant to be embedded.
+func renderHostedPage(w http.ResponseWriter, r *http.Request, page *template.Template, status int, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	if err := page.Execute(w, data); err != nil {
+		slog.ErrorContext(r.Context(), "rendering page failed", "page", page.Name(), "error", err)
+	}
+}
+
+// pageErrors returns the status and the messages to show on a hosted page for err.
+func pageErrors(r *http.Request, err error) (int, []string) {
+	appErr := apperrors.From(err)
+
+	if appErr.Kind == apperrors.Internal {
+		slog.ErrorContext(r.Context(), "request failed", "error", err)
+	}
+
+	messages := []string{appErr.Message}
+	for _, field := range appErr.Fields {
+		messages = append(messages, field.Message)
+	}
+
+	return appErr.Kind.Status(), messages
+}
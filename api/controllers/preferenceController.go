@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var errInvalidSnooze = apperrors.NewValidation("validation_failed", "one or more fields are invalid", apperrors.FieldError{
+	Field:   "snooze_days",
+	Rule:    "number",
+	Message: "snooze_days must be a whole number",
+})
+
+type PreferenceController struct {
+	preferenceService *services.PreferenceService
+}
+
+func NewPreferenceController(preferenceService *services.PreferenceService) *PreferenceController {
+	return &PreferenceController{
+		preferenceService: preferenceService,
+	}
+}
+
+// PreferenceLink returns a preference center link for a contact of the account.
+func (c *PreferenceController) PreferenceLink(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	contact := &model.Contact{
+		UUID:      mux.Vars(r)["uuid"],
+		AccountId: accountId,
+	}
+
+	result, err := c.preferenceService.PreferenceLink(r.Context(), contact)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+// PreferenceCenter serves the preference center page, or the preferences as JSON to clients that ask for it.
+func (c *PreferenceController) PreferenceCenter(w http.ResponseWriter, r *http.Request) {
+	preferences, err := c.preferenceService.GetPreferences(r.Context(), r.URL.Query().Get("token"))
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		if err != nil {
+			response.ErrorResponse(w, r, err)
+			return
+		}
+
+		response.SuccessResponse(w, 200, preferences)
+		return
+	}
+
+	if err != nil {
+		renderPreferencesError(w, r, preferenceView{}, err)
+		return
+	}
+
+	renderHostedPage(w, r, preferencePage, http.StatusOK, preferenceView{Preferences: preferences})
+}
+
+// UpdatePreferences accepts the preference center page posted as form data, which is answered with the page,
+// and preferences sent as JSON, which are answered with JSON.
+func (c *PreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		c.submitPreferencePage(w, r)
+		return
+	}
+
+	var reqdata model.UpdatePreferences
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.Token = r.URL.Query().Get("token")
+
+	result, err := c.preferenceService.UpdatePreferences(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *PreferenceController) submitPreferencePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	// show the error above the stored preferences, as the page can not be filled in again from a failed post
+	fail := func(err error) {
+		preferences, _ := c.preferenceService.GetPreferences(r.Context(), token)
+		renderPreferencesError(w, r, preferenceView{Preferences: preferences}, err)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormPostSize)
+
+	if err := r.ParseForm(); err != nil {
+		fail(errInvalidFormPost.Wrap(err))
+		return
+	}
+
+	firstName := r.PostForm.Get("firstname")
+	lastName := r.PostForm.Get("lastname")
+
+	// unchecked boxes are not posted, so the page always sends the complete choice of topics and lists
+	reqdata := &model.UpdatePreferences{
+		Token:       token,
+		FirstName:   &firstName,
+		LastName:    &lastName,
+		Topics:      append([]string{}, r.PostForm["topic"]...),
+		Lists:       append([]string{}, r.PostForm["list"]...),
+		Unsubscribe: r.PostForm.Get("unsubscribe") != "",
+	}
+
+	if value := r.PostForm.Get("snooze_days"); value != "" {
+		days, err := strconv.Atoi(value)
+
+		if err != nil {
+			fail(errInvalidSnooze)
+			return
+		}
+
+		reqdata.SnoozeDays = &days
+	}
+
+	result, err := c.preferenceService.UpdatePreferences(r.Context(), reqdata)
+
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	renderHostedPage(w, r, preferencePage, http.StatusOK, preferenceView{Preferences: result, Saved: true})
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"net/http"
+)
+
+// preferencePage renders the preference center. It posts back to its own URL, which carries the token.
+var preferencePage = newHostedPage("preferences", `<!DOCTYPE html>
+<html lang="en">
+<head>
+    {{template "style"}}
+    <title>Email preferences</title>
+</head>
+<body>
+    <h1>Email preferences</h1>
+    {{template "errors" .Errors}}
+{{- with .Preferences}}
+    <p class="hint">For {{.Email}}</p>
+    {{if $.Saved}}<p>Your preferences have been saved.</p>{{end}}
+    {{if eq .Status "unsubscribed"}}<p>You are unsubscribed and do not receive any emails.</p>{{end}}
+    <form method="post">
+        <label>First name
+            <input type="text" name="firstname" value="{{.FirstName}}" maxlength="100">
+        </label>
+        <label>Last name
+            <input type="text" name="lastname" value="{{.LastName}}" maxlength="100">
+        </label>
+        {{- if .Topics}}
+        <fieldset>
+            <legend>Topics you receive</legend>
+            {{- range .Topics}}
+            <label><input type="checkbox" name="topic" value="{{.UUID}}" {{if .Subscribed}}checked{{end}}> {{.Name}}</label>
+            {{with .Description}}<span class="hint">{{.}}</span>{{end}}
+            {{- end}}
+        </fieldset>
+        {{- end}}
+        {{- if .Lists}}
+        <fieldset>
+            <legend>Lists you are on</legend>
+            {{- range .Lists}}
+            <label><input type="checkbox" name="list" value="{{.UUID}}" checked> {{.Name}}</label>
+            {{- end}}
+        </fieldset>
+        {{- end}}
+        {{- if ne .Status "unsubscribed"}}
+        <label>Pause emails
+            <select name="snooze_days">
+                <option value="">{{if .SnoozedUntil}}Stay paused until {{.SnoozedUntil.Format "Jan 2, 2006"}}{{else}}Do not pause{{end}}</option>
+                {{if .SnoozedUntil}}<option value="0">Resume now</option>{{end}}
+                <option value="7">For 7 days</option>
+                <option value="30">For 30 days</option>
+                <option value="90">For 90 days</option>
+            </select>
+        </label>
+        <label><input type="checkbox" name="unsubscribe"> Unsubscribe from all emails</label>
+        {{- end}}
+        <button type="submit">Save preferences</button>
+    </form>
+{{- end}}
+</body>
+</html>
+`)
+
+type preferenceView struct {
+	Preferences *model.Preferences
+	Errors      []string
+	Saved       bool
+}
+
+func renderPreferencesError(w http.ResponseWriter, r *http.Request, view preferenceView, err error) {
+	var status int
+	status, view.Errors = pageErrors(r, err)
+
+	renderHostedPage(w, r, preferencePage, status, view)
+}
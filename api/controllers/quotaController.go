@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"email-marketing-service/api/services"
+	"net/http"
+)
+
+type QuotaController struct {
+	quotaService *services.QuotaService
+}
+
+func NewQuotaController(quotaService *services.QuotaService) *QuotaController {
+	return &QuotaController{
+		quotaService: quotaService,
+	}
+}
+
+func (c *QuotaController) AccountQuota(w http.ResponseWriter, r *http.Request) {
+	userId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.quotaService.AccountQuota(r.Context(), userId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
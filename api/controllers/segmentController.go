@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type SegmentController struct {
+	segmentService *services.SegmentService
+}
+
+func NewSegmentController(segmentService *services.SegmentService) *SegmentController {
+	return &SegmentController{
+		segmentService: segmentService,
+	}
+}
+
+func (c *SegmentController) CreateSegment(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.Segment
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.AccountId = accountId
+
+	result, err := c.segmentService.CreateSegment(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SegmentController) ListSegments(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.segmentService.ListSegments(r.Context(), accountId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SegmentController) GetSegment(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.segmentService.GetSegment(r.Context(), &model.Segment{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SegmentController) UpdateSegment(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.Segment
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.UUID = mux.Vars(r)["uuid"]
+	reqdata.AccountId = accountId
+
+	result, err := c.segmentService.UpdateSegment(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SegmentController) DeleteSegment(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	err = c.segmentService.DeleteSegment(r.Context(), &model.Segment{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "segment deleted successfully")
+}
+
+// PreviewRules previews rules that have not been saved, so a segment can be tried out while it is edited.
+func (c *SegmentController) PreviewRules(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.SegmentRules
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	if err := segment.Validate(reqdata.Rules); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.segmentService.Preview(r.Context(), accountId, reqdata.Rules)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SegmentController) PreviewSegment(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.segmentService.PreviewSegment(r.Context(), &model.Segment{UUID: mux.Vars(r)["uuid"], AccountId: accountId})
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
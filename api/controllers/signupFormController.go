@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// maxFormPostSize is the largest form-encoded body accepted from the hosted page.
+const maxFormPostSize = 64 << 10
+
+var errInvalidFormPost = apperrors.New(apperrors.BadRequest, "invalid_form_body", "the form could not be read")
+
+type SignupFormController struct {
+	signupFormService *services.SignupFormService
+}
+
+func NewSignupFormController(signupFormService *services.SignupFormService) *SignupFormController {
+	return &SignupFormController{
+		signupFormService: signupFormService,
+	}
+}
+
+func (c *SignupFormController) CreateSignupForm(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.SignupForm
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.AccountId = accountId
+
+	result, err := c.signupFormService.CreateSignupForm(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SignupFormController) ListSignupForms(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.signupFormService.ListSignupForms(r.Context(), accountId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SignupFormController) GetSignupForm(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	form := &model.SignupForm{
+		UUID:      mux.Vars(r)["uuid"],
+		AccountId: accountId,
+	}
+
+	result, err := c.signupFormService.GetSignupForm(r.Context(), form)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SignupFormController) UpdateSignupForm(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.SignupForm
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.UUID = mux.Vars(r)["uuid"]
+	reqdata.AccountId = accountId
+
+	result, err := c.signupFormService.UpdateSignupForm(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SignupFormController) DeleteSignupForm(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	form := &model.SignupForm{
+		UUID:      mux.Vars(r)["uuid"],
+		AccountId: accountId,
+	}
+
+	err = c.signupFormService.DeleteSignupForm(r.Context(), form)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "signup form deleted successfully")
+}
+
+// HostedSignupForm serves the public HTML page of a form.
+func (c *SignupFormController) HostedSignupForm(w http.ResponseWriter, r *http.Request) {
+	form, err := c.signupFormService.GetPublicSignupForm(r.Context(), mux.Vars(r)["public_id"])
+
+	if err != nil {
+		renderSignupFormError(w, r, signupFormView{}, err)
+		return
+	}
+
+	renderHostedPage(w, r, signupFormPage, http.StatusOK, signupFormView{Form: form})
+}
+
+// SubmitSignupForm accepts submissions posted by the hosted page as form data, which are answered with HTML,
+// and submissions sent as JSON by scripts, which are answered with JSON.
+func (c *SignupFormController) SubmitSignupForm(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		c.submitHostedForm(w, r)
+		return
+	}
+
+	var reqdata model.FormSubmission
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.FormPublicId = mux.Vars(r)["public_id"]
+	reqdata.IP = utils.ClientIP(r)
+	reqdata.UserAgent = r.UserAgent()
+
+	result, err := c.signupFormService.Submit(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *SignupFormController) submitHostedForm(w http.ResponseWriter, r *http.Request) {
+	publicId := mux.Vars(r)["public_id"]
+
+	form, err := c.signupFormService.GetPublicSignupForm(r.Context(), publicId)
+
+	if err != nil {
+		renderSignupFormError(w, r, signupFormView{}, err)
+		return
+	}
+
+	view := signupFormView{Form: form, Values: map[string]string{}}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormPostSize)
+
+	if err := r.ParseForm(); err != nil {
+		renderSignupFormError(w, r, view, errInvalidFormPost.Wrap(err))
+		return
+	}
+
+	submission := &model.FormSubmission{
+		FormPublicId: publicId,
+		Email:        r.PostForm.Get("email"),
+		Fields:       map[string]any{},
+		Honeypot:     r.PostForm.Get("_hp"),
+		IP:           utils.ClientIP(r),
+		UserAgent:    r.UserAgent(),
+	}
+
+	for key := range r.PostForm {
+		view.Values[key] = r.PostForm.Get(key)
+
+		if key != "email" && key != "_hp" {
+			submission.Fields[key] = r.PostForm.Get(key)
+		}
+	}
+
+	result, err := c.signupFormService.Submit(r.Context(), submission)
+
+	if err != nil {
+		renderSignupFormError(w, r, view, err)
+		return
+	}
+
+	if result.RedirectURL != "" {
+		http.Redirect(w, r, result.RedirectURL, http.StatusSeeOther)
+		return
+	}
+
+	renderHostedPage(w, r, signupFormPage, http.StatusOK, signupFormView{Form: form, Message: result.Message})
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"net/http"
+)
+
+// signupFormPage renders a hosted signup form. The _hp input is the honeypot: it is hidden from people,
+// so only bots fill it in.
+var signupFormPage = newHostedPage("signup-form", `<!DOCTYPE html>
+<html lang="en">
+<head>
+    {{template "style"}}
+    <title>{{if .Form}}{{or .Form.Title .Form.Name}}{{else}}Signup form{{end}}</title>
+</head>
+<body>
+{{- if .Message}}
+    <p>{{.Message}}</p>
+{{- else if .Form}}
+    {{with .Form.Title}}<h1>{{.}}</h1>{{end}}
+    {{template "errors" .Errors}}
+    <form method="post">
+        <label>Email
+            <input type="email" name="email" value="{{index $.Values "email"}}" required>
+        </label>
+        {{- range .Form.Fields}}
+        {{- if eq .Type "checkbox"}}
+        <label><input type="checkbox" name="{{.Attribute}}" {{if index $.Values .Attribute}}checked{{end}} {{if .Required}}required{{end}}> {{.Label}}</label>
+        {{- else}}
+        <label>{{.Label}}
+            <input type="{{.Type}}" name="{{.Attribute}}" value="{{index $.Values .Attribute}}" {{if .Required}}required{{end}}>
+        </label>
+        {{- end}}
+        {{- end}}
+        <div class="hp" aria-hidden="true"><input type="text" name="_hp" tabindex="-1" autocomplete="off"></div>
+        <button type="submit">Subscribe</button>
+    </form>
+{{- else}}
+    {{template "errors" .Errors}}
+{{- end}}
+</body>
+</html>
+`)
+
+type signupFormView struct {
+	Form    *model.SignupForm
+	Values  map[string]string
+	Errors  []string
+	Message string
+}
+
+// renderSignupFormError shows err on the hosted page above the form, if there is one.
+func renderSignupFormError(w http.ResponseWriter, r *http.Request, view signupFormView, err error) {
+	var status int
+	status, view.Errors = pageErrors(r, err)
+
+	renderHostedPage(w, r, signupFormPage, status, view)
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type SubscriptionController struct {
+	subscriptionService *services.SubscriptionService
+}
+
+func NewSubscriptionController(subscriptionService *services.SubscriptionService) *SubscriptionController {
+	return &SubscriptionController{
+		subscriptionService: subscriptionService,
+	}
+}
+
+func (c *SubscriptionController) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var reqdata model.Subscribe
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.ListPublicId = mux.Vars(r)["public_id"]
+	reqdata.IP = utils.ClientIP(r)
+	reqdata.UserAgent = r.UserAgent()
+
+	err := c.subscriptionService.Subscribe(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "please check your inbox to confirm your subscription")
+}
+
+func (c *SubscriptionController) ConfirmSubscription(w http.ResponseWriter, r *http.Request) {
+	var reqdata model.ConfirmSubscription
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.IP = utils.ClientIP(r)
+
+	err := c.subscriptionService.ConfirmSubscription(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "subscription confirmed")
+}
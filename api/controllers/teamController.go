@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type TeamController struct {
+	teamService *services.TeamService
+}
+
+func NewTeamController(teamService *services.TeamService) *TeamController {
+	return &TeamController{
+		teamService: teamService,
+	}
+}
+
+func (c *TeamController) InviteMember(w http.ResponseWriter, r *http.Request) {
+	access, err := authAccess(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.Invitation
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.AccountId = access.AccountId
+	reqdata.InvitedBy = access.UserId
+
+	result, err := c.teamService.InviteMember(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *TeamController) ListPendingInvitations(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.teamService.ListPendingInvitations(r.Context(), accountId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *TeamController) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	accountId, err := authAccountId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	invitation := &model.Invitation{
+		UUID:      mux.Vars(r)["uuid"],
+		AccountId: accountId,
+	}
+
+	err = c.teamService.RevokeInvitation(r.Context(), invitation)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "invitation revoked successfully")
+}
+
+func (c *TeamController) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var reqdata model.AcceptInvitation
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.teamService.AcceptInvitation(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"email-marketing-service/api/services"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// openPixel is the transparent 1x1 GIF that marketing mail loads to report that it was opened.
+var openPixel, _ = base64.StdEncoding.DecodeString("R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7")
+
+type TrackingController struct {
+	feedbackService *services.FeedbackService
+}
+
+func NewTrackingController(feedbackService *services.FeedbackService) *TrackingController {
+	return &TrackingController{
+		feedbackService: feedbackService,
+	}
+}
+
+// TrackOpen records the open of a message and answers with the tracking image. The image is served even when
+// the open cannot be recorded, so that mail clients never show a broken image.
+func (c *TrackingController) TrackOpen(w http.ResponseWriter, r *http.Request) {
+	if err := c.feedbackService.RecordOpen(r.Context(), mux.Vars(r)["message_id"]); err != nil {
+		slog.ErrorContext(r.Context(), "failed to record an open", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store, max-age=0")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openPixel)
+}
@@ -1,21 +1,83 @@
 package controllers
 
 import (
+	"email-marketing-service/api/apperrors"
 	"email-marketing-service/api/model"
 	"email-marketing-service/api/services"
 	"email-marketing-service/api/utils"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt"
+	"log/slog"
 	"net/http"
 )
 
 type UserController struct {
-	userService *services.UserService
+	userService        *services.UserService
+	authAttemptService *services.AuthAttemptService
 }
 
-func NewUserController(userService *services.UserService) *UserController {
+func NewUserController(userService *services.UserService, authAttemptService *services.AuthAttemptService) *UserController {
 	return &UserController{
-		userService: userService,
+		userService:        userService,
+		authAttemptService: authAttemptService,
+	}
+}
+
+type attemptKey struct {
+	scope string
+	key   string
+}
+
+// userKeys returns the attempt keys for an authenticated request that checks the user's password or an OTP, so
+// a stolen session can not be used to guess them faster than Login allows.
+func (c *UserController) userKeys(r *http.Request, userId int) ([]attemptKey, error) {
+	profile, err := c.userService.GetProfile(r.Context(), userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []attemptKey{
+		{services.AttemptScopeIP, utils.ClientIP(r)},
+		{services.AttemptScopeAccount, profile.Email},
+	}, nil
+}
+
+// throttled responds with 429 and returns true if any of the keys is currently delayed or locked out.
+func (c *UserController) throttled(w http.ResponseWriter, r *http.Request, keys []attemptKey) bool {
+	for _, k := range keys {
+		err := c.authAttemptService.Check(r.Context(), k.scope, k.key)
+
+		var tooMany *services.TooManyAttemptsError
+		if errors.As(err, &tooMany) {
+			response.ErrorResponse(w, r, apperrors.NewRateLimited("too_many_attempts", tooMany.Error(), tooMany.RetryAfter))
+			return true
+		}
+
+		if err != nil {
+			response.ErrorResponse(w, r, err)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *UserController) recordFailure(r *http.Request, keys []attemptKey, err error) {
+	if !services.IsCredentialFailure(err) {
+		return
+	}
+
+	c.recordAttempt(r, keys)
+}
+
+// recordAttempt counts a request against the keys whatever its outcome, for requests that send mail.
+func (c *UserController) recordAttempt(r *http.Request, keys []attemptKey) {
+	for _, k := range keys {
+		if err := c.authAttemptService.RecordFailure(r.Context(), k.scope, k.key); err != nil {
+			slog.ErrorContext(r.Context(), "failed to record auth attempt", "scope", k.scope, "error", err)
+		}
 	}
 }
 
@@ -40,13 +102,16 @@ func (c *UserController) Welcome(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *UserController) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	var reqdata *model.User
+	var reqdata model.User
 
-	utils.DecodeRequestBody(r, &reqdata)
-	userCreateService, err := c.userService.CreateUser(reqdata)
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+	userCreateService, err := c.userService.CreateUser(r.Context(), &reqdata)
 
 	if err != nil {
-		response.ErrorResponse(w, err.Error())
+		response.ErrorResponse(w, r, err)
 		return
 	}
 
@@ -55,60 +120,356 @@ func (c *UserController) RegisterUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *UserController) VerifyUser(w http.ResponseWriter, r *http.Request) {
-	var reqdata *model.OTP
+	var reqdata model.VerifyUser
 
-	utils.DecodeRequestBody(r, &reqdata)
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
 
-	err := c.userService.VerifyUser(reqdata)
+	keys := []attemptKey{
+		{services.AttemptScopeIP, utils.ClientIP(r)},
+		{services.AttemptScopeAccount, reqdata.Email},
+	}
+	if c.throttled(w, r, keys) {
+		return
+	}
+
+	err := c.userService.VerifyUser(r.Context(), &reqdata)
 
 	if err != nil {
-		response.ErrorResponse(w, err.Error())
+		c.recordFailure(r, keys, err)
+		response.ErrorResponse(w, r, err)
 		return
 	}
 	response.SuccessResponse(w, 200, "User has been successfully verifed")
 }
 
 func (c *UserController) Login(w http.ResponseWriter, r *http.Request) {
-	var reqdata *model.LoginModel
+	var reqdata model.LoginModel
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
 
-	utils.DecodeRequestBody(r, &reqdata)
+	keys := []attemptKey{
+		{services.AttemptScopeIP, utils.ClientIP(r)},
+		{services.AttemptScopeAccount, reqdata.Email},
+	}
+	if c.throttled(w, r, keys) {
+		return
+	}
 
-	result, err := c.userService.Login(reqdata)
+	result, err := c.userService.Login(r.Context(), &reqdata)
 
 	if err != nil {
-		response.ErrorResponse(w, err.Error())
+		c.recordFailure(r, keys, err)
+		response.ErrorResponse(w, r, err)
 		return
 	}
 
+	// only the account counter is cleared, so logging into one account can not reset the limit for the address
+	if err := c.authAttemptService.RecordSuccess(r.Context(), services.AttemptScopeAccount, reqdata.Email); err != nil {
+		slog.ErrorContext(r.Context(), "failed to reset auth attempts", "error", err)
+	}
+
 	response.SuccessResponse(w, 200, result)
 }
 
 func (c *UserController) ForgetPassword(w http.ResponseWriter, r *http.Request) {
-	var reqdata *model.ForgetPassword
+	var reqdata model.ForgetPassword
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
 
-	utils.DecodeRequestBody(r, &reqdata)
+	keys := []attemptKey{
+		{services.AttemptScopeIP, utils.ClientIP(r)},
+		{services.AttemptScopeMail, reqdata.Email},
+	}
+	if c.throttled(w, r, keys) {
+		return
+	}
 
-	err := c.userService.ForgetPassword(reqdata)
+	err := c.userService.ForgetPassword(r.Context(), &reqdata)
 
 	if err != nil {
-		response.ErrorResponse(w, err.Error())
+		response.ErrorResponse(w, r, err)
 		return
 	}
 
+	// counted whether or not an account exists for the email, so that the responses do not tell
+	c.recordAttempt(r, keys)
+
 	response.SuccessResponse(w, 200, "email sent successfully")
 }
 
 func (c *UserController) ResetPassword(w http.ResponseWriter, r *http.Request) {
-	var reqdata *model.ResetPassword
+	var reqdata model.ResetPassword
 
-	utils.DecodeRequestBody(r, &reqdata)
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	keys := []attemptKey{
+		{services.AttemptScopeIP, utils.ClientIP(r)},
+		{services.AttemptScopeAccount, reqdata.Email},
+	}
+	if c.throttled(w, r, keys) {
+		return
+	}
 
-	err := c.userService.ResetPassword(reqdata)
+	err := c.userService.ResetPassword(r.Context(), &reqdata)
 
 	if err != nil {
-		response.ErrorResponse(w, err.Error())
+		c.recordFailure(r, keys, err)
+		response.ErrorResponse(w, r, err)
 		return
 	}
 
 	response.SuccessResponse(w, 200, "password reset successfully")
 }
+
+// LockoutStatus reports whether the caller's address and the account of the email query parameter are
+// currently throttled, so that the login page can tell when to try again. It reveals no more than a failed
+// login, which answers 429 for a throttled account as well.
+func (c *UserController) LockoutStatus(w http.ResponseWriter, r *http.Request) {
+	ipStatus, err := c.authAttemptService.LockoutStatus(r.Context(), services.AttemptScopeIP, utils.ClientIP(r))
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	accountStatus, err := c.authAttemptService.LockoutStatus(r.Context(), services.AttemptScopeAccount, r.URL.Query().Get("email"))
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, map[string]*model.LockoutStatus{
+		"ip":      ipStatus,
+		"account": accountStatus,
+	})
+}
+
+func (c *UserController) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userId, err := authUserId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	result, err := c.userService.GetProfile(r.Context(), userId)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *UserController) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userId, err := authUserId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.UpdateProfile
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.ID = userId
+
+	result, err := c.userService.UpdateProfile(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *UserController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userId, err := authUserId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ChangePassword
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.ID = userId
+
+	keys, err := c.userKeys(r, userId)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	if c.throttled(w, r, keys) {
+		return
+	}
+
+	err = c.userService.ChangePassword(r.Context(), &reqdata)
+
+	if err != nil {
+		c.recordFailure(r, keys, err)
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "password changed successfully")
+}
+
+func (c *UserController) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userId, err := authUserId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.ChangeEmail
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.ID = userId
+
+	keys := []attemptKey{
+		{services.AttemptScopeIP, utils.ClientIP(r)},
+		{services.AttemptScopeMail, reqdata.Email},
+	}
+	if c.throttled(w, r, keys) {
+		return
+	}
+
+	err = c.userService.ChangeEmail(r.Context(), &reqdata)
+
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	c.recordAttempt(r, keys)
+
+	response.SuccessResponse(w, 200, "verification email sent successfully")
+}
+
+func (c *UserController) VerifyEmailChange(w http.ResponseWriter, r *http.Request) {
+	userId, err := authUserId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.VerifyEmailChange
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.ID = userId
+
+	keys, err := c.userKeys(r, userId)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	if c.throttled(w, r, keys) {
+		return
+	}
+
+	result, err := c.userService.VerifyEmailChange(r.Context(), &reqdata)
+
+	if err != nil {
+		c.recordFailure(r, keys, err)
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, result)
+}
+
+func (c *UserController) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userId, err := authUserId(r)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	var reqdata model.DeleteAccount
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	reqdata.ID = userId
+
+	keys, err := c.userKeys(r, userId)
+	if err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	if c.throttled(w, r, keys) {
+		return
+	}
+
+	err = c.userService.DeleteAccount(r.Context(), &reqdata)
+
+	if err != nil {
+		c.recordFailure(r, keys, err)
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "account deleted successfully")
+}
+
+func (c *UserController) CancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	var reqdata model.CancelAccountDeletion
+
+	if err := utils.DecodeAndValidate(w, r, &reqdata); err != nil {
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	keys := []attemptKey{
+		{services.AttemptScopeIP, utils.ClientIP(r)},
+		{services.AttemptScopeAccount, reqdata.Email},
+	}
+	if c.throttled(w, r, keys) {
+		return
+	}
+
+	err := c.userService.CancelAccountDeletion(r.Context(), &reqdata)
+
+	if err != nil {
+		c.recordFailure(r, keys, err)
+		response.ErrorResponse(w, r, err)
+		return
+	}
+
+	response.SuccessResponse(w, 200, "account restored successfully")
+}
@@ -1,11 +1,47 @@
 package custom
 
 import (
+	"context"
+	"email-marketing-service/api/config"
 	"email-marketing-service/api/utils"
+	"fmt"
+	"html"
+	"net/mail"
+	"net/url"
 	"strings"
+	"time"
 )
 
-func SignUpMail(email string, username string, otp string) error {
+// Mailer sends the transactional emails of the application.
+type Mailer interface {
+	SignUpMail(ctx context.Context, email string, username string, otp string) error
+	ResetPasswordMail(ctx context.Context, email string, username string, otp string) error
+	InvitationMail(ctx context.Context, email string, inviterName string, role string, link string) error
+	AccountLockedMail(ctx context.Context, email string, username string, lockedUntil time.Time) error
+	ChangeEmailMail(ctx context.Context, email string, username string, otp string) error
+	AccountDeletionMail(ctx context.Context, email string, username string, otp string, purgeAfter time.Time) error
+	SubscriptionConfirmationMail(ctx context.Context, email string, listName string, link string) error
+	MarketingMail(ctx context.Context, email string, subject string, body string, preferencesLink string, messageId string, tier string) error
+}
+
+// SMTPMailer renders the mail templates and delivers them through utils.SendMail.
+type SMTPMailer struct {
+	cfg     config.MailConfig
+	appName string
+	// appURL is the public base URL of the application, which the open tracking pixel of marketing mail is
+	// loaded from.
+	appURL string
+}
+
+func NewSMTPMailer(cfg config.MailConfig, appName string, appURL string) *SMTPMailer {
+	return &SMTPMailer{
+		cfg:     cfg,
+		appName: appName,
+		appURL:  appURL,
+	}
+}
+
+func (m *SMTPMailer) SignUpMail(ctx context.Context, email string, username string, otp string) error {
 	mailTemplate := `
 	<html>
 	<body style="font-family: Arial, sans-serif;">
@@ -24,7 +60,7 @@ func SignUpMail(email string, username string, otp string) error {
 	replacements := map[string]string{
 		".Username": username,
 		".Token":    otp,
-		".AppName":  "Appname",
+		".AppName":  m.appName,
 	}
 
 	formattedMail := mailTemplate
@@ -33,7 +69,7 @@ func SignUpMail(email string, username string, otp string) error {
 		formattedMail = strings.Replace(formattedMail, placeholder, value, -1)
 	}
 
-	err := utils.SendMail("Email Verification", email, formattedMail)
+	err := utils.SendMail(ctx, m.cfg, "Email Verification", email, formattedMail)
 
 	if err != nil {
 		return err
@@ -43,7 +79,7 @@ func SignUpMail(email string, username string, otp string) error {
 
 }
 
-func ResetPasswordMail(email string, username string, otp string) error {
+func (m *SMTPMailer) ResetPasswordMail(ctx context.Context, email string, username string, otp string) error {
 
 	mailTemplate :=
 		`<html>
@@ -54,14 +90,191 @@ func ResetPasswordMail(email string, username string, otp string) error {
         <p>Please note that this OTP can only be used once and is valid for a limited time.</p>
         <p>If you did not attempt to reset your password, please ignore this email.</p>
         <br>
-        <p>Regards,<br>  .Appname </p>
+        <p>Regards,<br>  .AppName </p>
+    </body>
+</html>
+`
+	replacements := map[string]string{
+		".Username": username,
+		".Token":    otp,
+		".AppName":  m.appName,
+	}
+
+	formattedMail := mailTemplate
+
+	for placeholder, value := range replacements {
+		formattedMail = strings.Replace(formattedMail, placeholder, value, -1)
+	}
+
+	err := utils.SendMail(ctx, m.cfg, "Password Reset", email, formattedMail)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SMTPMailer) InvitationMail(ctx context.Context, email string, inviterName string, role string, link string) error {
+
+	mailTemplate :=
+		`<html>
+    <body style="font-family: Arial, sans-serif;">
+        <h2>Hi there,</h2>
+        <p>.Inviter has invited you to join their team as .Role .</p>
+        <p>Click the link below to accept the invitation:</p>
+        <p><a href=".Link">Accept invitation</a></p>
+        <p>Please note that this invitation expires after a limited time.</p>
+        <p>If you were not expecting this invitation, please ignore this email.</p>
+        <br>
+        <p>Regards,<br> .AppName </p>
+    </body>
+</html>
+`
+	replacements := map[string]string{
+		".Inviter": inviterName,
+		".Role":    role,
+		".Link":    link,
+		".AppName": m.appName,
+	}
+
+	formattedMail := mailTemplate
+
+	for placeholder, value := range replacements {
+		formattedMail = strings.Replace(formattedMail, placeholder, value, -1)
+	}
+
+	err := utils.SendMail(ctx, m.cfg, "Team Invitation", email, formattedMail)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SMTPMailer) AccountLockedMail(ctx context.Context, email string, username string, lockedUntil time.Time) error {
+
+	mailTemplate :=
+		`<html>
+    <body style="font-family: Arial, sans-serif;">
+        <h2>Hi .Username ,</h2>
+        <p>We noticed several failed attempts to sign in to your account, so it has been temporarily locked.</p>
+        <p>You can sign in again after .LockedUntil .</p>
+        <p>If these attempts were not made by you, we recommend resetting your password once the lock expires.</p>
+        <br>
+        <p>Regards,<br> .AppName </p>
+    </body>
+</html>
+`
+	replacements := map[string]string{
+		".Username":    username,
+		".LockedUntil": lockedUntil.UTC().Format("Jan 2, 2006 15:04 MST"),
+		".AppName":     m.appName,
+	}
+
+	formattedMail := mailTemplate
+
+	for placeholder, value := range replacements {
+		formattedMail = strings.Replace(formattedMail, placeholder, value, -1)
+	}
+
+	err := utils.SendMail(ctx, m.cfg, "Account Temporarily Locked", email, formattedMail)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SMTPMailer) ChangeEmailMail(ctx context.Context, email string, username string, otp string) error {
+
+	mailTemplate :=
+		`<html>
+    <body style="font-family: Arial, sans-serif;">
+        <h2>Hi .Username ,</h2>
+        <p>Please use the following One-Time Password (OTP) to confirm this as the new email address of your account:</p>
+        <h3>OTP:  .Token </h3>
+        <p>Please note that this OTP can only be used once and is valid for a limited time.</p>
+        <p>If you did not request this change, please ignore this email.</p>
+        <br>
+        <p>Regards,<br> .AppName </p>
     </body>
 </html>
 `
 	replacements := map[string]string{
 		".Username": username,
 		".Token":    otp,
-		".AppName":  "Appname",
+		".AppName":  m.appName,
+	}
+
+	formattedMail := mailTemplate
+
+	for placeholder, value := range replacements {
+		formattedMail = strings.Replace(formattedMail, placeholder, value, -1)
+	}
+
+	err := utils.SendMail(ctx, m.cfg, "Confirm Email Change", email, formattedMail)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SMTPMailer) AccountDeletionMail(ctx context.Context, email string, username string, otp string, purgeAfter time.Time) error {
+
+	mailTemplate :=
+		`<html>
+    <body style="font-family: Arial, sans-serif;">
+        <h2>Hi .Username ,</h2>
+        <p>Your account has been deleted and you have been signed out everywhere.</p>
+        <p>Your personal data will be permanently erased on .PurgeAfter . Until then you can restore your account with the following One-Time Password (OTP):</p>
+        <h3>OTP:  .Token </h3>
+        <p>If you did not delete your account, please restore it and reset your password immediately.</p>
+        <br>
+        <p>Regards,<br> .AppName </p>
+    </body>
+</html>
+`
+	replacements := map[string]string{
+		".Username":   username,
+		".PurgeAfter": purgeAfter.UTC().Format("Jan 2, 2006"),
+		".Token":      otp,
+		".AppName":    m.appName,
+	}
+
+	formattedMail := mailTemplate
+
+	for placeholder, value := range replacements {
+		formattedMail = strings.Replace(formattedMail, placeholder, value, -1)
+	}
+
+	err := utils.SendMail(ctx, m.cfg, "Account Deleted", email, formattedMail)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SMTPMailer) SubscriptionConfirmationMail(ctx context.Context, email string, listName string, link string) error {
+
+	mailTemplate :=
+		`<html>
+    <body style="font-family: Arial, sans-serif;">
+        <h2>Hi there,</h2>
+        <p>Thank you for signing up to .ListName . Please confirm your subscription by clicking the link below:</p>
+        <p><a href=".Link">Confirm subscription</a></p>
+        <p>Please note that this link expires after a limited time.</p>
+        <p>If you did not sign up, please ignore this email and you will not be subscribed.</p>
+        <br>
+        <p>Regards,<br> .AppName </p>
+    </body>
+</html>
+`
+	replacements := map[string]string{
+		".ListName": html.EscapeString(listName),
+		".Link":     link,
+		".AppName":  m.appName,
 	}
 
 	formattedMail := mailTemplate
@@ -70,10 +283,65 @@ func ResetPasswordMail(email string, username string, otp string) error {
 		formattedMail = strings.Replace(formattedMail, placeholder, value, -1)
 	}
 
-	err := utils.SendMail("Password Reset", email, formattedMail)
+	err := utils.SendMail(ctx, m.cfg, "Confirm Your Subscription", email, formattedMail)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarketingMail sends a message written by an account, such as a workflow email. body is HTML whose merge
+// tags are already filled in; a link to the preference center of the contact is added below it, followed by
+// an image that records the message as opened when it is loaded. messageId becomes the local part of the
+// Message-ID, by which bounces and complaints are matched to the message, and tier the plan of the account
+// the metrics count the mail under.
+func (m *SMTPMailer) MarketingMail(ctx context.Context, email string, subject string, body string, preferencesLink string, messageId string, tier string) error {
+
+	mailTemplate :=
+		`<html>
+    <body style="font-family: Arial, sans-serif;">
+        .Body
+        <br>
+        <p style="font-size: 12px; color: #666666;">You receive this email from .AppName . <a href=".Link">Manage your email preferences or unsubscribe</a>.</p>
+        <img src=".Pixel" width="1" height="1" alt="" style="display: block; border: 0;">
+    </body>
+</html>
+`
+	replacements := map[string]string{
+		".Body":    body,
+		".Link":    html.EscapeString(preferencesLink),
+		".Pixel":   html.EscapeString(fmt.Sprintf("%s/api/v1/public/messages/%s/open", m.appURL, url.PathEscape(messageId))),
+		".AppName": m.appName,
+	}
+
+	formattedMail := mailTemplate
+
+	// the body goes last so that text in it that looks like a placeholder is left alone
+	for _, placeholder := range []string{".Link", ".Pixel", ".AppName", ".Body"} {
+		formattedMail = strings.Replace(formattedMail, placeholder, replacements[placeholder], -1)
+	}
+
+	err := utils.SendMessage(ctx, m.cfg, utils.Mail{
+		To:      email,
+		Subject: subject,
+		HTML:    formattedMail,
+		Headers: map[string]string{"Message-ID": "<" + messageId + "@" + m.messageIdDomain() + ">"},
+		Tier:    tier,
+	})
 
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// messageIdDomain returns the domain of the sender address, which the Message-IDs of sent mail end with.
+func (m *SMTPMailer) messageIdDomain() string {
+	if address, err := mail.ParseAddress(m.cfg.From); err == nil {
+		if _, domain, ok := strings.Cut(address.Address, "@"); ok {
+			return domain
+		}
+	}
+	return "localhost"
+}
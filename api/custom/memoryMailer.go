@@ -0,0 +1,98 @@
+package custom
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SentMail is a message recorded by MemoryMailer.
+type SentMail struct {
+	Kind      string
+	Email     string
+	Username  string
+	Token     string
+	Link      string
+	Subject   string
+	Body      string
+	MessageId string
+	Until     time.Time
+}
+
+// MemoryMailer records messages instead of sending them. It is meant for tests.
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []SentMail
+	// Err, when set, is returned by every send.
+	Err error
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Sent returns the recorded messages in the order they were sent.
+func (m *MemoryMailer) Sent() []SentMail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]SentMail(nil), m.sent...)
+}
+
+// Last returns the most recent message of the given kind, e.g. "signup".
+func (m *MemoryMailer) Last(kind string) (SentMail, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].Kind == kind {
+			return m.sent[i], true
+		}
+	}
+
+	return SentMail{}, false
+}
+
+func (m *MemoryMailer) record(mail SentMail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+func (m *MemoryMailer) SignUpMail(ctx context.Context, email string, username string, otp string) error {
+	return m.record(SentMail{Kind: "signup", Email: email, Username: username, Token: otp})
+}
+
+func (m *MemoryMailer) ResetPasswordMail(ctx context.Context, email string, username string, otp string) error {
+	return m.record(SentMail{Kind: "reset_password", Email: email, Username: username, Token: otp})
+}
+
+func (m *MemoryMailer) InvitationMail(ctx context.Context, email string, inviterName string, role string, link string) error {
+	return m.record(SentMail{Kind: "invitation", Email: email, Username: inviterName, Link: link})
+}
+
+func (m *MemoryMailer) AccountLockedMail(ctx context.Context, email string, username string, lockedUntil time.Time) error {
+	return m.record(SentMail{Kind: "account_locked", Email: email, Username: username, Until: lockedUntil})
+}
+
+func (m *MemoryMailer) ChangeEmailMail(ctx context.Context, email string, username string, otp string) error {
+	return m.record(SentMail{Kind: "change_email", Email: email, Username: username, Token: otp})
+}
+
+func (m *MemoryMailer) AccountDeletionMail(ctx context.Context, email string, username string, otp string, purgeAfter time.Time) error {
+	return m.record(SentMail{Kind: "account_deletion", Email: email, Username: username, Token: otp, Until: purgeAfter})
+}
+
+func (m *MemoryMailer) SubscriptionConfirmationMail(ctx context.Context, email string, listName string, link string) error {
+	return m.record(SentMail{Kind: "subscription_confirmation", Email: email, Username: listName, Link: link})
+}
+
+func (m *MemoryMailer) MarketingMail(ctx context.Context, email string, subject string, body string, preferencesLink string, messageId string, tier string) error {
+	return m.record(SentMail{Kind: "marketing", Email: email, Subject: subject, Body: body, Link: preferencesLink, MessageId: messageId})
+}
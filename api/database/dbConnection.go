@@ -2,45 +2,31 @@ package database
 
 import (
 	"database/sql"
-	"email-marketing-service/api/utils"
-	"fmt"
+	"email-marketing-service/api/config"
 	_ "github.com/lib/pq"
-	"log"
-	"os"
+	"log/slog"
 )
 
-var db *sql.DB
+// InitDB opens the connection pool shared by the whole application. It must be called once at startup
+// and the pool passed to everything that needs it.
+func InitDB(cfg config.DatabaseConfig) (*sql.DB, error) {
 
-func GetDb() *sql.DB {
-	return db
-}
-
-func InitDB() (*sql.DB, error) {
-
-	utils.LoadEnv()
-
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
-		log.Fatal(err)
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(10) // Set maximum number of open connections
-	db.SetMaxIdleConns(5)  // Set maximum number of idle connections
+	db.SetMaxOpenConns(cfg.MaxOpenConns)       // Set maximum number of open connections
+	db.SetMaxIdleConns(cfg.MaxIdleConns)       // Set maximum number of idle connections
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime) // Recycle connections so server-side restarts are picked up
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime) // Release idle connections
 
 	err = db.Ping()
 	if err != nil {
-		log.Fatal(err)
+		db.Close()
 		return nil, err
 	}
 
-	fmt.Println("Connected to the database")
+	slog.Info("connected to the database", "host", cfg.Host, "name", cfg.Name)
 	return db, nil
 }
@@ -0,0 +1,35 @@
+// Package dbtest connects tests to a real PostgreSQL database. Tests using it are skipped unless
+// TEST_DATABASE_URL is set, e.g. to postgres://postgres@localhost/email_marketing_test?sslmode=disable.
+// The database is migrated to the latest version; tests share it, so they must not depend on it being empty.
+package dbtest
+
+import (
+	"database/sql"
+	"email-marketing-service/api/database"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// Open returns a connection to the test database, or skips the test when none is configured.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	return db
+}
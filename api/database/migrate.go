@@ -0,0 +1,298 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey identifies the advisory lock held while migrations run, so that instances
+// starting at the same time do not apply the same migration twice.
+const migrationLockKey = 727274001
+
+// Migration is a pair of up/down scripts named <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// LoadMigrations reads the embedded migrations ordered by version.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionPart, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s must be named <version>_<name>.%s.sql", fileName, direction)
+		}
+
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in migration file %s: %w", fileName, err)
+		}
+
+		contents, err := fs.ReadFile(migrationFiles, path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrateUp applies every migration that has not been applied yet and returns how many were applied.
+func MigrateUp(db *sql.DB) (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+
+	err = withMigrationLock(db, func(conn *sql.Conn) error {
+		current, err := currentVersion(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			err := runMigration(conn, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// MigrateDown reverts the last steps applied migrations and returns how many were reverted.
+func MigrateDown(db *sql.DB, steps int) (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+
+	err = withMigrationLock(db, func(conn *sql.Conn) error {
+		for reverted < steps {
+			current, err := currentVersion(conn)
+			if err != nil {
+				return err
+			}
+
+			if current == 0 {
+				return nil
+			}
+
+			var migration *Migration
+			for i := range migrations {
+				if migrations[i].Version == current {
+					migration = &migrations[i]
+				}
+			}
+
+			if migration == nil {
+				return fmt.Errorf("applied migration %d is unknown to this build", current)
+			}
+
+			err = runMigration(conn, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("reverting migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			reverted++
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// MigrationVersion returns the version of the last applied migration and the number of pending ones.
+func MigrationVersion(db *sql.DB) (int, int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if err := ensureMigrationsTable(conn); err != nil {
+		return 0, 0, err
+	}
+
+	current, err := currentVersion(conn)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pending := 0
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending++
+		}
+	}
+
+	return current, pending, nil
+}
+
+// CheckMigrations returns an error unless every embedded migration has been applied. Unlike MigrationVersion
+// it never writes, so it is safe to call from health checks.
+func CheckMigrations(ctx context.Context, db *sql.DB) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	if current < latest {
+		return fmt.Errorf("database is at version %d, expected %d", current, latest)
+	}
+
+	return nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration advisory lock.
+// Advisory locks belong to the session, so everything has to happen on that same connection.
+func withMigrationLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	if err := ensureMigrationsTable(conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func ensureMigrationsTable(conn *sql.Conn) error {
+	_, err := conn.ExecContext(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version integer NOT NULL,
+			name character varying NOT NULL,
+			applied_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT schema_migrations_pkey PRIMARY KEY (version)
+		)`)
+	return err
+}
+
+func currentVersion(conn *sql.Conn) (int, error) {
+	var version int
+	err := conn.QueryRowContext(context.Background(), "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// runMigration executes script and records it through record in a single transaction.
+func runMigration(conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
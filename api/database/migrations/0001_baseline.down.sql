@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS otp;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id serial NOT NULL,
+    uuid character varying,
+    firstname character varying,
+    middlename character varying,
+    lastname character varying,
+    email character varying,
+    password bytea,
+    verified boolean DEFAULT false,
+    created_at timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    verified_at timestamp without time zone,
+    updated_at timestamp without time zone,
+    deleted_at timestamp without time zone,
+    username character varying,
+    CONSTRAINT users_pkey PRIMARY KEY (id)
+);
+
+-- databases created from the old pgAdmin dump store the password as varchar. lib/pq sends []byte
+-- in bytea hex format, so those values are the text "\x..." and have to be decoded.
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'password') <> 'bytea' THEN
+        ALTER TABLE users ALTER COLUMN password TYPE bytea USING
+            CASE WHEN left(password, 2) = '\x' THEN decode(substring(password FROM 3), 'hex')
+                 ELSE convert_to(password, 'UTF8') END;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS otp
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token character varying NOT NULL,
+    created_at timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT otp_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS otp_token_idx ON otp (token);
@@ -0,0 +1,27 @@
+CREATE TABLE invitations
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email character varying NOT NULL,
+    role character varying NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    accepted_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT invitations_pkey PRIMARY KEY (id),
+    CONSTRAINT invitations_uuid_key UNIQUE (uuid)
+);
+
+CREATE INDEX invitations_account_id_idx ON invitations (account_id);
+
+CREATE TABLE team_members
+(
+    id serial NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role character varying NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT team_members_pkey PRIMARY KEY (id),
+    CONSTRAINT team_members_account_user_key UNIQUE (account_id, user_id)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE auth_attempts
+(
+    scope character varying NOT NULL,
+    key character varying NOT NULL,
+    failures integer NOT NULL DEFAULT 0,
+    last_failed_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until timestamp with time zone,
+    CONSTRAINT auth_attempts_pkey PRIMARY KEY (scope, key)
+);
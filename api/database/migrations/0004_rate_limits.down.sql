@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rate_limit_buckets;
+DROP TABLE IF EXISTS account_quotas;
+DROP TABLE IF EXISTS plans;
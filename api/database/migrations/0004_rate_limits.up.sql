@@ -0,0 +1,34 @@
+CREATE TABLE plans
+(
+    name character varying NOT NULL,
+    requests_per_second double precision NOT NULL,
+    request_burst integer NOT NULL,
+    messages_per_hour integer NOT NULL,
+    messages_per_day integer NOT NULL,
+    CONSTRAINT plans_pkey PRIMARY KEY (name)
+);
+
+INSERT INTO plans (name, requests_per_second, request_burst, messages_per_hour, messages_per_day) VALUES
+    ('free', 2, 10, 100, 300),
+    ('pro', 10, 50, 10000, 100000),
+    ('enterprise', 50, 200, 100000, 2000000);
+
+-- null columns fall back to the limits of the plan
+CREATE TABLE account_quotas
+(
+    user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    plan character varying NOT NULL DEFAULT 'free' REFERENCES plans (name),
+    requests_per_second double precision,
+    request_burst integer,
+    messages_per_hour integer,
+    messages_per_day integer,
+    CONSTRAINT account_quotas_pkey PRIMARY KEY (user_id)
+);
+
+CREATE TABLE rate_limit_buckets
+(
+    key character varying NOT NULL,
+    tokens double precision NOT NULL,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT rate_limit_buckets_pkey PRIMARY KEY (key)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_purge_after_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS sessions_revoked_at,
+    DROP COLUMN IF EXISTS purge_after;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN purge_after timestamp with time zone,
+    ADD COLUMN sessions_revoked_at timestamp with time zone;
+
+CREATE INDEX users_purge_after_idx ON users (purge_after) WHERE deleted_at IS NOT NULL;
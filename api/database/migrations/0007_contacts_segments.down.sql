@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS message_events;
+DROP TABLE IF EXISTS campaigns;
+DROP TABLE IF EXISTS segments;
+DROP TABLE IF EXISTS contact_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS list_contacts;
+DROP TABLE IF EXISTS lists;
+DROP TABLE IF EXISTS contacts;
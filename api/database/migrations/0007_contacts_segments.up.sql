@@ -0,0 +1,116 @@
+CREATE TABLE contacts
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email character varying NOT NULL,
+    firstname character varying NOT NULL DEFAULT '',
+    lastname character varying NOT NULL DEFAULT '',
+    -- custom attributes set by the account, e.g. {"plan": "pro", "seats": 12}
+    attributes jsonb NOT NULL DEFAULT '{}',
+    status character varying NOT NULL DEFAULT 'subscribed',
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT contacts_pkey PRIMARY KEY (id),
+    CONSTRAINT contacts_uuid_key UNIQUE (uuid)
+);
+
+CREATE UNIQUE INDEX contacts_account_email_key ON contacts (account_id, lower(email));
+CREATE INDEX contacts_attributes_idx ON contacts USING gin (attributes);
+
+CREATE TABLE lists
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name character varying NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT lists_pkey PRIMARY KEY (id),
+    CONSTRAINT lists_uuid_key UNIQUE (uuid)
+);
+
+CREATE INDEX lists_account_id_idx ON lists (account_id);
+
+CREATE TABLE list_contacts
+(
+    list_id integer NOT NULL REFERENCES lists (id) ON DELETE CASCADE,
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT list_contacts_pkey PRIMARY KEY (list_id, contact_id)
+);
+
+CREATE INDEX list_contacts_contact_id_idx ON list_contacts (contact_id);
+
+-- tag names are unique per account regardless of case
+CREATE TABLE tags
+(
+    id serial NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name character varying NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT tags_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX tags_account_name_key ON tags (account_id, lower(name));
+
+CREATE TABLE contact_tags
+(
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    tag_id integer NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT contact_tags_pkey PRIMARY KEY (contact_id, tag_id)
+);
+
+CREATE INDEX contact_tags_tag_id_idx ON contact_tags (tag_id);
+
+CREATE TABLE segments
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name character varying NOT NULL,
+    rules jsonb NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT segments_pkey PRIMARY KEY (id),
+    CONSTRAINT segments_uuid_key UNIQUE (uuid)
+);
+
+CREATE INDEX segments_account_id_idx ON segments (account_id);
+
+-- the audience is the list, the segment, or the contacts of the list matching the segment
+CREATE TABLE campaigns
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name character varying NOT NULL,
+    subject character varying NOT NULL DEFAULT '',
+    body text NOT NULL DEFAULT '',
+    list_id integer REFERENCES lists (id) ON DELETE RESTRICT,
+    segment_id integer REFERENCES segments (id) ON DELETE RESTRICT,
+    status character varying NOT NULL DEFAULT 'draft',
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT campaigns_pkey PRIMARY KEY (id),
+    CONSTRAINT campaigns_uuid_key UNIQUE (uuid)
+);
+
+CREATE INDEX campaigns_account_id_idx ON campaigns (account_id);
+CREATE INDEX campaigns_list_id_idx ON campaigns (list_id);
+CREATE INDEX campaigns_segment_id_idx ON campaigns (segment_id);
+
+-- what happened to the messages sent to a contact: sent, delivered, opened, clicked, bounced, complained, unsubscribed
+CREATE TABLE message_events
+(
+    id bigserial NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    campaign_id integer REFERENCES campaigns (id) ON DELETE SET NULL,
+    type character varying NOT NULL,
+    data jsonb NOT NULL DEFAULT '{}',
+    occurred_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT message_events_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX message_events_contact_type_idx ON message_events (contact_id, type, occurred_at);
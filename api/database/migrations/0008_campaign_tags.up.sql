@@ -0,0 +1,2 @@
+-- a campaign with tags only goes to the contacts of its audience that have all of them
+ALTER TABLE campaigns ADD COLUMN tags character varying[] NOT NULL DEFAULT '{}';
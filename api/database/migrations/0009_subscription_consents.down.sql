@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS subscription_consents;
+
+ALTER TABLE lists DROP COLUMN IF EXISTS public_id;
@@ -0,0 +1,26 @@
+-- public_id identifies a list on signup forms without exposing its uuid
+ALTER TABLE lists ADD COLUMN public_id character varying;
+UPDATE lists SET public_id = md5(random()::text || clock_timestamp()::text || id::text);
+ALTER TABLE lists
+    ALTER COLUMN public_id SET NOT NULL,
+    ADD CONSTRAINT lists_public_id_key UNIQUE (public_id);
+
+-- every signup through a public form, kept as proof of consent
+CREATE TABLE subscription_consents
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    list_id integer NOT NULL REFERENCES lists (id) ON DELETE CASCADE,
+    source character varying NOT NULL DEFAULT '',
+    ip character varying NOT NULL,
+    user_agent character varying NOT NULL DEFAULT '',
+    requested_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    confirmed_at timestamp with time zone,
+    confirmed_ip character varying,
+    CONSTRAINT subscription_consents_pkey PRIMARY KEY (id),
+    CONSTRAINT subscription_consents_uuid_key UNIQUE (uuid)
+);
+
+CREATE INDEX subscription_consents_contact_list_idx ON subscription_consents (contact_id, list_id, requested_at);
@@ -0,0 +1,23 @@
+-- hosted signup forms; public_id identifies a form in its public page and submission URLs.
+-- list_id uses NO ACTION rather than RESTRICT so that deleting an account can cascade to both the form and its list
+CREATE TABLE signup_forms
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    public_id character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    list_id integer NOT NULL REFERENCES lists (id),
+    name character varying NOT NULL,
+    title character varying NOT NULL DEFAULT '',
+    fields jsonb NOT NULL DEFAULT '[]',
+    double_opt_in boolean NOT NULL DEFAULT true,
+    redirect_url character varying NOT NULL DEFAULT '',
+    success_message character varying NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT signup_forms_pkey PRIMARY KEY (id),
+    CONSTRAINT signup_forms_uuid_key UNIQUE (uuid),
+    CONSTRAINT signup_forms_public_id_key UNIQUE (public_id)
+);
+
+CREATE INDEX signup_forms_account_id_idx ON signup_forms (account_id);
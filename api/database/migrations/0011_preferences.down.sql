@@ -0,0 +1,4 @@
+ALTER TABLE contacts DROP COLUMN IF EXISTS snoozed_until;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS topic_id;
+DROP TABLE IF EXISTS contact_topic_optouts;
+DROP TABLE IF EXISTS topics;
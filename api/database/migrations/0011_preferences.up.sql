@@ -0,0 +1,31 @@
+-- topics group the campaigns of an account so that contacts can choose what they receive
+CREATE TABLE topics
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name character varying NOT NULL,
+    description character varying NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT topics_pkey PRIMARY KEY (id),
+    CONSTRAINT topics_uuid_key UNIQUE (uuid)
+);
+
+CREATE UNIQUE INDEX topics_account_name_idx ON topics (account_id, lower(name));
+
+-- contacts receive every topic unless they opted out of it
+CREATE TABLE contact_topic_optouts
+(
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    topic_id integer NOT NULL REFERENCES topics (id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT contact_topic_optouts_pkey PRIMARY KEY (contact_id, topic_id)
+);
+
+CREATE INDEX contact_topic_optouts_topic_id_idx ON contact_topic_optouts (topic_id);
+
+ALTER TABLE campaigns ADD COLUMN topic_id integer REFERENCES topics (id);
+
+-- contacts are left out of campaigns until snoozed_until has passed
+ALTER TABLE contacts ADD COLUMN snoozed_until timestamp with time zone;
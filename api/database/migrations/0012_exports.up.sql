@@ -0,0 +1,41 @@
+-- exports run in the background write a file to EXPORT_DIR that is downloaded through a signed link
+CREATE TABLE exports
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type character varying NOT NULL,
+    list_uuid character varying NOT NULL DEFAULT '',
+    segment_uuid character varying NOT NULL DEFAULT '',
+    format character varying NOT NULL,
+    columns character varying[] NOT NULL,
+    status character varying NOT NULL DEFAULT 'pending',
+    row_count integer NOT NULL DEFAULT 0,
+    error character varying NOT NULL DEFAULT '',
+    file_path character varying NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at timestamp with time zone,
+    completed_at timestamp with time zone,
+    expires_at timestamp with time zone,
+    CONSTRAINT exports_pkey PRIMARY KEY (id),
+    CONSTRAINT exports_uuid_key UNIQUE (uuid)
+);
+
+CREATE INDEX exports_account_id_idx ON exports (account_id, created_at);
+CREATE INDEX exports_queue_idx ON exports (created_at) WHERE status IN ('pending', 'running');
+
+-- audit_log keeps who did what with the data of an account, such as taking an export
+CREATE TABLE audit_log
+(
+    id bigserial NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    action character varying NOT NULL,
+    target character varying NOT NULL DEFAULT '',
+    details jsonb NOT NULL DEFAULT '{}',
+    ip character varying NOT NULL DEFAULT '',
+    user_agent character varying NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT audit_log_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX audit_log_account_id_idx ON audit_log (account_id, created_at);
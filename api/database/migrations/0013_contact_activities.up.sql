@@ -0,0 +1,14 @@
+-- contact_activities records what happened to a contact besides the message events: status, list and
+-- attribute changes. Together with message_events it makes up the activity timeline of a contact.
+CREATE TABLE contact_activities
+(
+    id bigserial NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    type character varying NOT NULL,
+    data jsonb NOT NULL DEFAULT '{}',
+    occurred_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT contact_activities_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX contact_activities_contact_idx ON contact_activities (contact_id, occurred_at);
@@ -0,0 +1,43 @@
+-- workflows send a sequence of steps to the contacts that meet their trigger, e.g. joining a list
+CREATE TABLE workflows
+(
+    id serial NOT NULL,
+    uuid character varying NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name character varying NOT NULL,
+    status character varying NOT NULL DEFAULT 'draft',
+    trigger jsonb NOT NULL,
+    steps jsonb NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT workflows_pkey PRIMARY KEY (id),
+    CONSTRAINT workflows_uuid_key UNIQUE (uuid)
+);
+
+CREATE INDEX workflows_account_id_idx ON workflows (account_id);
+
+-- workflow_runs keeps where each contact is in a workflow, so that runs go on after a restart. step_id is
+-- the step to run next at next_run_at.
+CREATE TABLE workflow_runs
+(
+    id bigserial NOT NULL,
+    workflow_id integer NOT NULL REFERENCES workflows (id) ON DELETE CASCADE,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    status character varying NOT NULL DEFAULT 'active',
+    step_id character varying NOT NULL DEFAULT '',
+    -- trigger_key tells apart the runs of a trigger that fires once per period, e.g. the year of an anniversary
+    trigger_key character varying NOT NULL DEFAULT '',
+    error character varying NOT NULL DEFAULT '',
+    next_run_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at timestamp with time zone,
+    CONSTRAINT workflow_runs_pkey PRIMARY KEY (id)
+);
+
+-- a contact goes through a workflow once at a time, and once per trigger key
+CREATE UNIQUE INDEX workflow_runs_active_idx ON workflow_runs (workflow_id, contact_id) WHERE status = 'active';
+CREATE UNIQUE INDEX workflow_runs_trigger_key_idx ON workflow_runs (workflow_id, contact_id, trigger_key) WHERE trigger_key <> '';
+CREATE INDEX workflow_runs_due_idx ON workflow_runs (next_run_at) WHERE status = 'active';
+CREATE INDEX workflow_runs_workflow_id_idx ON workflow_runs (workflow_id, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS contact_events;
+DROP INDEX IF EXISTS contacts_external_id_key;
+ALTER TABLE contacts DROP COLUMN IF EXISTS external_id;
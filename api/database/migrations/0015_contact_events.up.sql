@@ -0,0 +1,22 @@
+-- external_id is the id of a contact in the account's own systems, so that events can name contacts by it
+ALTER TABLE contacts ADD COLUMN external_id character varying NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX contacts_external_id_key ON contacts (account_id, external_id) WHERE external_id <> '';
+
+-- contact_events are custom events sent by an account about its contacts, such as signed_up or purchased
+CREATE TABLE contact_events
+(
+    id bigserial NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    name character varying NOT NULL,
+    properties jsonb NOT NULL DEFAULT '{}',
+    -- idempotency_key makes sending the same event again a no-op
+    idempotency_key character varying NOT NULL DEFAULT '',
+    occurred_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    received_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT contact_events_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX contact_events_contact_idx ON contact_events (contact_id, name, occurred_at);
+CREATE UNIQUE INDEX contact_events_idempotency_key ON contact_events (account_id, idempotency_key) WHERE idempotency_key <> '';
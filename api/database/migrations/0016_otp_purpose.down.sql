@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS otp_token_purpose_idx;
+CREATE INDEX IF NOT EXISTS otp_token_idx ON otp (token);
+
+ALTER TABLE otp DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE otp DROP COLUMN IF EXISTS purpose;
@@ -0,0 +1,7 @@
+-- an OTP only works in the flow it was mailed for and until it expires; existing OTPs get neither, so they
+-- can no longer be used
+ALTER TABLE otp ADD COLUMN purpose character varying NOT NULL DEFAULT '';
+ALTER TABLE otp ADD COLUMN expires_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+DROP INDEX otp_token_idx;
+CREATE INDEX otp_token_purpose_idx ON otp (token, purpose);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS message_events_feedback_key;
+DROP INDEX IF EXISTS message_events_message_id_idx;
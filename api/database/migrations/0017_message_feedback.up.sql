@@ -0,0 +1,6 @@
+-- bounces and complaints name the message they are about by the Message-ID recorded when it was sent
+CREATE INDEX message_events_message_id_idx ON message_events ((data ->> 'message_id')) WHERE type = 'sent';
+
+-- a report received twice is recorded once
+CREATE UNIQUE INDEX message_events_feedback_key ON message_events (contact_id, type, (data ->> 'message_id'))
+    WHERE type IN ('bounced', 'complained');
@@ -0,0 +1 @@
+ALTER TABLE exports DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,3 @@
+-- a running export is touched regularly, so that one abandoned by a stopped instance is claimed again soon
+-- after its last heartbeat instead of an hour after it started
+ALTER TABLE exports ADD COLUMN updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,4 @@
+ALTER TABLE campaigns DROP CONSTRAINT campaigns_segment_id_fkey;
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_segment_id_fkey FOREIGN KEY (segment_id) REFERENCES segments (id) ON DELETE RESTRICT;
+ALTER TABLE campaigns DROP CONSTRAINT campaigns_list_id_fkey;
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_list_id_fkey FOREIGN KEY (list_id) REFERENCES lists (id) ON DELETE RESTRICT;
@@ -0,0 +1,7 @@
+-- RESTRICT is checked as soon as a row is deleted, so deleting an account failed on the lists and segments of
+-- its campaigns before the cascade reached the campaigns. NO ACTION is checked at the end of the statement,
+-- and still keeps a list or segment used by a campaign from being deleted on its own.
+ALTER TABLE campaigns DROP CONSTRAINT campaigns_list_id_fkey;
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_list_id_fkey FOREIGN KEY (list_id) REFERENCES lists (id);
+ALTER TABLE campaigns DROP CONSTRAINT campaigns_segment_id_fkey;
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_segment_id_fkey FOREIGN KEY (segment_id) REFERENCES segments (id);
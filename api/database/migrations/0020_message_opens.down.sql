@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS message_events_feedback_key;
+CREATE UNIQUE INDEX message_events_feedback_key ON message_events (contact_id, type, (data ->> 'message_id'))
+    WHERE type IN ('bounced', 'complained');
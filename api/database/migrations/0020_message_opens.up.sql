@@ -0,0 +1,4 @@
+-- the first open of a message is recorded once, like its bounces and complaints
+DROP INDEX IF EXISTS message_events_feedback_key;
+CREATE UNIQUE INDEX message_events_feedback_key ON message_events (contact_id, type, (data ->> 'message_id'))
+    WHERE type IN ('bounced', 'complained', 'opened');
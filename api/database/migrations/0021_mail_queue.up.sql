@@ -0,0 +1,27 @@
+-- mail_queue keeps the marketing mail that was not sent yet, so that it is sent after a restart and tried again
+-- when the SMTP server fails. A queued mail is due at next_attempt_at; sent mail leaves the queue and is
+-- recorded as a sent message event.
+CREATE TABLE mail_queue
+(
+    id bigserial NOT NULL,
+    account_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    contact_id integer NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    email character varying NOT NULL,
+    subject character varying NOT NULL,
+    body text NOT NULL,
+    preferences_link character varying NOT NULL,
+    message_id character varying NOT NULL,
+    data jsonb NOT NULL DEFAULT '{}',
+    status character varying NOT NULL DEFAULT 'queued',
+    attempts integer NOT NULL DEFAULT 0,
+    error character varying NOT NULL DEFAULT '',
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT mail_queue_pkey PRIMARY KEY (id),
+    CONSTRAINT mail_queue_message_id_key UNIQUE (message_id)
+);
+
+CREATE INDEX mail_queue_due_idx ON mail_queue (next_attempt_at) WHERE status = 'queued';
+CREATE INDEX mail_queue_account_id_idx ON mail_queue (account_id);
+CREATE INDEX mail_queue_contact_id_idx ON mail_queue (contact_id);
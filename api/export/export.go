@@ -0,0 +1,135 @@
+// Package export writes contacts and suppression entries as CSV or JSON lines with a chosen set of columns.
+// Rows are written one at a time, so an export never has to be held in memory.
+package export
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/segment"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	CSV       = "csv"
+	JSONLines = "jsonl"
+)
+
+// attributePrefix starts the columns of contact exports that hold a custom attribute.
+const attributePrefix = "attributes."
+
+var (
+	// ContactColumns are the standard columns of contact, list and segment exports.
+	ContactColumns = []string{"uuid", "external_id", "email", "firstname", "lastname", "status", "tags", "snoozed_until", "created_at", "updated_at"}
+	// SuppressionColumns are the columns of suppression exports.
+	SuppressionColumns = []string{"email", "reason", "suppressed_at"}
+)
+
+// Columns returns the columns an export of kind can have, besides contact attributes.
+func Columns(kind string) []string {
+	if kind == "suppression" {
+		return SuppressionColumns
+	}
+	return ContactColumns
+}
+
+// ValidateColumns checks that every one of columns exists for an export of kind and that none repeats.
+func ValidateColumns(kind string, columns []string) error {
+	known := Columns(kind)
+
+	for i, column := range columns {
+		field := fmt.Sprintf("columns[%d]", i)
+
+		if slices.Contains(columns[:i], column) {
+			return invalid(field, "unique", fmt.Sprintf("column %s is repeated", column))
+		}
+
+		if slices.Contains(known, column) {
+			continue
+		}
+
+		if key, ok := strings.CutPrefix(column, attributePrefix); ok && kind != "suppression" {
+			if !segment.ValidAttributeKey(key) {
+				return invalid(field, "format", "attribute keys are made of letters, digits, '_', '.' or '-'")
+			}
+			continue
+		}
+
+		message := fmt.Sprintf("column must be one of: %s", strings.Join(known, ", "))
+		if kind != "suppression" {
+			message += ", or attributes.<key>"
+		}
+		return invalid(field, "oneof", message)
+	}
+
+	return nil
+}
+
+func invalid(field string, rule string, message string) error {
+	return apperrors.NewValidation("invalid_export_columns", "export columns are invalid", apperrors.FieldError{
+		Field:   field,
+		Rule:    rule,
+		Message: message,
+	})
+}
+
+// ContactValues returns the values of columns for a contact.
+func ContactValues(c *model.Contact, columns []string) []any {
+	values := make([]any, len(columns))
+
+	for i, column := range columns {
+		switch column {
+		case "uuid":
+			values[i] = c.UUID
+		case "external_id":
+			values[i] = c.ExternalId
+		case "email":
+			values[i] = c.Email
+		case "firstname":
+			values[i] = c.FirstName
+		case "lastname":
+			values[i] = c.LastName
+		case "status":
+			values[i] = c.Status
+		case "tags":
+			values[i] = c.Tags
+		case "snoozed_until":
+			values[i] = c.SnoozedUntil
+		case "created_at":
+			values[i] = c.CreatedAt
+		case "updated_at":
+			values[i] = c.UpdatedAt
+		default:
+			values[i] = c.Attributes[strings.TrimPrefix(column, attributePrefix)]
+		}
+	}
+
+	return values
+}
+
+// SuppressionValues returns the values of columns for a suppression entry.
+func SuppressionValues(s *model.Suppression, columns []string) []any {
+	values := make([]any, len(columns))
+
+	for i, column := range columns {
+		switch column {
+		case "email":
+			values[i] = s.Email
+		case "reason":
+			values[i] = s.Reason
+		default:
+			values[i] = s.SuppressedAt
+		}
+	}
+
+	return values
+}
+
+// ContentType returns the media type of files in format.
+func ContentType(format string) string {
+	if format == JSONLines {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
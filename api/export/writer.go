@@ -0,0 +1,127 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writer writes the rows of an export in CSV, with a header line, or as JSON lines, with one object per row
+// whose keys are the columns in order.
+type Writer struct {
+	format  string
+	columns []string
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	rows    int
+}
+
+// NewWriter returns a writer of rows with columns to w. CSV output starts with the header line.
+func NewWriter(w io.Writer, format string, columns []string) (*Writer, error) {
+	buf := bufio.NewWriter(w)
+	writer := &Writer{format: format, columns: columns, buf: buf}
+
+	if format == CSV {
+		writer.csv = csv.NewWriter(buf)
+		if err := writer.csv.Write(columns); err != nil {
+			return nil, err
+		}
+	}
+
+	return writer, nil
+}
+
+// Write writes a row of values, one for each column.
+func (w *Writer) Write(values []any) error {
+	w.rows++
+
+	if w.csv != nil {
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = csvField(value)
+		}
+		return w.csv.Write(record)
+	}
+
+	var line bytes.Buffer
+	line.WriteByte('{')
+
+	for i, value := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(w.columns[i])
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		line.Write(key)
+		line.WriteByte(':')
+		line.Write(encoded)
+	}
+
+	line.WriteString("}\n")
+
+	_, err := w.buf.Write(line.Bytes())
+	return err
+}
+
+// Rows returns the number of rows written so far.
+func (w *Writer) Rows() int {
+	return w.rows
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+// csvField formats a value for a CSV cell. Values that a spreadsheet would run as a formula get a leading
+// quote, as contact fields come from public signup forms.
+func csvField(value any) string {
+	var s string
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case []string:
+		s = strings.Join(v, ",")
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		s = string(encoded)
+	}
+
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
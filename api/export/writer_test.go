@@ -0,0 +1,98 @@
+package export
+
+import (
+	"bytes"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"testing"
+	"time"
+)
+
+func testContact() *model.Contact {
+	return &model.Contact{
+		UUID:       "c-1",
+		Email:      "ada@example.com",
+		FirstName:  "=HYPERLINK(\"http://evil\")",
+		Tags:       []string{"beta", "vip"},
+		Attributes: map[string]any{"seats": 12.0, "plan": "pro"},
+		CreatedAt:  time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWriterCSV(t *testing.T) {
+	columns := []string{"email", "firstname", "tags", "attributes.seats", "attributes.missing", "snoozed_until", "created_at"}
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, CSV, columns)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	if err := w.Write(ContactValues(testContact(), columns)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	want := "email,firstname,tags,attributes.seats,attributes.missing,snoozed_until,created_at\n" +
+		`ada@example.com,"'=HYPERLINK(""http://evil"")","beta,vip",12,,,2024-01-31T12:00:00Z` + "\n"
+	if out.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriterJSONLines(t *testing.T) {
+	columns := []string{"uuid", "tags", "attributes.plan", "snoozed_until"}
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, JSONLines, columns)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.Write(ContactValues(testContact(), columns)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	line := `{"uuid":"c-1","tags":["beta","vip"],"attributes.plan":"pro","snoozed_until":null}` + "\n"
+	if out.String() != line+line {
+		t.Errorf("jsonl =\n%s\nwant\n%s", out.String(), line+line)
+	}
+	if w.Rows() != 2 {
+		t.Errorf("rows = %d, want 2", w.Rows())
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		columns []string
+		field   string
+	}{
+		{"unknown column", "contacts", []string{"email", "password"}, "columns[1]"},
+		{"repeated column", "list", []string{"email", "email"}, "columns[1]"},
+		{"bad attribute key", "segment", []string{"attributes.a b"}, "columns[0]"},
+		{"attribute of suppression", "suppression", []string{"attributes.plan"}, "columns[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateColumns(tt.kind, tt.columns)
+			if !apperrors.Is(err, "invalid_export_columns") {
+				t.Fatalf("expected invalid_export_columns, got %v", err)
+			}
+			if fields := err.(*apperrors.Error).Fields; len(fields) != 1 || fields[0].Field != tt.field {
+				t.Fatalf("expected a problem with %s, got %+v", tt.field, fields)
+			}
+		})
+	}
+
+	if err := ValidateColumns("contacts", []string{"email", "attributes.plan", "tags"}); err != nil {
+		t.Errorf("valid columns rejected: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Package feedback reads the reports mailbox providers send back about delivered mail: delivery status
+// notifications (RFC 3464) for bounces and delays, and abuse reports (RFC 5965) for spam complaints.
+package feedback
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	TypeDelivery  = "delivery"
+	TypeComplaint = "complaint"
+)
+
+// ErrNotReport is returned for messages that are not a delivery status notification or an abuse report,
+// such as auto-replies.
+var ErrNotReport = errors.New("message is not a delivery or feedback report")
+
+// Report is a report about a message the service sent.
+type Report struct {
+	// Type is delivery for delivery status notifications and complaint for abuse reports.
+	Type string
+	// MessageId is the Message-ID of the reported message without the angle brackets, if the report
+	// includes the headers of the message.
+	MessageId string
+	// Recipients of a delivery report, with what happened to the message for each of them.
+	Recipients []Recipient
+	// FeedbackType of a complaint, such as abuse or fraud.
+	FeedbackType string
+}
+
+type Recipient struct {
+	Email string
+	// Action is failed, delayed, delivered, relayed or expanded.
+	Action string
+	// Status is the enhanced status code, such as 5.1.1.
+	Status     string
+	Diagnostic string
+}
+
+// Hard reports whether a failed delivery is permanent, so that the address should not be mailed again.
+func (r Recipient) Hard() bool {
+	return r.Action == "failed" && !strings.HasPrefix(r.Status, "4")
+}
+
+// Parse reads a report from a raw message.
+func Parse(r io.Reader) (*Report, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["boundary"] == "" {
+		return nil, ErrNotReport
+	}
+
+	report := &Report{}
+
+	switch strings.ToLower(params["report-type"]) {
+	case "delivery-status", "global-delivery-status":
+		report.Type = TypeDelivery
+	case "feedback-report":
+		report.Type = TypeComplaint
+	default:
+		return nil, ErrNotReport
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	found := false
+
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body := decode(part)
+
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if report.Type != TypeDelivery {
+				continue
+			}
+			if report.Recipients, err = readDeliveryStatus(body); err != nil {
+				return nil, fmt.Errorf("reading delivery status: %w", err)
+			}
+			found = true
+		case "message/feedback-report":
+			if report.Type != TypeComplaint {
+				continue
+			}
+			fields, err := readFields(bufio.NewReader(body))
+			if err != nil && len(fields) == 0 {
+				return nil, fmt.Errorf("reading feedback report: %w", err)
+			}
+			report.FeedbackType = strings.ToLower(fields.Get("Feedback-Type"))
+			found = true
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			headers, _ := readFields(bufio.NewReader(body))
+			report.MessageId = strings.Trim(strings.TrimSpace(headers.Get("Message-Id")), "<>")
+		}
+	}
+
+	if !found {
+		return nil, ErrNotReport
+	}
+
+	return report, nil
+}
+
+// readDeliveryStatus reads the per-recipient fields of a delivery status, which follow the per-message fields.
+func readDeliveryStatus(r io.Reader) ([]Recipient, error) {
+	reader := bufio.NewReader(r)
+
+	if _, err := readFields(reader); err != nil {
+		return nil, err
+	}
+
+	var recipients []Recipient
+
+	for {
+		fields, err := readFields(reader)
+
+		if email := addressOf(fields.Get("Final-Recipient")); email != "" {
+			recipients = append(recipients, Recipient{
+				Email:      email,
+				Action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+				Status:     statusCode(fields.Get("Status")),
+				Diagnostic: strings.TrimSpace(fields.Get("Diagnostic-Code")),
+			})
+		}
+
+		if err == io.EOF {
+			return recipients, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readFields reads one block of header fields up to a blank line. It returns io.EOF with the fields read
+// when the input ends instead.
+func readFields(r *bufio.Reader) (textproto.MIMEHeader, error) {
+	// skip blank lines between blocks
+	for {
+		next, err := r.Peek(1)
+		if err != nil {
+			return textproto.MIMEHeader{}, io.EOF
+		}
+		if next[0] != '\r' && next[0] != '\n' {
+			break
+		}
+		r.ReadByte()
+	}
+
+	fields, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return fields, err
+}
+
+// addressOf returns the address of a field such as "rfc822; user@example.com".
+func addressOf(field string) string {
+	if _, address, ok := strings.Cut(field, ";"); ok {
+		field = address
+	}
+	return strings.ToLower(strings.Trim(strings.TrimSpace(field), "<>"))
+}
+
+// statusCode returns the status code of a Status field, dropping any comment after it.
+func statusCode(field string) string {
+	code, _, _ := strings.Cut(strings.TrimSpace(field), " ")
+	return code
+}
+
+// decode undoes a base64 transfer encoding; multipart already decodes quoted-printable parts.
+func decode(part *multipart.Part) io.Reader {
+	if strings.EqualFold(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, part)
+	}
+	return part
+}
@@ -0,0 +1,106 @@
+package feedback
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const bounce = "From: MAILER-DAEMON@mx.example.net\r\n" +
+	"To: bounces@mail.example.com\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example.net\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; Ada@Example.org\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1 (user unknown)\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 no such user\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; grace@example.org\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.4.1\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"From: sender@mail.example.com\r\n" +
+	"Message-ID: <6f1c7f3e-9d2a-4c41-8f0e-0c3a4f1b2d5e@mail.example.com>\r\n" +
+	"Subject: Welcome\r\n" +
+	"--b1--\r\n"
+
+const complaint = "From: fbl@isp.example\r\n" +
+	"To: bounces@mail.example.com\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report; boundary=\"b2\"\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"This is an email abuse report.\r\n" +
+	"--b2\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: SomeGenerator/1.0\r\n" +
+	"Version: 1\r\n" +
+	"--b2\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"\r\n" +
+	"From: sender@mail.example.com\r\n" +
+	"Message-Id: <a1b2@mail.example.com>\r\n" +
+	"\r\n" +
+	"Hello\r\n" +
+	"--b2--\r\n"
+
+func TestParseBounce(t *testing.T) {
+	report, err := Parse(strings.NewReader(bounce))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := &Report{
+		Type:      TypeDelivery,
+		MessageId: "6f1c7f3e-9d2a-4c41-8f0e-0c3a4f1b2d5e@mail.example.com",
+		Recipients: []Recipient{
+			{Email: "ada@example.org", Action: "failed", Status: "5.1.1", Diagnostic: "smtp; 550 5.1.1 no such user"},
+			{Email: "grace@example.org", Action: "delayed", Status: "4.4.1"},
+		},
+	}
+
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("report = %+v, want %+v", report, want)
+	}
+
+	if !report.Recipients[0].Hard() || report.Recipients[1].Hard() {
+		t.Fatal("only the failed recipient is a hard bounce")
+	}
+}
+
+func TestParseComplaint(t *testing.T) {
+	report, err := Parse(strings.NewReader(complaint))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if report.Type != TypeComplaint || report.FeedbackType != "abuse" || report.MessageId != "a1b2@mail.example.com" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestParseIgnoresOtherMail(t *testing.T) {
+	reply := "From: ada@example.org\r\nSubject: Out of office\r\nContent-Type: text/plain\r\n\r\nI am away.\r\n"
+
+	if _, err := Parse(strings.NewReader(reply)); !errors.Is(err, ErrNotReport) {
+		t.Fatalf("expected ErrNotReport, got %v", err)
+	}
+}
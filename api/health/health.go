@@ -0,0 +1,130 @@
+// Package health serves the liveness and readiness endpoints used by orchestrators and load balancers.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds each dependency check so that one hanging dependency can not stall the probe.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+type Checker struct {
+	mu     sync.Mutex
+	checks map[string]Check
+	// shuttingDown makes readiness fail once the application has started to shut down.
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		checks: make(map[string]Check),
+	}
+}
+
+// Add registers a readiness check under name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// SetShuttingDown makes every following readiness probe fail.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+type checkResult struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type report struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// Liveness reports that the process is up and able to serve requests. It checks no dependencies,
+// so an outage of the database does not get every instance restarted.
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "ok"})
+}
+
+// Readiness runs every check concurrently and responds with 503 if any of them fails or the
+// application is shutting down.
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = c.checks[name]
+	}
+	c.mu.Unlock()
+
+	results := make([]checkResult, len(names))
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = run(r.Context(), checks[i])
+		}(i)
+	}
+	wg.Wait()
+
+	body := report{Status: "ok", Checks: make(map[string]checkResult, len(names))}
+	status := http.StatusOK
+
+	for i, name := range names {
+		body.Checks[name] = results[i]
+		if results[i].Status != "ok" {
+			body.Status = "failing"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	if c.shuttingDown.Load() {
+		body.Status = "shutting_down"
+		status = http.StatusServiceUnavailable
+	}
+
+	writeReport(w, status, body)
+}
+
+func run(ctx context.Context, check Check) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+
+	result := checkResult{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "failing"
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func writeReport(w http.ResponseWriter, status int, body report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
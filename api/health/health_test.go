@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, report) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+
+	return recorder.Code, body
+}
+
+func TestReadiness(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("database", func(ctx context.Context) error { return nil })
+
+	status, body := probe(t, checker.Readiness)
+	if status != http.StatusOK || body.Checks["database"].Status != "ok" {
+		t.Fatalf("expected ready, got %d %+v", status, body)
+	}
+
+	checker.Add("smtp", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	status, body = probe(t, checker.Readiness)
+	if status != http.StatusServiceUnavailable || body.Checks["smtp"].Error != "connection refused" || body.Checks["database"].Status != "ok" {
+		t.Fatalf("expected a failing smtp check, got %d %+v", status, body)
+	}
+}
+
+func TestReadinessFailsWhileShuttingDown(t *testing.T) {
+	checker := NewChecker()
+	checker.SetShuttingDown()
+
+	status, body := probe(t, checker.Readiness)
+	if status != http.StatusServiceUnavailable || body.Status != "shutting_down" {
+		t.Fatalf("expected not ready, got %d %+v", status, body)
+	}
+
+	status, _ = probe(t, checker.Liveness)
+	if status != http.StatusOK {
+		t.Fatalf("liveness must not depend on the shutdown, got %d", status)
+	}
+}
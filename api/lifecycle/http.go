@@ -0,0 +1,49 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// HTTPServer runs an http.Server as a component. Stop stops accepting connections and waits for
+// in-flight requests to complete.
+type HTTPServer struct {
+	name      string
+	server    *http.Server
+	lifecycle *Lifecycle
+	// addr is the address actually listened on, which differs from server.Addr for port 0.
+	addr net.Addr
+}
+
+// AddHTTPServer registers server under name.
+func (l *Lifecycle) AddHTTPServer(name string, server *http.Server) {
+	l.Add(&HTTPServer{name: name, server: server, lifecycle: l})
+}
+
+func (s *HTTPServer) Name() string {
+	return s.name
+}
+
+func (s *HTTPServer) Start() error {
+	// Listen before returning so that a port already in use fails the start instead of the run.
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	s.addr = listener.Addr()
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.lifecycle.fail(fmt.Errorf("%s stopped: %w", s.name, err))
+		}
+	}()
+
+	return nil
+}
+
+func (s *HTTPServer) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,133 @@
+// Package lifecycle starts the long running parts of the service and stops them in order on shutdown.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Component is anything the application runs for its whole lifetime: a server or a background worker.
+//
+// Start must not block. Stop asks the component to stop accepting new work and waits for the work
+// in progress to finish, giving up when ctx is done. Work that can not finish in time must be
+// abandoned in a state another instance can pick up again, e.g. by rolling back its transaction.
+type Component interface {
+	Name() string
+	Start() error
+	Stop(ctx context.Context) error
+}
+
+// Lifecycle owns the servers and workers of the application.
+type Lifecycle struct {
+	components      []Component
+	shutdownTimeout time.Duration
+	// drainDelay is how long to keep serving after the shutdown hooks ran, giving load balancers
+	// time to notice the failing readiness check and stop sending traffic.
+	drainDelay time.Duration
+	// shutdownHooks run as soon as the shutdown starts, before any component stops.
+	shutdownHooks []func()
+	// closers run after every component has stopped, in reverse order of registration.
+	closers []func() error
+	// failed receives the error of a component that stopped on its own.
+	failed chan error
+}
+
+func New(shutdownTimeout time.Duration, drainDelay time.Duration) *Lifecycle {
+	return &Lifecycle{
+		shutdownTimeout: shutdownTimeout,
+		drainDelay:      drainDelay,
+		failed:          make(chan error, 1),
+	}
+}
+
+// Add registers components. Servers should be added before the workers they feed so that they stop first.
+func (l *Lifecycle) Add(components ...Component) {
+	l.components = append(l.components, components...)
+}
+
+// OnClose registers a function that releases a shared resource, such as the database pool,
+// once nothing uses it anymore.
+func (l *Lifecycle) OnClose(fn func() error) {
+	l.closers = append(l.closers, fn)
+}
+
+// OnShutdown registers a function called when the shutdown starts, e.g. to fail readiness checks.
+func (l *Lifecycle) OnShutdown(fn func()) {
+	l.shutdownHooks = append(l.shutdownHooks, fn)
+}
+
+// Run starts every component and blocks until the process receives SIGINT or SIGTERM
+// or a component fails, then shuts everything down.
+func (l *Lifecycle) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return l.RunContext(ctx)
+}
+
+// RunContext is Run with the shutdown triggered by ctx instead of a signal.
+func (l *Lifecycle) RunContext(ctx context.Context) error {
+	started := 0
+	var runErr error
+
+	for _, component := range l.components {
+		if err := component.Start(); err != nil {
+			runErr = fmt.Errorf("failed to start %s: %w", component.Name(), err)
+			break
+		}
+		slog.Info("started", "component", component.Name())
+		started++
+	}
+
+	if runErr == nil {
+		select {
+		case <-ctx.Done():
+			slog.Info("shutting down", "timeout", l.shutdownTimeout.String())
+		case runErr = <-l.failed:
+		}
+	}
+
+	for _, hook := range l.shutdownHooks {
+		hook()
+	}
+
+	if runErr == nil && l.drainDelay > 0 {
+		time.Sleep(l.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancel()
+
+	errs := []error{runErr}
+
+	for i := 0; i < started; i++ {
+		component := l.components[i]
+		if err := component.Stop(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", component.Name(), err))
+			continue
+		}
+		slog.Info("stopped", "component", component.Name())
+	}
+
+	for i := len(l.closers) - 1; i >= 0; i-- {
+		if err := l.closers[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// fail reports a component that stopped on its own. Only the first failure triggers the shutdown.
+func (l *Lifecycle) fail(err error) {
+	select {
+	case l.failed <- err:
+	default:
+	}
+}
@@ -0,0 +1,125 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunContextWaitsForRunningJob(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan struct{})
+
+	worker := NewPeriodicWorker("test worker", time.Millisecond, func(ctx context.Context) error {
+		select {
+		case <-started:
+			return nil
+		default:
+			close(started)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(finished)
+		return nil
+	})
+
+	closed := false
+	app := New(time.Second, 0)
+	app.Add(worker)
+	app.OnClose(func() error {
+		select {
+		case <-finished:
+		default:
+			t.Error("closer ran before the job finished")
+		}
+		closed = true
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	if err := app.RunContext(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if !closed {
+		t.Fatal("closer did not run")
+	}
+}
+
+func TestStopCancelsJobAfterTimeout(t *testing.T) {
+	started := make(chan struct{})
+	var jobErr error
+
+	worker := NewPeriodicWorker("slow worker", time.Millisecond, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		jobErr = ctx.Err()
+		return jobErr
+	})
+
+	app := New(20*time.Millisecond, 0)
+	app.Add(worker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	err := app.RunContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shutdown to time out, got %v", err)
+	}
+
+	if !errors.Is(jobErr, context.Canceled) {
+		t.Fatalf("expected the job to be cancelled, got %v", jobErr)
+	}
+}
+
+func TestHTTPServerDrainsInFlightRequests(t *testing.T) {
+	inFlight := make(chan struct{})
+
+	server := &http.Server{
+		Addr: "127.0.0.1:0",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(inFlight)
+			time.Sleep(50 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+
+	app := New(time.Second, 0)
+	component := &HTTPServer{name: "test server", server: server, lifecycle: app}
+	app.Add(component)
+
+	if err := component.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	response := make(chan error, 1)
+	go func() {
+		res, err := http.Get("http://" + component.addr.String())
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode != http.StatusNoContent {
+				err = errors.New(res.Status)
+			}
+		}
+		response <- err
+	}()
+	<-inFlight
+
+	if err := component.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	if err := <-response; err != nil {
+		t.Fatalf("in-flight request was cut off: %v", err)
+	}
+}
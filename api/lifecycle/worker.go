@@ -0,0 +1,125 @@
+package lifecycle
+
+import (
+	"context"
+	"email-marketing-service/api/logging"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PeriodicWorker runs a job every interval.
+type PeriodicWorker struct {
+	name     string
+	interval time.Duration
+	job      func(ctx context.Context) error
+
+	stop   chan struct{}
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+
+	mu sync.Mutex
+	// lastBeat is when the loop last showed it was alive: at start, on each tick and after each run.
+	lastBeat time.Time
+}
+
+// NewPeriodicWorker returns a worker calling job every interval. The context passed to job is only
+// cancelled when a shutdown runs out of time, so a job in progress normally gets to finish.
+func NewPeriodicWorker(name string, interval time.Duration, job func(ctx context.Context) error) *PeriodicWorker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PeriodicWorker{
+		name:     name,
+		interval: interval,
+		job:      job,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (w *PeriodicWorker) Name() string {
+	return w.name
+}
+
+func (w *PeriodicWorker) Start() error {
+	w.beat()
+	go w.loop()
+	return nil
+}
+
+func (w *PeriodicWorker) beat() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastBeat = time.Now()
+}
+
+// Check reports an error when the worker has missed two beats in a row, which means its loop is stuck
+// or a run takes far longer than the interval. It is meant for readiness checks.
+func (w *PeriodicWorker) Check(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lastBeat.IsZero() {
+		return fmt.Errorf("%s is not running", w.name)
+	}
+
+	if since := time.Since(w.lastBeat); since > 2*w.interval {
+		return fmt.Errorf("%s last reported %s ago", w.name, since.Round(time.Second))
+	}
+
+	return nil
+}
+
+func (w *PeriodicWorker) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.beat()
+		}
+
+		// a tick and a stop can arrive together; prefer stopping over starting another run
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		// every run gets its own ID so that its log lines can be told apart
+		ctx := logging.WithRequestID(w.ctx, uuid.New().String())
+
+		if err := w.job(ctx); err != nil {
+			slog.ErrorContext(ctx, "worker run failed", "worker", w.name, "error", err)
+		}
+
+		w.beat()
+	}
+}
+
+// Stop waits for the current run to finish. When ctx is done first the run is cancelled.
+func (w *PeriodicWorker) Stop(ctx context.Context) error {
+	w.once.Do(func() { close(w.stop) })
+	defer w.cancel()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+		return fmt.Errorf("job cancelled: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,123 @@
+// Package logging configures the structured logger and carries request correlation data through contexts.
+//
+// Code logs through slog with a context, e.g. slog.ErrorContext(ctx, ...). The handler returned by New adds
+// the request ID and the authenticated account ID found in that context to every record, so all log lines
+// of one request, including those of the mails it sent, can be found by request ID.
+package logging
+
+import (
+	"context"
+	"email-marketing-service/api/config"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	requestInfoKey
+)
+
+// New returns a logger writing to w in the configured format and level.
+func New(cfg config.LogConfig, w io.Writer) *slog.Logger {
+	var level slog.Level
+	switch cfg.Level {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		level = slog.LevelInfo
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// contextHandler adds the correlation data of the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	if accountId, ok := AccountID(ctx); ok {
+		record.AddAttrs(slog.Int("account_id", accountId))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// WithRequestID returns a context carrying id. Background jobs use it too, to correlate the lines of one run.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestInfo holds what is learned about a request while it is handled, such as who sent it.
+// It is shared by pointer so that the access log sees values set further down the handler chain.
+type requestInfo struct {
+	mu        sync.Mutex
+	accountId int
+	known     bool
+}
+
+// WithRequestInfo returns a context in which SetAccountID can record the account of the request.
+func WithRequestInfo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestInfoKey, &requestInfo{})
+}
+
+// SetAccountID records the authenticated account of the request. It does nothing outside WithRequestInfo.
+func SetAccountID(ctx context.Context, accountId int) {
+	info, ok := ctx.Value(requestInfoKey).(*requestInfo)
+	if !ok {
+		return
+	}
+
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	info.accountId = accountId
+	info.known = true
+}
+
+// AccountID returns the account recorded with SetAccountID.
+func AccountID(ctx context.Context) (int, bool) {
+	info, ok := ctx.Value(requestInfoKey).(*requestInfo)
+	if !ok {
+		return 0, false
+	}
+
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	return info.accountId, info.known
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"email-marketing-service/api/config"
+	"encoding/json"
+	"testing"
+)
+
+func TestLoggerAddsCorrelationData(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(config.LogConfig{Level: "info", Format: "json"}, &out)
+
+	ctx := WithRequestInfo(WithRequestID(context.Background(), "req-1"))
+	SetAccountID(ctx, 42)
+
+	logger.With("component", "test").InfoContext(ctx, "hello")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+
+	if line["request_id"] != "req-1" || line["account_id"] != float64(42) || line["component"] != "test" {
+		t.Fatalf("unexpected log line %v", line)
+	}
+}
+
+func TestLoggerRespectsLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(config.LogConfig{Level: "warn", Format: "text"}, &out)
+
+	logger.Info("dropped")
+	if out.Len() != 0 {
+		t.Fatalf("info should be dropped at warn level, got %q", out.String())
+	}
+}
+
+func TestSetAccountIDWithoutRequestInfo(t *testing.T) {
+	ctx := context.Background()
+	SetAccountID(ctx, 42)
+
+	if _, ok := AccountID(ctx); ok {
+		t.Fatal("no account should be recorded without request info")
+	}
+}
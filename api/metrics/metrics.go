@@ -0,0 +1,212 @@
+// Package metrics exposes Prometheus metrics for the API and the mail pipeline.
+//
+// Metrics are labelled by account tier (the plan name) and never by account, so that the number of series
+// stays bounded however many accounts there are.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// TierNone labels unauthenticated requests.
+	TierNone = "none"
+	// TierTransactional labels mail sent by the application itself, such as verification and reset mails.
+	TierTransactional = "transactional"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route and status.",
+	}, []string{"method", "route", "status", "tier"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "tier"})
+
+	mailMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mail_messages_total",
+		Help: "Messages by outcome: queued, sent, failed or deferred.",
+	}, []string{"status", "tier"})
+
+	mailQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mail_queue_depth",
+		Help: "Messages waiting in the send queue.",
+	}, []string{"tier"})
+
+	smtpDialDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mail_smtp_dial_duration_seconds",
+		Help:    "Time taken to connect and authenticate to the SMTP server.",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	})
+
+	mailBounces = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mail_bounces_total",
+		Help: "Bounces received, by type (hard or soft).",
+	}, []string{"type", "tier"})
+
+	mailComplaints = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mail_complaints_total",
+		Help: "Spam complaints received.",
+	}, []string{"tier"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		mailMessages,
+		mailQueueDepth,
+		smtpDialDuration,
+		mailBounces,
+		mailComplaints,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exposes the statistics of the connection pool, such as open, in-use and idle connections
+// and how long callers waited for one.
+func RegisterDB(db *sql.DB, name string) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+type contextKey int
+
+const requestLabelsKey contextKey = iota
+
+// requestLabels holds labels only known once the handler has run, such as the tier of the caller.
+type requestLabels struct {
+	mu   sync.Mutex
+	tier string
+}
+
+// SetTier records the tier of the account making the request. It does nothing outside Middleware.
+func SetTier(ctx context.Context, tier string) {
+	labels, ok := ctx.Value(requestLabelsKey).(*requestLabels)
+	if !ok {
+		return
+	}
+
+	labels.mu.Lock()
+	defer labels.mu.Unlock()
+
+	labels.tier = tier
+}
+
+// statusRecorder remembers the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer, for flushing and write deadlines.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware counts requests and measures their latency per route template, so that paths with ids
+// such as /team-invite/{uuid} share one series.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		labels := &requestLabels{tier: TierNone}
+		ctx := context.WithValue(r.Context(), requestLabelsKey, labels)
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		labels.mu.Lock()
+		tier := labels.tier
+		labels.mu.Unlock()
+
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status), tier).Inc()
+		httpDuration.WithLabelValues(r.Method, route, tier).Observe(time.Since(start).Seconds())
+	})
+}
+
+// MailQueued counts n messages accepted into the send queue.
+func MailQueued(tier string, n int) {
+	mailMessages.WithLabelValues("queued", tier).Add(float64(n))
+}
+
+// MailSent counts a message accepted by the SMTP server.
+func MailSent(tier string) {
+	mailMessages.WithLabelValues("sent", tier).Inc()
+}
+
+// MailFailed counts a message that will not be retried.
+func MailFailed(tier string) {
+	mailMessages.WithLabelValues("failed", tier).Inc()
+}
+
+// MailDeferred counts a message put back in the queue after a temporary failure.
+func MailDeferred(tier string) {
+	mailMessages.WithLabelValues("deferred", tier).Inc()
+}
+
+// SetQueueDepth reports how many messages of the tier are waiting to be sent.
+func SetQueueDepth(tier string, depth int) {
+	mailQueueDepth.WithLabelValues(tier).Set(float64(depth))
+}
+
+// ObserveSMTPDial records how long connecting to the SMTP server took.
+func ObserveSMTPDial(d time.Duration) {
+	smtpDialDuration.Observe(d.Seconds())
+}
+
+// MailBounced counts a bounce; bounceType is "hard" or "soft".
+func MailBounced(tier string, bounceType string) {
+	mailBounces.WithLabelValues(bounceType, tier).Inc()
+}
+
+// MailComplained counts a spam complaint.
+func MailComplained(tier string) {
+	mailComplaints.WithLabelValues(tier).Inc()
+}
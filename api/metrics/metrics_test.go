@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	return recorder.Body.String()
+}
+
+func TestMiddlewareLabelsByRouteAndTier(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		SetTier(r.Context(), "pro")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, id := range []string{"1", "2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/things/"+id, nil))
+	}
+
+	want := `http_requests_total{method="POST",route="/things/{id}",status="201",tier="pro"} 2`
+	if body := scrape(t); !strings.Contains(body, want) {
+		t.Fatalf("missing %s in\n%s", want, body)
+	}
+}
+
+func TestMailCounters(t *testing.T) {
+	MailQueued("free", 3)
+	MailDeferred("free")
+	MailBounced("free", "hard")
+
+	body := scrape(t)
+	for _, want := range []string{
+		`mail_messages_total{status="queued",tier="free"} 3`,
+		`mail_messages_total{status="deferred",tier="free"} 1`,
+		`mail_bounces_total{tier="free",type="hard"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
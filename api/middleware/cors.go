@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORSPolicy describes which cross-origin requests a group of routes accepts.
+type CORSPolicy struct {
+	// AllowedOrigins lists origins such as https://app.example.com. An entry may use a wildcard
+	// subdomain (https://*.example.com) or be "*" to accept any origin.
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and Authorization headers. It can not be combined with "*".
+	AllowCredentials bool
+	AllowedHeaders   []string
+	// ExposedHeaders are response headers scripts may read, e.g. X-Request-ID.
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// CORS applies a CORSPolicy to the routes of one router or subrouter.
+type CORS struct {
+	policy CORSPolicy
+}
+
+func NewCORS(policy CORSPolicy) *CORS {
+	return &CORS{policy: policy}
+}
+
+// allowedOrigin returns the value for Access-Control-Allow-Origin, or "" if origin is not allowed.
+func (c *CORS) allowedOrigin(origin string) string {
+	for _, allowed := range c.policy.AllowedOrigins {
+		if allowed == "*" {
+			if c.policy.AllowCredentials {
+				return origin
+			}
+			return "*"
+		}
+
+		if matchOrigin(allowed, origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// matchOrigin compares scheme and host, treating a leading "*." in the allowed host as one or more subdomains.
+func matchOrigin(allowed string, origin string) bool {
+	if strings.EqualFold(allowed, origin) {
+		return true
+	}
+
+	scheme, host, ok := strings.Cut(allowed, "://*.")
+	if !ok {
+		return false
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(parsed.Scheme, scheme) {
+		return false
+	}
+
+	return strings.HasSuffix(strings.ToLower(parsed.Host), "."+strings.ToLower(host))
+}
+
+// setOriginHeaders sets the headers shared by preflight and actual responses and reports whether origin is allowed.
+func (c *CORS) setOriginHeaders(w http.ResponseWriter, origin string) bool {
+	// responses differ per origin, so caches must not hand one origin's response to another
+	w.Header().Add("Vary", "Origin")
+
+	allowOrigin := c.allowedOrigin(origin)
+	if allowOrigin == "" {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	if c.policy.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	return true
+}
+
+// Middleware adds the CORS headers to the actual (non-preflight) responses of the routes it wraps.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+
+		if origin != "" && !isPreflight(r) && c.setOriginHeaders(w, origin) && len(c.policy.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.policy.ExposedHeaders, ", "))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// HandlePreflight answers preflight requests for the routes of router. It must be registered after them.
+// A preflight only succeeds if a route exists for the requested path and method, so clients learn about
+// a mistyped path from the preflight instead of getting an empty success.
+func (c *CORS) HandlePreflight(router *mux.Router) {
+	router.Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		method := r.Header.Get("Access-Control-Request-Method")
+
+		if origin == "" || method == "" {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// ask the router whether the request the browser is about to send would be routed
+		actual := r.Clone(r.Context())
+		actual.Method = method
+
+		var match mux.RouteMatch
+		if !router.Match(actual, &match) || match.MatchErr != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !c.setOriginHeaders(w, origin) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", method)
+		if len(c.policy.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.policy.AllowedHeaders, ", "))
+		}
+		if c.policy.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.policy.MaxAge.Seconds())))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func newCORSRouter(policy CORSPolicy) *mux.Router {
+	cors := NewCORS(policy)
+
+	router := mux.NewRouter()
+	router.Use(cors.Middleware)
+	router.HandleFunc("/contacts", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET", "POST")
+	cors.HandlePreflight(router)
+
+	return router
+}
+
+func preflight(router http.Handler, path string, origin string, method string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSAllowsListedOrigins(t *testing.T) {
+	router := newCORSRouter(CORSPolicy{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowCredentials: true,
+		ExposedHeaders:   []string{"X-Request-ID"},
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://eu.app.example.org", true},
+		{"https://example.org", false},
+		{"http://app.example.org", false},
+		{"https://evil.com", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/contacts", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		got := rec.Header().Get("Access-Control-Allow-Origin")
+		if tt.allowed && got != tt.origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q", tt.origin, got)
+		}
+		if !tt.allowed && got != "" {
+			t.Errorf("%s: expected no Access-Control-Allow-Origin, got %q", tt.origin, got)
+		}
+		if tt.allowed && (rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID") {
+			t.Errorf("%s: missing credentials or exposed headers: %v", tt.origin, rec.Header())
+		}
+	}
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	router := newCORSRouter(CORSPolicy{AllowedOrigins: []string{"*"}})
+
+	rec := preflight(router, "/contacts", "https://shop.example.net", "POST")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("unexpected preflight response %d %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatal("credentials must not be allowed")
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	router := newCORSRouter(CORSPolicy{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	rec := preflight(router, "/contacts", "https://app.example.com", "POST")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "POST" {
+		t.Errorf("Access-Control-Allow-Methods = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization" {
+		t.Errorf("Access-Control-Allow-Headers = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q", got)
+	}
+
+	if rec := preflight(router, "/contactz", "https://app.example.com", "POST"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown route: expected 404, got %d", rec.Code)
+	}
+	if rec := preflight(router, "/contacts", "https://app.example.com", "DELETE"); rec.Code != http.StatusNotFound {
+		t.Errorf("unsupported method: expected 404, got %d", rec.Code)
+	}
+	if rec := preflight(router, "/contacts", "https://evil.com", "POST"); rec.Code != http.StatusForbidden {
+		t.Errorf("foreign origin: expected 403, got %d", rec.Code)
+	}
+}
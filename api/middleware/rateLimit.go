@@ -0,0 +1,103 @@
+// Package middleware contains HTTP middleware shared by the API routes.
+package middleware
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/ratelimit"
+	"email-marketing-service/api/services"
+	"email-marketing-service/api/utils"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var response = &utils.ApiResponse{}
+
+var errUnauthorized = apperrors.NewUnauthorized("unauthorized", "Unauthorized")
+
+type RateLimiter struct {
+	quotaService *services.QuotaService
+}
+
+func NewRateLimiter(quotaService *services.QuotaService) *RateLimiter {
+	return &RateLimiter{
+		quotaService: quotaService,
+	}
+}
+
+// Limit rate limits authenticated requests per account and reports the
+// state of the bucket in RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// Requests of team members count against the account they act on, so it must run after the JWT
+// middleware and the TeamGuard when there is one.
+func (m *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("jwtclaims").(jwt.MapClaims)
+		if !ok {
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		sub, ok := claims["sub"].(float64)
+		if !ok {
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		accountId := int(sub)
+		if access, ok := services.AccountAccessFrom(r.Context()); ok {
+			accountId = access.AccountId
+		}
+
+		result, err := m.quotaService.AllowRequest(r.Context(), accountId)
+		if err != nil {
+			response.ErrorResponse(w, r, fmt.Errorf("rate limiter unavailable: %w", err))
+			return
+		}
+
+		if !allow(w, r, result) {
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// LimitByIP rate limits unauthenticated submissions, such as signup forms, per client IP address.
+func (m *RateLimiter) LimitByIP(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := m.quotaService.AllowPublicSubmission(r.Context(), utils.ClientIP(r))
+		if err != nil {
+			response.ErrorResponse(w, r, fmt.Errorf("rate limiter unavailable: %w", err))
+			return
+		}
+
+		if !allow(w, r, result) {
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// allow reports the state of the bucket in the RateLimit headers and responds with an error if the
+// request is not allowed.
+func allow(w http.ResponseWriter, r *http.Request, result ratelimit.Result) bool {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+	if !result.Allowed {
+		response.ErrorResponse(w, r, apperrors.NewRateLimited("rate_limit_exceeded", "rate limit exceeded", result.RetryAfter))
+		return false
+	}
+
+	return true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
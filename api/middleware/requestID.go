@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"email-marketing-service/api/logging"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits the IDs accepted from clients, so that they can not inject arbitrary text into the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, reusing a valid X-Request-ID sent by the client or a proxy.
+// The ID is stored in the request context and echoed in the X-Request-ID response header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := logging.WithRequestID(r.Context(), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"log/slog"
+	"net/http"
+
+	"github.com/golang-jwt/jwt"
+)
+
+type SessionGuard struct {
+	userRepository repository.UserStore
+}
+
+func NewSessionGuard(userRepo repository.UserStore) *SessionGuard {
+	return &SessionGuard{
+		userRepository: userRepo,
+	}
+}
+
+// RequireActiveSession rejects tokens of deleted accounts and tokens issued before the account's sessions
+// were revoked. It must run after the JWT middleware.
+func (m *SessionGuard) RequireActiveSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("jwtclaims").(jwt.MapClaims)
+		if !ok {
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		sub, ok := claims["sub"].(float64)
+		if !ok {
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		state, err := m.userRepository.FindSessionState(r.Context(), &model.User{ID: int(sub)})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to load session state", "error", err)
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		if state.DeletedAt.Valid {
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		if state.SessionsRevokedAt.Valid {
+			iat, ok := claims["iat"].(float64)
+			if !ok || int64(iat) <= state.SessionsRevokedAt.Time.Unix() {
+				response.ErrorResponse(w, r, errUnauthorized)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
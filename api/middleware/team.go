@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/logging"
+	"email-marketing-service/api/services"
+	"net/http"
+	"strconv"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// AccountHeader selects the account a request acts on. Without it, requests act on the account of the
+// authenticated user.
+const AccountHeader = "X-Account-Id"
+
+var errInvalidAccount = apperrors.New(apperrors.BadRequest, "invalid_account", AccountHeader+" must be an account id")
+
+type TeamGuard struct {
+	teamService *services.TeamService
+}
+
+func NewTeamGuard(teamService *services.TeamService) *TeamGuard {
+	return &TeamGuard{
+		teamService: teamService,
+	}
+}
+
+// RequireRole only lets requests through from the owner of the selected account and from its members
+// with at least the given role, and stores the account in the request context. It must run after the
+// JWT middleware.
+func (m *TeamGuard) RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("jwtclaims").(jwt.MapClaims)
+		if !ok {
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		sub, ok := claims["sub"].(float64)
+		if !ok {
+			response.ErrorResponse(w, r, errUnauthorized)
+			return
+		}
+
+		accountId := int(sub)
+
+		if header := r.Header.Get(AccountHeader); header != "" {
+			id, err := strconv.Atoi(header)
+			if err != nil || id <= 0 {
+				response.ErrorResponse(w, r, errInvalidAccount)
+				return
+			}
+			accountId = id
+		}
+
+		access, err := m.teamService.Authorize(r.Context(), int(sub), accountId, role)
+		if err != nil {
+			response.ErrorResponse(w, r, err)
+			return
+		}
+
+		logging.SetAccountID(r.Context(), access.AccountId)
+
+		next(w, r.WithContext(services.WithAccountAccess(r.Context(), access)))
+	}
+}
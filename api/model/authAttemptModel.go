@@ -0,0 +1,22 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AuthAttempt tracks failed authentication attempts for a single scope/key pair,
+// e.g. scope "ip" with the client address or scope "account" with the email address.
+type AuthAttempt struct {
+	Scope        string       `json:"scope"`
+	Key          string       `json:"key"`
+	Failures     int          `json:"failures"`
+	LastFailedAt time.Time    `json:"last_failed_at"`
+	LockedUntil  sql.NullTime `json:"locked_until"`
+}
+
+type LockoutStatus struct {
+	Locked      bool       `json:"locked"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	RetryAfter  int        `json:"retry_after"`
+}
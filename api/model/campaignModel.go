@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Campaign is an email sent to an audience: the subscribed contacts of a list, of a segment,
+// or of a list that also match a segment. Tags narrow the audience down to the contacts having all of them.
+// Contacts that opted out of the campaign's topic or snoozed their subscription do not receive it.
+type Campaign struct {
+	ID          int       `json:"id"`
+	UUID        string    `json:"uuid"`
+	AccountId   int       `json:"account_id"`
+	Name        string    `json:"name" validate:"required,max=200"`
+	Subject     string    `json:"subject" validate:"max=255"`
+	Body        string    `json:"body"`
+	ListUUID    *string   `json:"list_uuid"`
+	SegmentUUID *string   `json:"segment_uuid"`
+	Tags        []string  `json:"tags" validate:"max=20,dive,required,max=50"`
+	TopicUUID   *string   `json:"topic_uuid"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// ListId, SegmentId and TopicId are resolved from the UUIDs before the campaign is stored.
+	ListId    *int `json:"-"`
+	SegmentId *int `json:"-"`
+	TopicId   *int `json:"-"`
+}
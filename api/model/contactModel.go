@@ -0,0 +1,121 @@
+package model
+
+import "time"
+
+type Contact struct {
+	ID        int    `json:"id"`
+	UUID      string `json:"uuid"`
+	AccountId int    `json:"account_id"`
+	// ExternalId is the id of the contact in the account's own systems, unique per account when set.
+	ExternalId string `json:"external_id" validate:"max=255"`
+	Email      string `json:"email" validate:"required,email,max=254"`
+	FirstName  string `json:"firstname" validate:"max=100"`
+	LastName   string `json:"lastname" validate:"max=100"`
+	// Attributes are custom values set by the account, usable in segment rules.
+	Attributes map[string]any `json:"attributes"`
+	// Status is pending for contacts that signed up through a form and have not confirmed yet.
+	Status string   `json:"status" validate:"omitempty,oneof=subscribed unsubscribed"`
+	Tags   []string `json:"tags" validate:"max=20,dive,required,max=50"`
+	// SnoozedUntil is set while the contact paused its emails from the preference center.
+	SnoozedUntil *time.Time `json:"snoozed_until"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// UpdateContact changes the fields of a contact that are set. Attributes are merged into the existing
+// ones; an attribute set to null is removed.
+type UpdateContact struct {
+	UUID       string         `json:"-"`
+	AccountId  int            `json:"-"`
+	ExternalId *string        `json:"external_id" validate:"omitempty,max=255"`
+	FirstName  *string        `json:"firstname" validate:"omitempty,max=100"`
+	LastName   *string        `json:"lastname" validate:"omitempty,max=100"`
+	Attributes map[string]any `json:"attributes"`
+	Status     *string        `json:"status" validate:"omitempty,oneof=subscribed unsubscribed"`
+	// Source tells where the change came from in the contact timeline. Empty means api.
+	Source string `json:"-"`
+}
+
+// ContactPage selects a page of contacts ordered from newest to oldest.
+type ContactPage struct {
+	AccountId int
+	// Tags keeps only the contacts that have all of them.
+	Tags   []string
+	Limit  int
+	Offset int
+}
+
+type ContactList struct {
+	ID        int    `json:"id"`
+	UUID      string `json:"uuid"`
+	AccountId int    `json:"account_id"`
+	// PublicId identifies the list on public signup forms.
+	PublicId  string    `json:"public_id"`
+	Name      string    `json:"name" validate:"required,max=100"`
+	Contacts  int       `json:"contacts"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListMembers names the contacts to add to or remove from a list.
+type ListMembers struct {
+	Contacts []string `json:"contacts" validate:"required,min=1,max=1000"`
+}
+
+// Tag is a free-form label on contacts. Names are unique per account regardless of case.
+type Tag struct {
+	ID        int       `json:"id"`
+	AccountId int       `json:"account_id"`
+	Name      string    `json:"name"`
+	Contacts  int       `json:"contacts"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContactTags names the tags to add to or remove from a set of contacts.
+type ContactTags struct {
+	Contacts []string `json:"contacts" validate:"required,min=1,max=1000"`
+	Tags     []string `json:"tags" validate:"required,min=1,max=20,dive,required,max=50"`
+}
+
+// TaggedContact records a tag being added to a contact.
+type TaggedContact struct {
+	AccountId int    `json:"account_id"`
+	ContactId int    `json:"contact_id"`
+	TagId     int    `json:"tag_id"`
+	Tag       string `json:"tag"`
+}
+
+// ContactActivity is an event in the timeline of a contact: a message event such as opened, or a change
+// such as list_added.
+type ContactActivity struct {
+	// ID is unique across event sources, so it is a string.
+	ID        string `json:"id"`
+	AccountId int    `json:"-"`
+	ContactId int    `json:"-"`
+	Type      string `json:"type"`
+	// Data describes the event, e.g. the list a contact was added to or the changed attributes.
+	Data       map[string]any `json:"data"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// ActivityQuery selects a page of the timeline of a contact, newest first.
+type ActivityQuery struct {
+	AccountId   int
+	ContactUUID string
+	// Types keeps only the events of these types. Empty means every type.
+	Types []string
+	// Cursor is the next_cursor of the previous page, empty for the first page.
+	Cursor string
+	Limit  int
+}
+
+// ActivityCursor is the position of the last event of a timeline page.
+type ActivityCursor struct {
+	OccurredAt time.Time
+	ID         string
+}
+
+type ActivityPage struct {
+	Activities []ContactActivity `json:"activities"`
+	// NextCursor fetches the following page. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// Event is a custom event about a contact, such as purchased, sent by the account. The contact is named by
+// its email or its external id.
+type Event struct {
+	Name       string `json:"name" validate:"required,max=100"`
+	Email      string `json:"email" validate:"required_without=ExternalId,omitempty,email,max=254"`
+	ExternalId string `json:"external_id" validate:"max=255"`
+	// Properties describe the event, e.g. the amount of a purchase.
+	Properties map[string]any `json:"properties"`
+	// OccurredAt defaults to the time the event is received.
+	OccurredAt *time.Time `json:"occurred_at"`
+	// IdempotencyKey makes sending an event again a no-op, so that clients can retry safely. Keys are unique
+	// per account.
+	IdempotencyKey string `json:"idempotency_key" validate:"max=255"`
+}
+
+// EventBatch is the body of a request sending events.
+type EventBatch struct {
+	Events []Event `json:"events" validate:"required,min=1,max=100,dive"`
+}
+
+// EventResult tells what became of one event of a batch.
+type EventResult struct {
+	Index int `json:"index"`
+	// Status is accepted, duplicate for an idempotency key that was used before, or rejected.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type EventBatchResult struct {
+	Accepted   int           `json:"accepted"`
+	Duplicates int           `json:"duplicates"`
+	Rejected   int           `json:"rejected"`
+	Results    []EventResult `json:"results"`
+}
+
+// ContactEvent is a stored custom event.
+type ContactEvent struct {
+	ID             int64
+	AccountId      int
+	ContactId      int
+	Name           string
+	Properties     map[string]any
+	IdempotencyKey string
+	OccurredAt     time.Time
+	ReceivedAt     time.Time
+}
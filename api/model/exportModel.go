@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// ExportRequest selects the rows and columns of an export.
+type ExportRequest struct {
+	// Type is contacts for every contact, list or segment for their contacts, or suppression for the addresses
+	// that must not be mailed.
+	Type        string `json:"type" validate:"required,oneof=contacts list segment suppression"`
+	ListUUID    string `json:"list_uuid" validate:"required_if=Type list,max=64"`
+	SegmentUUID string `json:"segment_uuid" validate:"required_if=Type segment,max=64"`
+	// Format is csv or jsonl, one JSON object per line.
+	Format string `json:"format" validate:"required,oneof=csv jsonl"`
+	// Columns are taken in order. Contact exports accept attributes.<key> for custom attributes. Empty means
+	// every standard column.
+	Columns []string `json:"columns" validate:"max=50,dive,required,max=100"`
+}
+
+// Export is an export run in the background, whose file is downloaded through a time-limited link.
+type Export struct {
+	ID        int    `json:"id"`
+	UUID      string `json:"uuid"`
+	AccountId int    `json:"account_id"`
+	ExportRequest
+	// Status is pending, running, completed, failed or expired.
+	Status string `json:"status"`
+	Rows   int    `json:"rows"`
+	Error  string `json:"error,omitempty"`
+	// DownloadURL is set while a completed export can be downloaded.
+	DownloadURL string     `json:"download_url,omitempty"`
+	FilePath    string     `json:"-"`
+	IP          string     `json:"-"`
+	UserAgent   string     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// Suppression is an address that must not be mailed, because it unsubscribed, bounced or complained.
+type Suppression struct {
+	Email        string
+	Reason       string
+	SuppressedAt time.Time
+}
+
+// AuditEvent records an action on the data of an account, such as an export.
+type AuditEvent struct {
+	ID        int64
+	AccountId int
+	// Action is a dotted name such as export.downloaded.
+	Action string
+	// Target identifies what the action applied to, such as an export uuid.
+	Target    string
+	Details   map[string]any
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+}
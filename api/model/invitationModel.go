@@ -0,0 +1,45 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Invitation struct {
+	ID         int          `json:"id"`
+	UUID       string       `json:"uuid"`
+	AccountId  int          `json:"account_id"`
+	Email      string       `json:"email" validate:"required,email"`
+	Role       string       `json:"role" validate:"required,oneof=admin member viewer"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	AcceptedAt sql.NullTime `json:"accepted_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	// InvitedBy is the user sending the invitation, the account owner or one of its admins.
+	InvitedBy int `json:"-"`
+}
+
+type AcceptInvitation struct {
+	Token      string  `json:"token" validate:"required"`
+	Email      string  `json:"email" validate:"required,email"`
+	FirstName  string  `json:"firstname"`
+	MiddleName *string `json:"middlename"`
+	LastName   string  `json:"lastname"`
+	UserName   string  `json:"username"`
+	Password   []byte  `json:"password"`
+}
+
+type TeamMember struct {
+	ID        int       `json:"id"`
+	AccountId int       `json:"account_id"`
+	UserId    int       `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AccountAccess is the account a request acts on and the role the authenticated user has in it.
+type AccountAccess struct {
+	AccountId int
+	UserId    int
+	Role      string
+}
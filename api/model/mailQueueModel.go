@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// QueuedMail is a marketing mail waiting in the mail queue to be sent, or one that failed for good.
+type QueuedMail struct {
+	ID        int64
+	AccountId int
+	ContactId int
+	// ContactStatus is the status of the contact when the mail was claimed.
+	ContactStatus   string
+	Email           string
+	Subject         string
+	Body            string
+	PreferencesLink string
+	// MessageId identifies the mail in the bounces, complaints and opens reported about it; it stays the
+	// same when the mail is sent again.
+	MessageId string
+	// Data describes where the mail comes from, such as the workflow and step, and is kept in the sent event
+	// of the mail.
+	Data map[string]any
+	// Status is queued until the mail is sent, when it leaves the queue, or failed once it is given up.
+	Status        string
+	Attempts      int
+	Error         string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
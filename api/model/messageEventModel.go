@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// MessageEvent is something that happened to a message sent to a contact, such as sent, bounced or complained.
+type MessageEvent struct {
+	ID           int64
+	AccountId    int
+	ContactId    int
+	ContactUUID  string
+	ContactEmail string
+	Type         string
+	Data         map[string]any
+	OccurredAt   time.Time
+}
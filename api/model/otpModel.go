@@ -3,9 +3,12 @@ package model
 import "time"
 
 type OTP struct {
-	Id       int       `json:"id"`
-	UUID     string    `json:"uuid"`
-	UserId   int       `json:"user_id"`
-	Token    string    `json:"token" validated:"required"`
+	Id     int    `json:"id"`
+	UUID   string `json:"uuid"`
+	UserId int    `json:"user_id"`
+	Token  string `json:"token" validate:"required"`
+	// Purpose names the flow the OTP was mailed for; an OTP only works in that flow and until ExpiresAt.
+	Purpose   string    `json:"-"`
+	ExpiresAt time.Time `json:"-"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// Topic groups campaigns by subject. Contacts receive every topic unless they opt out of it.
+type Topic struct {
+	ID          int       `json:"id"`
+	UUID        string    `json:"uuid"`
+	AccountId   int       `json:"account_id"`
+	Name        string    `json:"name" validate:"required,max=100"`
+	Description string    `json:"description" validate:"max=500"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Preferences is what a contact sees in the preference center.
+type Preferences struct {
+	Email        string            `json:"email"`
+	FirstName    string            `json:"firstname"`
+	LastName     string            `json:"lastname"`
+	Status       string            `json:"status"`
+	SnoozedUntil *time.Time        `json:"snoozed_until"`
+	Topics       []TopicPreference `json:"topics"`
+	// Lists are the lists the contact is on.
+	Lists []ListPreference `json:"lists"`
+}
+
+type TopicPreference struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Subscribed  bool   `json:"subscribed"`
+}
+
+type ListPreference struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// UpdatePreferences changes the preferences of the contact the token was issued for. Fields that are
+// not set are left as they are.
+type UpdatePreferences struct {
+	Token     string  `json:"-"`
+	FirstName *string `json:"firstname" validate:"omitempty,max=100"`
+	LastName  *string `json:"lastname" validate:"omitempty,max=100"`
+	// Topics are the UUIDs of the topics to receive; the contact opts out of the others.
+	Topics []string `json:"topics" validate:"max=100"`
+	// Lists are the UUIDs of the lists to stay on; the contact leaves the others.
+	Lists []string `json:"lists" validate:"max=100"`
+	// SnoozeDays pauses every campaign for that many days. Zero ends a pause.
+	SnoozeDays *int `json:"snooze_days" validate:"omitempty,min=0,max=365"`
+	// Unsubscribe stops every email, whatever the other preferences.
+	Unsubscribe bool `json:"unsubscribe"`
+}
+
+type PreferenceLink struct {
+	Link      string    `json:"link"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// AccountQuota holds the effective limits of an account: the limits of its plan with any per-account overrides applied.
+type AccountQuota struct {
+	UserId            int     `json:"user_id"`
+	Plan              string  `json:"plan"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	RequestBurst      int     `json:"request_burst"`
+	MessagesPerHour   int     `json:"messages_per_hour"`
+	MessagesPerDay    int     `json:"messages_per_day"`
+}
+
+// QuotaOverride puts an account on a plan and overrides some of the plan's limits. Nil limits use the plan's.
+type QuotaOverride struct {
+	UserId            int
+	Plan              string
+	RequestsPerSecond *float64
+	RequestBurst      *int
+	MessagesPerHour   *int
+	MessagesPerDay    *int
+}
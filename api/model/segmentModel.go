@@ -0,0 +1,27 @@
+package model
+
+import (
+	"email-marketing-service/api/segment"
+	"time"
+)
+
+type Segment struct {
+	ID        int          `json:"id"`
+	UUID      string       `json:"uuid"`
+	AccountId int          `json:"account_id"`
+	Name      string       `json:"name" validate:"required,max=100"`
+	Rules     segment.Rule `json:"rules"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// SegmentRules is the body of a preview request for rules that have not been saved yet.
+type SegmentRules struct {
+	Rules segment.Rule `json:"rules"`
+}
+
+// SegmentPreview shows how many contacts match a segment and a few of them.
+type SegmentPreview struct {
+	Count  int       `json:"count"`
+	Sample []Contact `json:"sample"`
+}
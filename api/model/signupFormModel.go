@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// SignupForm is a hosted form that signs visitors up to a list.
+type SignupForm struct {
+	ID   int    `json:"id"`
+	UUID string `json:"uuid"`
+	// PublicId identifies the form in the URLs of its hosted page and submissions.
+	PublicId  string `json:"public_id"`
+	AccountId int    `json:"account_id"`
+	Name      string `json:"name" validate:"required,max=100"`
+	// Title is the heading of the hosted page.
+	Title    string      `json:"title" validate:"max=200"`
+	ListUUID string      `json:"list_uuid" validate:"required"`
+	ListId   int         `json:"-"`
+	Fields   []FormField `json:"fields" validate:"max=20,dive"`
+	// DoubleOptIn makes new subscribers confirm their address before they join the list.
+	DoubleOptIn bool `json:"double_opt_in"`
+	// RedirectURL is where the hosted page sends visitors after signing up instead of showing SuccessMessage.
+	RedirectURL    string    `json:"redirect_url" validate:"omitempty,http_url,max=2048"`
+	SuccessMessage string    `json:"success_message" validate:"max=500"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// FormField is an input of a signup form besides the email, which every form asks for. The value is stored
+// in the contact attribute named Attribute, except for firstname and lastname, which set the contact's name.
+type FormField struct {
+	Attribute string `json:"attribute" validate:"required,max=64"`
+	Label     string `json:"label" validate:"required,max=100"`
+	Type      string `json:"type" validate:"required,oneof=text number date checkbox"`
+	Required  bool   `json:"required"`
+}
+
+// FormSubmission is a visitor signing up through the form with FormPublicId. Fields holds the values
+// of the form's fields by attribute.
+type FormSubmission struct {
+	FormPublicId string         `json:"-"`
+	Email        string         `json:"email" validate:"required,email,max=254"`
+	Fields       map[string]any `json:"fields"`
+	// Honeypot is a field hidden from people; a value means the form was filled in by a bot.
+	Honeypot  string `json:"_hp"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// FormSubmitted tells the client what to show after a successful submission.
+type FormSubmitted struct {
+	Message     string `json:"message"`
+	RedirectURL string `json:"redirect_url,omitempty"`
+}
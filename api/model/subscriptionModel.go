@@ -0,0 +1,40 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Subscribe is a signup through a public form for the list with ListPublicId.
+type Subscribe struct {
+	ListPublicId string `json:"-"`
+	Email        string `json:"email" validate:"required,email,max=254"`
+	FirstName    string `json:"firstname" validate:"max=100"`
+	LastName     string `json:"lastname" validate:"max=100"`
+	// Attributes are set on contacts created by the signup.
+	Attributes map[string]any `json:"-"`
+	// Source names the form or page the signup came from.
+	Source    string `json:"source" validate:"max=100"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type ConfirmSubscription struct {
+	Token string `json:"token" validate:"required"`
+	IP    string `json:"-"`
+}
+
+// SubscriptionConsent records a signup and its confirmation as proof that the contact asked for the emails.
+type SubscriptionConsent struct {
+	ID          int            `json:"id"`
+	UUID        string         `json:"uuid"`
+	AccountId   int            `json:"account_id"`
+	ContactId   int            `json:"contact_id"`
+	ListId      int            `json:"list_id"`
+	Source      string         `json:"source"`
+	IP          string         `json:"ip"`
+	UserAgent   string         `json:"user_agent"`
+	RequestedAt time.Time      `json:"requested_at"`
+	ConfirmedAt sql.NullTime   `json:"confirmed_at"`
+	ConfirmedIP sql.NullString `json:"confirmed_ip"`
+}
@@ -30,7 +30,68 @@ type ForgetPassword struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+// VerifyUser confirms the email of a new account with the OTP mailed to it.
+type VerifyUser struct {
+	Email string `json:"email" validate:"required,email"`
+	Token string `json:"token" validate:"required"`
+}
+
 type ResetPassword struct {
-	Token    string `json:"token" validated:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type UserProfile struct {
+	ID         int          `json:"id"`
+	UUID       string       `json:"uuid"`
+	FirstName  string       `json:"firstname"`
+	MiddleName *string      `json:"middlename"`
+	LastName   string       `json:"lastname"`
+	UserName   string       `json:"username"`
+	Email      string       `json:"email"`
+	Verified   bool         `json:"verified"`
+	CreatedAt  time.Time    `json:"created_at"`
+	VerifiedAt sql.NullTime `json:"verified_at"`
+	UpdatedAt  sql.NullTime `json:"updated_at"`
+}
+
+type UpdateProfile struct {
+	ID         int     `json:"-"`
+	FirstName  string  `json:"firstname" validate:"required"`
+	MiddleName *string `json:"middlename"`
+	LastName   string  `json:"lastname" validate:"required"`
+	UserName   string  `json:"username" validate:"required"`
+}
+
+type ChangePassword struct {
+	ID              int    `json:"-"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type ChangeEmail struct {
+	ID    int    `json:"-"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+type VerifyEmailChange struct {
+	ID    int    `json:"-"`
+	Token string `json:"token" validate:"required"`
+}
+
+type DeleteAccount struct {
+	ID       int    `json:"-"`
 	Password string `json:"password" validate:"required"`
 }
+
+type CancelAccountDeletion struct {
+	Email string `json:"email" validate:"required,email"`
+	Token string `json:"token" validate:"required"`
+}
+
+// SessionState is what the auth middleware needs to decide whether an otherwise valid token may still be used.
+type SessionState struct {
+	DeletedAt         sql.NullTime
+	SessionsRevokedAt sql.NullTime
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"email-marketing-service/api/workflow"
+	"time"
+)
+
+// Workflow runs a sequence of steps, such as a drip campaign, for every contact that meets its trigger.
+type Workflow struct {
+	ID        int    `json:"id"`
+	UUID      string `json:"uuid"`
+	AccountId int    `json:"account_id"`
+	Name      string `json:"name" validate:"required,max=100"`
+	// Status is draft, active or paused. Only active workflows start runs and move them on; the runs of a
+	// paused workflow wait where they are.
+	Status    string           `json:"status" validate:"omitempty,oneof=draft active paused"`
+	Trigger   workflow.Trigger `json:"trigger"`
+	Steps     []workflow.Step  `json:"steps"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// WorkflowRun is where a contact is in a workflow.
+type WorkflowRun struct {
+	ID          int64  `json:"id"`
+	WorkflowId  int    `json:"-"`
+	AccountId   int    `json:"-"`
+	ContactId   int    `json:"-"`
+	ContactUUID string `json:"contact_uuid"`
+	// Status is active while the run goes on, then completed after the last step, exited after an exit step
+	// or when the contact unsubscribed, or failed.
+	Status string `json:"status"`
+	// StepId is the step run next, at NextRunAt.
+	StepId      string     `json:"step_id"`
+	Error       string     `json:"error,omitempty"`
+	NextRunAt   time.Time  `json:"next_run_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
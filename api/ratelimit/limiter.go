@@ -0,0 +1,74 @@
+// Package ratelimit provides token bucket rate limiting with pluggable storage.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket: it refills at Rate tokens per second and holds at most Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerHour returns a limit allowing n events per hour, all of which may be used at once.
+func PerHour(n int) Limit {
+	return Limit{Rate: float64(n) / 3600, Burst: n}
+}
+
+// PerDay returns a limit allowing n events per day, all of which may be used at once.
+func PerDay(n int) Limit {
+	return Limit{Rate: float64(n) / 86400, Burst: n}
+}
+
+// Result is the outcome of taking tokens from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// Limiter takes n tokens from the bucket identified by key. Tokens are only taken when all n are available.
+// Refund puts n tokens taken by Allow back, never filling the bucket beyond its burst.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit, n int) (Result, error)
+	Refund(ctx context.Context, key string, limit Limit, n int) error
+}
+
+// take applies the token bucket algorithm to a bucket holding tokens that was last updated at updatedAt.
+// It returns the result and the number of tokens left in the bucket.
+func take(tokens float64, updatedAt time.Time, now time.Time, limit Limit, n int) (Result, float64) {
+	burst := float64(limit.Burst)
+
+	elapsed := now.Sub(updatedAt).Seconds()
+	if elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*limit.Rate)
+	}
+
+	result := Result{Limit: limit.Burst}
+
+	if float64(n) <= tokens {
+		tokens -= float64(n)
+		result.Allowed = true
+	} else if limit.Rate > 0 && float64(n) <= burst {
+		result.RetryAfter = secondsToDuration((float64(n) - tokens) / limit.Rate)
+	} else {
+		// the request can never be satisfied, e.g. a rate of zero or more tokens than the burst
+		result.RetryAfter = -1
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	if limit.Rate > 0 {
+		result.ResetAfter = secondsToDuration((burst - tokens) / limit.Rate)
+	}
+
+	return result, tokens
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// limiterStore creates a limiter and a function moving a bucket back in time, as if d had passed since it
+// was last used.
+type limiterStore func(t *testing.T) (Limiter, func(key string, d time.Duration))
+
+// slow refills so slowly that no token comes back while a test runs.
+var slow = Limit{Rate: 0.0001, Burst: 3}
+
+func testKey(t *testing.T) string {
+	return fmt.Sprintf("test:%s:%d", t.Name(), time.Now().UnixNano())
+}
+
+func allow(t *testing.T, l Limiter, key string, limit Limit, n int) Result {
+	t.Helper()
+
+	result, err := l.Allow(context.Background(), key, limit, n)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+
+	return result
+}
+
+func testLimiter(t *testing.T, store limiterStore) {
+	t.Run("burst", func(t *testing.T) {
+		l, _ := store(t)
+		key := testKey(t)
+
+		for i := 0; i < slow.Burst; i++ {
+			if result := allow(t, l, key, slow, 1); !result.Allowed || result.Remaining != slow.Burst-1-i {
+				t.Fatalf("request %d: %+v", i, result)
+			}
+		}
+
+		result := allow(t, l, key, slow, 1)
+		if result.Allowed || result.Remaining != 0 || result.RetryAfter <= 0 {
+			t.Fatalf("expected the empty bucket to deny, got %+v", result)
+		}
+	})
+
+	t.Run("more than the burst", func(t *testing.T) {
+		l, _ := store(t)
+		key := testKey(t)
+
+		result := allow(t, l, key, slow, slow.Burst+1)
+		if result.Allowed || result.RetryAfter != -1 {
+			t.Fatalf("expected a request that can never be allowed, got %+v", result)
+		}
+
+		if result := allow(t, l, key, slow, slow.Burst); !result.Allowed {
+			t.Fatalf("a denied request must not take tokens, got %+v", result)
+		}
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		l, age := store(t)
+		key := testKey(t)
+		limit := Limit{Rate: 1, Burst: 2}
+
+		allow(t, l, key, limit, 2)
+
+		age(key, 1500*time.Millisecond)
+
+		if result := allow(t, l, key, limit, 1); !result.Allowed {
+			t.Fatalf("expected a token after a second, got %+v", result)
+		}
+
+		if result := allow(t, l, key, limit, 1); result.Allowed {
+			t.Fatalf("expected a single token after a second and a half, got %+v", result)
+		}
+
+		age(key, time.Hour)
+
+		if result := allow(t, l, key, limit, 2); !result.Allowed {
+			t.Fatalf("expected a full bucket after an hour, got %+v", result)
+		}
+
+		if result := allow(t, l, key, limit, 1); result.Allowed {
+			t.Fatalf("the bucket must not fill beyond its burst, got %+v", result)
+		}
+	})
+
+	t.Run("refund", func(t *testing.T) {
+		l, _ := store(t)
+		key := testKey(t)
+
+		allow(t, l, key, slow, slow.Burst)
+
+		if err := l.Refund(context.Background(), key, slow, 2); err != nil {
+			t.Fatalf("Refund: %v", err)
+		}
+
+		if result := allow(t, l, key, slow, 2); !result.Allowed {
+			t.Fatalf("expected the refunded tokens back, got %+v", result)
+		}
+
+		if err := l.Refund(context.Background(), key, slow, 10); err != nil {
+			t.Fatalf("Refund: %v", err)
+		}
+
+		if result := allow(t, l, key, slow, slow.Burst); !result.Allowed || result.Remaining != 0 {
+			t.Fatalf("a refund must fill the bucket up to its burst only, got %+v", result)
+		}
+	})
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	testLimiter(t, func(t *testing.T) (Limiter, func(string, time.Duration)) {
+		l := NewMemoryLimiter()
+
+		return l, func(key string, d time.Duration) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.buckets[key].updatedAt = l.buckets[key].updatedAt.Add(-d)
+		}
+	})
+}
+
+func TestMemoryLimiterCleanup(t *testing.T) {
+	l := NewMemoryLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 1, Burst: 2}
+	allow(t, l, "full", limit, 0)
+	allow(t, l, "drained", limit, 2)
+
+	l.Cleanup()
+
+	if _, ok := l.buckets["full"]; ok {
+		t.Fatal("a full bucket should be dropped")
+	}
+	if _, ok := l.buckets["drained"]; !ok {
+		t.Fatal("a drained bucket must be kept")
+	}
+
+	now = now.Add(2 * time.Second)
+	l.Cleanup()
+
+	if len(l.buckets) != 0 {
+		t.Fatalf("a refilled bucket should be dropped, got %d buckets", len(l.buckets))
+	}
+}
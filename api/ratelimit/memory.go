@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     Limit
+}
+
+// MemoryLimiter keeps buckets in process memory. It is suitable for a single instance only. Unlike the
+// PostgresLimiter it does not take part in transactions, so tokens taken in one that rolls back stay taken.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	result, tokens := take(b.tokens, b.updatedAt, now, limit, n)
+	b.tokens = tokens
+	b.updatedAt = now
+	b.limit = limit
+
+	return result, nil
+}
+
+func (l *MemoryLimiter) Refund(ctx context.Context, key string, limit Limit, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+float64(n))
+	}
+
+	return nil
+}
+
+// Cleanup drops buckets that have been idle long enough to be full again, keeping memory bounded.
+// A dropped bucket is recreated full, so this does not change any outcome.
+func (l *MemoryLimiter) Cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updatedAt).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
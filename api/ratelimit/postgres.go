@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"time"
+)
+
+// PostgresLimiter stores buckets in the rate_limit_buckets table so that limits are shared
+// between all instances of the service. Each call locks the bucket row for the duration of a short transaction.
+// Calls made inside a transaction take part in it: tokens taken are given back if it rolls back, and the
+// bucket stays locked until it ends.
+type PostgresLimiter struct {
+	DB *sql.DB
+}
+
+func NewPostgresLimiter(db *sql.DB) *PostgresLimiter {
+	return &PostgresLimiter{DB: db}
+}
+
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	var result Result
+
+	err := database.NewTxManager(l.DB).WithinTx(ctx, func(ctx context.Context) error {
+		conn := database.Conn(ctx, l.DB)
+
+		_, err := conn.ExecContext(ctx, "INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, now()) ON CONFLICT (key) DO NOTHING", key, limit.Burst)
+		if err != nil {
+			return err
+		}
+
+		var (
+			tokens    float64
+			updatedAt time.Time
+			now       time.Time
+		)
+
+		err = conn.QueryRowContext(ctx, "SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE", key).Scan(&tokens, &updatedAt, &now)
+		if err != nil {
+			return err
+		}
+
+		result, tokens = take(tokens, updatedAt, now, limit, n)
+
+		_, err = conn.ExecContext(ctx, "UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1", key, tokens, now)
+		return err
+	})
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+func (l *PostgresLimiter) Refund(ctx context.Context, key string, limit Limit, n int) error {
+	_, err := database.Conn(ctx, l.DB).ExecContext(ctx, "UPDATE rate_limit_buckets SET tokens = LEAST(tokens + $2, $3) WHERE key = $1", key, n, limit.Burst)
+	return err
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/database/dbtest"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPostgresLimiter(t *testing.T) {
+	testLimiter(t, func(t *testing.T) (Limiter, func(string, time.Duration)) {
+		db := dbtest.Open(t)
+
+		return NewPostgresLimiter(db), func(key string, d time.Duration) {
+			_, err := db.Exec("UPDATE rate_limit_buckets SET updated_at = updated_at - $2 * interval '1 microsecond' WHERE key = $1", key, d.Microseconds())
+			if err != nil {
+				t.Fatalf("age bucket: %v", err)
+			}
+		}
+	})
+}
+
+func TestPostgresLimiterRollsBackWithTheTransaction(t *testing.T) {
+	db := dbtest.Open(t)
+	l := NewPostgresLimiter(db)
+	key := testKey(t)
+
+	failure := errors.New("failed")
+
+	err := database.NewTxManager(db).WithinTx(context.Background(), func(ctx context.Context) error {
+		result, err := l.Allow(ctx, key, slow, slow.Burst)
+		if err != nil {
+			return err
+		}
+
+		if !result.Allowed {
+			t.Fatalf("expected a full bucket, got %+v", result)
+		}
+
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithinTx = %v, want %v", err, failure)
+	}
+
+	if result := allow(t, l, key, slow, slow.Burst); !result.Allowed {
+		t.Fatalf("the tokens taken in the rolled back transaction should be back, got %+v", result)
+	}
+}
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"email-marketing-service/api/database"
 	"email-marketing-service/api/model"
 	"fmt"
+	"time"
 )
 
 type UserRepository struct {
@@ -15,11 +17,11 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
-func (r *UserRepository) CreateUser(d *model.User) (*model.User, error) {
+func (r *UserRepository) CreateUser(ctx context.Context, d *model.User) (*model.User, error) {
 
-	query := "INSERT INTO users (uuid,firstname,middlename,lastname,username, email,password) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id"
+	query := "INSERT INTO users (uuid,firstname,middlename,lastname,username, email,password,verified,verified_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id"
 
-	err := r.DB.QueryRow(query, d.UUID, d.FirstName, d.MiddleName, d.LastName, d.UserName, d.Email, d.Password).Scan(&d.ID)
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.FirstName, d.MiddleName, d.LastName, d.UserName, d.Email, d.Password, d.Verified, d.VerifiedAt).Scan(&d.ID)
 
 	if err != nil {
 		return nil, err
@@ -31,12 +33,12 @@ func (r *UserRepository) CreateUser(d *model.User) (*model.User, error) {
 	return d, nil
 }
 
-func (r *UserRepository) CheckIfEmailAlreadyExists(d *model.User) (bool, error) {
+func (r *UserRepository) CheckIfEmailAlreadyExists(ctx context.Context, d *model.User) (bool, error) {
 
-	query := "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)"
+	query := "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND deleted_at IS NULL)"
 
 	var exists bool
-	err := r.DB.QueryRow(query, d.Email).Scan(&exists)
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.Email).Scan(&exists)
 
 	if err != nil && err != sql.ErrNoRows {
 		return false, err
@@ -45,9 +47,9 @@ func (r *UserRepository) CheckIfEmailAlreadyExists(d *model.User) (bool, error)
 	return exists, nil
 }
 
-func (r *UserRepository) VerifyUserAccount(d *model.User) error {
-	query := "UPDATE users SET verified = $2, verified_at = $3 WHERE id = $1"
-	_, err := r.DB.Exec(query, d.ID, d.Verified, d.VerifiedAt)
+func (r *UserRepository) VerifyUserAccount(ctx context.Context, d *model.User) error {
+	query := "UPDATE users SET verified = $2, verified_at = $3, updated_at = now() WHERE id = $1"
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.Verified, d.VerifiedAt)
 	if err != nil {
 		return err
 	}
@@ -55,11 +57,11 @@ func (r *UserRepository) VerifyUserAccount(d *model.User) error {
 	return nil
 }
 
-func (r *UserRepository) Login(d *model.User) (*model.User, error) {
+func (r *UserRepository) Login(ctx context.Context, d *model.User) (*model.User, error) {
 
-	// query := "SELECT * FROM users WHERE email = $1 AND verified = true"
-	query := "SELECT id, uuid, firstname, middlename, lastname, username, email, password, verified, verified_at FROM users WHERE email = $1 AND verified = true"
-	row := r.DB.QueryRow(query, d.Email)
+	// query := "SELECT * FROM users WHERE email = $1 AND verified = true AND deleted_at IS NULL"
+	query := "SELECT id, uuid, firstname, middlename, lastname, username, email, password, verified, verified_at FROM users WHERE email = $1 AND verified = true AND deleted_at IS NULL"
+	row := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.Email)
 
 	err := row.Scan(&d.ID, &d.UUID, &d.FirstName, &d.MiddleName, &d.LastName, &d.UserName, &d.Email, &d.Password, &d.Verified, &d.VerifiedAt)
 	if err != nil {
@@ -72,12 +74,12 @@ func (r *UserRepository) Login(d *model.User) (*model.User, error) {
 	return d, nil
 }
 
-func (r *UserRepository) FindUserById(d *model.User) (*model.User, error) {
+func (r *UserRepository) FindUserById(ctx context.Context, d *model.User) (*model.User, error) {
 
-	query := "SELECT id, username, email FROM users WHERE id = $1"
-	row := r.DB.QueryRow(query, d.ID)
+	query := "SELECT id, uuid, firstname, middlename, lastname, username, email, verified, created_at, verified_at, updated_at FROM users WHERE id = $1"
+	row := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.ID)
 
-	err := row.Scan(&d.ID, &d.UserName, &d.Email)
+	err := row.Scan(&d.ID, &d.UUID, &d.FirstName, &d.MiddleName, &d.LastName, &d.UserName, &d.Email, &d.Verified, &d.CreatedAt, &d.VerifiedAt, &d.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err // User not found, return nil without an error
@@ -88,10 +90,10 @@ func (r *UserRepository) FindUserById(d *model.User) (*model.User, error) {
 	return d, nil
 }
 
-func (r *UserRepository) FindUserByEmail(d *model.User) (*model.User, error) {
+func (r *UserRepository) FindUserByEmail(ctx context.Context, d *model.User) (*model.User, error) {
 
-	query := "SELECT id, username, email FROM users WHERE email = $1"
-	row := r.DB.QueryRow(query, d.Email)
+	query := "SELECT id, username, email FROM users WHERE email = $1 AND deleted_at IS NULL"
+	row := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.Email)
 
 	err := row.Scan(&d.ID, &d.UserName, &d.Email)
 	if err != nil {
@@ -104,28 +106,176 @@ func (r *UserRepository) FindUserByEmail(d *model.User) (*model.User, error) {
 	return d, nil
 }
 
-func (r *UserRepository) ResetPassword(d *model.User) error {
-	db, err := database.InitDB()
+func (r *UserRepository) ResetPassword(ctx context.Context, d *model.User) error {
+	query := "UPDATE users SET password = $1, updated_at = now() WHERE id = $2"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.Password, d.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepository) FindUserPasswordById(ctx context.Context, d *model.User) (*model.User, error) {
+
+	query := "SELECT id, password FROM users WHERE id = $1"
+	row := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.ID)
+
+	err := row.Scan(&d.ID, &d.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, d *model.UpdateProfile) error {
+	query := "UPDATE users SET firstname = $2, middlename = $3, lastname = $4, username = $5, updated_at = now() WHERE id = $1"
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.FirstName, d.MiddleName, d.LastName, d.UserName)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
-	query := "UPDATE users SET password = $1 WHERE id = $2"
+	return nil
+}
+
+// SetPendingEmail stores an email address that replaces the current one once it has been verified.
+func (r *UserRepository) SetPendingEmail(ctx context.Context, d *model.ChangeEmail) error {
+	query := "UPDATE users SET pending_email = $2, updated_at = now() WHERE id = $1"
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.Email)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange replaces the email address with the pending one and returns the new address.
+func (r *UserRepository) ConfirmEmailChange(ctx context.Context, d *model.User) (*model.User, error) {
+	query := `UPDATE users SET email = pending_email, pending_email = NULL, updated_at = now()
+		WHERE id = $1 AND pending_email IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM users other WHERE other.email = users.pending_email AND other.id <> users.id)
+		RETURNING email`
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.ID).Scan(&d.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no pending email change or email already in use: %w", err)
+		}
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// SoftDeleteUser marks the account as deleted, revokes every session issued so far and schedules its purge.
+func (r *UserRepository) SoftDeleteUser(ctx context.Context, d *model.User, purgeAfter time.Time) error {
+	query := "UPDATE users SET deleted_at = now(), sessions_revoked_at = now(), purge_after = $2, updated_at = now() WHERE id = $1 AND deleted_at IS NULL"
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, purgeAfter)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RestoreUser cancels a pending deletion, unless the email address has been taken by a new account in the meantime.
+func (r *UserRepository) RestoreUser(ctx context.Context, d *model.User) error {
+	query := `UPDATE users SET deleted_at = NULL, purge_after = NULL, updated_at = now()
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM users other WHERE other.email = users.email AND other.id <> users.id AND other.deleted_at IS NULL)`
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID)
+	if err != nil {
+		return err
+	}
 
-	_, err = db.Exec(query, d.Password, d.ID)
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if affected == 0 {
+		return ErrAccountNotRestorable
+	}
+
 	return nil
 }
 
-func (r *UserRepository) FindAllUsers() ([]model.User, error) {
+func (r *UserRepository) FindSessionState(ctx context.Context, d *model.User) (*model.SessionState, error) {
+
+	query := "SELECT deleted_at, sessions_revoked_at FROM users WHERE id = $1"
+
+	var state model.SessionState
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.ID).Scan(&state.DeletedAt, &state.SessionsRevokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// FindPurgeableUsers returns the ids of the accounts PurgeDeletedUsers would remove and locks them, so that
+// they are not restored before the purge in the same transaction is done.
+func (r *UserRepository) FindPurgeableUsers(ctx context.Context) ([]int, error) {
+
+	query := "SELECT id FROM users WHERE deleted_at IS NOT NULL AND purge_after <= now() ORDER BY id FOR UPDATE"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// PurgeDeletedUsers permanently removes the personal data of accounts whose grace period has ended
+// and returns how many accounts were purged. Rows referencing the users cascade.
+// It should run in a transaction so that a failure does not leave an account partially purged.
+func (r *UserRepository) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	conn := database.Conn(ctx, r.DB)
+
+	purgeable := "SELECT id FROM users WHERE deleted_at IS NOT NULL AND purge_after <= now()"
+
+	queries := []string{
+		"DELETE FROM auth_attempts WHERE scope = 'account' AND key IN (SELECT lower(email) FROM users WHERE id IN (" + purgeable + "))",
+		"DELETE FROM invitations WHERE lower(email) IN (SELECT lower(email) FROM users WHERE id IN (" + purgeable + "))",
+		"DELETE FROM otp WHERE user_id IN (" + purgeable + ")",
+	}
+
+	for _, query := range queries {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := conn.ExecContext(ctx, "DELETE FROM users WHERE id IN ("+purgeable+")")
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(purged), nil
+}
+
+func (r *UserRepository) FindAllUsers(ctx context.Context) ([]model.User, error) {
 
 	query := "SELECT id, uuid, firstname, middlename, lastname, username, email, password, verified, created_at, verified_at, updated_at, deleted_at FROM users"
 
-	rows, err := r.DB.Query(query)
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/database/dbtest"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/segment"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPurgeDeletedUsersRemovesAccountsWithCampaigns(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	users := NewUserRepository(db)
+	user, err := users.CreateUser(ctx, &model.User{
+		UUID:      uuid.New().String(),
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		UserName:  "ada",
+		Email:     fmt.Sprintf("purge-%s@example.com", uuid.New().String()),
+		Password:  []byte("hash"),
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	list, err := NewListRepository(db).CreateList(ctx, &model.ContactList{UUID: uuid.New().String(), PublicId: uuid.New().String(), AccountId: user.ID, Name: "News"})
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+
+	segments := NewSegmentRepository(db)
+	seg, err := segments.CreateSegment(ctx, &model.Segment{UUID: uuid.New().String(), AccountId: user.ID, Name: "Everyone", Rules: segment.Rule{Type: "and"}})
+	if err != nil {
+		t.Fatalf("CreateSegment: %v", err)
+	}
+
+	campaigns := NewCampaignRepository(db)
+	campaign, err := campaigns.CreateCampaign(ctx, &model.Campaign{UUID: uuid.New().String(), AccountId: user.ID, Name: "Launch", ListId: &list.ID, SegmentId: &seg.ID, Status: "draft"})
+	if err != nil {
+		t.Fatalf("CreateCampaign: %v", err)
+	}
+
+	if err := users.SoftDeleteUser(ctx, user, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("SoftDeleteUser: %v", err)
+	}
+
+	err = database.NewTxManager(db).WithinTx(ctx, func(ctx context.Context) error {
+		_, err := users.PurgeDeletedUsers(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("PurgeDeletedUsers: %v", err)
+	}
+
+	if _, err := users.FindUserById(ctx, &model.User{ID: user.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("the account should be purged, got %v", err)
+	}
+
+	if _, err := campaigns.FindCampaignByUUID(ctx, &model.Campaign{UUID: campaign.UUID, AccountId: user.ID}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("the campaign should be purged with its account, got %v", err)
+	}
+}
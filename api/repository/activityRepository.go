@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type ActivityRepository struct {
+	DB *sql.DB
+}
+
+func NewActivityRepository(db *sql.DB) *ActivityRepository {
+	return &ActivityRepository{DB: db}
+}
+
+// logListChanges turns the (list_id, contact_id) rows of a data-modifying query named changed into activities of
+// the given type. ListRepository wraps its membership queries with it, so every way of joining or leaving a
+// list shows in the timeline.
+func logListChanges(activityType string) string {
+	return `INSERT INTO contact_activities (account_id, contact_id, type, data)
+		SELECT l.account_id, changed.contact_id, '` + activityType + `', jsonb_build_object('list_uuid', l.uuid, 'list_name', l.name)
+		FROM changed JOIN lists l ON l.id = changed.list_id`
+}
+
+func (r *ActivityRepository) RecordActivity(ctx context.Context, d *model.ContactActivity) error {
+	data, err := marshalAttributes(d.Data)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO contact_activities (account_id, contact_id, type, data) VALUES ($1,$2,$3,$4) RETURNING id, occurred_at"
+
+	var id int64
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.ContactId, d.Type, data).Scan(&id, &d.OccurredAt)
+
+	if err != nil {
+		return err
+	}
+
+	d.ID = fmt.Sprintf("a%d", id)
+
+	return nil
+}
+
+// timeline merges the activities, message events and custom events of contact $1. Ids are prefixed by their
+// source so that they are unique across all of them.
+const timeline = `SELECT 'a' || a.id AS id, a.type, a.data, a.occurred_at FROM contact_activities a WHERE a.contact_id = $1
+	UNION ALL
+	SELECT 'm' || e.id, e.type,
+		e.data || CASE WHEN cp.id IS NULL THEN '{}'::jsonb ELSE jsonb_build_object('campaign_uuid', cp.uuid, 'campaign_name', cp.name) END,
+		e.occurred_at
+	FROM message_events e LEFT JOIN campaigns cp ON cp.id = e.campaign_id WHERE e.contact_id = $1
+	UNION ALL
+	SELECT 'e' || ev.id, 'event', jsonb_build_object('name', ev.name, 'properties', ev.properties), ev.occurred_at
+	FROM contact_events ev WHERE ev.contact_id = $1`
+
+// FindActivities returns up to limit events of a contact, newest first, that come after the cursor if it is set
+// and have one of types if any are given.
+func (r *ActivityRepository) FindActivities(ctx context.Context, contactId int, types []string, cursor *model.ActivityCursor, limit int) ([]model.ContactActivity, error) {
+	args := []any{contactId}
+	where := "TRUE"
+
+	if len(types) > 0 {
+		args = append(args, pq.Array(types))
+		where += fmt.Sprintf(" AND t.type = ANY($%d)", len(args))
+	}
+
+	if cursor != nil {
+		args = append(args, cursor.OccurredAt, cursor.ID)
+		where += fmt.Sprintf(" AND (t.occurred_at, t.id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	args = append(args, limit)
+	query := fmt.Sprintf("SELECT t.id, t.type, t.data, t.occurred_at FROM (%s) t WHERE %s ORDER BY t.occurred_at DESC, t.id DESC LIMIT $%d", timeline, where, len(args))
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []model.ContactActivity{}
+
+	for rows.Next() {
+		activity := model.ContactActivity{ContactId: contactId}
+		var data []byte
+
+		if err := rows.Scan(&activity.ID, &activity.Type, &data, &activity.OccurredAt); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &activity.Data); err != nil {
+			return nil, fmt.Errorf("invalid data of activity %s: %w", activity.ID, err)
+		}
+
+		activities = append(activities, activity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return activities, nil
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+)
+
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+func (r *AuditRepository) RecordAuditEvent(ctx context.Context, d *model.AuditEvent) error {
+	details, err := marshalAttributes(d.Details)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO audit_log (account_id, action, target, details, ip, user_agent) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at"
+
+	return database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.Action, d.Target, details, d.IP, d.UserAgent).Scan(&d.ID, &d.CreatedAt)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"time"
+)
+
+type AuthAttemptRepository struct {
+	DB *sql.DB
+}
+
+func NewAuthAttemptRepository(db *sql.DB) *AuthAttemptRepository {
+	return &AuthAttemptRepository{DB: db}
+}
+
+// FindAttempt returns the attempt record for the scope/key pair, or nil when nothing has been recorded.
+func (r *AuthAttemptRepository) FindAttempt(ctx context.Context, scope string, key string) (*model.AuthAttempt, error) {
+
+	query := "SELECT scope, key, failures, last_failed_at, locked_until FROM auth_attempts WHERE scope = $1 AND key = $2"
+	row := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, scope, key)
+
+	var attempt model.AuthAttempt
+	err := row.Scan(&attempt.Scope, &attempt.Key, &attempt.Failures, &attempt.LastFailedAt, &attempt.LockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure increments the failure counter atomically. Counters whose last failure is older than
+// window start again from one.
+func (r *AuthAttemptRepository) RecordFailure(ctx context.Context, scope string, key string, window time.Duration) (*model.AuthAttempt, error) {
+
+	query := `INSERT INTO auth_attempts (scope, key, failures, last_failed_at) VALUES ($1, $2, 1, now())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN auth_attempts.last_failed_at < now() - make_interval(secs => $3) THEN 1 ELSE auth_attempts.failures + 1 END,
+			last_failed_at = now()
+		RETURNING scope, key, failures, last_failed_at, locked_until`
+
+	var attempt model.AuthAttempt
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, scope, key, window.Seconds()).Scan(&attempt.Scope, &attempt.Key, &attempt.Failures, &attempt.LastFailedAt, &attempt.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// Lock locks the scope/key pair until the given time and clears the failure counter.
+func (r *AuthAttemptRepository) Lock(ctx context.Context, scope string, key string, until time.Time) error {
+	query := "UPDATE auth_attempts SET failures = 0, locked_until = $3 WHERE scope = $1 AND key = $2"
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, scope, key, until)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *AuthAttemptRepository) ResetAttempts(ctx context.Context, scope string, key string) error {
+	query := "DELETE FROM auth_attempts WHERE scope = $1 AND key = $2"
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, scope, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+
+	"github.com/lib/pq"
+)
+
+type CampaignRepository struct {
+	DB *sql.DB
+}
+
+func NewCampaignRepository(db *sql.DB) *CampaignRepository {
+	return &CampaignRepository{DB: db}
+}
+
+// campaignSelect reads campaigns with the UUIDs of their list, segment and topic.
+const campaignSelect = `SELECT c.id, c.uuid, c.account_id, c.name, c.subject, c.body, c.list_id, l.uuid, c.segment_id, s.uuid, c.tags, c.topic_id, tp.uuid,
+		c.status, c.created_at, c.updated_at
+	FROM campaigns c LEFT JOIN lists l ON l.id = c.list_id LEFT JOIN segments s ON s.id = c.segment_id LEFT JOIN topics tp ON tp.id = c.topic_id`
+
+func scanCampaign(row scanner) (*model.Campaign, error) {
+	var campaign model.Campaign
+
+	err := row.Scan(&campaign.ID, &campaign.UUID, &campaign.AccountId, &campaign.Name, &campaign.Subject, &campaign.Body,
+		&campaign.ListId, &campaign.ListUUID, &campaign.SegmentId, &campaign.SegmentUUID, pq.Array(&campaign.Tags), &campaign.TopicId, &campaign.TopicUUID, &campaign.Status, &campaign.CreatedAt, &campaign.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &campaign, nil
+}
+
+func (r *CampaignRepository) CreateCampaign(ctx context.Context, d *model.Campaign) (*model.Campaign, error) {
+
+	query := "INSERT INTO campaigns (uuid, account_id, name, subject, body, list_id, segment_id, tags, topic_id, status) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id, created_at, updated_at"
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, d.Subject, d.Body, d.ListId, d.SegmentId, pq.Array(d.Tags), d.TopicId, d.Status).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *CampaignRepository) FindCampaignByUUID(ctx context.Context, d *model.Campaign) (*model.Campaign, error) {
+
+	query := campaignSelect + " WHERE c.uuid = $1 AND c.account_id = $2"
+
+	return scanCampaign(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+func (r *CampaignRepository) FindCampaigns(ctx context.Context, accountId int) ([]model.Campaign, error) {
+
+	query := campaignSelect + " WHERE c.account_id = $1 ORDER BY c.created_at DESC"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campaigns := []model.Campaign{}
+
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		campaigns = append(campaigns, *campaign)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return campaigns, nil
+}
+
+// UpdateCampaign changes the content, audience and topic of a draft campaign.
+func (r *CampaignRepository) UpdateCampaign(ctx context.Context, d *model.Campaign) error {
+
+	query := `UPDATE campaigns SET name = $3, subject = $4, body = $5, list_id = $6, segment_id = $7, tags = $8, topic_id = $9, updated_at = now()
+		WHERE uuid = $1 AND account_id = $2 AND status = 'draft'`
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.UUID, d.AccountId, d.Name, d.Subject, d.Body, d.ListId, d.SegmentId, pq.Array(d.Tags), d.TopicId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrCampaignNotDraft
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+)
+
+type ConsentRepository struct {
+	DB *sql.DB
+}
+
+func NewConsentRepository(db *sql.DB) *ConsentRepository {
+	return &ConsentRepository{DB: db}
+}
+
+const consentColumns = "id, uuid, account_id, contact_id, list_id, source, ip, user_agent, requested_at, confirmed_at, confirmed_ip"
+
+func scanConsent(row scanner) (*model.SubscriptionConsent, error) {
+	var consent model.SubscriptionConsent
+
+	err := row.Scan(&consent.ID, &consent.UUID, &consent.AccountId, &consent.ContactId, &consent.ListId, &consent.Source,
+		&consent.IP, &consent.UserAgent, &consent.RequestedAt, &consent.ConfirmedAt, &consent.ConfirmedIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+func (r *ConsentRepository) CreateConsent(ctx context.Context, d *model.SubscriptionConsent) (*model.SubscriptionConsent, error) {
+
+	query := "INSERT INTO subscription_consents (uuid, account_id, contact_id, list_id, source, ip, user_agent, confirmed_at, confirmed_ip) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id, requested_at"
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.ContactId, d.ListId, d.Source, d.IP, d.UserAgent, d.ConfirmedAt, d.ConfirmedIP).Scan(&d.ID, &d.RequestedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *ConsentRepository) FindConsentByUUID(ctx context.Context, consentUUID string) (*model.SubscriptionConsent, error) {
+
+	query := "SELECT " + consentColumns + " FROM subscription_consents WHERE uuid = $1"
+
+	return scanConsent(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, consentUUID))
+}
+
+// FindLatestConsent returns the most recent signup of a contact to a list.
+func (r *ConsentRepository) FindLatestConsent(ctx context.Context, contactId int, listId int) (*model.SubscriptionConsent, error) {
+
+	query := "SELECT " + consentColumns + " FROM subscription_consents WHERE contact_id = $1 AND list_id = $2 ORDER BY requested_at DESC LIMIT 1"
+
+	return scanConsent(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, contactId, listId))
+}
+
+// ConfirmConsent records the confirmation of a signup. Confirming twice keeps the first confirmation.
+func (r *ConsentRepository) ConfirmConsent(ctx context.Context, d *model.SubscriptionConsent) error {
+
+	query := "UPDATE subscription_consents SET confirmed_at = $2, confirmed_ip = $3 WHERE id = $1 AND confirmed_at IS NULL"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.ConfirmedAt, d.ConfirmedIP)
+
+	return err
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/segment"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ContactRepository struct {
+	DB *sql.DB
+}
+
+func NewContactRepository(db *sql.DB) *ContactRepository {
+	return &ContactRepository{DB: db}
+}
+
+const contactColumns = `c.id, c.uuid, c.account_id, c.external_id, c.email, c.firstname, c.lastname, c.attributes, c.status,
+	ARRAY(SELECT t.name FROM contact_tags ct JOIN tags t ON t.id = ct.tag_id WHERE ct.contact_id = c.id ORDER BY lower(t.name)),
+	c.snoozed_until, c.created_at, c.updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanContact(row scanner) (*model.Contact, error) {
+	var contact model.Contact
+	var attributes []byte
+
+	err := row.Scan(&contact.ID, &contact.UUID, &contact.AccountId, &contact.ExternalId, &contact.Email, &contact.FirstName, &contact.LastName, &attributes, &contact.Status, pq.Array(&contact.Tags), &contact.SnoozedUntil, &contact.CreatedAt, &contact.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(attributes, &contact.Attributes); err != nil {
+		return nil, fmt.Errorf("invalid attributes of contact %d: %w", contact.ID, err)
+	}
+
+	return &contact, nil
+}
+
+func scanContacts(rows *sql.Rows) ([]model.Contact, error) {
+	defer rows.Close()
+
+	contacts := []model.Contact{}
+
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		contacts = append(contacts, *contact)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+func marshalAttributes(attributes map[string]any) ([]byte, error) {
+	if attributes == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(attributes)
+}
+
+func (r *ContactRepository) CreateContact(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	attributes, err := marshalAttributes(d.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO contacts (uuid, account_id, external_id, email, firstname, lastname, attributes, status) VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id, created_at, updated_at"
+
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.ExternalId, d.Email, d.FirstName, d.LastName, attributes, d.Status).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *ContactRepository) CheckIfContactExists(ctx context.Context, d *model.Contact) (bool, error) {
+
+	query := "SELECT EXISTS(SELECT 1 FROM contacts WHERE account_id = $1 AND lower(email) = lower($2))"
+
+	var exists bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.Email).Scan(&exists)
+
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (r *ContactRepository) FindContactByUUID(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+
+	query := "SELECT " + contactColumns + " FROM contacts c WHERE c.uuid = $1 AND c.account_id = $2"
+
+	return scanContact(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+// FindContactByUUIDForUpdate looks a contact up like FindContactByUUID and locks it until the end of the
+// transaction, so that changes made from what was read do not overwrite concurrent ones.
+func (r *ContactRepository) FindContactByUUIDForUpdate(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+
+	query := "SELECT " + contactColumns + " FROM contacts c WHERE c.uuid = $1 AND c.account_id = $2 FOR UPDATE OF c"
+
+	return scanContact(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+func (r *ContactRepository) FindContactByEmail(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+
+	query := "SELECT " + contactColumns + " FROM contacts c WHERE c.account_id = $1 AND lower(c.email) = lower($2)"
+
+	return scanContact(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.Email))
+}
+
+// FindContactByExternalId looks a contact up by the id the account gave it in its own systems.
+func (r *ContactRepository) FindContactByExternalId(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+
+	query := "SELECT " + contactColumns + " FROM contacts c WHERE c.account_id = $1 AND c.external_id = $2 AND c.external_id <> ''"
+
+	return scanContact(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.ExternalId))
+}
+
+func (r *ContactRepository) FindContacts(ctx context.Context, page model.ContactPage) ([]model.Contact, error) {
+	rules := make([]segment.Rule, len(page.Tags))
+	for i, tag := range page.Tags {
+		rules[i] = segment.Rule{Type: "tag", Operator: "has", Value: tag}
+	}
+
+	where, args, err := segment.Compile(page.AccountId, rules...)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM contacts c WHERE %s ORDER BY c.created_at DESC, c.id DESC LIMIT $%d OFFSET $%d", contactColumns, where, len(args)+1, len(args)+2)
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, append(args, page.Limit, page.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanContacts(rows)
+}
+
+func (r *ContactRepository) UpdateContact(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	attributes, err := marshalAttributes(d.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE contacts c SET firstname = $3, lastname = $4, attributes = $5, status = $6, external_id = $7, updated_at = now()
+		WHERE c.uuid = $1 AND c.account_id = $2
+		RETURNING ` + contactColumns
+
+	return scanContact(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.FirstName, d.LastName, attributes, d.Status, d.ExternalId))
+}
+
+func (r *ContactRepository) DeleteContact(ctx context.Context, d *model.Contact) error {
+
+	query := "DELETE FROM contacts WHERE uuid = $1 AND account_id = $2 RETURNING id"
+
+	return database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId).Scan(&d.ID)
+}
+
+// MarkSubscribed sets a pending or unsubscribed contact to subscribed and reports whether its status changed.
+func (r *ContactRepository) MarkSubscribed(ctx context.Context, contactId int) (bool, error) {
+
+	query := "UPDATE contacts SET status = 'subscribed', updated_at = now() WHERE id = $1 AND status <> 'subscribed'"
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, contactId)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := result.RowsAffected()
+
+	return changed > 0, err
+}
+
+// SetSnooze pauses the emails of a contact until the given time, or resumes them if until is nil.
+func (r *ContactRepository) SetSnooze(ctx context.Context, contactId int, until *time.Time) error {
+
+	query := "UPDATE contacts SET snoozed_until = $2, updated_at = now() WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, contactId, until)
+
+	return err
+}
+
+// CountMatching counts the contacts of accountId that match every one of rules.
+func (r *ContactRepository) CountMatching(ctx context.Context, accountId int, rules ...segment.Rule) (int, error) {
+	where, args, err := segment.Compile(accountId, rules...)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, "SELECT count(*) FROM contacts c WHERE "+where, args...).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// FindMatching returns up to limit of the newest contacts of accountId that match every one of rules.
+func (r *ContactRepository) FindMatching(ctx context.Context, limit int, accountId int, rules ...segment.Rule) ([]model.Contact, error) {
+	where, args, err := segment.Compile(accountId, rules...)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM contacts c WHERE %s ORDER BY c.created_at DESC, c.id DESC LIMIT $%d", contactColumns, where, len(args)+1)
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanContacts(rows)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import "errors"
+
+// Errors for conditional updates that matched no row. Lookups that find nothing return sql.ErrNoRows instead.
+var (
+	ErrAccountNotRestorable = errors.New("account can not be restored")
+	ErrInvitationNotPending = errors.New("invitation is no longer pending")
+	ErrNoPendingInvitation  = errors.New("no pending invitation found")
+	ErrCampaignNotDraft     = errors.New("no draft campaign found")
+)
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"errors"
+)
+
+type EventRepository struct {
+	DB *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{DB: db}
+}
+
+// RecordEvent stores a custom event and reports whether it is new. An event whose idempotency key was used
+// before is not stored again.
+func (r *EventRepository) RecordEvent(ctx context.Context, d *model.ContactEvent) (bool, error) {
+	properties, err := marshalAttributes(d.Properties)
+	if err != nil {
+		return false, err
+	}
+
+	query := `INSERT INTO contact_events (account_id, contact_id, name, properties, idempotency_key, occurred_at)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (account_id, idempotency_key) WHERE idempotency_key <> '' DO NOTHING
+		RETURNING id, received_at`
+
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.ContactId, d.Name, properties, d.IdempotencyKey, d.OccurredAt).Scan(&d.ID, &d.ReceivedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/segment"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// staleExportAfter is how long a running export can go without a heartbeat before it is considered
+// abandoned by a crashed instance and handed to another one. It spans several heartbeats of the worker.
+const staleExportAfter = 5 * time.Minute
+
+type ExportRepository struct {
+	DB *sql.DB
+}
+
+func NewExportRepository(db *sql.DB) *ExportRepository {
+	return &ExportRepository{DB: db}
+}
+
+const exportColumns = `id, uuid, account_id, type, list_uuid, segment_uuid, format, columns, status, row_count, error, file_path,
+	created_at, started_at, completed_at, expires_at`
+
+func scanExport(row scanner) (*model.Export, error) {
+	var export model.Export
+
+	err := row.Scan(&export.ID, &export.UUID, &export.AccountId, &export.Type, &export.ListUUID, &export.SegmentUUID, &export.Format, pq.Array(&export.Columns),
+		&export.Status, &export.Rows, &export.Error, &export.FilePath, &export.CreatedAt, &export.StartedAt, &export.CompletedAt, &export.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+func (r *ExportRepository) CreateExport(ctx context.Context, d *model.Export) (*model.Export, error) {
+
+	query := `INSERT INTO exports (uuid, account_id, type, list_uuid, segment_uuid, format, columns, status)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id, created_at`
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Type, d.ListUUID, d.SegmentUUID, d.Format, pq.Array(d.Columns), d.Status).Scan(&d.ID, &d.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// FindAccountsExports returns every export of the given accounts, whatever its status.
+func (r *ExportRepository) FindAccountsExports(ctx context.Context, accountIds []int) ([]model.Export, error) {
+
+	query := "SELECT " + exportColumns + " FROM exports WHERE account_id = ANY($1) ORDER BY id"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, pq.Array(accountIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []model.Export
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *export)
+	}
+
+	return exports, rows.Err()
+}
+
+func (r *ExportRepository) FindExportByUUID(ctx context.Context, d *model.Export) (*model.Export, error) {
+
+	query := "SELECT " + exportColumns + " FROM exports WHERE uuid = $1 AND account_id = $2"
+
+	return scanExport(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+// FindExports returns the latest exports of an account, newest first.
+func (r *ExportRepository) FindExports(ctx context.Context, accountId int, limit int) ([]model.Export, error) {
+
+	query := "SELECT " + exportColumns + " FROM exports WHERE account_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []model.Export{}
+
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		exports = append(exports, *export)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+// ClaimExport marks the oldest pending export as running and returns it, or sql.ErrNoRows if there is none.
+// Rows locked by another instance are skipped, so every export is run by one instance only; an export left
+// running without a heartbeat for longer than staleExportAfter is claimed again.
+func (r *ExportRepository) ClaimExport(ctx context.Context) (*model.Export, error) {
+
+	query := `UPDATE exports SET status = 'running', started_at = now(), updated_at = now()
+		WHERE id = (
+			SELECT id FROM exports
+			WHERE status = 'pending' OR (status = 'running' AND updated_at < now() - make_interval(secs => $1))
+			ORDER BY created_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + exportColumns
+
+	return scanExport(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, staleExportAfter.Seconds()))
+}
+
+// TouchExport records a heartbeat of a running export, showing that the instance running it is alive.
+func (r *ExportRepository) TouchExport(ctx context.Context, exportId int) error {
+
+	query := "UPDATE exports SET updated_at = now() WHERE id = $1 AND status = 'running'"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, exportId)
+
+	return err
+}
+
+// ReleaseExport puts a running export back in the queue, for an instance that stops before finishing it.
+func (r *ExportRepository) ReleaseExport(ctx context.Context, exportId int) error {
+
+	query := "UPDATE exports SET status = 'pending', started_at = NULL, updated_at = now() WHERE id = $1 AND status = 'running'"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, exportId)
+
+	return err
+}
+
+func (r *ExportRepository) CompleteExport(ctx context.Context, d *model.Export) error {
+
+	query := `UPDATE exports SET status = 'completed', row_count = $2, file_path = $3, completed_at = now(), expires_at = $4
+		WHERE id = $1 AND status = 'running'`
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.Rows, d.FilePath, d.ExpiresAt)
+
+	return err
+}
+
+func (r *ExportRepository) FailExport(ctx context.Context, exportId int, message string) error {
+
+	query := "UPDATE exports SET status = 'failed', error = $2, completed_at = now() WHERE id = $1 AND status = 'running'"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, exportId, message)
+
+	return err
+}
+
+// ExpireExports marks the completed exports whose link has expired and returns how many there were.
+func (r *ExportRepository) ExpireExports(ctx context.Context) (int, error) {
+
+	query := "UPDATE exports SET status = 'expired', file_path = '' WHERE status = 'completed' AND expires_at <= now()"
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(expired), nil
+}
+
+// StreamContacts calls fn with each contact of accountId that matches every one of rules, in the order
+// they were created. Rows are read as fn consumes them, so any number of contacts can be exported.
+func (r *ExportRepository) StreamContacts(ctx context.Context, accountId int, fn func(*model.Contact) error, rules ...segment.Rule) error {
+	where, args, err := segment.Compile(accountId, rules...)
+	if err != nil {
+		return err
+	}
+
+	query := "SELECT " + contactColumns + " FROM contacts c WHERE " + where + " ORDER BY c.id"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(contact); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// suppressions lists the addresses of an account that unsubscribed, bounced or complained, once per reason.
+const suppressions = `SELECT c.email, s.reason, s.suppressed_at FROM (
+		SELECT id AS contact_id, 'unsubscribed' AS reason, updated_at AS suppressed_at FROM contacts
+		WHERE account_id = $1 AND status = 'unsubscribed'
+		UNION ALL
+		SELECT contact_id, type, max(occurred_at) FROM message_events
+		WHERE account_id = $1 AND type IN ('bounced', 'complained')
+		GROUP BY contact_id, type
+	) s JOIN contacts c ON c.id = s.contact_id`
+
+func (r *ExportRepository) CountSuppressions(ctx context.Context, accountId int) (int, error) {
+
+	query := "SELECT count(*) FROM (" + suppressions + ") suppressions"
+
+	var count int
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, accountId).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// StreamSuppressions calls fn with each suppression entry of accountId, ordered by email.
+func (r *ExportRepository) StreamSuppressions(ctx context.Context, accountId int, fn func(*model.Suppression) error) error {
+
+	query := suppressions + " ORDER BY lower(c.email), s.reason"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var suppression model.Suppression
+
+		if err := rows.Scan(&suppression.Email, &suppression.Reason, &suppression.SuppressedAt); err != nil {
+			return err
+		}
+
+		if err := fn(&suppression); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
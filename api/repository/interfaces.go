@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/segment"
+	"time"
+)
+
+// The interfaces below describe what the services need from storage. The Postgres repositories in this
+// package implement them; the memory package provides in-memory implementations for tests.
+
+type UserStore interface {
+	CreateUser(ctx context.Context, d *model.User) (*model.User, error)
+	CheckIfEmailAlreadyExists(ctx context.Context, d *model.User) (bool, error)
+	VerifyUserAccount(ctx context.Context, d *model.User) error
+	Login(ctx context.Context, d *model.User) (*model.User, error)
+	FindUserById(ctx context.Context, d *model.User) (*model.User, error)
+	FindUserByEmail(ctx context.Context, d *model.User) (*model.User, error)
+	ResetPassword(ctx context.Context, d *model.User) error
+	FindUserPasswordById(ctx context.Context, d *model.User) (*model.User, error)
+	UpdateProfile(ctx context.Context, d *model.UpdateProfile) error
+	SetPendingEmail(ctx context.Context, d *model.ChangeEmail) error
+	ConfirmEmailChange(ctx context.Context, d *model.User) (*model.User, error)
+	SoftDeleteUser(ctx context.Context, d *model.User, purgeAfter time.Time) error
+	RestoreUser(ctx context.Context, d *model.User) error
+	FindSessionState(ctx context.Context, d *model.User) (*model.SessionState, error)
+	FindPurgeableUsers(ctx context.Context) ([]int, error)
+	PurgeDeletedUsers(ctx context.Context) (int, error)
+	FindAllUsers(ctx context.Context) ([]model.User, error)
+}
+
+type OTPStore interface {
+	CreateOTP(ctx context.Context, d *model.OTP) error
+	FindOTP(ctx context.Context, d *model.OTP) (*model.OTP, error)
+	DeleteOTP(ctx context.Context, id int) error
+}
+
+type InvitationStore interface {
+	CreateInvitation(ctx context.Context, d *model.Invitation) (*model.Invitation, error)
+	CheckIfPendingInvitationExists(ctx context.Context, d *model.Invitation) (bool, error)
+	FindInvitationByUUID(ctx context.Context, d *model.Invitation) (*model.Invitation, error)
+	FindPendingInvitations(ctx context.Context, accountId int) ([]model.Invitation, error)
+	AcceptInvitation(ctx context.Context, d *model.Invitation) error
+	RevokeInvitation(ctx context.Context, d *model.Invitation) error
+}
+
+type TeamMemberStore interface {
+	AddTeamMember(ctx context.Context, d *model.TeamMember) (*model.TeamMember, error)
+	FindTeamMember(ctx context.Context, accountId int, userId int) (*model.TeamMember, error)
+}
+
+type AuthAttemptStore interface {
+	FindAttempt(ctx context.Context, scope string, key string) (*model.AuthAttempt, error)
+	RecordFailure(ctx context.Context, scope string, key string, window time.Duration) (*model.AuthAttempt, error)
+	Lock(ctx context.Context, scope string, key string, until time.Time) error
+	ResetAttempts(ctx context.Context, scope string, key string) error
+}
+
+type QuotaStore interface {
+	FindAccountQuota(ctx context.Context, userId int) (*model.AccountQuota, error)
+}
+
+type ContactStore interface {
+	CreateContact(ctx context.Context, d *model.Contact) (*model.Contact, error)
+	CheckIfContactExists(ctx context.Context, d *model.Contact) (bool, error)
+	FindContactByUUID(ctx context.Context, d *model.Contact) (*model.Contact, error)
+	FindContactByUUIDForUpdate(ctx context.Context, d *model.Contact) (*model.Contact, error)
+	FindContactByEmail(ctx context.Context, d *model.Contact) (*model.Contact, error)
+	FindContactByExternalId(ctx context.Context, d *model.Contact) (*model.Contact, error)
+	FindContacts(ctx context.Context, page model.ContactPage) ([]model.Contact, error)
+	UpdateContact(ctx context.Context, d *model.Contact) (*model.Contact, error)
+	DeleteContact(ctx context.Context, d *model.Contact) error
+	MarkSubscribed(ctx context.Context, contactId int) (bool, error)
+	SetSnooze(ctx context.Context, contactId int, until *time.Time) error
+	CountMatching(ctx context.Context, accountId int, rules ...segment.Rule) (int, error)
+	FindMatching(ctx context.Context, limit int, accountId int, rules ...segment.Rule) ([]model.Contact, error)
+}
+
+type ListStore interface {
+	CreateList(ctx context.Context, d *model.ContactList) (*model.ContactList, error)
+	FindListByUUID(ctx context.Context, d *model.ContactList) (*model.ContactList, error)
+	FindListByPublicId(ctx context.Context, publicId string) (*model.ContactList, error)
+	FindLists(ctx context.Context, accountId int) ([]model.ContactList, error)
+	CheckIfListInUse(ctx context.Context, listId int) (bool, error)
+	DeleteList(ctx context.Context, listId int) error
+	CheckIfContactInList(ctx context.Context, listId int, contactId int) (bool, error)
+	AddContactById(ctx context.Context, listId int, contactId int) (bool, error)
+	FindContactLists(ctx context.Context, contactId int) ([]model.ListPreference, error)
+	KeepContactLists(ctx context.Context, contactId int, listUUIDs []string) error
+	AddContacts(ctx context.Context, d *model.ContactList, contactUUIDs []string) ([]int, error)
+	RemoveContacts(ctx context.Context, d *model.ContactList, contactUUIDs []string) (int, error)
+}
+
+type TagStore interface {
+	EnsureTags(ctx context.Context, accountId int, names []string) ([]model.Tag, error)
+	TagContacts(ctx context.Context, accountId int, tagIds []int, contactUUIDs []string) ([]model.TaggedContact, error)
+	UntagContacts(ctx context.Context, accountId int, names []string, contactUUIDs []string) (int, error)
+	FindTags(ctx context.Context, accountId int) ([]model.Tag, error)
+}
+
+type ConsentStore interface {
+	CreateConsent(ctx context.Context, d *model.SubscriptionConsent) (*model.SubscriptionConsent, error)
+	FindConsentByUUID(ctx context.Context, consentUUID string) (*model.SubscriptionConsent, error)
+	FindLatestConsent(ctx context.Context, contactId int, listId int) (*model.SubscriptionConsent, error)
+	ConfirmConsent(ctx context.Context, d *model.SubscriptionConsent) error
+}
+
+type SignupFormStore interface {
+	CreateSignupForm(ctx context.Context, d *model.SignupForm) (*model.SignupForm, error)
+	FindSignupFormByUUID(ctx context.Context, d *model.SignupForm) (*model.SignupForm, error)
+	FindSignupFormByPublicId(ctx context.Context, publicId string) (*model.SignupForm, error)
+	FindSignupForms(ctx context.Context, accountId int) ([]model.SignupForm, error)
+	UpdateSignupForm(ctx context.Context, d *model.SignupForm) error
+	DeleteSignupForm(ctx context.Context, d *model.SignupForm) error
+}
+
+type TopicStore interface {
+	CreateTopic(ctx context.Context, d *model.Topic) (*model.Topic, error)
+	CheckIfTopicNameTaken(ctx context.Context, d *model.Topic) (bool, error)
+	FindTopicByUUID(ctx context.Context, d *model.Topic) (*model.Topic, error)
+	FindTopics(ctx context.Context, accountId int) ([]model.Topic, error)
+	UpdateTopic(ctx context.Context, d *model.Topic) (*model.Topic, error)
+	CheckIfTopicInUse(ctx context.Context, topicId int) (bool, error)
+	DeleteTopic(ctx context.Context, topicId int) error
+	FindTopicPreferences(ctx context.Context, accountId int, contactId int) ([]model.TopicPreference, error)
+	CheckIfOptedOut(ctx context.Context, contactId int, topicUUID string) (bool, error)
+	SetTopicOptOuts(ctx context.Context, accountId int, contactId int, subscribedUUIDs []string) error
+}
+
+type SegmentStore interface {
+	CreateSegment(ctx context.Context, d *model.Segment) (*model.Segment, error)
+	FindSegmentByUUID(ctx context.Context, d *model.Segment) (*model.Segment, error)
+	FindSegments(ctx context.Context, accountId int) ([]model.Segment, error)
+	UpdateSegment(ctx context.Context, d *model.Segment) (*model.Segment, error)
+	CheckIfSegmentInUse(ctx context.Context, segmentId int) (bool, error)
+	DeleteSegment(ctx context.Context, segmentId int) error
+}
+
+type CampaignStore interface {
+	CreateCampaign(ctx context.Context, d *model.Campaign) (*model.Campaign, error)
+	FindCampaignByUUID(ctx context.Context, d *model.Campaign) (*model.Campaign, error)
+	FindCampaigns(ctx context.Context, accountId int) ([]model.Campaign, error)
+	UpdateCampaign(ctx context.Context, d *model.Campaign) error
+}
+
+type ActivityStore interface {
+	RecordActivity(ctx context.Context, d *model.ContactActivity) error
+	FindActivities(ctx context.Context, contactId int, types []string, cursor *model.ActivityCursor, limit int) ([]model.ContactActivity, error)
+}
+
+type ExportStore interface {
+	CreateExport(ctx context.Context, d *model.Export) (*model.Export, error)
+	FindExportByUUID(ctx context.Context, d *model.Export) (*model.Export, error)
+	FindExports(ctx context.Context, accountId int, limit int) ([]model.Export, error)
+	FindAccountsExports(ctx context.Context, accountIds []int) ([]model.Export, error)
+	ClaimExport(ctx context.Context) (*model.Export, error)
+	TouchExport(ctx context.Context, exportId int) error
+	ReleaseExport(ctx context.Context, exportId int) error
+	CompleteExport(ctx context.Context, d *model.Export) error
+	FailExport(ctx context.Context, exportId int, message string) error
+	ExpireExports(ctx context.Context) (int, error)
+	StreamContacts(ctx context.Context, accountId int, fn func(*model.Contact) error, rules ...segment.Rule) error
+	CountSuppressions(ctx context.Context, accountId int) (int, error)
+	StreamSuppressions(ctx context.Context, accountId int, fn func(*model.Suppression) error) error
+}
+
+type AuditStore interface {
+	RecordAuditEvent(ctx context.Context, d *model.AuditEvent) error
+}
+
+type WorkflowStore interface {
+	CreateWorkflow(ctx context.Context, d *model.Workflow) (*model.Workflow, error)
+	FindWorkflowByUUID(ctx context.Context, d *model.Workflow) (*model.Workflow, error)
+	FindWorkflowById(ctx context.Context, workflowId int) (*model.Workflow, error)
+	FindWorkflows(ctx context.Context, accountId int) ([]model.Workflow, error)
+	UpdateWorkflow(ctx context.Context, d *model.Workflow) (*model.Workflow, error)
+	DeleteWorkflow(ctx context.Context, d *model.Workflow) error
+	EnrollOnListJoined(ctx context.Context, listId int, contactIds []int) (int, error)
+	EnrollOnTagAdded(ctx context.Context, accountId int, tag string, contactIds []int) (int, error)
+	EnrollOnEvent(ctx context.Context, accountId int, event string, contactIds []int) (int, error)
+	EnrollAnniversaries(ctx context.Context) (int, error)
+	ClaimDueRun(ctx context.Context) (*model.WorkflowRun, error)
+	UpdateRun(ctx context.Context, d *model.WorkflowRun) error
+	RetryRun(ctx context.Context, d *model.WorkflowRun, at time.Time, message string) error
+	FindRuns(ctx context.Context, workflowId int, limit int) ([]model.WorkflowRun, error)
+}
+
+type MailQueueStore interface {
+	EnqueueMail(ctx context.Context, d *model.QueuedMail) error
+	ClaimDueMail(ctx context.Context, lease time.Duration) (*model.QueuedMail, error)
+	CompleteMail(ctx context.Context, id int64) error
+	DeferMail(ctx context.Context, id int64, at time.Time) error
+	RetryMail(ctx context.Context, id int64, at time.Time, message string) error
+	FailMail(ctx context.Context, id int64, message string) error
+	DropMail(ctx context.Context, id int64) error
+	CountQueuedMail(ctx context.Context) (map[string]int, error)
+}
+
+type EventStore interface {
+	RecordEvent(ctx context.Context, d *model.ContactEvent) (bool, error)
+}
+
+type MessageEventStore interface {
+	FindSentMessage(ctx context.Context, messageId string) (*model.MessageEvent, error)
+	RecordMessageEvent(ctx context.Context, d *model.MessageEvent) (bool, error)
+}
+
+var (
+	_ UserStore         = (*UserRepository)(nil)
+	_ OTPStore          = (*OTPRepository)(nil)
+	_ InvitationStore   = (*InvitationRepository)(nil)
+	_ TeamMemberStore   = (*TeamMemberRepository)(nil)
+	_ AuthAttemptStore  = (*AuthAttemptRepository)(nil)
+	_ QuotaStore        = (*QuotaRepository)(nil)
+	_ ContactStore      = (*ContactRepository)(nil)
+	_ ListStore         = (*ListRepository)(nil)
+	_ TagStore          = (*TagRepository)(nil)
+	_ ConsentStore      = (*ConsentRepository)(nil)
+	_ SignupFormStore   = (*SignupFormRepository)(nil)
+	_ TopicStore        = (*TopicRepository)(nil)
+	_ SegmentStore      = (*SegmentRepository)(nil)
+	_ CampaignStore     = (*CampaignRepository)(nil)
+	_ ActivityStore     = (*ActivityRepository)(nil)
+	_ ExportStore       = (*ExportRepository)(nil)
+	_ AuditStore        = (*AuditRepository)(nil)
+	_ WorkflowStore     = (*WorkflowRepository)(nil)
+	_ MailQueueStore    = (*MailQueueRepository)(nil)
+	_ EventStore        = (*EventRepository)(nil)
+	_ MessageEventStore = (*MessageEventRepository)(nil)
+)
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"fmt"
+)
+
+type InvitationRepository struct {
+	DB *sql.DB
+}
+
+func NewInvitationRepository(db *sql.DB) *InvitationRepository {
+	return &InvitationRepository{DB: db}
+}
+
+func (r *InvitationRepository) CreateInvitation(ctx context.Context, d *model.Invitation) (*model.Invitation, error) {
+
+	query := "INSERT INTO invitations (uuid, account_id, email, role, expires_at) VALUES ($1,$2,$3,$4,$5) RETURNING id, created_at"
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Email, d.Role, d.ExpiresAt).Scan(&d.ID, &d.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *InvitationRepository) CheckIfPendingInvitationExists(ctx context.Context, d *model.Invitation) (bool, error) {
+
+	query := "SELECT EXISTS(SELECT 1 FROM invitations WHERE account_id = $1 AND lower(email) = lower($2) AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > now())"
+
+	var exists bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.Email).Scan(&exists)
+
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (r *InvitationRepository) FindInvitationByUUID(ctx context.Context, d *model.Invitation) (*model.Invitation, error) {
+
+	query := "SELECT id, uuid, account_id, email, role, expires_at, accepted_at, revoked_at, created_at FROM invitations WHERE uuid = $1"
+	row := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID)
+
+	var invitation model.Invitation
+	err := row.Scan(&invitation.ID, &invitation.UUID, &invitation.AccountId, &invitation.Email, &invitation.Role, &invitation.ExpiresAt, &invitation.AcceptedAt, &invitation.RevokedAt, &invitation.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invitation does not exist: %w", err)
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+func (r *InvitationRepository) FindPendingInvitations(ctx context.Context, accountId int) ([]model.Invitation, error) {
+
+	query := "SELECT id, uuid, account_id, email, role, expires_at, accepted_at, revoked_at, created_at FROM invitations WHERE account_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > now() ORDER BY created_at DESC"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []model.Invitation{}
+
+	for rows.Next() {
+		var invitation model.Invitation
+		err := rows.Scan(
+			&invitation.ID,
+			&invitation.UUID,
+			&invitation.AccountId,
+			&invitation.Email,
+			&invitation.Role,
+			&invitation.ExpiresAt,
+			&invitation.AcceptedAt,
+			&invitation.RevokedAt,
+			&invitation.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+func (r *InvitationRepository) AcceptInvitation(ctx context.Context, d *model.Invitation) error {
+	query := "UPDATE invitations SET accepted_at = $2 WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL"
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.AcceptedAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrInvitationNotPending
+	}
+
+	return nil
+}
+
+func (r *InvitationRepository) RevokeInvitation(ctx context.Context, d *model.Invitation) error {
+	query := "UPDATE invitations SET revoked_at = $3 WHERE uuid = $1 AND account_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL"
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.UUID, d.AccountId, d.RevokedAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNoPendingInvitation
+	}
+
+	return nil
+}
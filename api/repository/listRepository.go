@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+
+	"github.com/lib/pq"
+)
+
+type ListRepository struct {
+	DB *sql.DB
+}
+
+func NewListRepository(db *sql.DB) *ListRepository {
+	return &ListRepository{DB: db}
+}
+
+func (r *ListRepository) CreateList(ctx context.Context, d *model.ContactList) (*model.ContactList, error) {
+
+	query := "INSERT INTO lists (uuid, public_id, account_id, name) VALUES ($1,$2,$3,$4) RETURNING id, created_at"
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.PublicId, d.AccountId, d.Name).Scan(&d.ID, &d.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+const listColumns = "l.id, l.uuid, l.account_id, l.public_id, l.name"
+
+func (r *ListRepository) FindListByUUID(ctx context.Context, d *model.ContactList) (*model.ContactList, error) {
+
+	query := "SELECT " + listColumns + ", (SELECT count(*) FROM list_contacts lc WHERE lc.list_id = l.id), l.created_at FROM lists l WHERE l.uuid = $1 AND l.account_id = $2"
+
+	var list model.ContactList
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId).Scan(&list.ID, &list.UUID, &list.AccountId, &list.PublicId, &list.Name, &list.Contacts, &list.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// FindListByPublicId looks a list up by the id used on signup forms. The member count is not filled in.
+func (r *ListRepository) FindListByPublicId(ctx context.Context, publicId string) (*model.ContactList, error) {
+
+	query := "SELECT " + listColumns + ", l.created_at FROM lists l WHERE l.public_id = $1"
+
+	var list model.ContactList
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, publicId).Scan(&list.ID, &list.UUID, &list.AccountId, &list.PublicId, &list.Name, &list.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+func (r *ListRepository) FindLists(ctx context.Context, accountId int) ([]model.ContactList, error) {
+
+	query := "SELECT " + listColumns + `, count(lc.contact_id), l.created_at
+		FROM lists l LEFT JOIN list_contacts lc ON lc.list_id = l.id
+		WHERE l.account_id = $1 GROUP BY l.id ORDER BY l.name`
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []model.ContactList{}
+
+	for rows.Next() {
+		var list model.ContactList
+		if err := rows.Scan(&list.ID, &list.UUID, &list.AccountId, &list.PublicId, &list.Name, &list.Contacts, &list.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+func (r *ListRepository) CheckIfListInUse(ctx context.Context, listId int) (bool, error) {
+
+	query := `SELECT EXISTS(SELECT 1 FROM campaigns WHERE list_id = $1) OR EXISTS(SELECT 1 FROM signup_forms WHERE list_id = $1)
+		OR EXISTS(SELECT 1 FROM workflows w JOIN lists l ON l.id = $1 AND l.account_id = w.account_id WHERE w.trigger ->> 'list_uuid' = l.uuid)`
+
+	var inUse bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, listId).Scan(&inUse)
+
+	if err != nil {
+		return false, err
+	}
+
+	return inUse, nil
+}
+
+func (r *ListRepository) DeleteList(ctx context.Context, listId int) error {
+
+	query := "DELETE FROM lists WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, listId)
+
+	return err
+}
+
+func (r *ListRepository) CheckIfContactInList(ctx context.Context, listId int, contactId int) (bool, error) {
+
+	query := "SELECT EXISTS(SELECT 1 FROM list_contacts WHERE list_id = $1 AND contact_id = $2)"
+
+	var member bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, listId, contactId).Scan(&member)
+
+	if err != nil {
+		return false, err
+	}
+
+	return member, nil
+}
+
+// FindContactLists returns the lists a contact is on.
+func (r *ListRepository) FindContactLists(ctx context.Context, contactId int) ([]model.ListPreference, error) {
+
+	query := "SELECT l.uuid, l.name FROM lists l JOIN list_contacts lc ON lc.list_id = l.id WHERE lc.contact_id = $1 ORDER BY l.name"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, contactId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []model.ListPreference{}
+
+	for rows.Next() {
+		var list model.ListPreference
+		if err := rows.Scan(&list.UUID, &list.Name); err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// KeepContactLists removes a contact from every list except the ones with the given UUIDs.
+func (r *ListRepository) KeepContactLists(ctx context.Context, contactId int, listUUIDs []string) error {
+	if listUUIDs == nil {
+		listUUIDs = []string{}
+	}
+
+	query := `WITH changed AS (
+			DELETE FROM list_contacts lc USING lists l WHERE l.id = lc.list_id AND lc.contact_id = $1 AND NOT l.uuid = ANY($2)
+			RETURNING lc.list_id, lc.contact_id
+		) ` + logListChanges("list_removed")
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, contactId, pq.Array(listUUIDs))
+
+	return err
+}
+
+// AddContacts adds the contacts of the list's account with the given UUIDs to the list and returns the ids of
+// the ones that were not members yet. UUIDs of unknown contacts are ignored.
+func (r *ListRepository) AddContacts(ctx context.Context, d *model.ContactList, contactUUIDs []string) ([]int, error) {
+
+	query := `WITH changed AS (
+			INSERT INTO list_contacts (list_id, contact_id)
+			SELECT $1, id FROM contacts WHERE account_id = $2 AND uuid = ANY($3)
+			ON CONFLICT DO NOTHING
+			RETURNING list_id, contact_id
+		) ` + logListChanges("list_added") + " RETURNING contact_id"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, d.ID, d.AccountId, pq.Array(contactUUIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	added := []int{}
+
+	for rows.Next() {
+		var contactId int
+		if err := rows.Scan(&contactId); err != nil {
+			return nil, err
+		}
+
+		added = append(added, contactId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// AddContactById adds a single contact to a list unless it is a member already, and reports whether it was added.
+func (r *ListRepository) AddContactById(ctx context.Context, listId int, contactId int) (bool, error) {
+
+	query := `WITH changed AS (
+			INSERT INTO list_contacts (list_id, contact_id) VALUES ($1,$2) ON CONFLICT DO NOTHING
+			RETURNING list_id, contact_id
+		) ` + logListChanges("list_added")
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, listId, contactId)
+	if err != nil {
+		return false, err
+	}
+
+	added, err := result.RowsAffected()
+
+	return added > 0, err
+}
+
+// RemoveContacts removes the contacts with the given UUIDs from the list and returns how many were members.
+func (r *ListRepository) RemoveContacts(ctx context.Context, d *model.ContactList, contactUUIDs []string) (int, error) {
+
+	query := `WITH changed AS (
+			DELETE FROM list_contacts WHERE list_id = $1
+			AND contact_id IN (SELECT id FROM contacts WHERE account_id = $2 AND uuid = ANY($3))
+			RETURNING list_id, contact_id
+		) ` + logListChanges("list_removed")
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.AccountId, pq.Array(contactUUIDs))
+	if err != nil {
+		return 0, err
+	}
+
+	removed, err := result.RowsAffected()
+
+	return int(removed), err
+}
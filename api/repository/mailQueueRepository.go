@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"encoding/json"
+	"time"
+)
+
+type MailQueueRepository struct {
+	DB *sql.DB
+}
+
+func NewMailQueueRepository(db *sql.DB) *MailQueueRepository {
+	return &MailQueueRepository{DB: db}
+}
+
+// EnqueueMail adds a mail to the queue, due right away.
+func (r *MailQueueRepository) EnqueueMail(ctx context.Context, d *model.QueuedMail) error {
+	data, err := marshalAttributes(d.Data)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO mail_queue (account_id, contact_id, email, subject, body, preferences_link, message_id, data)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		RETURNING id, status, next_attempt_at, created_at`
+
+	return database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.ContactId, d.Email, d.Subject, d.Body, d.PreferencesLink, d.MessageId, data).
+		Scan(&d.ID, &d.Status, &d.NextAttemptAt, &d.CreatedAt)
+}
+
+// ClaimDueMail takes the queued mail that is due the longest, or returns sql.ErrNoRows when none is due. The
+// attempt is counted and the mail is put off by lease, so that other instances leave it alone while it is
+// sent; it is sent again once the lease ends if the instance sending it stops before recording the outcome.
+func (r *MailQueueRepository) ClaimDueMail(ctx context.Context, lease time.Duration) (*model.QueuedMail, error) {
+
+	query := `UPDATE mail_queue q SET attempts = q.attempts + 1, next_attempt_at = now() + make_interval(secs => $1), updated_at = now()
+		FROM contacts c
+		WHERE c.id = q.contact_id AND q.id = (
+			SELECT id FROM mail_queue WHERE status = 'queued' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING q.id, q.account_id, q.contact_id, c.status, q.email, q.subject, q.body, q.preferences_link, q.message_id, q.data,
+			q.status, q.attempts, q.error, q.next_attempt_at, q.created_at`
+
+	var mail model.QueuedMail
+	var data []byte
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, lease.Seconds()).Scan(&mail.ID, &mail.AccountId, &mail.ContactId, &mail.ContactStatus,
+		&mail.Email, &mail.Subject, &mail.Body, &mail.PreferencesLink, &mail.MessageId, &data,
+		&mail.Status, &mail.Attempts, &mail.Error, &mail.NextAttemptAt, &mail.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &mail.Data); err != nil {
+		return nil, err
+	}
+
+	return &mail, nil
+}
+
+// CompleteMail takes a sent mail out of the queue and records it as a sent message event of the contact.
+func (r *MailQueueRepository) CompleteMail(ctx context.Context, id int64) error {
+
+	query := `WITH sent AS (DELETE FROM mail_queue WHERE id = $1 RETURNING account_id, contact_id, message_id, data)
+		INSERT INTO message_events (account_id, contact_id, type, data)
+		SELECT account_id, contact_id, 'sent', data || jsonb_build_object('message_id', message_id) FROM sent`
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, id)
+
+	return err
+}
+
+// DeferMail puts a claimed mail off until the given time without counting the attempt, e.g. when the quota of
+// the account does not allow it yet.
+func (r *MailQueueRepository) DeferMail(ctx context.Context, id int64, at time.Time) error {
+
+	query := "UPDATE mail_queue SET attempts = attempts - 1, next_attempt_at = $2, updated_at = now() WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, id, at)
+
+	return err
+}
+
+// RetryMail tries a mail that failed to send again at the given time and keeps the error.
+func (r *MailQueueRepository) RetryMail(ctx context.Context, id int64, at time.Time, message string) error {
+
+	query := "UPDATE mail_queue SET next_attempt_at = $2, error = $3, updated_at = now() WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, id, at, message)
+
+	return err
+}
+
+// FailMail gives up a mail. It stays in the queue with its error, but is no longer sent.
+func (r *MailQueueRepository) FailMail(ctx context.Context, id int64, message string) error {
+
+	query := "UPDATE mail_queue SET status = 'failed', error = $2, updated_at = now() WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, id, message)
+
+	return err
+}
+
+// DropMail takes a mail out of the queue without sending it.
+func (r *MailQueueRepository) DropMail(ctx context.Context, id int64) error {
+
+	query := "DELETE FROM mail_queue WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, id)
+
+	return err
+}
+
+// CountQueuedMail returns how many mails wait in the queue, per plan of their account. Every plan is listed,
+// with zero when none of its mails are waiting.
+func (r *MailQueueRepository) CountQueuedMail(ctx context.Context) (map[string]int, error) {
+
+	query := `SELECT p.name, count(queued.id) FROM plans p
+		LEFT JOIN (
+			SELECT m.id, COALESCE(q.plan, $1) AS plan
+			FROM mail_queue m LEFT JOIN account_quotas q ON q.user_id = m.account_id
+			WHERE m.status = 'queued'
+		) queued ON queued.plan = p.name
+		GROUP BY p.name`
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, DefaultPlan)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+
+	for rows.Next() {
+		var plan string
+		var count int
+
+		if err := rows.Scan(&plan, &count); err != nil {
+			return nil, err
+		}
+
+		counts[plan] = count
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database/dbtest"
+	"email-marketing-service/api/model"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMailQueueLeasesClaimedMailAndRecordsItAsSent(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	user, err := NewUserRepository(db).CreateUser(ctx, &model.User{
+		UUID:      uuid.New().String(),
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		UserName:  "ada",
+		Email:     fmt.Sprintf("queue-%s@example.com", uuid.New().String()),
+		Password:  []byte("hash"),
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	contact, err := NewContactRepository(db).CreateContact(ctx, &model.Contact{UUID: uuid.New().String(), AccountId: user.ID, Email: "grace@example.com", Status: "subscribed"})
+	if err != nil {
+		t.Fatalf("CreateContact: %v", err)
+	}
+
+	queue := NewMailQueueRepository(db)
+	mail := &model.QueuedMail{
+		AccountId:       user.ID,
+		ContactId:       contact.ID,
+		Email:           contact.Email,
+		Subject:         "Welcome",
+		Body:            "<p>Welcome</p>",
+		PreferencesLink: "https://app.example.com/api/v1/public/preferences?token=t",
+		MessageId:       uuid.New().String(),
+		Data:            map[string]any{"workflow_uuid": "workflow", "step_id": "welcome"},
+	}
+	if err := queue.EnqueueMail(ctx, mail); err != nil {
+		t.Fatalf("EnqueueMail: %v", err)
+	}
+
+	claimed, err := queue.ClaimDueMail(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimDueMail: %v", err)
+	}
+	if claimed.ID != mail.ID || claimed.Attempts != 1 || claimed.ContactStatus != "subscribed" || claimed.Data["step_id"] != "welcome" {
+		t.Fatalf("unexpected claimed mail: %+v", claimed)
+	}
+
+	if _, err := queue.ClaimDueMail(ctx, time.Minute); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("a leased mail was claimed again: %v", err)
+	}
+
+	if err := queue.CompleteMail(ctx, claimed.ID); err != nil {
+		t.Fatalf("CompleteMail: %v", err)
+	}
+
+	sent, err := NewMessageEventRepository(db).FindSentMessage(ctx, mail.MessageId)
+	if err != nil {
+		t.Fatalf("FindSentMessage: %v", err)
+	}
+	if sent.ContactId != contact.ID || sent.Data["step_id"] != "welcome" {
+		t.Fatalf("unexpected sent event: %+v", sent)
+	}
+
+	if _, err := queue.CountQueuedMail(ctx); err != nil {
+		t.Fatalf("CountQueuedMail: %v", err)
+	}
+}
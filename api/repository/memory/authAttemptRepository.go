@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/model"
+	"sync"
+	"time"
+)
+
+type AuthAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[[2]string]*model.AuthAttempt
+}
+
+func NewAuthAttemptRepository() *AuthAttemptRepository {
+	return &AuthAttemptRepository{attempts: make(map[[2]string]*model.AuthAttempt)}
+}
+
+func (r *AuthAttemptRepository) Snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts := make(map[[2]string]model.AuthAttempt, len(r.attempts))
+	for key, attempt := range r.attempts {
+		attempts[key] = *attempt
+	}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.attempts = make(map[[2]string]*model.AuthAttempt, len(attempts))
+		for key, attempt := range attempts {
+			attempt := attempt
+			r.attempts[key] = &attempt
+		}
+	}
+}
+
+func (r *AuthAttemptRepository) FindAttempt(ctx context.Context, scope string, key string) (*model.AuthAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[[2]string{scope, key}]
+	if !ok {
+		return nil, nil
+	}
+
+	found := *attempt
+	return &found, nil
+}
+
+func (r *AuthAttemptRepository) RecordFailure(ctx context.Context, scope string, key string, window time.Duration) (*model.AuthAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	attempt, ok := r.attempts[[2]string{scope, key}]
+	if !ok {
+		attempt = &model.AuthAttempt{Scope: scope, Key: key}
+		r.attempts[[2]string{scope, key}] = attempt
+	}
+
+	if ok && attempt.LastFailedAt.Before(now.Add(-window)) {
+		attempt.Failures = 1
+	} else {
+		attempt.Failures++
+	}
+	attempt.LastFailedAt = now
+
+	found := *attempt
+	return &found, nil
+}
+
+func (r *AuthAttemptRepository) Lock(ctx context.Context, scope string, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[[2]string{scope, key}]; ok {
+		attempt.Failures = 0
+		attempt.LockedUntil = sql.NullTime{Time: until, Valid: true}
+	}
+
+	return nil
+}
+
+func (r *AuthAttemptRepository) ResetAttempts(ctx context.Context, scope string, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, [2]string{scope, key})
+	return nil
+}
+
+// Age moves the attempts of a scope/key pair back in time by d, as if d had passed since they were recorded.
+func (r *AuthAttemptRepository) Age(scope string, key string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[[2]string{scope, key}]; ok {
+		attempt.LastFailedAt = attempt.LastFailedAt.Add(-d)
+		attempt.LockedUntil.Time = attempt.LockedUntil.Time.Add(-d)
+	}
+}
@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/repository"
+)
+
+var (
+	_ repository.UserStore        = (*UserRepository)(nil)
+	_ repository.OTPStore         = (*OTPRepository)(nil)
+	_ repository.InvitationStore  = (*InvitationRepository)(nil)
+	_ repository.TeamMemberStore  = (*TeamMemberRepository)(nil)
+	_ repository.AuthAttemptStore = (*AuthAttemptRepository)(nil)
+	_ repository.QuotaStore       = (*QuotaRepository)(nil)
+	_ database.Transactor         = (*Transactor)(nil)
+
+	_ Snapshotter = (*UserRepository)(nil)
+	_ Snapshotter = (*OTPRepository)(nil)
+	_ Snapshotter = (*InvitationRepository)(nil)
+	_ Snapshotter = (*TeamMemberRepository)(nil)
+	_ Snapshotter = (*AuthAttemptRepository)(nil)
+	_ Snapshotter = (*QuotaRepository)(nil)
+)
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type InvitationRepository struct {
+	mu          sync.Mutex
+	nextId      int
+	invitations map[int]model.Invitation
+}
+
+func NewInvitationRepository() *InvitationRepository {
+	return &InvitationRepository{invitations: make(map[int]model.Invitation)}
+}
+
+func (r *InvitationRepository) Snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nextId, invitations := r.nextId, maps.Clone(r.invitations)
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.nextId, r.invitations = nextId, invitations
+	}
+}
+
+func isPending(invitation model.Invitation) bool {
+	return !invitation.AcceptedAt.Valid && !invitation.RevokedAt.Valid && invitation.ExpiresAt.After(time.Now())
+}
+
+func (r *InvitationRepository) CreateInvitation(ctx context.Context, d *model.Invitation) (*model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextId++
+	d.ID = r.nextId
+	d.CreatedAt = time.Now()
+	r.invitations[d.ID] = *d
+
+	return d, nil
+}
+
+func (r *InvitationRepository) CheckIfPendingInvitationExists(ctx context.Context, d *model.Invitation) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, invitation := range r.invitations {
+		if invitation.AccountId == d.AccountId && strings.EqualFold(invitation.Email, d.Email) && isPending(invitation) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *InvitationRepository) FindInvitationByUUID(ctx context.Context, d *model.Invitation) (*model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, invitation := range r.invitations {
+		if invitation.UUID == d.UUID {
+			found := invitation
+			return &found, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invitation does not exist: %w", sql.ErrNoRows)
+}
+
+func (r *InvitationRepository) FindPendingInvitations(ctx context.Context, accountId int) ([]model.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitations := []model.Invitation{}
+	for _, invitation := range r.invitations {
+		if invitation.AccountId == accountId && isPending(invitation) {
+			invitations = append(invitations, invitation)
+		}
+	}
+
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].ID > invitations[j].ID
+	})
+
+	return invitations, nil
+}
+
+func (r *InvitationRepository) AcceptInvitation(ctx context.Context, d *model.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitation, ok := r.invitations[d.ID]
+	if !ok || invitation.AcceptedAt.Valid || invitation.RevokedAt.Valid {
+		return repository.ErrInvitationNotPending
+	}
+
+	invitation.AcceptedAt = d.AcceptedAt
+	r.invitations[d.ID] = invitation
+
+	return nil
+}
+
+func (r *InvitationRepository) RevokeInvitation(ctx context.Context, d *model.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, invitation := range r.invitations {
+		if invitation.UUID == d.UUID && invitation.AccountId == d.AccountId && !invitation.AcceptedAt.Valid && !invitation.RevokedAt.Valid {
+			invitation.RevokedAt = d.RevokedAt
+			r.invitations[id] = invitation
+			return nil
+		}
+	}
+
+	return repository.ErrNoPendingInvitation
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/model"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+type OTPRepository struct {
+	mu     sync.Mutex
+	nextId int
+	otps   map[int]model.OTP
+}
+
+func NewOTPRepository() *OTPRepository {
+	return &OTPRepository{otps: make(map[int]model.OTP)}
+}
+
+func (r *OTPRepository) Snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nextId, otps := r.nextId, maps.Clone(r.otps)
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.nextId, r.otps = nextId, otps
+	}
+}
+
+func (r *OTPRepository) CreateOTP(ctx context.Context, d *model.OTP) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextId++
+	otp := *d
+	otp.Id = r.nextId
+	otp.CreatedAt = time.Now()
+	r.otps[otp.Id] = otp
+
+	return nil
+}
+
+func (r *OTPRepository) FindOTP(ctx context.Context, d *model.OTP) (*model.OTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, otp := range r.otps {
+		if otp.Token == d.Token && otp.Purpose == d.Purpose && otp.ExpiresAt.After(now) {
+			found := otp
+			return &found, nil
+		}
+	}
+
+	return nil, fmt.Errorf("otp does not exist: %w", sql.ErrNoRows)
+}
+
+func (r *OTPRepository) DeleteOTP(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.otps, id)
+	return nil
+}
+
+// Count returns how many OTPs are stored.
+func (r *OTPRepository) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.otps)
+}
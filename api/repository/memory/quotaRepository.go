@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"email-marketing-service/api/model"
+	"maps"
+	"sync"
+)
+
+type QuotaRepository struct {
+	mu     sync.Mutex
+	quotas map[int]model.AccountQuota
+	// Default is returned for accounts without a quota of their own.
+	Default model.AccountQuota
+}
+
+func NewQuotaRepository(defaultQuota model.AccountQuota) *QuotaRepository {
+	return &QuotaRepository{
+		quotas:  make(map[int]model.AccountQuota),
+		Default: defaultQuota,
+	}
+}
+
+func (r *QuotaRepository) Snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	quotas := maps.Clone(r.quotas)
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.quotas = quotas
+	}
+}
+
+// SetAccountQuota configures the quota of a single account.
+func (r *QuotaRepository) SetAccountQuota(d model.AccountQuota) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.quotas[d.UserId] = d
+}
+
+func (r *QuotaRepository) FindAccountQuota(ctx context.Context, userId int) (*model.AccountQuota, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	quota, ok := r.quotas[userId]
+	if !ok {
+		quota = r.Default
+		quota.UserId = userId
+	}
+
+	return &quota, nil
+}
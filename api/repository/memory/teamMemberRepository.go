@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/model"
+	"slices"
+	"sync"
+	"time"
+)
+
+type TeamMemberRepository struct {
+	mu      sync.Mutex
+	nextId  int
+	members []model.TeamMember
+}
+
+func NewTeamMemberRepository() *TeamMemberRepository {
+	return &TeamMemberRepository{}
+}
+
+func (r *TeamMemberRepository) Snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nextId, members := r.nextId, slices.Clone(r.members)
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.nextId, r.members = nextId, members
+	}
+}
+
+func (r *TeamMemberRepository) AddTeamMember(ctx context.Context, d *model.TeamMember) (*model.TeamMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, member := range r.members {
+		if member.AccountId == d.AccountId && member.UserId == d.UserId {
+			r.members[i].Role = d.Role
+			*d = r.members[i]
+			return d, nil
+		}
+	}
+
+	r.nextId++
+	d.ID = r.nextId
+	d.CreatedAt = time.Now()
+	r.members = append(r.members, *d)
+
+	return d, nil
+}
+
+func (r *TeamMemberRepository) FindTeamMember(ctx context.Context, accountId int, userId int) (*model.TeamMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, member := range r.members {
+		if member.AccountId == accountId && member.UserId == userId {
+			return &member, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
@@ -0,0 +1,42 @@
+package memory
+
+import "context"
+
+// Snapshotter is implemented by the in-memory repositories. Snapshot copies the state of the repository and
+// returns a function that puts it back.
+type Snapshotter interface {
+	Snapshot() (restore func())
+}
+
+type txKey struct{}
+
+// Transactor runs functions as if in a transaction: when fn fails, the changes it made to the repositories
+// given to NewTransactor are discarded, as a rollback would. Calls nested in a running transaction join it.
+// Concurrent transactions are not isolated from each other.
+type Transactor struct {
+	stores []Snapshotter
+}
+
+func NewTransactor(stores ...Snapshotter) *Transactor {
+	return &Transactor{stores: stores}
+}
+
+func (t *Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	restores := make([]func(), len(t.stores))
+	for i, store := range t.stores {
+		restores[i] = store.Snapshot()
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		for _, restore := range restores {
+			restore()
+		}
+		return err
+	}
+
+	return nil
+}
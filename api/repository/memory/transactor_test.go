@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"email-marketing-service/api/model"
+	"errors"
+	"testing"
+)
+
+func TestTransactorRollsBackWhenFnFails(t *testing.T) {
+	ctx := context.Background()
+	otps := NewOTPRepository()
+	transactor := NewTransactor(otps)
+
+	failure := errors.New("failed")
+
+	err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := otps.CreateOTP(ctx, &model.OTP{UserId: 1, Token: "12345678", UUID: "otp-uuid", Purpose: "verify_email"}); err != nil {
+			return err
+		}
+
+		// A nested call joins the running transaction and is rolled back with it.
+		return transactor.WithinTx(ctx, func(ctx context.Context) error {
+			if err := otps.CreateOTP(ctx, &model.OTP{UserId: 2, Token: "87654321", UUID: "other-uuid", Purpose: "verify_email"}); err != nil {
+				return err
+			}
+
+			return failure
+		})
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithinTx = %v, want %v", err, failure)
+	}
+
+	if otps.Count() != 0 {
+		t.Fatalf("expected the otps to be rolled back, got %d", otps.Count())
+	}
+
+	err = transactor.WithinTx(ctx, func(ctx context.Context) error {
+		return otps.CreateOTP(ctx, &model.OTP{UserId: 1, Token: "12345678", UUID: "otp-uuid", Purpose: "verify_email"})
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+
+	if otps.Count() != 1 {
+		t.Fatalf("expected the otp to be kept, got %d", otps.Count())
+	}
+}
@@ -0,0 +1,304 @@
+// Package memory provides in-memory implementations of the repository interfaces for tests.
+// They mirror the behaviour of the Postgres repositories, including the errors they return.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type userRecord struct {
+	user              model.User
+	pendingEmail      string
+	purgeAfter        time.Time
+	sessionsRevokedAt sql.NullTime
+}
+
+type UserRepository struct {
+	mu     sync.Mutex
+	nextId int
+	users  map[int]*userRecord
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: make(map[int]*userRecord)}
+}
+
+func (r *UserRepository) Snapshot() func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nextId := r.nextId
+	users := make(map[int]userRecord, len(r.users))
+	for id, record := range r.users {
+		users[id] = *record
+	}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.nextId = nextId
+		r.users = make(map[int]*userRecord, len(users))
+		for id, record := range users {
+			record := record
+			r.users[id] = &record
+		}
+	}
+}
+
+func now() sql.NullTime {
+	return sql.NullTime{Time: time.Now(), Valid: true}
+}
+
+func (r *UserRepository) findByEmail(email string) *userRecord {
+	for _, record := range r.users {
+		if record.user.Email == email && !record.user.DeletedAt.Valid {
+			return record
+		}
+	}
+	return nil
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, d *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextId++
+	d.ID = r.nextId
+	d.CreatedAt = time.Now()
+
+	r.users[d.ID] = &userRecord{user: *d}
+
+	return d, nil
+}
+
+func (r *UserRepository) CheckIfEmailAlreadyExists(ctx context.Context, d *model.User) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.findByEmail(d.Email) != nil, nil
+}
+
+func (r *UserRepository) VerifyUserAccount(ctx context.Context, d *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.users[d.ID]; ok {
+		record.user.Verified = d.Verified
+		record.user.VerifiedAt = d.VerifiedAt
+		record.user.UpdatedAt = now()
+	}
+
+	return nil
+}
+
+func (r *UserRepository) Login(ctx context.Context, d *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record := r.findByEmail(d.Email)
+	if record == nil || !record.user.Verified {
+		return nil, fmt.Errorf("no user found: %w", sql.ErrNoRows)
+	}
+
+	*d = record.user
+	return d, nil
+}
+
+func (r *UserRepository) FindUserById(ctx context.Context, d *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.users[d.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	*d = record.user
+	d.Password = nil
+	return d, nil
+}
+
+func (r *UserRepository) FindUserByEmail(ctx context.Context, d *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record := r.findByEmail(d.Email)
+	if record == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	d.ID = record.user.ID
+	d.UserName = record.user.UserName
+	d.Email = record.user.Email
+	return d, nil
+}
+
+func (r *UserRepository) ResetPassword(ctx context.Context, d *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.users[d.ID]; ok {
+		record.user.Password = d.Password
+		record.user.UpdatedAt = now()
+	}
+
+	return nil
+}
+
+func (r *UserRepository) FindUserPasswordById(ctx context.Context, d *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.users[d.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	d.Password = record.user.Password
+	return d, nil
+}
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, d *model.UpdateProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.users[d.ID]; ok {
+		record.user.FirstName = d.FirstName
+		record.user.MiddleName = d.MiddleName
+		record.user.LastName = d.LastName
+		record.user.UserName = d.UserName
+		record.user.UpdatedAt = now()
+	}
+
+	return nil
+}
+
+func (r *UserRepository) SetPendingEmail(ctx context.Context, d *model.ChangeEmail) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.users[d.ID]; ok {
+		record.pendingEmail = d.Email
+		record.user.UpdatedAt = now()
+	}
+
+	return nil
+}
+
+func (r *UserRepository) ConfirmEmailChange(ctx context.Context, d *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.users[d.ID]
+	if !ok || record.pendingEmail == "" {
+		return nil, fmt.Errorf("no pending email change or email already in use: %w", sql.ErrNoRows)
+	}
+
+	for id, other := range r.users {
+		if id != d.ID && other.user.Email == record.pendingEmail {
+			return nil, fmt.Errorf("no pending email change or email already in use: %w", sql.ErrNoRows)
+		}
+	}
+
+	record.user.Email = record.pendingEmail
+	record.pendingEmail = ""
+	record.user.UpdatedAt = now()
+
+	d.Email = record.user.Email
+	return d, nil
+}
+
+func (r *UserRepository) SoftDeleteUser(ctx context.Context, d *model.User, purgeAfter time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record, ok := r.users[d.ID]; ok && !record.user.DeletedAt.Valid {
+		record.user.DeletedAt = now()
+		record.sessionsRevokedAt = now()
+		record.purgeAfter = purgeAfter
+		record.user.UpdatedAt = now()
+	}
+
+	return nil
+}
+
+func (r *UserRepository) RestoreUser(ctx context.Context, d *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.users[d.ID]
+	if !ok || !record.user.DeletedAt.Valid || r.findByEmail(record.user.Email) != nil {
+		return repository.ErrAccountNotRestorable
+	}
+
+	record.user.DeletedAt = sql.NullTime{}
+	record.purgeAfter = time.Time{}
+	record.user.UpdatedAt = now()
+
+	return nil
+}
+
+func (r *UserRepository) FindSessionState(ctx context.Context, d *model.User) (*model.SessionState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.users[d.ID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &model.SessionState{
+		DeletedAt:         record.user.DeletedAt,
+		SessionsRevokedAt: record.sessionsRevokedAt,
+	}, nil
+}
+
+func (r *UserRepository) FindPurgeableUsers(ctx context.Context) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []int
+	for id := 1; id <= r.nextId; id++ {
+		if record, ok := r.users[id]; ok && record.user.DeletedAt.Valid && !record.purgeAfter.After(time.Now()) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r *UserRepository) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, record := range r.users {
+		if record.user.DeletedAt.Valid && !record.purgeAfter.After(time.Now()) {
+			delete(r.users, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (r *UserRepository) FindAllUsers(ctx context.Context) ([]model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := make([]model.User, 0, len(r.users))
+	for id := 1; id <= r.nextId; id++ {
+		if record, ok := r.users[id]; ok {
+			users = append(users, record.user)
+		}
+	}
+
+	return users, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"encoding/json"
+	"errors"
+)
+
+type MessageEventRepository struct {
+	DB *sql.DB
+}
+
+func NewMessageEventRepository(db *sql.DB) *MessageEventRepository {
+	return &MessageEventRepository{DB: db}
+}
+
+// FindSentMessage returns the sent event of the message with the given message id, with the contact it was
+// sent to.
+func (r *MessageEventRepository) FindSentMessage(ctx context.Context, messageId string) (*model.MessageEvent, error) {
+
+	query := `SELECT e.id, e.account_id, e.contact_id, c.uuid, c.email, e.type, e.data, e.occurred_at
+		FROM message_events e JOIN contacts c ON c.id = e.contact_id
+		WHERE e.type = 'sent' AND e.data ->> 'message_id' = $1
+		LIMIT 1`
+
+	var event model.MessageEvent
+	var data []byte
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, messageId).Scan(&event.ID, &event.AccountId, &event.ContactId, &event.ContactUUID, &event.ContactEmail, &event.Type, &data, &event.OccurredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &event.Data); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// RecordMessageEvent stores a message event and reports whether it is new. A bounce or complaint about a
+// message that was recorded before is not stored again.
+func (r *MessageEventRepository) RecordMessageEvent(ctx context.Context, d *model.MessageEvent) (bool, error) {
+	data, err := marshalAttributes(d.Data)
+	if err != nil {
+		return false, err
+	}
+
+	query := `INSERT INTO message_events (account_id, contact_id, type, data) VALUES ($1,$2,$3,$4)
+		ON CONFLICT DO NOTHING
+		RETURNING id, occurred_at`
+
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.ContactId, d.Type, data).Scan(&d.ID, &d.OccurredAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"email-marketing-service/api/database"
 	"email-marketing-service/api/model"
 	"fmt"
 )
@@ -14,11 +16,11 @@ func NewOTPRepository(db *sql.DB) *OTPRepository {
 	return &OTPRepository{DB: db}
 }
 
-func (r *OTPRepository) CreateOTP(d *model.OTP) error {
+func (r *OTPRepository) CreateOTP(ctx context.Context, d *model.OTP) error {
 
-	query := "Insert into otp (user_id,token,uuid)Values($1,$2,$3)"
+	query := "Insert into otp (user_id,token,uuid,purpose,expires_at)Values($1,$2,$3,$4,$5)"
 
-	_, err := r.DB.Exec(query, d.UserId, d.Token, d.UUID)
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.UserId, d.Token, d.UUID, d.Purpose, d.ExpiresAt)
 
 	if err != nil {
 		return err
@@ -28,13 +30,14 @@ func (r *OTPRepository) CreateOTP(d *model.OTP) error {
 
 }
 
-func (r *OTPRepository) FindOTP(d *model.OTP) (*model.OTP, error) {
+// FindOTP returns the unexpired OTP with the token and purpose of d.
+func (r *OTPRepository) FindOTP(ctx context.Context, d *model.OTP) (*model.OTP, error) {
 
-	query := "SELECT id,user_id, token, created_at,uuid FROM otp WHERE token = $1"
-	row := r.DB.QueryRow(query, d.Token)
+	query := "SELECT id,user_id, token, created_at,uuid, purpose, expires_at FROM otp WHERE token = $1 AND purpose = $2 AND expires_at > now()"
+	row := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.Token, d.Purpose)
 
 	var otp model.OTP
-	err := row.Scan(&otp.Id, &otp.UserId, &otp.Token, &otp.CreatedAt, &otp.UUID)
+	err := row.Scan(&otp.Id, &otp.UserId, &otp.Token, &otp.CreatedAt, &otp.UUID, &otp.Purpose, &otp.ExpiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("otp does not exist: %w", err) // OTP not found, return nil without an error
@@ -45,10 +48,10 @@ func (r *OTPRepository) FindOTP(d *model.OTP) (*model.OTP, error) {
 	return &otp, nil
 }
 
-func (r *OTPRepository) DeleteOTP(id int) error {
+func (r *OTPRepository) DeleteOTP(ctx context.Context, id int) error {
 
 	query := "DELETE FROM otp WHERE id = $1"
-	_, err := r.DB.Exec(query, id)
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
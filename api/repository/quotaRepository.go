@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"fmt"
+)
+
+// DefaultPlan is used for accounts without a row in account_quotas.
+const DefaultPlan = "free"
+
+type QuotaRepository struct {
+	DB *sql.DB
+}
+
+func NewQuotaRepository(db *sql.DB) *QuotaRepository {
+	return &QuotaRepository{DB: db}
+}
+
+// FindAccountQuota returns the limits of the account's plan, overridden by any non-null column of its account_quotas row.
+func (r *QuotaRepository) FindAccountQuota(ctx context.Context, userId int) (*model.AccountQuota, error) {
+
+	query := `SELECT p.name,
+			COALESCE(q.requests_per_second, p.requests_per_second),
+			COALESCE(q.request_burst, p.request_burst),
+			COALESCE(q.messages_per_hour, p.messages_per_hour),
+			COALESCE(q.messages_per_day, p.messages_per_day)
+		FROM plans p
+		LEFT JOIN account_quotas q ON q.user_id = $1 AND q.plan = p.name
+		WHERE p.name = COALESCE((SELECT plan FROM account_quotas WHERE user_id = $1), $2)`
+
+	quota := model.AccountQuota{UserId: userId}
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, userId, DefaultPlan).Scan(&quota.Plan, &quota.RequestsPerSecond, &quota.RequestBurst, &quota.MessagesPerHour, &quota.MessagesPerDay)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no plan configured for account: %w", err)
+		}
+		return nil, err
+	}
+
+	return &quota, nil
+}
+
+// SaveAccountQuota replaces the account_quotas row of an account.
+func (r *QuotaRepository) SaveAccountQuota(ctx context.Context, d *model.QuotaOverride) error {
+
+	query := `INSERT INTO account_quotas (user_id, plan, requests_per_second, request_burst, messages_per_hour, messages_per_day)
+		VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (user_id) DO UPDATE SET plan = EXCLUDED.plan,
+			requests_per_second = EXCLUDED.requests_per_second,
+			request_burst = EXCLUDED.request_burst,
+			messages_per_hour = EXCLUDED.messages_per_hour,
+			messages_per_day = EXCLUDED.messages_per_day`
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.UserId, d.Plan, d.RequestsPerSecond, d.RequestBurst, d.MessagesPerHour, d.MessagesPerDay)
+	return err
+}
+
+// DeleteAccountQuota puts an account back on the default plan without overrides.
+func (r *QuotaRepository) DeleteAccountQuota(ctx context.Context, userId int) error {
+
+	query := "DELETE FROM account_quotas WHERE user_id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, userId)
+	return err
+}
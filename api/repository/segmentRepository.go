@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"encoding/json"
+	"fmt"
+)
+
+type SegmentRepository struct {
+	DB *sql.DB
+}
+
+func NewSegmentRepository(db *sql.DB) *SegmentRepository {
+	return &SegmentRepository{DB: db}
+}
+
+const segmentColumns = "id, uuid, account_id, name, rules, created_at, updated_at"
+
+func scanSegment(row scanner) (*model.Segment, error) {
+	var segment model.Segment
+	var rules []byte
+
+	err := row.Scan(&segment.ID, &segment.UUID, &segment.AccountId, &segment.Name, &rules, &segment.CreatedAt, &segment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(rules, &segment.Rules); err != nil {
+		return nil, fmt.Errorf("invalid rules of segment %d: %w", segment.ID, err)
+	}
+
+	return &segment, nil
+}
+
+func (r *SegmentRepository) CreateSegment(ctx context.Context, d *model.Segment) (*model.Segment, error) {
+	rules, err := json.Marshal(d.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO segments (uuid, account_id, name, rules) VALUES ($1,$2,$3,$4) RETURNING id, created_at, updated_at"
+
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, rules).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *SegmentRepository) FindSegmentByUUID(ctx context.Context, d *model.Segment) (*model.Segment, error) {
+
+	query := "SELECT " + segmentColumns + " FROM segments WHERE uuid = $1 AND account_id = $2"
+
+	return scanSegment(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+func (r *SegmentRepository) FindSegments(ctx context.Context, accountId int) ([]model.Segment, error) {
+
+	query := "SELECT " + segmentColumns + " FROM segments WHERE account_id = $1 ORDER BY name"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []model.Segment{}
+
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, *segment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
+
+func (r *SegmentRepository) UpdateSegment(ctx context.Context, d *model.Segment) (*model.Segment, error) {
+	rules, err := json.Marshal(d.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE segments SET name = $3, rules = $4, updated_at = now()
+		WHERE uuid = $1 AND account_id = $2
+		RETURNING ` + segmentColumns
+
+	return scanSegment(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, rules))
+}
+
+func (r *SegmentRepository) CheckIfSegmentInUse(ctx context.Context, segmentId int) (bool, error) {
+
+	query := "SELECT EXISTS(SELECT 1 FROM campaigns WHERE segment_id = $1)"
+
+	var inUse bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, segmentId).Scan(&inUse)
+
+	if err != nil {
+		return false, err
+	}
+
+	return inUse, nil
+}
+
+func (r *SegmentRepository) DeleteSegment(ctx context.Context, segmentId int) error {
+
+	query := "DELETE FROM segments WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, segmentId)
+
+	return err
+}
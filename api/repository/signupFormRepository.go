@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"encoding/json"
+	"fmt"
+)
+
+type SignupFormRepository struct {
+	DB *sql.DB
+}
+
+func NewSignupFormRepository(db *sql.DB) *SignupFormRepository {
+	return &SignupFormRepository{DB: db}
+}
+
+// signupFormSelect reads signup forms with the UUID of their list.
+const signupFormSelect = `SELECT f.id, f.uuid, f.public_id, f.account_id, f.name, f.title, f.list_id, l.uuid, f.fields,
+		f.double_opt_in, f.redirect_url, f.success_message, f.created_at, f.updated_at
+	FROM signup_forms f JOIN lists l ON l.id = f.list_id`
+
+func scanSignupForm(row scanner) (*model.SignupForm, error) {
+	var form model.SignupForm
+	var fields []byte
+
+	err := row.Scan(&form.ID, &form.UUID, &form.PublicId, &form.AccountId, &form.Name, &form.Title, &form.ListId, &form.ListUUID, &fields,
+		&form.DoubleOptIn, &form.RedirectURL, &form.SuccessMessage, &form.CreatedAt, &form.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(fields, &form.Fields); err != nil {
+		return nil, fmt.Errorf("invalid fields of signup form %d: %w", form.ID, err)
+	}
+
+	return &form, nil
+}
+
+func marshalFormFields(fields []model.FormField) ([]byte, error) {
+	if fields == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(fields)
+}
+
+func (r *SignupFormRepository) CreateSignupForm(ctx context.Context, d *model.SignupForm) (*model.SignupForm, error) {
+	fields, err := marshalFormFields(d.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO signup_forms (uuid, public_id, account_id, name, title, list_id, fields, double_opt_in, redirect_url, success_message)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id, created_at, updated_at`
+
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.PublicId, d.AccountId, d.Name, d.Title, d.ListId, fields,
+		d.DoubleOptIn, d.RedirectURL, d.SuccessMessage).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *SignupFormRepository) FindSignupFormByUUID(ctx context.Context, d *model.SignupForm) (*model.SignupForm, error) {
+
+	query := signupFormSelect + " WHERE f.uuid = $1 AND f.account_id = $2"
+
+	return scanSignupForm(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+func (r *SignupFormRepository) FindSignupFormByPublicId(ctx context.Context, publicId string) (*model.SignupForm, error) {
+
+	query := signupFormSelect + " WHERE f.public_id = $1"
+
+	return scanSignupForm(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, publicId))
+}
+
+func (r *SignupFormRepository) FindSignupForms(ctx context.Context, accountId int) ([]model.SignupForm, error) {
+
+	query := signupFormSelect + " WHERE f.account_id = $1 ORDER BY f.name"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forms := []model.SignupForm{}
+
+	for rows.Next() {
+		form, err := scanSignupForm(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		forms = append(forms, *form)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return forms, nil
+}
+
+func (r *SignupFormRepository) UpdateSignupForm(ctx context.Context, d *model.SignupForm) error {
+	fields, err := marshalFormFields(d.Fields)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE signup_forms SET name = $3, title = $4, list_id = $5, fields = $6, double_opt_in = $7, redirect_url = $8,
+			success_message = $9, updated_at = now()
+		WHERE uuid = $1 AND account_id = $2 RETURNING id`
+
+	return database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, d.Title, d.ListId, fields,
+		d.DoubleOptIn, d.RedirectURL, d.SuccessMessage).Scan(&d.ID)
+}
+
+func (r *SignupFormRepository) DeleteSignupForm(ctx context.Context, d *model.SignupForm) error {
+
+	query := "DELETE FROM signup_forms WHERE uuid = $1 AND account_id = $2 RETURNING id"
+
+	return database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId).Scan(&d.ID)
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+
+	"github.com/lib/pq"
+)
+
+type TagRepository struct {
+	DB *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) *TagRepository {
+	return &TagRepository{DB: db}
+}
+
+// EnsureTags creates the tags of names the account does not have yet and returns all of them.
+// Existing tags keep the case they were created with.
+func (r *TagRepository) EnsureTags(ctx context.Context, accountId int, names []string) ([]model.Tag, error) {
+
+	insert := `INSERT INTO tags (account_id, name) SELECT $1, unnest($2::varchar[])
+		ON CONFLICT (account_id, lower(name)) DO NOTHING`
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, insert, accountId, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, account_id, name, created_at FROM tags
+		WHERE account_id = $1 AND lower(name) IN (SELECT lower(unnest($2::varchar[])))`
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.ID, &tag.AccountId, &tag.Name, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// TagContacts adds the tags to the account's contacts with the given UUIDs and returns the pairs that were
+// not tagged yet. UUIDs of unknown contacts are ignored.
+func (r *TagRepository) TagContacts(ctx context.Context, accountId int, tagIds []int, contactUUIDs []string) ([]model.TaggedContact, error) {
+
+	query := `WITH added AS (
+			INSERT INTO contact_tags (contact_id, tag_id)
+			SELECT c.id, t.id FROM contacts c CROSS JOIN unnest($2::integer[]) AS t(id)
+			WHERE c.account_id = $1 AND c.uuid = ANY($3)
+			ON CONFLICT DO NOTHING
+			RETURNING contact_id, tag_id
+		)
+		SELECT added.contact_id, added.tag_id, tags.name FROM added JOIN tags ON tags.id = added.tag_id`
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId, pq.Array(tagIds), pq.Array(contactUUIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	added := []model.TaggedContact{}
+
+	for rows.Next() {
+		tagged := model.TaggedContact{AccountId: accountId}
+		if err := rows.Scan(&tagged.ContactId, &tagged.TagId, &tagged.Tag); err != nil {
+			return nil, err
+		}
+
+		added = append(added, tagged)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// UntagContacts removes the tags with the given names from the contacts and returns how many were removed.
+func (r *TagRepository) UntagContacts(ctx context.Context, accountId int, names []string, contactUUIDs []string) (int, error) {
+
+	query := `DELETE FROM contact_tags ct USING contacts c, tags t
+		WHERE ct.contact_id = c.id AND ct.tag_id = t.id
+		AND c.account_id = $1 AND c.uuid = ANY($3)
+		AND t.account_id = $1 AND lower(t.name) IN (SELECT lower(unnest($2::varchar[])))`
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, accountId, pq.Array(names), pq.Array(contactUUIDs))
+	if err != nil {
+		return 0, err
+	}
+
+	removed, err := result.RowsAffected()
+
+	return int(removed), err
+}
+
+// FindTags returns the tags of an account with the number of contacts that have them.
+func (r *TagRepository) FindTags(ctx context.Context, accountId int) ([]model.Tag, error) {
+
+	query := `SELECT t.id, t.account_id, t.name, count(ct.contact_id), t.created_at
+		FROM tags t LEFT JOIN contact_tags ct ON ct.tag_id = t.id
+		WHERE t.account_id = $1 GROUP BY t.id ORDER BY lower(t.name)`
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.ID, &tag.AccountId, &tag.Name, &tag.Contacts, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+)
+
+type TeamMemberRepository struct {
+	DB *sql.DB
+}
+
+func NewTeamMemberRepository(db *sql.DB) *TeamMemberRepository {
+	return &TeamMemberRepository{DB: db}
+}
+
+// AddTeamMember links a user to an account. Adding an existing member updates their role.
+func (r *TeamMemberRepository) AddTeamMember(ctx context.Context, d *model.TeamMember) (*model.TeamMember, error) {
+
+	query := `INSERT INTO team_members (account_id, user_id, role) VALUES ($1,$2,$3)
+		ON CONFLICT (account_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING id, created_at`
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.UserId, d.Role).Scan(&d.ID, &d.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// FindTeamMember returns the membership of a user in an account. Accounts that are deleted have no members.
+func (r *TeamMemberRepository) FindTeamMember(ctx context.Context, accountId int, userId int) (*model.TeamMember, error) {
+
+	query := `SELECT tm.id, tm.account_id, tm.user_id, tm.role, tm.created_at FROM team_members tm
+		JOIN users u ON u.id = tm.account_id
+		WHERE tm.account_id = $1 AND tm.user_id = $2 AND u.deleted_at IS NULL`
+
+	var member model.TeamMember
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, accountId, userId).Scan(&member.ID, &member.AccountId, &member.UserId, &member.Role, &member.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+
+	"github.com/lib/pq"
+)
+
+type TopicRepository struct {
+	DB *sql.DB
+}
+
+func NewTopicRepository(db *sql.DB) *TopicRepository {
+	return &TopicRepository{DB: db}
+}
+
+const topicColumns = "id, uuid, account_id, name, description, created_at, updated_at"
+
+func scanTopic(row scanner) (*model.Topic, error) {
+	var topic model.Topic
+
+	err := row.Scan(&topic.ID, &topic.UUID, &topic.AccountId, &topic.Name, &topic.Description, &topic.CreatedAt, &topic.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &topic, nil
+}
+
+func (r *TopicRepository) CreateTopic(ctx context.Context, d *model.Topic) (*model.Topic, error) {
+
+	query := "INSERT INTO topics (uuid, account_id, name, description) VALUES ($1,$2,$3,$4) RETURNING id, created_at, updated_at"
+
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, d.Description).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// CheckIfTopicNameTaken reports whether another topic of the account has the name of d, regardless of case.
+func (r *TopicRepository) CheckIfTopicNameTaken(ctx context.Context, d *model.Topic) (bool, error) {
+
+	query := "SELECT EXISTS(SELECT 1 FROM topics WHERE account_id = $1 AND lower(name) = lower($2) AND uuid <> $3)"
+
+	var taken bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.AccountId, d.Name, d.UUID).Scan(&taken)
+
+	if err != nil {
+		return false, err
+	}
+
+	return taken, nil
+}
+
+func (r *TopicRepository) FindTopicByUUID(ctx context.Context, d *model.Topic) (*model.Topic, error) {
+
+	query := "SELECT " + topicColumns + " FROM topics WHERE uuid = $1 AND account_id = $2"
+
+	return scanTopic(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+func (r *TopicRepository) FindTopics(ctx context.Context, accountId int) ([]model.Topic, error) {
+
+	query := "SELECT " + topicColumns + " FROM topics WHERE account_id = $1 ORDER BY name"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []model.Topic{}
+
+	for rows.Next() {
+		topic, err := scanTopic(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		topics = append(topics, *topic)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+func (r *TopicRepository) UpdateTopic(ctx context.Context, d *model.Topic) (*model.Topic, error) {
+
+	query := `UPDATE topics SET name = $3, description = $4, updated_at = now()
+		WHERE uuid = $1 AND account_id = $2
+		RETURNING ` + topicColumns
+
+	return scanTopic(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, d.Description))
+}
+
+// CheckIfTopicInUse reports whether a campaign or a send step of a workflow is under the topic.
+func (r *TopicRepository) CheckIfTopicInUse(ctx context.Context, topicId int) (bool, error) {
+
+	query := `SELECT EXISTS(SELECT 1 FROM campaigns WHERE topic_id = $1)
+		OR EXISTS(SELECT 1 FROM topics t JOIN workflows w ON w.account_id = t.account_id
+			WHERE t.id = $1 AND EXISTS (SELECT 1 FROM jsonb_array_elements(w.steps) s WHERE s ->> 'topic_uuid' = t.uuid))`
+
+	var inUse bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, topicId).Scan(&inUse)
+
+	if err != nil {
+		return false, err
+	}
+
+	return inUse, nil
+}
+
+func (r *TopicRepository) DeleteTopic(ctx context.Context, topicId int) error {
+
+	query := "DELETE FROM topics WHERE id = $1"
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, topicId)
+
+	return err
+}
+
+// CheckIfOptedOut reports whether the contact opted out of the topic with the given uuid.
+func (r *TopicRepository) CheckIfOptedOut(ctx context.Context, contactId int, topicUUID string) (bool, error) {
+
+	query := `SELECT EXISTS(SELECT 1 FROM contact_topic_optouts o JOIN topics t ON t.id = o.topic_id
+		WHERE o.contact_id = $1 AND t.uuid = $2)`
+
+	var optedOut bool
+	err := database.Conn(ctx, r.DB).QueryRowContext(ctx, query, contactId, topicUUID).Scan(&optedOut)
+
+	if err != nil {
+		return false, err
+	}
+
+	return optedOut, nil
+}
+
+// FindTopicPreferences returns the topics of the account and whether the contact receives each of them.
+func (r *TopicRepository) FindTopicPreferences(ctx context.Context, accountId int, contactId int) ([]model.TopicPreference, error) {
+
+	query := `SELECT t.uuid, t.name, t.description, o.contact_id IS NULL
+		FROM topics t LEFT JOIN contact_topic_optouts o ON o.topic_id = t.id AND o.contact_id = $2
+		WHERE t.account_id = $1 ORDER BY t.name`
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId, contactId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []model.TopicPreference{}
+
+	for rows.Next() {
+		var topic model.TopicPreference
+		if err := rows.Scan(&topic.UUID, &topic.Name, &topic.Description, &topic.Subscribed); err != nil {
+			return nil, err
+		}
+
+		topics = append(topics, topic)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+// SetTopicOptOuts opts the contact out of every topic of the account except the ones with the given UUIDs,
+// and back into those.
+func (r *TopicRepository) SetTopicOptOuts(ctx context.Context, accountId int, contactId int, subscribedUUIDs []string) error {
+	if subscribedUUIDs == nil {
+		subscribedUUIDs = []string{}
+	}
+
+	conn := database.Conn(ctx, r.DB)
+
+	query := "DELETE FROM contact_topic_optouts o USING topics t WHERE t.id = o.topic_id AND o.contact_id = $1 AND t.uuid = ANY($2)"
+
+	_, err := conn.ExecContext(ctx, query, contactId, pq.Array(subscribedUUIDs))
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO contact_topic_optouts (contact_id, topic_id)
+		SELECT $2, id FROM topics WHERE account_id = $1 AND NOT uuid = ANY($3)
+		ON CONFLICT DO NOTHING`
+
+	_, err = conn.ExecContext(ctx, query, accountId, contactId, pq.Array(subscribedUUIDs))
+
+	return err
+}
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WorkflowRepository struct {
+	DB *sql.DB
+}
+
+func NewWorkflowRepository(db *sql.DB) *WorkflowRepository {
+	return &WorkflowRepository{DB: db}
+}
+
+const workflowColumns = "id, uuid, account_id, name, status, trigger, steps, created_at, updated_at"
+
+func scanWorkflow(row scanner) (*model.Workflow, error) {
+	var workflow model.Workflow
+	var trigger, steps []byte
+
+	err := row.Scan(&workflow.ID, &workflow.UUID, &workflow.AccountId, &workflow.Name, &workflow.Status, &trigger, &steps, &workflow.CreatedAt, &workflow.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(trigger, &workflow.Trigger); err != nil {
+		return nil, fmt.Errorf("invalid trigger of workflow %d: %w", workflow.ID, err)
+	}
+
+	if err := json.Unmarshal(steps, &workflow.Steps); err != nil {
+		return nil, fmt.Errorf("invalid steps of workflow %d: %w", workflow.ID, err)
+	}
+
+	return &workflow, nil
+}
+
+func (r *WorkflowRepository) CreateWorkflow(ctx context.Context, d *model.Workflow) (*model.Workflow, error) {
+	trigger, err := json.Marshal(d.Trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := json.Marshal(d.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO workflows (uuid, account_id, name, status, trigger, steps) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id, created_at, updated_at"
+
+	err = database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, d.Status, trigger, steps).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (r *WorkflowRepository) FindWorkflowByUUID(ctx context.Context, d *model.Workflow) (*model.Workflow, error) {
+
+	query := "SELECT " + workflowColumns + " FROM workflows WHERE uuid = $1 AND account_id = $2"
+
+	return scanWorkflow(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId))
+}
+
+func (r *WorkflowRepository) FindWorkflowById(ctx context.Context, workflowId int) (*model.Workflow, error) {
+
+	query := "SELECT " + workflowColumns + " FROM workflows WHERE id = $1"
+
+	return scanWorkflow(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, workflowId))
+}
+
+func (r *WorkflowRepository) FindWorkflows(ctx context.Context, accountId int) ([]model.Workflow, error) {
+
+	query := "SELECT " + workflowColumns + " FROM workflows WHERE account_id = $1 ORDER BY name"
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workflows := []model.Workflow{}
+
+	for rows.Next() {
+		workflow, err := scanWorkflow(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		workflows = append(workflows, *workflow)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return workflows, nil
+}
+
+func (r *WorkflowRepository) UpdateWorkflow(ctx context.Context, d *model.Workflow) (*model.Workflow, error) {
+	trigger, err := json.Marshal(d.Trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := json.Marshal(d.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE workflows SET name = $3, status = $4, trigger = $5, steps = $6, updated_at = now()
+		WHERE uuid = $1 AND account_id = $2
+		RETURNING ` + workflowColumns
+
+	return scanWorkflow(database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId, d.Name, d.Status, trigger, steps))
+}
+
+// DeleteWorkflow deletes a workflow together with its runs.
+func (r *WorkflowRepository) DeleteWorkflow(ctx context.Context, d *model.Workflow) error {
+
+	query := "DELETE FROM workflows WHERE uuid = $1 AND account_id = $2 RETURNING id"
+
+	return database.Conn(ctx, r.DB).QueryRowContext(ctx, query, d.UUID, d.AccountId).Scan(&d.ID)
+}
+
+// enroll starts a run of every active workflow matching the condition on w for each subscribed contact with
+// one of contactIds, at the first step. Contacts already going through a workflow are skipped, so a trigger
+// firing twice starts one run.
+func (r *WorkflowRepository) enroll(ctx context.Context, match string, contactIds []int, args ...any) (int, error) {
+	if contactIds == nil {
+		contactIds = []int{}
+	}
+
+	query := `INSERT INTO workflow_runs (workflow_id, account_id, contact_id, step_id)
+		SELECT w.id, w.account_id, c.id, w.steps -> 0 ->> 'id'
+		FROM workflows w JOIN contacts c ON c.account_id = w.account_id AND c.id = ANY($1) AND c.status = 'subscribed'
+		WHERE w.status = 'active' AND ` + match + `
+		ON CONFLICT DO NOTHING`
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, append([]any{pq.Array(contactIds)}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	started, err := result.RowsAffected()
+
+	return int(started), err
+}
+
+// EnrollOnListJoined starts the workflows triggered by joining the list for the given contacts of its account.
+func (r *WorkflowRepository) EnrollOnListJoined(ctx context.Context, listId int, contactIds []int) (int, error) {
+	return r.enroll(ctx, `w.trigger ->> 'type' = 'list_joined'
+		AND w.trigger ->> 'list_uuid' = (SELECT uuid FROM lists WHERE id = $2 AND account_id = w.account_id)`, contactIds, listId)
+}
+
+// EnrollOnTagAdded starts the workflows of the account triggered by the tag for the given contacts.
+func (r *WorkflowRepository) EnrollOnTagAdded(ctx context.Context, accountId int, tag string, contactIds []int) (int, error) {
+	return r.enroll(ctx, `w.account_id = $2 AND w.trigger ->> 'type' = 'tag_added'
+		AND lower(w.trigger ->> 'tag') = lower($3)`, contactIds, accountId, tag)
+}
+
+// EnrollOnEvent starts the workflows of the account triggered by the custom event for the given contacts.
+func (r *WorkflowRepository) EnrollOnEvent(ctx context.Context, accountId int, event string, contactIds []int) (int, error) {
+	return r.enroll(ctx, `w.account_id = $2 AND w.trigger ->> 'type' = 'event'
+		AND w.trigger ->> 'event' = $3`, contactIds, accountId, event)
+}
+
+// EnrollAnniversaries starts the date_anniversary workflows for the subscribed contacts whose date attribute
+// has today's month and day in UTC. The run is keyed by the year, so it starts once a year however often
+// this is called.
+func (r *WorkflowRepository) EnrollAnniversaries(ctx context.Context) (int, error) {
+
+	query := `INSERT INTO workflow_runs (workflow_id, account_id, contact_id, step_id, trigger_key)
+		SELECT w.id, w.account_id, c.id, w.steps -> 0 ->> 'id', to_char(now() AT TIME ZONE 'UTC', 'YYYY')
+		FROM workflows w JOIN contacts c ON c.account_id = w.account_id AND c.status = 'subscribed'
+		WHERE w.status = 'active' AND w.trigger ->> 'type' = 'date_anniversary'
+		AND c.attributes ->> (w.trigger ->> 'attribute') ~ '^\d{4}-\d{2}-\d{2}'
+		AND substr(c.attributes ->> (w.trigger ->> 'attribute'), 5, 6) = to_char(now() AT TIME ZONE 'UTC', '-MM-DD')
+		ON CONFLICT DO NOTHING`
+
+	result, err := database.Conn(ctx, r.DB).ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	started, err := result.RowsAffected()
+
+	return int(started), err
+}
+
+const workflowRunColumns = `r.id, r.workflow_id, r.account_id, r.contact_id, c.uuid, r.status, r.step_id, r.error,
+	r.next_run_at, r.created_at, r.updated_at, r.completed_at`
+
+func scanWorkflowRun(row scanner) (*model.WorkflowRun, error) {
+	var run model.WorkflowRun
+
+	err := row.Scan(&run.ID, &run.WorkflowId, &run.AccountId, &run.ContactId, &run.ContactUUID, &run.Status, &run.StepId, &run.Error,
+		&run.NextRunAt, &run.CreatedAt, &run.UpdatedAt, &run.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// ClaimDueRun locks the active run of an active workflow that is due the longest, or returns sql.ErrNoRows
+// when none is due. It must be called inside a transaction, which holds the run until it ends; other
+// instances skip locked runs, so each run is advanced by one of them at a time.
+func (r *WorkflowRepository) ClaimDueRun(ctx context.Context) (*model.WorkflowRun, error) {
+
+	query := "SELECT " + workflowRunColumns + `
+		FROM workflow_runs r JOIN contacts c ON c.id = r.contact_id JOIN workflows w ON w.id = r.workflow_id
+		WHERE r.status = 'active' AND r.next_run_at <= now() AND w.status = 'active'
+		ORDER BY r.next_run_at LIMIT 1
+		FOR UPDATE OF r SKIP LOCKED`
+
+	return scanWorkflowRun(database.Conn(ctx, r.DB).QueryRowContext(ctx, query))
+}
+
+// UpdateRun stores the position and status of a run. Runs that are no longer active are completed.
+func (r *WorkflowRepository) UpdateRun(ctx context.Context, d *model.WorkflowRun) error {
+
+	query := `UPDATE workflow_runs SET status = $2, step_id = $3, error = $4, next_run_at = $5, updated_at = now(),
+		completed_at = CASE WHEN $2 = 'active' THEN NULL ELSE now() END
+		WHERE id = $1`
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.Status, d.StepId, d.Error, d.NextRunAt)
+
+	return err
+}
+
+// RetryRun postpones a run whose step failed until the given time and keeps the error. It is called in the
+// transaction that claimed the run, so no other instance can advance the run first; the run is left alone
+// if it changed since it was read anyway.
+func (r *WorkflowRepository) RetryRun(ctx context.Context, d *model.WorkflowRun, at time.Time, message string) error {
+
+	query := `UPDATE workflow_runs SET next_run_at = $3, error = $4, updated_at = now()
+		WHERE id = $1 AND status = 'active' AND updated_at = $2`
+
+	_, err := database.Conn(ctx, r.DB).ExecContext(ctx, query, d.ID, d.UpdatedAt, at, message)
+
+	return err
+}
+
+// FindRuns returns the newest runs of a workflow.
+func (r *WorkflowRepository) FindRuns(ctx context.Context, workflowId int, limit int) ([]model.WorkflowRun, error) {
+
+	query := "SELECT " + workflowRunColumns + `
+		FROM workflow_runs r JOIN contacts c ON c.id = r.contact_id
+		WHERE r.workflow_id = $1 ORDER BY r.created_at DESC, r.id DESC LIMIT $2`
+
+	rows, err := database.Conn(ctx, r.DB).QueryContext(ctx, query, workflowId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []model.WorkflowRun{}
+
+	for rows.Next() {
+		run, err := scanWorkflowRun(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, *run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
@@ -2,80 +2,296 @@ package routes
 
 import (
 	"context"
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/config"
 	"email-marketing-service/api/controllers"
+	"email-marketing-service/api/custom"
 	"email-marketing-service/api/database"
+	"email-marketing-service/api/lifecycle"
+	"email-marketing-service/api/logging"
+	"email-marketing-service/api/middleware"
+	"email-marketing-service/api/ratelimit"
 	"email-marketing-service/api/repository"
 	"email-marketing-service/api/services"
 	"email-marketing-service/api/utils"
+	smtpserver "email-marketing-service/smtp_server"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"time"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/gorilla/mux"
 )
 
-var key = os.Getenv("JWT_KEY")
-
-func JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	utils.LoadEnv()
-	response := &utils.ApiResponse{}
-	return func(w http.ResponseWriter, r *http.Request) {
-		tokenString := utils.ExtractTokenFromHeader(r)
-		if tokenString == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+var (
+	response        = &utils.ApiResponse{}
+	errUnauthorized = apperrors.NewUnauthorized("unauthorized", "Unauthorized")
+)
 
-		// Define the secret key used for verification
-		secretKey := []byte(key)
+// JWTMiddleware returns middleware that only lets requests with a valid bearer token through
+// and stores the token's claims in the request context under "jwtclaims".
+func JWTMiddleware(jwtManager *utils.JWTManager) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tokenString := utils.ExtractTokenFromHeader(r)
+			if tokenString == "" {
+				response.ErrorResponse(w, r, errUnauthorized)
+				return
+			}
 
-		// Parse and verify the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			// Parse and verify the token
+			jwtclaims, err := jwtManager.Decode(tokenString)
+			if err != nil {
+				response.ErrorResponse(w, r, errUnauthorized)
+				return
 			}
-			return secretKey, nil
-		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		jwtclaims, ok := token.Claims.(jwt.MapClaims)
 
-		if !ok {
+			if sub, ok := jwtclaims["sub"].(float64); ok {
+				logging.SetAccountID(r.Context(), int(sub))
+			}
 
-			response.ErrorResponse(w, "invalid jwt claims")
-			return
+			ctx := context.WithValue(r.Context(), "jwtclaims", jwtclaims)
+			// Proceed to the next handler
+			next(w, r.WithContext(ctx))
 		}
-
-		ctx := context.WithValue(r.Context(), "jwtclaims", jwtclaims)
-		// Proceed to the next handler
-		next(w, r.WithContext(ctx))
 	}
 }
 
-var RegisterRoutes = func(router *mux.Router) {
+// RegisterRoutes wires the dashboard handlers onto router and the unauthenticated endpoints embedded
+// on third party sites, such as tracking and signup forms, onto public. It returns the background
+// workers the handlers depend on; the caller owns them and has to run them through the application lifecycle.
+var RegisterRoutes = func(router *mux.Router, public *mux.Router, db *sql.DB, cfg *config.Config) []lifecycle.Component {
+	var workers []lifecycle.Component
+
+	transactor := database.NewTxManager(db)
+	mailer := custom.NewSMTPMailer(cfg.Mail, cfg.App.Name, cfg.App.URL)
+	jwtManager := utils.NewJWTManager(cfg.Auth)
+	requireJWT := JWTMiddleware(jwtManager)
 
-	// Initialize the database connection pool
-	db, err := database.InitDB()
-	if err != nil {
-		fmt.Println("Failed to connect to the database")
-		return
+	//initialize the rate limiting dependencies
+	var limiter ratelimit.Limiter
+	if cfg.RateLimit.Store == "postgres" {
+		limiter = ratelimit.NewPostgresLimiter(db)
+	} else {
+		memoryLimiter := ratelimit.NewMemoryLimiter()
+		workers = append(workers, lifecycle.NewPeriodicWorker("rate limit cleanup", 10*time.Minute, func(ctx context.Context) error {
+			memoryLimiter.Cleanup()
+			return nil
+		}))
+		limiter = memoryLimiter
 	}
+	quotaRepo := repository.NewQuotaRepository(db)
+	quotaService := services.NewQuotaService(quotaRepo, limiter)
+	quotaController := controllers.NewQuotaController(quotaService)
+	rateLimiter := middleware.NewRateLimiter(quotaService)
 
 	//intialize the user  dependencies
 	otpRepo := repository.NewOTPRepository(db)
 	OTPService := services.NewOTPService(otpRepo)
 	UserRepo := repository.NewUserRepository(db)
-	UserServices := services.NewUserService(UserRepo, OTPService)
-	userController := controllers.NewUserController(UserServices)
+	sessionGuard := middleware.NewSessionGuard(UserRepo)
+
+	authenticated := func(next http.HandlerFunc) http.HandlerFunc {
+		return requireJWT(sessionGuard.RequireActiveSession(rateLimiter.Limit(next)))
+	}
+
+	UserServices := services.NewUserService(UserRepo, OTPService, mailer, jwtManager, transactor)
+	authAttemptRepo := repository.NewAuthAttemptRepository(db)
+	authAttemptService := services.NewAuthAttemptService(authAttemptRepo, UserRepo, mailer)
+	userController := controllers.NewUserController(UserServices, authAttemptService)
+
+	//initialize the team dependencies
+	invitationRepo := repository.NewInvitationRepository(db)
+	teamMemberRepo := repository.NewTeamMemberRepository(db)
+	teamService := services.NewTeamService(invitationRepo, teamMemberRepo, UserRepo, UserServices, mailer, jwtManager, cfg.App.URL, transactor)
+	teamController := controllers.NewTeamController(teamService)
+	teamGuard := middleware.NewTeamGuard(teamService)
+
+	// account only lets the owner of the account selected by the request, and its members with at least
+	// the given role, through
+	account := func(role string, next http.HandlerFunc) http.HandlerFunc {
+		return requireJWT(sessionGuard.RequireActiveSession(teamGuard.RequireRole(role, rateLimiter.Limit(next))))
+	}
+
+	//initialize the contact, segment and campaign dependencies
+	contactRepo := repository.NewContactRepository(db)
+	listRepo := repository.NewListRepository(db)
+	activityRepo := repository.NewActivityRepository(db)
+	contactService := services.NewContactService(contactRepo, listRepo, repository.NewTagRepository(db), activityRepo, transactor)
+	contactController := controllers.NewContactController(contactService)
+	segmentService := services.NewSegmentService(repository.NewSegmentRepository(db), contactRepo, transactor)
+	segmentController := controllers.NewSegmentController(segmentService)
+	topicRepo := repository.NewTopicRepository(db)
+	topicService := services.NewTopicService(topicRepo, transactor)
+	topicController := controllers.NewTopicController(topicService)
+	campaignService := services.NewCampaignService(repository.NewCampaignRepository(db), listRepo, segmentService, topicService)
+	campaignController := controllers.NewCampaignController(campaignService)
+	subscriptionService := services.NewSubscriptionService(contactRepo, listRepo, repository.NewConsentRepository(db), activityRepo, mailer, jwtManager, cfg.App.URL, transactor)
+	subscriptionController := controllers.NewSubscriptionController(subscriptionService)
+	signupFormService := services.NewSignupFormService(repository.NewSignupFormRepository(db), listRepo, subscriptionService)
+	signupFormController := controllers.NewSignupFormController(signupFormService)
+	preferenceService := services.NewPreferenceService(contactRepo, listRepo, topicRepo, activityRepo, jwtManager, cfg.App.URL, transactor)
+	preferenceController := controllers.NewPreferenceController(preferenceService)
+	exportService := services.NewExportService(repository.NewExportRepository(db), repository.NewAuditRepository(db), contactRepo, listRepo, segmentService, jwtManager, cfg.App.URL, cfg.Export.Dir, cfg.Export.LinkTTL)
+	exportController := controllers.NewExportController(exportService)
+	mailQueueRepo := repository.NewMailQueueRepository(db)
+	mailQueueService := services.NewMailQueueService(mailQueueRepo, quotaService, mailer)
+	workflowService := services.NewWorkflowService(repository.NewWorkflowRepository(db), contactRepo, listRepo, topicRepo, mailQueueRepo, contactService, preferenceService, quotaService, transactor)
+	workflowController := controllers.NewWorkflowController(workflowService)
+	contactService.OnListJoined(workflowService.ListJoined)
+	contactService.OnTagAdded(workflowService.TagAdded)
+	subscriptionService.OnListJoined(workflowService.ListJoined)
+	eventService := services.NewEventService(repository.NewEventRepository(db), contactRepo, workflowService, transactor)
+	eventController := controllers.NewEventController(eventService)
+	UserServices.OnPurge(exportService.DeleteAccountsFiles)
+	feedbackService := services.NewFeedbackService(repository.NewMessageEventRepository(db), contactService, quotaService, transactor)
+	trackingController := controllers.NewTrackingController(feedbackService)
+
+	// receive the bounces and complaints sent back to the return path of marketing mail
+	if cfg.SMTPServer.Port != 0 {
+		workers = append(workers, smtpserver.New(fmt.Sprintf(":%d", cfg.SMTPServer.Port), cfg.SMTPServer.Domain, cfg.SMTPServer.MaxMessageBytes, feedbackService))
+	}
+
+	// erase accounts whose deletion grace period has ended
+	workers = append(workers, lifecycle.NewPeriodicWorker("account purge", time.Hour, func(ctx context.Context) error {
+		purged, err := UserServices.PurgeDeletedAccounts(ctx)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			slog.InfoContext(ctx, "purged deleted accounts", "count", purged)
+		}
+		return nil
+	}))
 
-	router.HandleFunc("/greet", JWTMiddleware(userController.Welcome)).Methods("GET")
+	// send the queued marketing mail and report how much of it is waiting
+	workers = append(workers, lifecycle.NewPeriodicWorker("mail queue", 10*time.Second, func(ctx context.Context) error {
+		_, err := mailQueueService.ProcessMailQueue(ctx)
+		return err
+	}))
+	workers = append(workers, lifecycle.NewPeriodicWorker("mail queue depth", 30*time.Second, mailQueueService.ReportQueueDepth))
+
+	// run queued exports and delete the files of expired ones
+	workers = append(workers, lifecycle.NewPeriodicWorker("exports", 10*time.Second, func(ctx context.Context) error {
+		_, err := exportService.ProcessExports(ctx)
+		return err
+	}))
+	workers = append(workers, lifecycle.NewPeriodicWorker("export cleanup", time.Hour, func(ctx context.Context) error {
+		expired, err := exportService.CleanupExports(ctx)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			slog.InfoContext(ctx, "expired exports", "count", expired)
+		}
+		return nil
+	}))
+
+	// advance the workflow runs that are due and start the anniversaries of the day
+	workers = append(workers, lifecycle.NewPeriodicWorker("workflows", 10*time.Second, func(ctx context.Context) error {
+		_, err := workflowService.ProcessWorkflows(ctx)
+		return err
+	}))
+	workers = append(workers, lifecycle.NewPeriodicWorker("workflow anniversaries", time.Hour, func(ctx context.Context) error {
+		started, err := workflowService.StartAnniversaries(ctx)
+		if err != nil {
+			return err
+		}
+		if started > 0 {
+			slog.InfoContext(ctx, "started anniversary workflows", "count", started)
+		}
+		return nil
+	}))
+
+	router.HandleFunc("/greet", authenticated(userController.Welcome)).Methods("GET")
 	router.HandleFunc("/user-signup", userController.RegisterUser).Methods("POST")
 	router.HandleFunc("/verify-user", userController.VerifyUser).Methods("POST")
 	router.HandleFunc("/user-login", userController.Login).Methods("POST")
 	router.HandleFunc("/user-forget-password", userController.ForgetPassword).Methods("POST")
 	router.HandleFunc("/user-reset-password", userController.ResetPassword).Methods("POST")
+	router.HandleFunc("/user-lockout-status", userController.LockoutStatus).Methods("GET")
+	router.HandleFunc("/user-profile", authenticated(userController.GetProfile)).Methods("GET")
+	router.HandleFunc("/user-profile", authenticated(userController.UpdateProfile)).Methods("PUT")
+	router.HandleFunc("/user-change-password", authenticated(userController.ChangePassword)).Methods("POST")
+	router.HandleFunc("/user-change-email", authenticated(userController.ChangeEmail)).Methods("POST")
+	router.HandleFunc("/user-verify-email-change", authenticated(userController.VerifyEmailChange)).Methods("POST")
+	router.HandleFunc("/user-account", authenticated(userController.DeleteAccount)).Methods("DELETE")
+	router.HandleFunc("/user-cancel-account-deletion", userController.CancelAccountDeletion).Methods("POST")
+
+	router.HandleFunc("/team-invite", account(services.RoleAdmin, teamController.InviteMember)).Methods("POST")
+	router.HandleFunc("/team-invites", account(services.RoleAdmin, teamController.ListPendingInvitations)).Methods("GET")
+	router.HandleFunc("/team-invite/{uuid}", account(services.RoleAdmin, teamController.RevokeInvitation)).Methods("DELETE")
+	router.HandleFunc("/accept-team-invite", teamController.AcceptInvitation).Methods("POST")
+
+	router.HandleFunc("/account-quota", account(services.RoleViewer, quotaController.AccountQuota)).Methods("GET")
+
+	router.HandleFunc("/contacts", account(services.RoleMember, contactController.CreateContact)).Methods("POST")
+	router.HandleFunc("/contacts", account(services.RoleViewer, contactController.ListContacts)).Methods("GET")
+	router.HandleFunc("/contacts/{uuid}", account(services.RoleViewer, contactController.GetContact)).Methods("GET")
+	router.HandleFunc("/contacts/{uuid}", account(services.RoleMember, contactController.UpdateContact)).Methods("PUT")
+	router.HandleFunc("/contacts/{uuid}", account(services.RoleMember, contactController.DeleteContact)).Methods("DELETE")
+	router.HandleFunc("/contacts/{uuid}/activity", account(services.RoleViewer, contactController.ContactActivity)).Methods("GET")
+	router.HandleFunc("/contacts/{uuid}/preference-link", account(services.RoleMember, preferenceController.PreferenceLink)).Methods("GET")
+
+	router.HandleFunc("/contact-tags", account(services.RoleMember, contactController.TagContacts)).Methods("POST")
+	router.HandleFunc("/contact-tags", account(services.RoleMember, contactController.UntagContacts)).Methods("DELETE")
+	router.HandleFunc("/tags", account(services.RoleViewer, contactController.ListTags)).Methods("GET")
+
+	router.HandleFunc("/lists", account(services.RoleMember, contactController.CreateList)).Methods("POST")
+	router.HandleFunc("/lists", account(services.RoleViewer, contactController.ListLists)).Methods("GET")
+	router.HandleFunc("/lists/{uuid}", account(services.RoleMember, contactController.DeleteList)).Methods("DELETE")
+	router.HandleFunc("/lists/{uuid}/contacts", account(services.RoleMember, contactController.AddToList)).Methods("POST")
+	router.HandleFunc("/lists/{uuid}/contacts", account(services.RoleMember, contactController.RemoveFromList)).Methods("DELETE")
+
+	router.HandleFunc("/segments", account(services.RoleMember, segmentController.CreateSegment)).Methods("POST")
+	router.HandleFunc("/segments", account(services.RoleViewer, segmentController.ListSegments)).Methods("GET")
+	router.HandleFunc("/segments-preview", account(services.RoleViewer, segmentController.PreviewRules)).Methods("POST")
+	router.HandleFunc("/segments/{uuid}", account(services.RoleViewer, segmentController.GetSegment)).Methods("GET")
+	router.HandleFunc("/segments/{uuid}", account(services.RoleMember, segmentController.UpdateSegment)).Methods("PUT")
+	router.HandleFunc("/segments/{uuid}", account(services.RoleMember, segmentController.DeleteSegment)).Methods("DELETE")
+	router.HandleFunc("/segments/{uuid}/preview", account(services.RoleViewer, segmentController.PreviewSegment)).Methods("GET")
+
+	router.HandleFunc("/topics", account(services.RoleMember, topicController.CreateTopic)).Methods("POST")
+	router.HandleFunc("/topics", account(services.RoleViewer, topicController.ListTopics)).Methods("GET")
+	router.HandleFunc("/topics/{uuid}", account(services.RoleViewer, topicController.GetTopic)).Methods("GET")
+	router.HandleFunc("/topics/{uuid}", account(services.RoleMember, topicController.UpdateTopic)).Methods("PUT")
+	router.HandleFunc("/topics/{uuid}", account(services.RoleMember, topicController.DeleteTopic)).Methods("DELETE")
+
+	router.HandleFunc("/campaigns", account(services.RoleMember, campaignController.CreateCampaign)).Methods("POST")
+	router.HandleFunc("/campaigns", account(services.RoleViewer, campaignController.ListCampaigns)).Methods("GET")
+	router.HandleFunc("/campaigns/{uuid}", account(services.RoleViewer, campaignController.GetCampaign)).Methods("GET")
+	router.HandleFunc("/campaigns/{uuid}", account(services.RoleMember, campaignController.UpdateCampaign)).Methods("PUT")
+	router.HandleFunc("/campaigns/{uuid}/audience", account(services.RoleViewer, campaignController.PreviewAudience)).Methods("GET")
+
+	router.HandleFunc("/forms", account(services.RoleMember, signupFormController.CreateSignupForm)).Methods("POST")
+	router.HandleFunc("/forms", account(services.RoleViewer, signupFormController.ListSignupForms)).Methods("GET")
+	router.HandleFunc("/forms/{uuid}", account(services.RoleViewer, signupFormController.GetSignupForm)).Methods("GET")
+	router.HandleFunc("/forms/{uuid}", account(services.RoleMember, signupFormController.UpdateSignupForm)).Methods("PUT")
+	router.HandleFunc("/forms/{uuid}", account(services.RoleMember, signupFormController.DeleteSignupForm)).Methods("DELETE")
+
+	router.HandleFunc("/exports", account(services.RoleMember, exportController.CreateExport)).Methods("POST")
+	router.HandleFunc("/exports", account(services.RoleViewer, exportController.ListExports)).Methods("GET")
+	router.HandleFunc("/exports/download", account(services.RoleMember, exportController.DownloadExport)).Methods("POST")
+	router.HandleFunc("/exports/{uuid}", account(services.RoleViewer, exportController.GetExport)).Methods("GET")
+
+	router.HandleFunc("/events", account(services.RoleMember, eventController.TrackEvents)).Methods("POST")
+
+	router.HandleFunc("/workflows", account(services.RoleMember, workflowController.CreateWorkflow)).Methods("POST")
+	router.HandleFunc("/workflows", account(services.RoleViewer, workflowController.ListWorkflows)).Methods("GET")
+	router.HandleFunc("/workflows/{uuid}", account(services.RoleViewer, workflowController.GetWorkflow)).Methods("GET")
+	router.HandleFunc("/workflows/{uuid}", account(services.RoleMember, workflowController.UpdateWorkflow)).Methods("PUT")
+	router.HandleFunc("/workflows/{uuid}", account(services.RoleMember, workflowController.DeleteWorkflow)).Methods("DELETE")
+	router.HandleFunc("/workflows/{uuid}/runs", account(services.RoleViewer, workflowController.ListRuns)).Methods("GET")
+
+	public.HandleFunc("/lists/{public_id}/subscribe", rateLimiter.LimitByIP(subscriptionController.Subscribe)).Methods("POST")
+	public.HandleFunc("/subscriptions/confirm", rateLimiter.LimitByIP(subscriptionController.ConfirmSubscription)).Methods("POST")
+	public.HandleFunc("/forms/{public_id}", signupFormController.HostedSignupForm).Methods("GET")
+	public.HandleFunc("/forms/{public_id}", rateLimiter.LimitByIP(signupFormController.SubmitSignupForm)).Methods("POST")
+	public.HandleFunc("/preferences", preferenceController.PreferenceCenter).Methods("GET")
+	public.HandleFunc("/preferences", rateLimiter.LimitByIP(preferenceController.UpdatePreferences)).Methods("POST")
+	public.HandleFunc("/exports/download", rateLimiter.LimitByIP(exportController.DownloadExportFile)).Methods("GET")
+	public.HandleFunc("/messages/{message_id}/open", trackingController.TrackOpen).Methods("GET")
 
+	return workers
 }
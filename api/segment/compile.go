@@ -0,0 +1,176 @@
+package segment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Compile returns a condition selecting the contacts of accountId that match every one of rules, and its
+// arguments. The condition refers to the contacts table as "c" and uses the placeholders $1, $2, ... in
+// order, so it can be appended to a query such as "SELECT ... FROM contacts c WHERE ". Values never end
+// up in the SQL text.
+func Compile(accountId int, rules ...Rule) (string, []any, error) {
+	b := &builder{args: []any{accountId}}
+	where := "c.account_id = $1"
+
+	for _, rule := range rules {
+		if err := Validate(rule); err != nil {
+			return "", nil, err
+		}
+		where += " AND " + b.rule(rule)
+	}
+
+	return where, b.args, nil
+}
+
+type builder struct {
+	args []any
+}
+
+// arg adds a query argument and returns its placeholder.
+func (b *builder) arg(value any) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *builder) rule(rule Rule) string {
+	switch rule.Type {
+	case "and", "or":
+		parts := make([]string, len(rule.Rules))
+		for i, child := range rule.Rules {
+			parts[i] = b.rule(child)
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(rule.Type)+" ") + ")"
+	case "field":
+		// the column name comes from the fields whitelist checked by Validate
+		column := "c." + rule.Field
+		if fields[rule.Field] == timeField {
+			return b.time(column, rule)
+		}
+		return b.text(column, rule)
+	case "attribute":
+		return b.attribute(rule)
+	case "list":
+		return b.exists(rule.Operator == "in",
+			"SELECT 1 FROM list_contacts lc JOIN lists l ON l.id = lc.list_id WHERE lc.contact_id = c.id AND l.account_id = $1 AND l.uuid = "+b.arg(rule.Value))
+	case "tag":
+		return b.exists(rule.Operator == "has",
+			"SELECT 1 FROM contact_tags ct JOIN tags t ON t.id = ct.tag_id WHERE ct.contact_id = c.id AND lower(t.name) = lower("+b.arg(rule.Value)+")")
+	case "topic":
+		return b.exists(rule.Operator == "unsubscribed",
+			"SELECT 1 FROM contact_topic_optouts o JOIN topics t ON t.id = o.topic_id WHERE o.contact_id = c.id AND t.account_id = $1 AND t.uuid = "+b.arg(rule.Value))
+	case "snoozed":
+		if rule.Operator == "is" {
+			return "c.snoozed_until > now()"
+		}
+		return "(c.snoozed_until IS NULL OR c.snoozed_until <= now())"
+	case "event":
+		query := "SELECT 1 FROM contact_events ev WHERE ev.contact_id = c.id AND ev.name = " + b.arg(rule.Field)
+		if rule.Days > 0 {
+			query += " AND ev.occurred_at >= now() - make_interval(days => " + b.arg(rule.Days) + ")"
+		}
+		return b.exists(rule.Operator == "did", query)
+	default:
+		query := "SELECT 1 FROM message_events e WHERE e.contact_id = c.id AND e.type = " + b.arg(rule.Field)
+		if rule.Days > 0 {
+			query += " AND e.occurred_at >= now() - make_interval(days => " + b.arg(rule.Days) + ")"
+		}
+		return b.exists(rule.Operator == "did", query)
+	}
+}
+
+func (b *builder) exists(positive bool, query string) string {
+	if positive {
+		return "EXISTS (" + query + ")"
+	}
+	return "NOT EXISTS (" + query + ")"
+}
+
+// text compares a text expression case-insensitively. Negative operators also match a missing value.
+func (b *builder) text(expr string, rule Rule) string {
+	switch rule.Operator {
+	case "is_empty":
+		return "coalesce(" + expr + ", '') = ''"
+	case "is_not_empty":
+		return "coalesce(" + expr + ", '') <> ''"
+	}
+
+	value := text(rule.Value)
+
+	switch rule.Operator {
+	case "eq":
+		return "lower(" + expr + ") = lower(" + b.arg(value) + ")"
+	case "neq":
+		return "(" + expr + " IS NULL OR lower(" + expr + ") <> lower(" + b.arg(value) + "))"
+	case "contains":
+		return expr + " ILIKE " + b.arg("%"+escapeLike(value)+"%")
+	case "not_contains":
+		return "(" + expr + " IS NULL OR " + expr + " NOT ILIKE " + b.arg("%"+escapeLike(value)+"%") + ")"
+	case "starts_with":
+		return expr + " ILIKE " + b.arg(escapeLike(value)+"%")
+	default:
+		return expr + " ILIKE " + b.arg("%"+escapeLike(value))
+	}
+}
+
+func (b *builder) time(column string, rule Rule) string {
+	switch rule.Operator {
+	case "before", "after":
+		t, _ := parseTime(rule.Value)
+		operator := "<"
+		if rule.Operator == "after" {
+			operator = ">"
+		}
+		return column + " " + operator + " " + b.arg(t)
+	case "within_days":
+		return column + " >= now() - make_interval(days => " + b.arg(int(rule.Value.(float64))) + ")"
+	default:
+		return column + " < now() - make_interval(days => " + b.arg(int(rule.Value.(float64))) + ")"
+	}
+}
+
+func (b *builder) attribute(rule Rule) string {
+	key := b.arg(rule.Field)
+
+	switch rule.Operator {
+	case "exists":
+		return "c.attributes ? " + key
+	case "not_exists":
+		return "NOT c.attributes ? " + key
+	case "gt", "gte", "lt", "lte":
+		// values that are not JSON numbers compare as NULL instead of failing the cast
+		number := "CASE WHEN jsonb_typeof(c.attributes -> " + key + ") = 'number' THEN (c.attributes ->> " + key + ")::numeric END"
+		return number + " " + comparisons[rule.Operator] + " " + b.arg(rule.Value)
+	default:
+		return b.text("(c.attributes ->> "+key+")", rule)
+	}
+}
+
+var comparisons = map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+
+// text formats a value the way Postgres' ->> operator prints JSON scalars.
+func text(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeLike escapes the characters that are special in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func parseTime(value any) (time.Time, error) {
+	s, _ := value.(string)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
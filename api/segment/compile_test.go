@@ -0,0 +1,166 @@
+package segment
+
+import (
+	"email-marketing-service/api/apperrors"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parse(t *testing.T, s string) Rule {
+	t.Helper()
+
+	var rule Rule
+	if err := json.Unmarshal([]byte(s), &rule); err != nil {
+		t.Fatalf("unmarshal %s: %v", s, err)
+	}
+	return rule
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		where string
+		args  []any
+	}{
+		{
+			name:  "field",
+			rules: `{"type": "field", "field": "email", "operator": "ends_with", "value": "@example.com"}`,
+			where: `c.account_id = $1 AND c.email ILIKE $2`,
+			args:  []any{7, "%@example.com"},
+		},
+		{
+			name:  "like patterns are escaped",
+			rules: `{"type": "field", "field": "firstname", "operator": "contains", "value": "50%_off"}`,
+			where: `c.account_id = $1 AND c.firstname ILIKE $2`,
+			args:  []any{7, `%50\%\_off%`},
+		},
+		{
+			name:  "numeric attribute",
+			rules: `{"type": "attribute", "field": "seats", "operator": "gte", "value": 10}`,
+			where: `c.account_id = $1 AND CASE WHEN jsonb_typeof(c.attributes -> $2) = 'number' THEN (c.attributes ->> $2)::numeric END >= $3`,
+			args:  []any{7, "seats", 10.0},
+		},
+		{
+			name:  "boolean attribute",
+			rules: `{"type": "attribute", "field": "beta", "operator": "eq", "value": true}`,
+			where: `c.account_id = $1 AND lower((c.attributes ->> $2)) = lower($3)`,
+			args:  []any{7, "beta", "true"},
+		},
+		{
+			name: "nested groups",
+			rules: `{"type": "and", "rules": [
+				{"type": "list", "operator": "in", "value": "list-uuid"},
+				{"type": "or", "rules": [
+					{"type": "tag", "operator": "has", "value": "VIP"},
+					{"type": "engagement", "field": "sent", "operator": "did", "days": 30}
+				]},
+				{"type": "engagement", "field": "complained", "operator": "did_not"}
+			]}`,
+			where: `c.account_id = $1 AND (` +
+				`EXISTS (SELECT 1 FROM list_contacts lc JOIN lists l ON l.id = lc.list_id WHERE lc.contact_id = c.id AND l.account_id = $1 AND l.uuid = $2) AND ` +
+				`(EXISTS (SELECT 1 FROM contact_tags ct JOIN tags t ON t.id = ct.tag_id WHERE ct.contact_id = c.id AND lower(t.name) = lower($3)) OR ` +
+				`EXISTS (SELECT 1 FROM message_events e WHERE e.contact_id = c.id AND e.type = $4 AND e.occurred_at >= now() - make_interval(days => $5))) AND ` +
+				`NOT EXISTS (SELECT 1 FROM message_events e WHERE e.contact_id = c.id AND e.type = $6))`,
+			args: []any{7, "list-uuid", "VIP", "sent", 30, "complained"},
+		},
+		{
+			name:  "opened recently",
+			rules: `{"type": "engagement", "field": "opened", "operator": "did", "days": 30}`,
+			where: `c.account_id = $1 AND EXISTS (SELECT 1 FROM message_events e WHERE e.contact_id = c.id AND e.type = $2 AND e.occurred_at >= now() - make_interval(days => $3))`,
+			args:  []any{7, "opened", 30},
+		},
+		{
+			name: "topic and snooze",
+			rules: `{"type": "and", "rules": [
+				{"type": "topic", "operator": "subscribed", "value": "topic-uuid"},
+				{"type": "snoozed", "operator": "is_not"}
+			]}`,
+			where: `c.account_id = $1 AND (` +
+				`NOT EXISTS (SELECT 1 FROM contact_topic_optouts o JOIN topics t ON t.id = o.topic_id WHERE o.contact_id = c.id AND t.account_id = $1 AND t.uuid = $2) AND ` +
+				`(c.snoozed_until IS NULL OR c.snoozed_until <= now()))`,
+			args: []any{7, "topic-uuid"},
+		},
+		{
+			name:  "custom event",
+			rules: `{"type": "event", "field": "purchased", "operator": "did", "days": 7}`,
+			where: `c.account_id = $1 AND EXISTS (SELECT 1 FROM contact_events ev WHERE ev.contact_id = c.id AND ev.name = $2 AND ev.occurred_at >= now() - make_interval(days => $3))`,
+			args:  []any{7, "purchased", 7},
+		},
+		{
+			name:  "date field",
+			rules: `{"type": "field", "field": "created_at", "operator": "before", "value": "2024-01-31"}`,
+			where: `c.account_id = $1 AND c.created_at < $2`,
+			args:  []any{7, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := Compile(7, parse(t, tt.rules))
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if where != tt.where {
+				t.Errorf("where =\n%s\nwant\n%s", where, tt.where)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsInvalidRules(t *testing.T) {
+	deep := `{"type": "field", "field": "email", "operator": "is_empty"}`
+	for i := 0; i <= maxDepth; i++ {
+		deep = `{"type": "or", "rules": [` + deep + `]}`
+	}
+
+	tests := []struct {
+		name  string
+		rules string
+		field string
+	}{
+		{"unknown type", `{"type": "xor"}`, "rules.type"},
+		{"empty group", `{"type": "and", "rules": []}`, "rules.rules"},
+		{"unknown field", `{"type": "and", "rules": [{"type": "field", "field": "password", "operator": "eq", "value": "x"}]}`, "rules.rules[0].field"},
+		{"sql in attribute key", `{"type": "attribute", "field": "a'; DROP TABLE contacts; --", "operator": "exists"}`, "rules.field"},
+		{"wrong operator", `{"type": "tag", "operator": "in", "value": "vip"}`, "rules.operator"},
+		{"missing list", `{"type": "list", "operator": "in"}`, "rules.value"},
+		{"non numeric comparison", `{"type": "attribute", "field": "seats", "operator": "gt", "value": "ten"}`, "rules.value"},
+		{"untracked engagement", `{"type": "engagement", "field": "clicked", "operator": "did"}`, "rules.field"},
+		{"bad date", `{"type": "field", "field": "created_at", "operator": "after", "value": "yesterday"}`, "rules.value"},
+		{"too deep", deep, "rules" + strings.Repeat(".rules[0]", maxDepth)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Compile(7, parse(t, tt.rules))
+
+			var appErr *apperrors.Error
+			if !errors.As(err, &appErr) || appErr.Kind != apperrors.Validation {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if len(appErr.Fields) != 1 || appErr.Fields[0].Field != tt.field {
+				t.Fatalf("expected a problem with %s, got %+v", tt.field, appErr.Fields)
+			}
+		})
+	}
+}
+
+func TestCompileLimitsConditions(t *testing.T) {
+	conditions := make([]string, maxConditions+1)
+	for i := range conditions {
+		conditions[i] = `{"type": "tag", "operator": "has", "value": "t"}`
+	}
+
+	_, _, err := Compile(7, parse(t, `{"type": "or", "rules": [`+strings.Join(conditions, ",")+`]}`))
+	if !apperrors.Is(err, "invalid_segment_rules") {
+		t.Fatalf("expected invalid_segment_rules, got %v", err)
+	}
+}
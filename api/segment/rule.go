@@ -0,0 +1,217 @@
+// Package segment defines the rule trees of saved segments and compiles them into Postgres conditions.
+//
+// A rule is either a group combining other rules:
+//
+//	{"type": "and", "rules": [...]}
+//
+// or a condition on one aspect of a contact:
+//
+//	{"type": "field", "field": "email", "operator": "ends_with", "value": "@example.com"}
+//	{"type": "attribute", "field": "plan", "operator": "eq", "value": "pro"}
+//	{"type": "list", "operator": "in", "value": "<list uuid>"}
+//	{"type": "tag", "operator": "has", "value": "vip"}
+//	{"type": "engagement", "field": "bounced", "operator": "did_not", "days": 30}
+//	{"type": "event", "field": "purchased", "operator": "did", "days": 7}
+//	{"type": "topic", "operator": "subscribed", "value": "<topic uuid>"}
+//	{"type": "snoozed", "operator": "is_not"}
+package segment
+
+import (
+	"email-marketing-service/api/apperrors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// maxDepth and maxConditions keep the generated queries at a size Postgres plans quickly.
+	maxDepth      = 5
+	maxConditions = 50
+)
+
+type Rule struct {
+	// Type is "and" or "or" for a group, and field, attribute, list, tag, engagement, event, topic or snoozed for a condition.
+	Type  string `json:"type"`
+	Rules []Rule `json:"rules,omitempty"`
+	// Field is the contact field, the attribute key, the engagement event or the custom event the condition applies to.
+	Field    string `json:"field,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    any    `json:"value,omitempty"`
+	// Days limits an engagement or event condition to the last Days days. Zero means any time.
+	Days int `json:"days,omitempty"`
+}
+
+// Fields are the contact columns a field condition can use.
+var fields = map[string]fieldType{
+	"email":       textField,
+	"external_id": textField,
+	"firstname":   textField,
+	"lastname":    textField,
+	"status":      textField,
+	"created_at":  timeField,
+}
+
+type fieldType int
+
+const (
+	textField fieldType = iota
+	timeField
+)
+
+var (
+	textOperators = []string{"eq", "neq", "contains", "not_contains", "starts_with", "ends_with", "is_empty", "is_not_empty"}
+	timeOperators = []string{"before", "after", "within_days", "more_than_days_ago"}
+	// attributes accept the text operators as well as numeric comparisons and presence checks
+	attributeOperators = append(append([]string{}, textOperators...), "gt", "gte", "lt", "lte", "exists", "not_exists")
+
+	// EngagementEvents are the message events an engagement condition can look for. Only the events the
+	// service records are accepted: clicks and deliveries are not tracked, so conditions on them would
+	// silently match nobody.
+	EngagementEvents = []string{"sent", "opened", "bounced", "complained"}
+
+	attributeKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	eventNamePattern    = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,100}$`)
+)
+
+// ValidAttributeKey reports whether key can name a contact attribute.
+func ValidAttributeKey(key string) bool {
+	return attributeKeyPattern.MatchString(key)
+}
+
+// ValidEventName reports whether name can name a custom event.
+func ValidEventName(name string) bool {
+	return eventNamePattern.MatchString(name)
+}
+
+// Validate checks that rule is a well formed tree within the size limits.
+func Validate(rule Rule) error {
+	conditions := 0
+	return validate(rule, "rules", 1, &conditions)
+}
+
+func validate(rule Rule, path string, depth int, conditions *int) error {
+	switch rule.Type {
+	case "and", "or":
+		if depth > maxDepth {
+			return invalid(path, "max", fmt.Sprintf("groups can not be nested more than %d levels deep", maxDepth))
+		}
+		if len(rule.Rules) == 0 {
+			return invalid(path+".rules", "required", "a group needs at least one rule")
+		}
+		for i, child := range rule.Rules {
+			if err := validate(child, fmt.Sprintf("%s.rules[%d]", path, i), depth+1, conditions); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "field", "attribute", "list", "tag", "engagement", "event", "topic", "snoozed":
+		*conditions++
+		if *conditions > maxConditions {
+			return invalid(path, "max", fmt.Sprintf("a segment can have at most %d conditions", maxConditions))
+		}
+		return validateCondition(rule, path)
+	default:
+		return invalid(path+".type", "oneof", "type must be one of: and, or, field, attribute, list, tag, engagement, event, topic, snoozed")
+	}
+}
+
+func validateCondition(rule Rule, path string) error {
+	switch rule.Type {
+	case "field":
+		kind, ok := fields[rule.Field]
+		if !ok {
+			return invalid(path+".field", "oneof", "field must be one of: email, external_id, firstname, lastname, status, created_at")
+		}
+		if kind == timeField {
+			return checkOperator(rule, path, timeOperators)
+		}
+		return checkOperator(rule, path, textOperators)
+	case "attribute":
+		if !attributeKeyPattern.MatchString(rule.Field) {
+			return invalid(path+".field", "format", "field must be an attribute key of letters, digits, '_', '.' or '-'")
+		}
+		return checkOperator(rule, path, attributeOperators)
+	case "list":
+		if err := checkOperator(rule, path, []string{"in", "not_in"}); err != nil {
+			return err
+		}
+		return requireString(rule, path)
+	case "tag":
+		if err := checkOperator(rule, path, []string{"has", "not_has"}); err != nil {
+			return err
+		}
+		return requireString(rule, path)
+	case "topic":
+		if err := checkOperator(rule, path, []string{"subscribed", "unsubscribed"}); err != nil {
+			return err
+		}
+		return requireString(rule, path)
+	case "snoozed":
+		return checkOperator(rule, path, []string{"is", "is_not"})
+	case "event":
+		if !eventNamePattern.MatchString(rule.Field) {
+			return invalid(path+".field", "format", "field must be an event name of letters, digits, '_', '.', ':' or '-'")
+		}
+		if rule.Days < 0 {
+			return invalid(path+".days", "min", "days must not be negative")
+		}
+		return checkOperator(rule, path, []string{"did", "did_not"})
+	default:
+		if !slices.Contains(EngagementEvents, rule.Field) {
+			return invalid(path+".field", "oneof", "field must be one of: sent, opened, bounced, complained")
+		}
+		if rule.Days < 0 {
+			return invalid(path+".days", "min", "days must not be negative")
+		}
+		return checkOperator(rule, path, []string{"did", "did_not"})
+	}
+}
+
+// checkOperator checks rule.Operator against operators and that the value suits the operator.
+func checkOperator(rule Rule, path string, operators []string) error {
+	if !slices.Contains(operators, rule.Operator) {
+		return invalid(path+".operator", "oneof", fmt.Sprintf("operator must be one of: %s", strings.Join(operators, ", ")))
+	}
+
+	switch rule.Operator {
+	case "is_empty", "is_not_empty", "exists", "not_exists", "did", "did_not", "is", "is_not":
+		return nil
+	case "gt", "gte", "lt", "lte", "within_days", "more_than_days_ago":
+		if _, ok := rule.Value.(float64); !ok {
+			return invalid(path+".value", "number", "value must be a number")
+		}
+		return nil
+	case "before", "after":
+		if _, err := parseTime(rule.Value); err != nil {
+			return invalid(path+".value", "datetime", "value must be a date such as 2024-01-31 or an RFC 3339 timestamp")
+		}
+		return nil
+	}
+
+	if rule.Type == "list" || rule.Type == "tag" || rule.Type == "topic" {
+		return nil
+	}
+
+	switch rule.Value.(type) {
+	case string, float64, bool:
+		return nil
+	default:
+		return invalid(path+".value", "required", "value must be a string, number or boolean")
+	}
+}
+
+func requireString(rule Rule, path string) error {
+	if s, ok := rule.Value.(string); !ok || s == "" {
+		return invalid(path+".value", "required", "value is required")
+	}
+	return nil
+}
+
+func invalid(field string, rule string, message string) error {
+	return apperrors.NewValidation("invalid_segment_rules", "segment rules are invalid", apperrors.FieldError{
+		Field:   field,
+		Rule:    rule,
+		Message: message,
+	})
+}
@@ -1,26 +1,44 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"email-marketing-service/api/custom"
+	"email-marketing-service/api/database"
 	"email-marketing-service/api/model"
 	"email-marketing-service/api/repository"
 	"email-marketing-service/api/utils"
-	"fmt"
+	"errors"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 	"time"
 )
 
+// passwordCost is the bcrypt cost used to hash passwords. Tests lower it to keep hashing fast.
+var passwordCost = 14
+
+// PurgeHook is called with the ids of the accounts about to be purged, inside the purge transaction and before
+// their rows are deleted, to erase the data of the accounts kept outside the database. Returning an error
+// rolls the purge back.
+type PurgeHook func(ctx context.Context, accountIds []int) error
+
 type UserService struct {
-	userRepository *repository.UserRepository
+	userRepository repository.UserStore
 	otpService     *OTPService
+	mailer         custom.Mailer
+	jwtManager     *utils.JWTManager
+	transactor     database.Transactor
+	purgeHooks     []PurgeHook
 }
 
-func NewUserService(userRepo *repository.UserRepository, otpSvc *OTPService) *UserService {
+func NewUserService(userRepo repository.UserStore, otpSvc *OTPService, mailer custom.Mailer, jwtManager *utils.JWTManager, transactor database.Transactor) *UserService {
 	return &UserService{
 		userRepository: userRepo,
 		otpService:     otpSvc,
+		mailer:         mailer,
+		jwtManager:     jwtManager,
+		transactor:     transactor,
 	}
 }
 
@@ -29,7 +47,17 @@ func NewUserService(userRepo *repository.UserRepository, otpSvc *OTPService) *Us
 // 	otpService     = &OTPService{}
 // )
 
-func (s *UserService) CreateUser(d *model.User) (*model.User, error) {
+func (s *UserService) CreateUser(ctx context.Context, d *model.User) (*model.User, error) {
+	return s.createUser(ctx, d, false)
+}
+
+// CreateVerifiedUser creates an account whose email address is already proven, e.g. through an invitation link,
+// so no verification OTP is issued.
+func (s *UserService) CreateVerifiedUser(ctx context.Context, d *model.User) (*model.User, error) {
+	return s.createUser(ctx, d, true)
+}
+
+func (s *UserService) createUser(ctx context.Context, d *model.User, verified bool) (*model.User, error) {
 
 	err := utils.ValidateData(d)
 
@@ -37,54 +65,94 @@ func (s *UserService) CreateUser(d *model.User) (*model.User, error) {
 		return nil, err
 	}
 
-	password, _ := bcrypt.GenerateFromPassword([]byte(d.Password), 14)
+	password, _ := bcrypt.GenerateFromPassword([]byte(d.Password), passwordCost)
 
 	d.Password = password
 	d.UUID = uuid.New().String()
 
 	//check if user already exists
-	userExists, err := s.userRepository.CheckIfEmailAlreadyExists(d)
+	userExists, err := s.userRepository.CheckIfEmailAlreadyExists(ctx, d)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if userExists {
-		return nil, fmt.Errorf("user already exists")
+		return nil, errUserExists
 	}
 
-	_, err = s.userRepository.CreateUser(d)
-
-	if err != nil {
-		return nil, err
+	if verified {
+		d.Verified = true
+		d.VerifiedAt = sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		}
 	}
 
-	otp := utils.GenerateOTP(8)
+	// the user, its OTP and the verification mail succeed or fail together, so a failed signup can simply be retried
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := s.userRepository.CreateUser(ctx, d)
+
+		if err != nil {
+			return err
+		}
 
-	//store otp with user details in db
+		if verified {
+			return nil
+		}
 
-	var otpData model.OTP
+		otp := utils.GenerateOTP(8)
 
-	otpData.UserId = d.ID
-	otpData.Token = otp
-	otpData.UUID = uuid.New().String()
+		//store otp with user details in db
 
-	err = s.otpService.CreateOTP(&otpData)
+		var otpData model.OTP
+
+		otpData.UserId = d.ID
+		otpData.Token = otp
+		otpData.UUID = uuid.New().String()
+		otpData.Purpose = OTPPurposeVerifyEmail
+
+		err = s.otpService.CreateOTP(ctx, &otpData)
+
+		if err != nil {
+			return err
+		}
+
+		//send mail
+
+		return s.mailer.SignUpMail(ctx, d.Email, d.UserName, otp)
+	})
 
 	if err != nil {
 		return nil, err
 	}
+	return d, nil
+}
 
-	//send mail
+// retrieveAccountOTP returns the OTP mailed to the account of email for purpose. The OTP of another account
+// is reported as not found, so that OTPs can only be guessed one account at a time and the failed attempts
+// are counted against that account.
+func (s *UserService) retrieveAccountOTP(ctx context.Context, email string, token string, purpose string) (*model.OTP, error) {
+	otpData, err := s.otpService.RetrieveOTP(ctx, &model.OTP{Token: token, Purpose: purpose})
 
-	err = custom.SignUpMail(d.Email, d.UserName, otp)
 	if err != nil {
 		return nil, err
 	}
-	return d, nil
+
+	user, err := s.userRepository.FindUserById(ctx, &model.User{ID: otpData.UserId})
+
+	if err != nil {
+		return nil, whenNoRows(err, errOTPNotFound)
+	}
+
+	if !strings.EqualFold(user.Email, strings.TrimSpace(email)) {
+		return nil, errOTPNotFound.Wrap(sql.ErrNoRows)
+	}
+
+	return otpData, nil
 }
 
-func (s *UserService) VerifyUser(d *model.OTP) error {
+func (s *UserService) VerifyUser(ctx context.Context, d *model.VerifyUser) error {
 	err := utils.ValidateData(d)
 
 	if err != nil {
@@ -92,7 +160,7 @@ func (s *UserService) VerifyUser(d *model.OTP) error {
 	}
 	//check if token exists in the otp table if yes, retrieve the records
 	otpService := s.otpService
-	otpData, err := otpService.RetrieveOTP(d)
+	otpData, err := s.retrieveAccountOTP(ctx, d.Email, d.Token, OTPPurposeVerifyEmail)
 
 	if err != nil {
 		return err
@@ -107,15 +175,17 @@ func (s *UserService) VerifyUser(d *model.OTP) error {
 		Valid: true,
 	}
 
-	err = s.userRepository.VerifyUserAccount(&userModel)
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.userRepository.VerifyUserAccount(ctx, &userModel)
 
-	if err != nil {
-		return err
-	}
+		if err != nil {
+			return err
+		}
 
-	//delete otp from the database
+		//delete otp from the database
 
-	err = otpService.DeleteOTP(otpData.Id)
+		return otpService.DeleteOTP(ctx, otpData.Id)
+	})
 
 	if err != nil {
 		return err
@@ -126,7 +196,7 @@ func (s *UserService) VerifyUser(d *model.OTP) error {
 	return nil
 }
 
-func (s *UserService) Login(d *model.LoginModel) (map[string]string, error) {
+func (s *UserService) Login(ctx context.Context, d *model.LoginModel) (map[string]string, error) {
 	err := utils.ValidateData(d)
 
 	if err != nil {
@@ -137,20 +207,20 @@ func (s *UserService) Login(d *model.LoginModel) (map[string]string, error) {
 
 	user.Email = d.Email
 	user.Password = d.Password
-	userDetails, err := s.userRepository.Login(&user)
+	userDetails, err := s.userRepository.Login(ctx, &user)
 
 	if err != nil {
-		return nil, err
+		return nil, whenNoRows(err, errInvalidCredentials)
 	}
 
 	//compare password
 	err = bcrypt.CompareHashAndPassword(userDetails.Password, []byte(d.Password))
 
 	if err != nil {
-		return nil, fmt.Errorf("passwords do not match:%w", err)
+		return nil, errInvalidCredentials.Wrap(err)
 	}
 
-	token, err := utils.JWTEncode(userDetails.ID, userDetails.UserName, userDetails.Email)
+	token, err := s.jwtManager.Encode(userDetails.ID, userDetails.UserName, userDetails.Email)
 
 	if err != nil {
 		return nil, err
@@ -164,7 +234,7 @@ func (s *UserService) Login(d *model.LoginModel) (map[string]string, error) {
 	return successMap, nil
 }
 
-func (s *UserService) ForgetPassword(d *model.ForgetPassword) error {
+func (s *UserService) ForgetPassword(ctx context.Context, d *model.ForgetPassword) error {
 	err := utils.ValidateData(d)
 
 	if err != nil {
@@ -176,7 +246,7 @@ func (s *UserService) ForgetPassword(d *model.ForgetPassword) error {
 	}
 
 	//check if email exists in db
-	userExists, err := s.userRepository.CheckIfEmailAlreadyExists(userEmail)
+	userExists, err := s.userRepository.CheckIfEmailAlreadyExists(ctx, userEmail)
 
 	if err != nil {
 		return err
@@ -191,7 +261,7 @@ func (s *UserService) ForgetPassword(d *model.ForgetPassword) error {
 	}
 
 	//get username and id and append them to the email and otp services
-	userDetails, err := s.userRepository.FindUserByEmail(email)
+	userDetails, err := s.userRepository.FindUserByEmail(ctx, email)
 
 	if err != nil {
 		return err
@@ -201,20 +271,21 @@ func (s *UserService) ForgetPassword(d *model.ForgetPassword) error {
 	otp := utils.GenerateOTP(8)
 
 	otpData := &model.OTP{
-		UserId: userDetails.ID,
-		Token:  otp,
-		UUID:   uuid.New().String(),
+		UserId:  userDetails.ID,
+		Token:   otp,
+		UUID:    uuid.New().String(),
+		Purpose: OTPPurposeResetPassword,
 	}
 
 	otpService := s.otpService
 
-	err = otpService.CreateOTP(otpData)
+	err = otpService.CreateOTP(ctx, otpData)
 
 	if err != nil {
 		return err
 	}
 
-	err = custom.ResetPasswordMail(d.Email, userDetails.UserName, otp)
+	err = s.mailer.ResetPasswordMail(ctx, d.Email, userDetails.UserName, otp)
 
 	if err != nil {
 		return err
@@ -223,45 +294,304 @@ func (s *UserService) ForgetPassword(d *model.ForgetPassword) error {
 	return nil
 }
 
-func (s *UserService) ResetPassword(d *model.ResetPassword) error {
+func (s *UserService) ResetPassword(ctx context.Context, d *model.ResetPassword) error {
 
 	err := utils.ValidateData(d)
 	if err != nil {
 		return err
 	}
 
-	data := &model.OTP{
-		Token: d.Token,
-	}
-
 	otpService := s.otpService
 
-	otpData, err := otpService.RetrieveOTP(data)
+	otpData, err := s.retrieveAccountOTP(ctx, d.Email, d.Token, OTPPurposeResetPassword)
 
 	if err != nil {
 		return err
 	}
 
-	password, _ := bcrypt.GenerateFromPassword([]byte(d.Password), 14)
+	password, _ := bcrypt.GenerateFromPassword([]byte(d.Password), passwordCost)
 
 	user := &model.User{
 		ID:       otpData.UserId,
 		Password: password,
 	}
 
-	err = s.userRepository.ResetPassword(user)
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.userRepository.ResetPassword(ctx, user)
+
+		if err != nil {
+			return err
+		}
+
+		//delete otp from the database
+
+		return otpService.DeleteOTP(ctx, otpData.Id)
+	})
+}
+
+func (s *UserService) GetProfile(ctx context.Context, userId int) (*model.UserProfile, error) {
+	user, err := s.userRepository.FindUserById(ctx, &model.User{ID: userId})
+
+	if err != nil {
+		return nil, whenNoRows(err, errUserNotFound)
+	}
+
+	return &model.UserProfile{
+		ID:         user.ID,
+		UUID:       user.UUID,
+		FirstName:  user.FirstName,
+		MiddleName: user.MiddleName,
+		LastName:   user.LastName,
+		UserName:   user.UserName,
+		Email:      user.Email,
+		Verified:   user.Verified,
+		CreatedAt:  user.CreatedAt,
+		VerifiedAt: user.VerifiedAt,
+		UpdatedAt:  user.UpdatedAt,
+	}, nil
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, d *model.UpdateProfile) (*model.UserProfile, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userRepository.UpdateProfile(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(ctx, d.ID)
+}
+
+func (s *UserService) ChangePassword(ctx context.Context, d *model.ChangePassword) error {
+	err := utils.ValidateData(d)
 
 	if err != nil {
 		return err
 	}
 
-	//delete otp from the database
+	user, err := s.userRepository.FindUserPasswordById(ctx, &model.User{ID: d.ID})
+
+	if err != nil {
+		return whenNoRows(err, errUserNotFound)
+	}
 
-	err = otpService.DeleteOTP(otpData.Id)
+	err = bcrypt.CompareHashAndPassword(user.Password, []byte(d.CurrentPassword))
+
+	if err != nil {
+		return errIncorrectCurrent.Wrap(err)
+	}
+
+	password, _ := bcrypt.GenerateFromPassword([]byte(d.NewPassword), passwordCost)
+
+	user.Password = password
+
+	return s.userRepository.ResetPassword(ctx, user)
+}
+
+// ChangeEmail stores the new address as pending and sends an OTP to it. The address only replaces the current
+// one once the OTP has been confirmed through VerifyEmailChange.
+func (s *UserService) ChangeEmail(ctx context.Context, d *model.ChangeEmail) error {
+	err := utils.ValidateData(d)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	userExists, err := s.userRepository.CheckIfEmailAlreadyExists(ctx, &model.User{Email: d.Email})
+
+	if err != nil {
+		return err
+	}
+
+	if userExists {
+		return errEmailInUse
+	}
+
+	user, err := s.userRepository.FindUserById(ctx, &model.User{ID: d.ID})
+
+	if err != nil {
+		return whenNoRows(err, errUserNotFound)
+	}
+
+	// the pending email and the OTP are only kept if the mail could be sent
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.userRepository.SetPendingEmail(ctx, d)
+
+		if err != nil {
+			return err
+		}
+
+		otp := utils.GenerateOTP(8)
+
+		otpData := &model.OTP{
+			UserId:  d.ID,
+			Token:   otp,
+			UUID:    uuid.New().String(),
+			Purpose: OTPPurposeChangeEmail,
+		}
+
+		err = s.otpService.CreateOTP(ctx, otpData)
+
+		if err != nil {
+			return err
+		}
+
+		return s.mailer.ChangeEmailMail(ctx, d.Email, user.UserName, otp)
+	})
+}
+
+func (s *UserService) VerifyEmailChange(ctx context.Context, d *model.VerifyEmailChange) (*model.UserProfile, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	otpData, err := s.otpService.RetrieveOTP(ctx, &model.OTP{Token: d.Token, Purpose: OTPPurposeChangeEmail})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if otpData.UserId != d.ID {
+		return nil, errOTPNotFound.Wrap(sql.ErrNoRows)
+	}
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := s.userRepository.ConfirmEmailChange(ctx, &model.User{ID: d.ID})
+
+		if err != nil {
+			return whenNoRows(err, errEmailChangeFailed)
+		}
+
+		return s.otpService.DeleteOTP(ctx, otpData.Id)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(ctx, d.ID)
+}
+
+// accountDeletionGracePeriod is how long a deleted account can still be restored before its data is purged.
+const accountDeletionGracePeriod = 30 * 24 * time.Hour
+
+// DeleteAccount soft-deletes the account and revokes its sessions. The user is mailed an OTP that cancels
+// the deletion until the grace period ends, after which PurgeDeletedAccounts erases the account's data.
+// Sessions are the only credentials to revoke: the API is used with session tokens and has no API keys.
+func (s *UserService) DeleteAccount(ctx context.Context, d *model.DeleteAccount) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	credentials, err := s.userRepository.FindUserPasswordById(ctx, &model.User{ID: d.ID})
+
+	if err != nil {
+		return whenNoRows(err, errUserNotFound)
+	}
+
+	err = bcrypt.CompareHashAndPassword(credentials.Password, []byte(d.Password))
+
+	if err != nil {
+		return errIncorrectPassword.Wrap(err)
+	}
+
+	user, err := s.userRepository.FindUserById(ctx, &model.User{ID: d.ID})
+
+	if err != nil {
+		return whenNoRows(err, errUserNotFound)
+	}
+
+	purgeAfter := time.Now().Add(accountDeletionGracePeriod)
+
+	otp := utils.GenerateOTP(8)
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.userRepository.SoftDeleteUser(ctx, user, purgeAfter)
+
+		if err != nil {
+			return err
+		}
+
+		otpData := &model.OTP{
+			UserId:  user.ID,
+			Token:   otp,
+			UUID:    uuid.New().String(),
+			Purpose: OTPPurposeCancelDeletion,
+		}
+
+		return s.otpService.CreateOTP(ctx, otpData)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.AccountDeletionMail(ctx, user.Email, user.UserName, otp, purgeAfter)
+}
+
+func (s *UserService) CancelAccountDeletion(ctx context.Context, d *model.CancelAccountDeletion) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	otpData, err := s.retrieveAccountOTP(ctx, d.Email, d.Token, OTPPurposeCancelDeletion)
+
+	if err != nil {
+		return err
+	}
+
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.userRepository.RestoreUser(ctx, &model.User{ID: otpData.UserId})
+
+		if errors.Is(err, repository.ErrAccountNotRestorable) {
+			return errNotRestorable
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return s.otpService.DeleteOTP(ctx, otpData.Id)
+	})
+}
+
+// OnPurge registers a hook run whenever accounts are purged. Hooks must be registered before the service is
+// used.
+func (s *UserService) OnPurge(hook PurgeHook) {
+	s.purgeHooks = append(s.purgeHooks, hook)
+}
+
+// PurgeDeletedAccounts erases accounts whose deletion grace period has ended.
+func (s *UserService) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	var purged int
+
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		accountIds, err := s.userRepository.FindPurgeableUsers(ctx)
+
+		if err != nil || len(accountIds) == 0 {
+			return err
+		}
+
+		for _, hook := range s.purgeHooks {
+			if err := hook(ctx, accountIds); err != nil {
+				return err
+			}
+		}
+
+		purged, err = s.userRepository.PurgeDeletedUsers(ctx)
+		return err
+	})
+
+	return purged, err
 }
@@ -0,0 +1,430 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/config"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository/memory"
+	"email-marketing-service/api/utils"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	passwordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+type userServiceFixture struct {
+	service  *UserService
+	users    *memory.UserRepository
+	otps     *memory.OTPRepository
+	mailer   *custom.MemoryMailer
+	ctx      context.Context
+	password string
+}
+
+func newUserServiceFixture(t *testing.T) *userServiceFixture {
+	t.Helper()
+
+	users := memory.NewUserRepository()
+	otps := memory.NewOTPRepository()
+	mailer := custom.NewMemoryMailer()
+	jwtManager := utils.NewJWTManager(config.AuthConfig{
+		JWTSecret: "test-secret-that-is-long-enough-for-hs256",
+		TokenTTL:  time.Hour,
+	})
+
+	return &userServiceFixture{
+		service:  NewUserService(users, NewOTPService(otps), mailer, jwtManager, memory.NewTransactor(users, otps)),
+		users:    users,
+		otps:     otps,
+		mailer:   mailer,
+		ctx:      context.Background(),
+		password: "s3cret-password",
+	}
+}
+
+func (f *userServiceFixture) signUp(t *testing.T, email string) *model.User {
+	t.Helper()
+
+	user, err := f.service.CreateUser(f.ctx, &model.User{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		UserName:  "ada",
+		Email:     email,
+		Password:  []byte(f.password),
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	return user
+}
+
+func (f *userServiceFixture) lastToken(t *testing.T, kind string) string {
+	t.Helper()
+
+	mail, ok := f.mailer.Last(kind)
+	if !ok {
+		t.Fatalf("no %s mail was sent", kind)
+	}
+
+	return mail.Token
+}
+
+func (f *userServiceFixture) signUpVerified(t *testing.T, email string) *model.User {
+	t.Helper()
+
+	user := f.signUp(t, email)
+
+	if err := f.service.VerifyUser(f.ctx, &model.VerifyUser{Email: email, Token: f.lastToken(t, "signup")}); err != nil {
+		t.Fatalf("VerifyUser: %v", err)
+	}
+
+	return user
+}
+
+func TestCreateUserSendsVerificationOTP(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	user := f.signUp(t, "ada@example.com")
+
+	if user.ID == 0 || user.UUID == "" {
+		t.Fatalf("user was not stored: %+v", user)
+	}
+
+	if bcrypt.CompareHashAndPassword(user.Password, []byte(f.password)) != nil {
+		t.Fatal("password was not hashed")
+	}
+
+	mail, ok := f.mailer.Last("signup")
+	if !ok || mail.Email != "ada@example.com" || len(mail.Token) != 8 {
+		t.Fatalf("unexpected signup mail: %+v", mail)
+	}
+
+	if f.otps.Count() != 1 {
+		t.Fatalf("expected 1 stored otp, got %d", f.otps.Count())
+	}
+
+	stored, _ := f.users.FindUserById(f.ctx, &model.User{ID: user.ID})
+	if stored.Verified {
+		t.Fatal("new user must not be verified")
+	}
+}
+
+func TestCreateUserRejectsDuplicateEmail(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	f.signUp(t, "ada@example.com")
+
+	_, err := f.service.CreateUser(f.ctx, &model.User{
+		FirstName: "Ada",
+		LastName:  "Byron",
+		UserName:  "ada2",
+		Email:     "ada@example.com",
+		Password:  []byte("another-password"),
+	})
+	if !apperrors.Is(err, "user_already_exists") {
+		t.Fatalf("expected a user_already_exists error, got %v", err)
+	}
+}
+
+func TestCreateUserRollsBackWhenTheMailFails(t *testing.T) {
+	f := newUserServiceFixture(t)
+	f.mailer.Err = errors.New("smtp unavailable")
+
+	_, err := f.service.CreateUser(f.ctx, &model.User{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		UserName:  "ada",
+		Email:     "ada@example.com",
+		Password:  []byte(f.password),
+	})
+	if err == nil {
+		t.Fatal("expected the mail error")
+	}
+
+	if f.otps.Count() != 0 {
+		t.Fatalf("expected the otp to be rolled back, got %d", f.otps.Count())
+	}
+
+	f.mailer.Err = nil
+	f.signUp(t, "ada@example.com")
+}
+
+func TestCreateUserValidatesInput(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	_, err := f.service.CreateUser(f.ctx, &model.User{Email: "not-an-email"})
+	appErr := apperrors.From(err)
+	if appErr.Kind != apperrors.Validation {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	rules := map[string]string{}
+	for _, field := range appErr.Fields {
+		rules[field.Field] = field.Rule
+	}
+
+	if rules["email"] != "email" || rules["firstname"] != "required" {
+		t.Fatalf("unexpected field errors %+v", appErr.Fields)
+	}
+
+	if len(f.mailer.Sent()) != 0 {
+		t.Fatal("no mail should be sent for invalid input")
+	}
+}
+
+func TestCreateVerifiedUserSkipsOTP(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	user, err := f.service.CreateVerifiedUser(f.ctx, &model.User{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		UserName:  "ada",
+		Email:     "ada@example.com",
+		Password:  []byte(f.password),
+	})
+	if err != nil {
+		t.Fatalf("CreateVerifiedUser: %v", err)
+	}
+
+	if !user.Verified || !user.VerifiedAt.Valid {
+		t.Fatal("user should be verified")
+	}
+
+	if len(f.mailer.Sent()) != 0 || f.otps.Count() != 0 {
+		t.Fatal("no otp should be issued for a verified user")
+	}
+}
+
+func TestVerifyUser(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	user := f.signUpVerified(t, "ada@example.com")
+
+	stored, _ := f.users.FindUserById(f.ctx, &model.User{ID: user.ID})
+	if !stored.Verified || !stored.VerifiedAt.Valid {
+		t.Fatal("user should be verified")
+	}
+
+	if f.otps.Count() != 0 {
+		t.Fatal("otp should be deleted after use")
+	}
+
+	err := f.service.VerifyUser(f.ctx, &model.VerifyUser{Email: "ada@example.com", Token: f.lastToken(t, "signup")})
+	if !IsCredentialFailure(err) {
+		t.Fatalf("reusing an otp should fail as a credential failure, got %v", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	f.signUp(t, "ada@example.com")
+
+	_, err := f.service.Login(f.ctx, &model.LoginModel{Email: "ada@example.com", Password: []byte(f.password)})
+	if !IsCredentialFailure(err) {
+		t.Fatalf("unverified users must not log in, got %v", err)
+	}
+
+	if err := f.service.VerifyUser(f.ctx, &model.VerifyUser{Email: "ada@example.com", Token: f.lastToken(t, "signup")}); err != nil {
+		t.Fatalf("VerifyUser: %v", err)
+	}
+
+	result, err := f.service.Login(f.ctx, &model.LoginModel{Email: "ada@example.com", Password: []byte(f.password)})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if result["token"] == "" {
+		t.Fatal("login should return a token")
+	}
+
+	_, err = f.service.Login(f.ctx, &model.LoginModel{Email: "ada@example.com", Password: []byte("wrong-password")})
+	if !IsCredentialFailure(err) {
+		t.Fatalf("wrong password should fail as a credential failure, got %v", err)
+	}
+	wrongPassword := apperrors.From(err)
+
+	_, err = f.service.Login(f.ctx, &model.LoginModel{Email: "nobody@example.com", Password: []byte(f.password)})
+	if !IsCredentialFailure(err) {
+		t.Fatalf("unknown email should fail as a credential failure, got %v", err)
+	}
+	unknownEmail := apperrors.From(err)
+
+	if wrongPassword.Kind != apperrors.Unauthorized || wrongPassword.Code != unknownEmail.Code || wrongPassword.Message != unknownEmail.Message {
+		t.Fatalf("a wrong password and an unknown email must look the same, got %v and %v", wrongPassword, unknownEmail)
+	}
+}
+
+func TestOTPOnlyWorksForItsPurpose(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	user := f.signUpVerified(t, "ada@example.com")
+
+	if err := f.service.ChangeEmail(f.ctx, &model.ChangeEmail{ID: user.ID, Email: "attacker@example.com"}); err != nil {
+		t.Fatalf("ChangeEmail: %v", err)
+	}
+
+	token := f.lastToken(t, "change_email")
+
+	err := f.service.ResetPassword(f.ctx, &model.ResetPassword{Email: "ada@example.com", Token: token, Password: "new-password"})
+	if !IsCredentialFailure(err) {
+		t.Fatalf("a change email OTP must not reset the password, got %v", err)
+	}
+
+	if err := f.service.CancelAccountDeletion(f.ctx, &model.CancelAccountDeletion{Email: "ada@example.com", Token: token}); !IsCredentialFailure(err) {
+		t.Fatalf("a change email OTP must not cancel a deletion, got %v", err)
+	}
+
+	if _, err := f.service.VerifyEmailChange(f.ctx, &model.VerifyEmailChange{ID: user.ID, Token: token}); err != nil {
+		t.Fatalf("VerifyEmailChange: %v", err)
+	}
+}
+
+func TestOTPOnlyWorksForItsAccount(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	f.signUpVerified(t, "ada@example.com")
+	f.signUpVerified(t, "grace@example.com")
+
+	if err := f.service.ForgetPassword(f.ctx, &model.ForgetPassword{Email: "ada@example.com"}); err != nil {
+		t.Fatalf("ForgetPassword: %v", err)
+	}
+
+	token := f.lastToken(t, "reset_password")
+
+	err := f.service.ResetPassword(f.ctx, &model.ResetPassword{Email: "grace@example.com", Token: token, Password: "new-password"})
+	if !IsCredentialFailure(err) {
+		t.Fatalf("an OTP of another account should fail as a credential failure, got %v", err)
+	}
+
+	if err := f.service.ResetPassword(f.ctx, &model.ResetPassword{Email: "ADA@example.com", Token: token, Password: "new-password"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+}
+
+func TestChangeEmailRollsBackWhenTheMailFails(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	user := f.signUpVerified(t, "ada@example.com")
+	f.mailer.Err = errors.New("smtp unavailable")
+
+	if err := f.service.ChangeEmail(f.ctx, &model.ChangeEmail{ID: user.ID, Email: "new@example.com"}); err == nil {
+		t.Fatal("expected the mail error")
+	}
+
+	if f.otps.Count() != 0 {
+		t.Fatalf("expected the otp to be rolled back, got %d", f.otps.Count())
+	}
+
+	if _, err := f.users.ConfirmEmailChange(f.ctx, &model.User{ID: user.ID}); err == nil {
+		t.Fatal("expected the pending email to be rolled back")
+	}
+}
+
+func TestForgetPassword(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	if err := f.service.ForgetPassword(f.ctx, &model.ForgetPassword{Email: "nobody@example.com"}); err != nil {
+		t.Fatalf("unknown emails should not be reported: %v", err)
+	}
+
+	if _, ok := f.mailer.Last("reset_password"); ok {
+		t.Fatal("no reset mail should be sent for an unknown email")
+	}
+
+	f.signUpVerified(t, "ada@example.com")
+
+	if err := f.service.ForgetPassword(f.ctx, &model.ForgetPassword{Email: "ada@example.com"}); err != nil {
+		t.Fatalf("ForgetPassword: %v", err)
+	}
+
+	mail, ok := f.mailer.Last("reset_password")
+	if !ok || mail.Email != "ada@example.com" || mail.Token == "" {
+		t.Fatalf("unexpected reset mail: %+v", mail)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	f.signUpVerified(t, "ada@example.com")
+
+	if err := f.service.ForgetPassword(f.ctx, &model.ForgetPassword{Email: "ada@example.com"}); err != nil {
+		t.Fatalf("ForgetPassword: %v", err)
+	}
+
+	token := f.lastToken(t, "reset_password")
+
+	if err := f.service.ResetPassword(f.ctx, &model.ResetPassword{Email: "ada@example.com", Token: token, Password: "new-password"}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	if _, err := f.service.Login(f.ctx, &model.LoginModel{Email: "ada@example.com", Password: []byte("new-password")}); err != nil {
+		t.Fatalf("login with the new password: %v", err)
+	}
+
+	if _, err := f.service.Login(f.ctx, &model.LoginModel{Email: "ada@example.com", Password: []byte(f.password)}); err == nil {
+		t.Fatal("the old password should no longer work")
+	}
+
+	err := f.service.ResetPassword(f.ctx, &model.ResetPassword{Email: "ada@example.com", Token: token, Password: "third-password"})
+	if !IsCredentialFailure(err) {
+		t.Fatalf("a used reset token must be rejected, got %v", err)
+	}
+}
+
+func TestPurgeDeletedAccountsRunsTheHooksFirst(t *testing.T) {
+	f := newUserServiceFixture(t)
+
+	kept := f.signUpVerified(t, "ada@example.com")
+	purged := f.signUpVerified(t, "grace@example.com")
+
+	if err := f.users.SoftDeleteUser(f.ctx, &model.User{ID: purged.ID}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("SoftDeleteUser: %v", err)
+	}
+
+	failure := errors.New("files could not be deleted")
+	var hooked [][]int
+
+	f.service.OnPurge(func(ctx context.Context, accountIds []int) error {
+		hooked = append(hooked, accountIds)
+		return failure
+	})
+
+	if _, err := f.service.PurgeDeletedAccounts(f.ctx); !errors.Is(err, failure) {
+		t.Fatalf("expected the hook error, got %v", err)
+	}
+
+	if _, err := f.users.FindUserById(f.ctx, &model.User{ID: purged.ID}); err != nil {
+		t.Fatal("a failed hook must roll the purge back")
+	}
+
+	failure = nil
+
+	count, err := f.service.PurgeDeletedAccounts(f.ctx)
+	if err != nil || count != 1 {
+		t.Fatalf("PurgeDeletedAccounts = %d, %v", count, err)
+	}
+
+	if len(hooked) != 2 || len(hooked[1]) != 1 || hooked[1][0] != purged.ID {
+		t.Fatalf("expected the hook to get the purged account, got %v", hooked)
+	}
+
+	if _, err := f.users.FindUserById(f.ctx, &model.User{ID: purged.ID}); err == nil {
+		t.Fatal("the account should be purged")
+	}
+
+	if _, err := f.users.FindUserById(f.ctx, &model.User{ID: kept.ID}); err != nil {
+		t.Fatalf("other accounts must be kept: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	defaultActivityPageSize = 50
+	maxActivityPageSize     = 200
+)
+
+// ActivityTypes are the event types of a contact timeline. Message events come from message_events and
+// event is a custom event sent through the events API; the others are recorded when a contact changes.
+// Deliveries and clicks are not tracked, so they are not accepted as filters.
+var ActivityTypes = []string{
+	"subscribed", "unsubscribed", "list_added", "list_removed", "attributes_changed",
+	"sent", "opened", "bounced", "complained", "event",
+}
+
+func encodeActivityCursor(activity model.ContactActivity) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(activity.OccurredAt.Format(time.RFC3339Nano) + "|" + activity.ID))
+}
+
+func decodeActivityCursor(cursor string) (*model.ActivityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	occurredAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, errInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, occurredAt)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &model.ActivityCursor{OccurredAt: t, ID: id}, nil
+}
+
+// recordChanges records the status and attribute changes between two versions of a contact in its timeline.
+// source tells where the change came from, e.g. api or preference_center.
+func recordChanges(ctx context.Context, activities repository.ActivityStore, before *model.Contact, after *model.Contact, source string) error {
+	if before.Status != after.Status && (after.Status == "subscribed" || after.Status == "unsubscribed") {
+		err := activities.RecordActivity(ctx, &model.ContactActivity{
+			AccountId: after.AccountId,
+			ContactId: after.ID,
+			Type:      after.Status,
+			Data:      map[string]any{"source": source},
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	fields := map[string]any{}
+
+	if before.ExternalId != after.ExternalId {
+		fields["external_id"] = change(before.ExternalId, after.ExternalId)
+	}
+
+	if before.FirstName != after.FirstName {
+		fields["firstname"] = change(before.FirstName, after.FirstName)
+	}
+
+	if before.LastName != after.LastName {
+		fields["lastname"] = change(before.LastName, after.LastName)
+	}
+
+	attributes := map[string]any{}
+
+	for key, value := range after.Attributes {
+		if old, ok := before.Attributes[key]; !ok || !reflect.DeepEqual(old, value) {
+			attributes[key] = change(old, value)
+		}
+	}
+
+	for key, old := range before.Attributes {
+		if _, ok := after.Attributes[key]; !ok {
+			attributes[key] = change(old, nil)
+		}
+	}
+
+	if len(fields) == 0 && len(attributes) == 0 {
+		return nil
+	}
+
+	data := map[string]any{"source": source}
+
+	if len(fields) > 0 {
+		data["fields"] = fields
+	}
+
+	if len(attributes) > 0 {
+		data["attributes"] = attributes
+	}
+
+	return activities.RecordActivity(ctx, &model.ContactActivity{
+		AccountId: after.AccountId,
+		ContactId: after.ID,
+		Type:      "attributes_changed",
+		Data:      data,
+	})
+}
+
+func change(from any, to any) map[string]any {
+	return map[string]any{"from": from, "to": to}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type recordedActivities struct {
+	activities []model.ContactActivity
+}
+
+func (r *recordedActivities) RecordActivity(ctx context.Context, d *model.ContactActivity) error {
+	r.activities = append(r.activities, *d)
+	return nil
+}
+
+func (r *recordedActivities) FindActivities(ctx context.Context, contactId int, types []string, cursor *model.ActivityCursor, limit int) ([]model.ContactActivity, error) {
+	return r.activities, nil
+}
+
+func TestRecordChanges(t *testing.T) {
+	before := &model.Contact{ID: 3, AccountId: 7, Status: "subscribed", FirstName: "Ada", Attributes: map[string]any{"plan": "free", "seats": 2.0, "old": true}}
+	after := &model.Contact{ID: 3, AccountId: 7, Status: "unsubscribed", FirstName: "Ada", LastName: "Lovelace", Attributes: map[string]any{"plan": "pro", "seats": 2.0}}
+
+	recorded := &recordedActivities{}
+	if err := recordChanges(context.Background(), recorded, before, after, "api"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorded.activities) != 2 {
+		t.Fatalf("recorded %d activities, want 2: %+v", len(recorded.activities), recorded.activities)
+	}
+
+	if got := recorded.activities[0]; got.Type != "unsubscribed" || got.ContactId != 3 || got.AccountId != 7 {
+		t.Errorf("first activity = %+v, want unsubscribed of contact 3", got)
+	}
+
+	want := map[string]any{
+		"source": "api",
+		"fields": map[string]any{"lastname": change("", "Lovelace")},
+		"attributes": map[string]any{
+			"plan": change("free", "pro"),
+			"old":  change(true, nil),
+		},
+	}
+	if got := recorded.activities[1]; got.Type != "attributes_changed" || !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("second activity = %+v, want attributes_changed with %v", got, want)
+	}
+
+	recorded = &recordedActivities{}
+	if err := recordChanges(context.Background(), recorded, after, after, "api"); err != nil || len(recorded.activities) != 0 {
+		t.Errorf("unchanged contact recorded %+v, %v", recorded.activities, err)
+	}
+}
+
+func TestActivityCursor(t *testing.T) {
+	activity := model.ContactActivity{ID: "m42", OccurredAt: time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.UTC)}
+
+	cursor, err := decodeActivityCursor(encodeActivityCursor(activity))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor.ID != "m42" || !cursor.OccurredAt.Equal(activity.OccurredAt) {
+		t.Errorf("cursor = %+v, want m42 at %v", cursor, activity.OccurredAt)
+	}
+
+	for _, bad := range []string{"not base64!", "bm8tc2VwYXJhdG9y"} {
+		if _, err := decodeActivityCursor(bad); !apperrors.Is(err, "invalid_cursor") {
+			t.Errorf("decodeActivityCursor(%q) = %v, want invalid_cursor", bad, err)
+		}
+	}
+}
+
+func TestActivityRejectsUntrackedTypes(t *testing.T) {
+	service := NewContactService(nil, nil, nil, nil, nil)
+
+	for _, untracked := range []string{"delivered", "clicked"} {
+		_, err := service.Activity(context.Background(), model.ActivityQuery{AccountId: 7, ContactUUID: "contact", Types: []string{"opened", untracked}})
+
+		var appErr *apperrors.Error
+		if !errors.As(err, &appErr) || appErr.Kind != apperrors.Validation || len(appErr.Fields) != 1 || appErr.Fields[0].Field != "type[1]" {
+			t.Errorf("filtering on %s = %v, want a validation error on type[1]", untracked, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AttemptScopeIP      = "ip"
+	AttemptScopeAccount = "account"
+	// AttemptScopeMail counts the mails requested for an address, such as password resets, so that nobody
+	// can flood a mailbox through the service.
+	AttemptScopeMail = "mail"
+)
+
+// attemptPolicy controls how failures for a scope are throttled. The first freeAttempts failures are not
+// delayed; every further failure doubles the wait before the next attempt, up to maxDelay. Reaching
+// maxFailures locks the key for lockout.
+type attemptPolicy struct {
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	maxFailures  int
+	lockout      time.Duration
+	window       time.Duration
+}
+
+var attemptPolicies = map[string]attemptPolicy{
+	AttemptScopeIP: {
+		freeAttempts: 10,
+		baseDelay:    time.Second,
+		maxDelay:     time.Minute,
+		maxFailures:  50,
+		lockout:      30 * time.Minute,
+		window:       time.Hour,
+	},
+	AttemptScopeAccount: {
+		freeAttempts: 3,
+		baseDelay:    time.Second,
+		maxDelay:     5 * time.Minute,
+		maxFailures:  10,
+		lockout:      15 * time.Minute,
+		window:       time.Hour,
+	},
+	AttemptScopeMail: {
+		freeAttempts: 3,
+		baseDelay:    time.Minute,
+		maxDelay:     time.Hour,
+		maxFailures:  10,
+		lockout:      24 * time.Hour,
+		window:       24 * time.Hour,
+	},
+}
+
+// TooManyAttemptsError is returned when a key is currently delayed or locked out.
+type TooManyAttemptsError struct {
+	Locked     bool
+	RetryAfter time.Duration
+	Until      time.Time
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed attempts, locked until %s", e.Until.UTC().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("too many failed attempts, retry in %d seconds", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+type AuthAttemptService struct {
+	authAttemptRepository repository.AuthAttemptStore
+	userRepository        repository.UserStore
+	mailer                custom.Mailer
+}
+
+func NewAuthAttemptService(authAttemptRepo repository.AuthAttemptStore, userRepo repository.UserStore, mailer custom.Mailer) *AuthAttemptService {
+	return &AuthAttemptService{
+		authAttemptRepository: authAttemptRepo,
+		userRepository:        userRepo,
+		mailer:                mailer,
+	}
+}
+
+// IsCredentialFailure reports whether err was caused by a wrong password, email or token,
+// as opposed to validation or infrastructure errors which should not count as a failed attempt.
+func IsCredentialFailure(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || errors.Is(err, bcrypt.ErrMismatchedHashAndPassword)
+}
+
+func normalizeAttemptKey(scope string, key string) string {
+	if scope == AttemptScopeAccount || scope == AttemptScopeMail {
+		return strings.ToLower(strings.TrimSpace(key))
+	}
+	return key
+}
+
+// Check returns a *TooManyAttemptsError if the scope/key pair may not attempt to authenticate right now.
+func (s *AuthAttemptService) Check(ctx context.Context, scope string, key string) error {
+	key = normalizeAttemptKey(scope, key)
+	if key == "" {
+		return nil
+	}
+
+	attempt, err := s.authAttemptRepository.FindAttempt(ctx, scope, key)
+
+	if err != nil {
+		return err
+	}
+
+	if attempt == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	if attempt.LockedUntil.Valid && attempt.LockedUntil.Time.After(now) {
+		return &TooManyAttemptsError{
+			Locked:     true,
+			RetryAfter: attempt.LockedUntil.Time.Sub(now),
+			Until:      attempt.LockedUntil.Time,
+		}
+	}
+
+	policy := attemptPolicies[scope]
+
+	if now.Sub(attempt.LastFailedAt) > policy.window {
+		return nil
+	}
+
+	nextAllowed := attempt.LastFailedAt.Add(policy.delay(attempt.Failures))
+
+	if nextAllowed.After(now) {
+		return &TooManyAttemptsError{
+			RetryAfter: nextAllowed.Sub(now),
+			Until:      nextAllowed,
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt and locks the key once the scope's limit is reached.
+// Locking an account sends a notification email to its owner.
+func (s *AuthAttemptService) RecordFailure(ctx context.Context, scope string, key string) error {
+	key = normalizeAttemptKey(scope, key)
+	if key == "" {
+		return nil
+	}
+
+	policy := attemptPolicies[scope]
+
+	attempt, err := s.authAttemptRepository.RecordFailure(ctx, scope, key, policy.window)
+
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures < policy.maxFailures {
+		return nil
+	}
+
+	until := time.Now().Add(policy.lockout)
+
+	err = s.authAttemptRepository.Lock(ctx, scope, key, until)
+
+	if err != nil {
+		return err
+	}
+
+	if scope != AttemptScopeAccount {
+		return nil
+	}
+
+	user, err := s.userRepository.FindUserByEmail(ctx, &model.User{Email: key})
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	return s.mailer.AccountLockedMail(ctx, user.Email, user.UserName, until)
+}
+
+func (s *AuthAttemptService) RecordSuccess(ctx context.Context, scope string, key string) error {
+	key = normalizeAttemptKey(scope, key)
+	if key == "" {
+		return nil
+	}
+
+	return s.authAttemptRepository.ResetAttempts(ctx, scope, key)
+}
+
+// LockoutStatus reports whether the scope/key pair is currently delayed or locked.
+func (s *AuthAttemptService) LockoutStatus(ctx context.Context, scope string, key string) (*model.LockoutStatus, error) {
+	err := s.Check(ctx, scope, key)
+
+	var tooMany *TooManyAttemptsError
+	if errors.As(err, &tooMany) {
+		status := &model.LockoutStatus{
+			Locked:     tooMany.Locked,
+			RetryAfter: int(math.Ceil(tooMany.RetryAfter.Seconds())),
+		}
+		if tooMany.Locked {
+			status.LockedUntil = &tooMany.Until
+		}
+		return status, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.LockoutStatus{}, nil
+}
+
+func (p attemptPolicy) delay(failures int) time.Duration {
+	if failures < p.freeAttempts {
+		return 0
+	}
+
+	shift := failures - p.freeAttempts
+	if shift > 30 {
+		return p.maxDelay
+	}
+
+	delay := p.baseDelay << uint(shift)
+	if delay <= 0 || delay > p.maxDelay {
+		return p.maxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository/memory"
+	"errors"
+	"testing"
+	"time"
+)
+
+type authAttemptFixture struct {
+	service  *AuthAttemptService
+	attempts *memory.AuthAttemptRepository
+	mailer   *custom.MemoryMailer
+	ctx      context.Context
+}
+
+func newAuthAttemptFixture(t *testing.T) *authAttemptFixture {
+	t.Helper()
+
+	users := memory.NewUserRepository()
+	_, err := users.CreateUser(context.Background(), &model.User{UserName: "ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	attempts := memory.NewAuthAttemptRepository()
+	mailer := custom.NewMemoryMailer()
+
+	return &authAttemptFixture{
+		service:  NewAuthAttemptService(attempts, users, mailer),
+		attempts: attempts,
+		mailer:   mailer,
+		ctx:      context.Background(),
+	}
+}
+
+func (f *authAttemptFixture) fail(t *testing.T, scope string, key string, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := f.service.RecordFailure(f.ctx, scope, key); err != nil {
+			t.Fatalf("RecordFailure: %v", err)
+		}
+	}
+}
+
+func (f *authAttemptFixture) check(t *testing.T, scope string, key string) *TooManyAttemptsError {
+	t.Helper()
+
+	err := f.service.Check(f.ctx, scope, key)
+	if err == nil {
+		return nil
+	}
+
+	var tooMany *TooManyAttemptsError
+	if !errors.As(err, &tooMany) {
+		t.Fatalf("Check: %v", err)
+	}
+
+	return tooMany
+}
+
+func TestAttemptPolicyDelay(t *testing.T) {
+	policy := attemptPolicies[AttemptScopeAccount]
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{12, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := policy.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDelaysFailuresAfterTheFreeAttempts(t *testing.T) {
+	f := newAuthAttemptFixture(t)
+
+	f.fail(t, AttemptScopeAccount, "ada@example.com", 2)
+
+	if tooMany := f.check(t, AttemptScopeAccount, "ada@example.com"); tooMany != nil {
+		t.Fatalf("the free attempts must not be delayed, got %v", tooMany)
+	}
+
+	f.fail(t, AttemptScopeAccount, "ada@example.com", 1)
+
+	tooMany := f.check(t, AttemptScopeAccount, "ADA@example.com ")
+	if tooMany == nil || tooMany.Locked || tooMany.RetryAfter <= 0 || tooMany.RetryAfter > time.Second {
+		t.Fatalf("expected a delay of up to a second, got %+v", tooMany)
+	}
+
+	if f.check(t, AttemptScopeAccount, "grace@example.com") != nil {
+		t.Fatal("failures of one account must not delay another")
+	}
+}
+
+func TestRecordFailureLocksTheAccountAndMailsItsOwner(t *testing.T) {
+	f := newAuthAttemptFixture(t)
+
+	f.fail(t, AttemptScopeAccount, "ada@example.com", attemptPolicies[AttemptScopeAccount].maxFailures-1)
+
+	if tooMany := f.check(t, AttemptScopeAccount, "ada@example.com"); tooMany == nil || tooMany.Locked {
+		t.Fatalf("expected a delay before the limit, got %+v", tooMany)
+	}
+
+	if _, ok := f.mailer.Last("account_locked"); ok {
+		t.Fatal("no lockout mail should be sent before the limit")
+	}
+
+	f.fail(t, AttemptScopeAccount, "ada@example.com", 1)
+
+	tooMany := f.check(t, AttemptScopeAccount, "ada@example.com")
+	if tooMany == nil || !tooMany.Locked {
+		t.Fatalf("expected the account to be locked, got %+v", tooMany)
+	}
+
+	lockout := attemptPolicies[AttemptScopeAccount].lockout
+	if tooMany.RetryAfter > lockout || tooMany.RetryAfter < lockout-time.Minute {
+		t.Fatalf("locked for %v, want %v", tooMany.RetryAfter, lockout)
+	}
+
+	mail, ok := f.mailer.Last("account_locked")
+	if !ok || mail.Email != "ada@example.com" || !mail.Until.Equal(tooMany.Until) {
+		t.Fatalf("unexpected lockout mail: %+v", mail)
+	}
+
+	status, err := f.service.LockoutStatus(f.ctx, AttemptScopeAccount, "ada@example.com")
+	if err != nil || !status.Locked || status.LockedUntil == nil {
+		t.Fatalf("expected a locked status, got %+v, %v", status, err)
+	}
+}
+
+func TestRecordFailureLocksAddressesWithoutMail(t *testing.T) {
+	f := newAuthAttemptFixture(t)
+
+	f.fail(t, AttemptScopeIP, "203.0.113.7", attemptPolicies[AttemptScopeIP].maxFailures)
+
+	if tooMany := f.check(t, AttemptScopeIP, "203.0.113.7"); tooMany == nil || !tooMany.Locked {
+		t.Fatalf("expected the address to be locked, got %+v", tooMany)
+	}
+
+	if len(f.mailer.Sent()) != 0 {
+		t.Fatalf("locking an address must not send mail, got %+v", f.mailer.Sent())
+	}
+}
+
+func TestLockoutExpires(t *testing.T) {
+	f := newAuthAttemptFixture(t)
+
+	policy := attemptPolicies[AttemptScopeAccount]
+	f.fail(t, AttemptScopeAccount, "ada@example.com", policy.maxFailures)
+
+	f.attempts.Age(AttemptScopeAccount, "ada@example.com", policy.lockout+time.Second)
+
+	if tooMany := f.check(t, AttemptScopeAccount, "ada@example.com"); tooMany != nil {
+		t.Fatalf("the lockout should have expired, got %+v", tooMany)
+	}
+
+	status, err := f.service.LockoutStatus(f.ctx, AttemptScopeAccount, "ada@example.com")
+	if err != nil || status.Locked || status.RetryAfter != 0 {
+		t.Fatalf("expected an unlocked status, got %+v, %v", status, err)
+	}
+
+	// locking resets the failures, so the account starts over with its free attempts
+	f.fail(t, AttemptScopeAccount, "ada@example.com", 1)
+
+	if tooMany := f.check(t, AttemptScopeAccount, "ada@example.com"); tooMany != nil {
+		t.Fatalf("a failure after the lockout should not be delayed, got %+v", tooMany)
+	}
+}
+
+func TestFailuresAreForgottenAfterTheWindow(t *testing.T) {
+	f := newAuthAttemptFixture(t)
+
+	policy := attemptPolicies[AttemptScopeAccount]
+	f.fail(t, AttemptScopeAccount, "ada@example.com", policy.maxFailures-1)
+
+	f.attempts.Age(AttemptScopeAccount, "ada@example.com", policy.window+time.Second)
+
+	if tooMany := f.check(t, AttemptScopeAccount, "ada@example.com"); tooMany != nil {
+		t.Fatalf("failures outside the window should not delay, got %+v", tooMany)
+	}
+
+	f.fail(t, AttemptScopeAccount, "ada@example.com", 1)
+
+	if tooMany := f.check(t, AttemptScopeAccount, "ada@example.com"); tooMany != nil {
+		t.Fatalf("the count should restart after the window, got %+v", tooMany)
+	}
+}
+
+func TestRecordSuccessOnlyClearsItsKey(t *testing.T) {
+	f := newAuthAttemptFixture(t)
+
+	f.fail(t, AttemptScopeIP, "203.0.113.7", attemptPolicies[AttemptScopeIP].freeAttempts)
+	f.fail(t, AttemptScopeAccount, "ada@example.com", attemptPolicies[AttemptScopeAccount].freeAttempts)
+
+	if err := f.service.RecordSuccess(f.ctx, AttemptScopeAccount, "Ada@example.com"); err != nil {
+		t.Fatalf("RecordSuccess: %v", err)
+	}
+
+	if tooMany := f.check(t, AttemptScopeAccount, "ada@example.com"); tooMany != nil {
+		t.Fatalf("the account counter should be cleared, got %+v", tooMany)
+	}
+
+	if tooMany := f.check(t, AttemptScopeIP, "203.0.113.7"); tooMany == nil {
+		t.Fatal("the address counter must survive a successful login")
+	}
+}
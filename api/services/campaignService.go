@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/utils"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// subscribedOnly keeps contacts that unsubscribed or did not confirm their signup out of every campaign audience.
+	subscribedOnly = segment.Rule{Type: "field", Field: "status", Operator: "eq", Value: "subscribed"}
+	// notSnoozed keeps contacts that paused their emails in the preference center out of every campaign audience.
+	notSnoozed = segment.Rule{Type: "snoozed", Operator: "is_not"}
+)
+
+type CampaignService struct {
+	campaignRepository repository.CampaignStore
+	listRepository     repository.ListStore
+	segmentService     *SegmentService
+	topicService       *TopicService
+}
+
+func NewCampaignService(campaignRepo repository.CampaignStore, listRepo repository.ListStore, segmentSvc *SegmentService, topicSvc *TopicService) *CampaignService {
+	return &CampaignService{
+		campaignRepository: campaignRepo,
+		listRepository:     listRepo,
+		segmentService:     segmentSvc,
+		topicService:       topicSvc,
+	}
+}
+
+// resolveAudience looks up the list, segment and topic the campaign refers to by UUID.
+func (s *CampaignService) resolveAudience(ctx context.Context, d *model.Campaign) error {
+	d.ListId = nil
+	d.SegmentId = nil
+	d.TopicId = nil
+
+	if d.ListUUID != nil {
+		list, err := s.listRepository.FindListByUUID(ctx, &model.ContactList{UUID: *d.ListUUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return whenNoRows(err, errListNotFound)
+		}
+
+		d.ListId = &list.ID
+	}
+
+	if d.SegmentUUID != nil {
+		found, err := s.segmentService.GetSegment(ctx, &model.Segment{UUID: *d.SegmentUUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return err
+		}
+
+		d.SegmentId = &found.ID
+	}
+
+	if d.TopicUUID != nil {
+		topic, err := s.topicService.GetTopic(ctx, &model.Topic{UUID: *d.TopicUUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return err
+		}
+
+		d.TopicId = &topic.ID
+	}
+
+	return nil
+}
+
+func (s *CampaignService) CreateCampaign(ctx context.Context, d *model.Campaign) (*model.Campaign, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.resolveAudience(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Tags = normalizeTags(d.Tags)
+	d.UUID = uuid.New().String()
+	d.Status = "draft"
+
+	return s.campaignRepository.CreateCampaign(ctx, d)
+}
+
+func (s *CampaignService) ListCampaigns(ctx context.Context, accountId int) ([]model.Campaign, error) {
+	return s.campaignRepository.FindCampaigns(ctx, accountId)
+}
+
+func (s *CampaignService) GetCampaign(ctx context.Context, d *model.Campaign) (*model.Campaign, error) {
+	campaign, err := s.campaignRepository.FindCampaignByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errCampaignNotFound)
+	}
+
+	return campaign, nil
+}
+
+// UpdateCampaign replaces the content and audience of a campaign that has not been sent yet.
+func (s *CampaignService) UpdateCampaign(ctx context.Context, d *model.Campaign) (*model.Campaign, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.GetCampaign(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.resolveAudience(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Tags = normalizeTags(d.Tags)
+
+	err = s.campaignRepository.UpdateCampaign(ctx, d)
+
+	if errors.Is(err, repository.ErrCampaignNotDraft) {
+		return nil, errCampaignNotDraft
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCampaign(ctx, d)
+}
+
+// AudienceRules returns the rules selecting the recipients of a campaign: the subscribed contacts of its
+// list that also match its segment and have all of its tags. Any of the three may be left out, but not all.
+// Contacts that snoozed their emails or opted out of the campaign's topic are not eligible.
+func (s *CampaignService) AudienceRules(ctx context.Context, campaign *model.Campaign) ([]segment.Rule, error) {
+	if campaign.ListUUID == nil && campaign.SegmentUUID == nil && len(campaign.Tags) == 0 {
+		return nil, errNoAudience
+	}
+
+	rules := []segment.Rule{subscribedOnly, notSnoozed}
+
+	if campaign.TopicUUID != nil {
+		rules = append(rules, segment.Rule{Type: "topic", Operator: "subscribed", Value: *campaign.TopicUUID})
+	}
+
+	if campaign.ListUUID != nil {
+		rules = append(rules, segment.Rule{Type: "list", Operator: "in", Value: *campaign.ListUUID})
+	}
+
+	if campaign.SegmentUUID != nil {
+		found, err := s.segmentService.GetSegment(ctx, &model.Segment{UUID: *campaign.SegmentUUID, AccountId: campaign.AccountId})
+
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, found.Rules)
+	}
+
+	for _, tag := range campaign.Tags {
+		rules = append(rules, segment.Rule{Type: "tag", Operator: "has", Value: tag})
+	}
+
+	return rules, nil
+}
+
+// PreviewAudience counts the current recipients of a campaign and returns a sample of them.
+func (s *CampaignService) PreviewAudience(ctx context.Context, d *model.Campaign) (*model.SegmentPreview, error) {
+	campaign, err := s.GetCampaign(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.AudienceRules(ctx, campaign)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.segmentService.Preview(ctx, campaign.AccountId, rules...)
+}
@@ -0,0 +1,510 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/utils"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultContactPageSize = 50
+	maxContactPageSize     = 200
+)
+
+// TagAddedHook is called with the tags that were just added to contacts, inside the transaction adding them.
+// Returning an error rolls the tagging back.
+type TagAddedHook func(ctx context.Context, added []model.TaggedContact) error
+
+// ListJoinedHook is called with the ids of the contacts that just joined a list, inside the transaction adding
+// them. Returning an error rolls the additions back.
+type ListJoinedHook func(ctx context.Context, list *model.ContactList, contactIds []int) error
+
+// ContactService manages the contacts of an account, the lists they are organised in and their tags.
+type ContactService struct {
+	contactRepository  repository.ContactStore
+	listRepository     repository.ListStore
+	tagRepository      repository.TagStore
+	activityRepository repository.ActivityStore
+	transactor         database.Transactor
+	tagAddedHooks      []TagAddedHook
+	listJoinedHooks    []ListJoinedHook
+}
+
+func NewContactService(contactRepo repository.ContactStore, listRepo repository.ListStore, tagRepo repository.TagStore, activityRepo repository.ActivityStore, transactor database.Transactor) *ContactService {
+	return &ContactService{
+		contactRepository:  contactRepo,
+		listRepository:     listRepo,
+		tagRepository:      tagRepo,
+		activityRepository: activityRepo,
+		transactor:         transactor,
+	}
+}
+
+// OnTagAdded registers a hook run whenever tags are added to contacts, e.g. to start automations.
+// Hooks must be registered before the service is used.
+func (s *ContactService) OnTagAdded(hook TagAddedHook) {
+	s.tagAddedHooks = append(s.tagAddedHooks, hook)
+}
+
+// OnListJoined registers a hook run whenever contacts are added to a list. Hooks must be registered before
+// the service is used.
+func (s *ContactService) OnListJoined(hook ListJoinedHook) {
+	s.listJoinedHooks = append(s.listJoinedHooks, hook)
+}
+
+func (s *ContactService) CreateContact(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Email = strings.ToLower(strings.TrimSpace(d.Email))
+
+	exists, err := s.contactRepository.CheckIfContactExists(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, errContactExists
+	}
+
+	d.ExternalId = strings.TrimSpace(d.ExternalId)
+
+	if err := s.checkExternalId(ctx, d.AccountId, d.ExternalId, ""); err != nil {
+		return nil, err
+	}
+
+	if d.Status == "" {
+		d.Status = "subscribed"
+	}
+
+	if d.Attributes == nil {
+		d.Attributes = map[string]any{}
+	}
+
+	d.UUID = uuid.New().String()
+	d.Tags = normalizeTags(d.Tags)
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := s.contactRepository.CreateContact(ctx, d)
+
+		if err != nil {
+			return err
+		}
+
+		if d.Status == "subscribed" {
+			err = s.activityRepository.RecordActivity(ctx, &model.ContactActivity{
+				AccountId: d.AccountId,
+				ContactId: d.ID,
+				Type:      "subscribed",
+				Data:      map[string]any{"source": "api"},
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(d.Tags) == 0 {
+			return nil
+		}
+
+		_, err = s.tagContacts(ctx, d.AccountId, d.Tags, []string{d.UUID})
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// read the contact back for the names of tags that existed already with a different case
+	return s.contactRepository.FindContactByUUID(ctx, d)
+}
+
+func (s *ContactService) ListContacts(ctx context.Context, page model.ContactPage) ([]model.Contact, error) {
+	if page.Limit <= 0 {
+		page.Limit = defaultContactPageSize
+	}
+
+	if page.Limit > maxContactPageSize {
+		page.Limit = maxContactPageSize
+	}
+
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+
+	page.Tags = normalizeTags(page.Tags)
+
+	return s.contactRepository.FindContacts(ctx, page)
+}
+
+func (s *ContactService) GetContact(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	contact, err := s.contactRepository.FindContactByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errContactNotFound)
+	}
+
+	return contact, nil
+}
+
+func (s *ContactService) UpdateContact(ctx context.Context, d *model.UpdateContact) (*model.Contact, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *model.Contact
+
+	// the contact stays locked from the read to the write so that concurrent attribute updates do not
+	// overwrite each other
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		contact, err := s.contactRepository.FindContactByUUIDForUpdate(ctx, &model.Contact{UUID: d.UUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return whenNoRows(err, errContactNotFound)
+		}
+
+		before := *contact
+		before.Attributes = maps.Clone(contact.Attributes)
+
+		if d.ExternalId != nil {
+			contact.ExternalId = strings.TrimSpace(*d.ExternalId)
+
+			if err := s.checkExternalId(ctx, contact.AccountId, contact.ExternalId, contact.UUID); err != nil {
+				return err
+			}
+		}
+
+		if d.FirstName != nil {
+			contact.FirstName = *d.FirstName
+		}
+
+		if d.LastName != nil {
+			contact.LastName = *d.LastName
+		}
+
+		if d.Status != nil {
+			contact.Status = *d.Status
+		}
+
+		for key, value := range d.Attributes {
+			if value == nil {
+				delete(contact.Attributes, key)
+				continue
+			}
+			contact.Attributes[key] = value
+		}
+
+		updated, err = s.contactRepository.UpdateContact(ctx, contact)
+
+		if err != nil {
+			return whenNoRows(err, errContactNotFound)
+		}
+
+		source := d.Source
+		if source == "" {
+			source = "api"
+		}
+
+		return recordChanges(ctx, s.activityRepository, &before, updated, source)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// checkExternalId fails if another contact of the account than the one with exceptUUID has the external id.
+func (s *ContactService) checkExternalId(ctx context.Context, accountId int, externalId string, exceptUUID string) error {
+	if externalId == "" {
+		return nil
+	}
+
+	other, err := s.contactRepository.FindContactByExternalId(ctx, &model.Contact{AccountId: accountId, ExternalId: externalId})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if other.UUID != exceptUUID {
+		return errExternalIdTaken
+	}
+
+	return nil
+}
+
+// Activity returns a page of the timeline of a contact, newest first.
+func (s *ContactService) Activity(ctx context.Context, q model.ActivityQuery) (*model.ActivityPage, error) {
+	for i, activityType := range q.Types {
+		if !slices.Contains(ActivityTypes, activityType) {
+			return nil, apperrors.NewValidation("validation_failed", "one or more fields are invalid", apperrors.FieldError{
+				Field:   fmt.Sprintf("type[%d]", i),
+				Rule:    "oneof",
+				Message: "type must be one of: " + strings.Join(ActivityTypes, ", "),
+			})
+		}
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = defaultActivityPageSize
+	}
+
+	if q.Limit > maxActivityPageSize {
+		q.Limit = maxActivityPageSize
+	}
+
+	var cursor *model.ActivityCursor
+
+	if q.Cursor != "" {
+		var err error
+		cursor, err = decodeActivityCursor(q.Cursor)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	contact, err := s.GetContact(ctx, &model.Contact{UUID: q.ContactUUID, AccountId: q.AccountId})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// one more than a page tells whether another page follows
+	activities, err := s.activityRepository.FindActivities(ctx, contact.ID, q.Types, cursor, q.Limit+1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.ActivityPage{Activities: activities}
+
+	if len(activities) > q.Limit {
+		page.Activities = activities[:q.Limit]
+		page.NextCursor = encodeActivityCursor(page.Activities[q.Limit-1])
+	}
+
+	return page, nil
+}
+
+func (s *ContactService) DeleteContact(ctx context.Context, d *model.Contact) error {
+	err := s.contactRepository.DeleteContact(ctx, d)
+
+	return whenNoRows(err, errContactNotFound)
+}
+
+func (s *ContactService) CreateList(ctx context.Context, d *model.ContactList) (*model.ContactList, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	d.UUID = uuid.New().String()
+	d.PublicId = strings.ReplaceAll(uuid.New().String(), "-", "")
+
+	return s.listRepository.CreateList(ctx, d)
+}
+
+func (s *ContactService) ListLists(ctx context.Context, accountId int) ([]model.ContactList, error) {
+	return s.listRepository.FindLists(ctx, accountId)
+}
+
+func (s *ContactService) GetList(ctx context.Context, d *model.ContactList) (*model.ContactList, error) {
+	list, err := s.listRepository.FindListByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errListNotFound)
+	}
+
+	return list, nil
+}
+
+// DeleteList deletes a list but keeps its contacts. Lists that are the audience of a campaign or the target
+// of a signup form can not be deleted.
+func (s *ContactService) DeleteList(ctx context.Context, d *model.ContactList) error {
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		list, err := s.GetList(ctx, d)
+
+		if err != nil {
+			return err
+		}
+
+		inUse, err := s.listRepository.CheckIfListInUse(ctx, list.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if inUse {
+			return errListInUse
+		}
+
+		return s.listRepository.DeleteList(ctx, list.ID)
+	})
+}
+
+// AddToList adds contacts to a list and returns how many were added. Contacts already on the list
+// and unknown contacts are skipped.
+func (s *ContactService) AddToList(ctx context.Context, d *model.ContactList, members *model.ListMembers) (int, error) {
+	err := utils.ValidateData(members)
+
+	if err != nil {
+		return 0, err
+	}
+
+	list, err := s.GetList(ctx, d)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var added []int
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		added, err = s.listRepository.AddContacts(ctx, list, members.Contacts)
+
+		if err != nil || len(added) == 0 {
+			return err
+		}
+
+		for _, hook := range s.listJoinedHooks {
+			if err := hook(ctx, list, added); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return len(added), err
+}
+
+// RemoveFromList removes contacts from a list and returns how many were removed.
+func (s *ContactService) RemoveFromList(ctx context.Context, d *model.ContactList, members *model.ListMembers) (int, error) {
+	err := utils.ValidateData(members)
+
+	if err != nil {
+		return 0, err
+	}
+
+	list, err := s.GetList(ctx, d)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return s.listRepository.RemoveContacts(ctx, list, members.Contacts)
+}
+
+// normalizeTags trims tag names and drops empty names and names that only differ in case.
+func normalizeTags(names []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+
+		if name == "" || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		normalized = append(normalized, name)
+	}
+
+	return normalized
+}
+
+// tagContacts adds tags to contacts and runs the tag added hooks. It must be called inside a transaction.
+func (s *ContactService) tagContacts(ctx context.Context, accountId int, names []string, contactUUIDs []string) (int, error) {
+	tags, err := s.tagRepository.EnsureTags(ctx, accountId, names)
+
+	if err != nil {
+		return 0, err
+	}
+
+	tagIds := make([]int, len(tags))
+	for i, tag := range tags {
+		tagIds[i] = tag.ID
+	}
+
+	added, err := s.tagRepository.TagContacts(ctx, accountId, tagIds, contactUUIDs)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	for _, hook := range s.tagAddedHooks {
+		if err := hook(ctx, added); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(added), nil
+}
+
+// TagContacts adds tags to contacts, creating the tags the account does not have yet, and returns how many
+// tags were added. Tags a contact already has and unknown contacts are skipped.
+func (s *ContactService) TagContacts(ctx context.Context, accountId int, d *model.ContactTags) (int, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var added int
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		added, err = s.tagContacts(ctx, accountId, normalizeTags(d.Tags), d.Contacts)
+		return err
+	})
+
+	return added, err
+}
+
+// UntagContacts removes tags from contacts and returns how many were removed. Tags are kept even
+// when no contact has them anymore.
+func (s *ContactService) UntagContacts(ctx context.Context, accountId int, d *model.ContactTags) (int, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return s.tagRepository.UntagContacts(ctx, accountId, normalizeTags(d.Tags), d.Contacts)
+}
+
+func (s *ContactService) ListTags(ctx context.Context, accountId int) ([]model.Tag, error) {
+	return s.tagRepository.FindTags(ctx, accountId)
+}
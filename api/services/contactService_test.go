@@ -0,0 +1,19 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" VIP ", "vip", "", "Beta", "  ", "beta", "churn-risk"})
+	want := []string{"VIP", "Beta", "churn-risk"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizeTags = %q, want %q", got, want)
+	}
+
+	if got := normalizeTags(nil); got == nil || len(got) != 0 {
+		t.Fatalf("normalizeTags(nil) = %#v, want an empty slice", got)
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"errors"
+)
+
+// Errors returned to clients. Login failures share one error so that a response does not reveal
+// whether an account exists for the email.
+var (
+	errInvalidCredentials = apperrors.NewUnauthorized("invalid_credentials", "invalid email or password")
+	errUserExists         = apperrors.NewConflict("user_already_exists", "user already exists")
+	errUserNotFound       = apperrors.NewNotFound("user_not_found", "user does not exist")
+	errEmailInUse         = apperrors.NewConflict("email_in_use", "email is already in use")
+	errEmailChangeFailed  = apperrors.NewConflict("email_change_failed", "no pending email change or email already in use")
+	errOTPNotFound        = apperrors.NewNotFound("otp_not_found", "otp does not exist")
+	errIncorrectPassword  = apperrors.NewValidation("incorrect_password", "password is incorrect")
+	errIncorrectCurrent   = apperrors.NewValidation("incorrect_password", "current password is incorrect")
+	errNotRestorable      = apperrors.NewConflict("account_not_restorable", "account can not be restored")
+
+	errInviteSelf           = apperrors.NewValidation("cannot_invite_self", "you can not invite yourself")
+	errInvitationPending    = apperrors.NewConflict("invitation_already_pending", "a pending invitation already exists for this email")
+	errInvitationNotFound   = apperrors.NewNotFound("invitation_not_found", "invitation does not exist")
+	errInvitationRevoked    = apperrors.NewConflict("invitation_revoked", "invitation has been revoked")
+	errInvitationAccepted   = apperrors.NewConflict("invitation_already_accepted", "invitation has already been accepted")
+	errInvitationExpired    = apperrors.NewConflict("invitation_expired", "invitation has expired")
+	errInvitationNotPending = apperrors.NewConflict("invitation_not_pending", "invitation is no longer pending")
+	errInvalidInviteToken   = apperrors.NewUnauthorized("invalid_invitation_token", "invalid or expired invitation token")
+	errInviteOwner          = apperrors.NewValidation("cannot_invite_owner", "the account owner is already part of the team")
+	errInvitationEmail      = apperrors.NewForbidden("invitation_email_mismatch", "the invitation was sent to another email address")
+	errNotTeamMember        = apperrors.NewForbidden("not_team_member", "you are not a member of this account")
+	errRoleForbidden        = apperrors.NewForbidden("insufficient_role", "your role in this account does not allow this")
+
+	errContactExists    = apperrors.NewConflict("contact_already_exists", "a contact with this email already exists")
+	errContactNotFound  = apperrors.NewNotFound("contact_not_found", "contact does not exist")
+	errExternalIdTaken  = apperrors.NewConflict("external_id_taken", "another contact has this external id")
+	errListNotFound     = apperrors.NewNotFound("list_not_found", "list does not exist")
+	errListInUse        = apperrors.NewConflict("list_in_use", "list is the audience of a campaign, the target of a signup form or the trigger of a workflow")
+	errSegmentNotFound  = apperrors.NewNotFound("segment_not_found", "segment does not exist")
+	errSegmentInUse     = apperrors.NewConflict("segment_in_use", "segment is the audience of a campaign")
+	errCampaignNotFound = apperrors.NewNotFound("campaign_not_found", "campaign does not exist")
+	errCampaignNotDraft = apperrors.NewConflict("campaign_not_draft", "only draft campaigns can be changed")
+	errNoAudience       = apperrors.NewValidation("campaign_has_no_audience", "campaign needs a list, a segment or tags as its audience")
+
+	errSubscriptionNotFound     = apperrors.NewNotFound("subscription_not_found", "subscription does not exist")
+	errInvalidSubscriptionToken = apperrors.NewUnauthorized("invalid_subscription_token", "invalid or expired confirmation link")
+	errSignupFormNotFound       = apperrors.NewNotFound("signup_form_not_found", "signup form does not exist")
+
+	errTopicExists             = apperrors.NewConflict("topic_already_exists", "a topic with this name already exists")
+	errTopicNotFound           = apperrors.NewNotFound("topic_not_found", "topic does not exist")
+	errTopicInUse              = apperrors.NewConflict("topic_in_use", "topic is used by a campaign or workflow")
+	errInvalidPreferencesToken = apperrors.NewUnauthorized("invalid_preferences_token", "invalid or expired preferences link")
+
+	errInvalidCursor      = apperrors.New(apperrors.BadRequest, "invalid_cursor", "cursor is not one returned by this endpoint")
+	errExportTooLarge     = apperrors.NewConflict("export_too_large", "exports of more than 10000 rows have to run in the background")
+	errExportNotFound     = apperrors.NewNotFound("export_not_found", "export does not exist")
+	errExportUnavailable  = apperrors.NewConflict("export_unavailable", "export has not completed or has expired")
+	errInvalidExportToken = apperrors.NewUnauthorized("invalid_export_token", "invalid or expired download link")
+
+	errWorkflowNotFound = apperrors.NewNotFound("workflow_not_found", "workflow does not exist")
+)
+
+// whenNoRows returns appErr wrapping err if err reports a missing row, and err unchanged otherwise.
+// The cause is kept so that IsCredentialFailure still recognises it.
+func whenNoRows(err error, appErr *apperrors.Error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return appErr.Wrap(err)
+	}
+	return err
+}
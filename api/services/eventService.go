@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventService stores the custom events accounts send about their contacts. Events can be used in segment
+// rules and start workflows.
+type EventService struct {
+	eventRepository   repository.EventStore
+	contactRepository repository.ContactStore
+	workflowService   *WorkflowService
+	transactor        database.Transactor
+}
+
+func NewEventService(eventRepo repository.EventStore, contactRepo repository.ContactStore, workflowSvc *WorkflowService, transactor database.Transactor) *EventService {
+	return &EventService{
+		eventRepository:   eventRepo,
+		contactRepository: contactRepo,
+		workflowService:   workflowSvc,
+		transactor:        transactor,
+	}
+}
+
+// TrackEvents stores a batch of events and starts the workflows they trigger. Events of unknown contacts
+// are rejected and events whose idempotency key was used before are skipped, without failing the others.
+func (s *EventService) TrackEvents(ctx context.Context, accountId int, d *model.EventBatch) (*model.EventBatchResult, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, event := range d.Events {
+		if !segment.ValidEventName(event.Name) {
+			return nil, apperrors.NewValidation("validation_failed", "one or more fields are invalid", apperrors.FieldError{
+				Field:   fmt.Sprintf("events[%d].name", i),
+				Rule:    "format",
+				Message: "name must be made of letters, digits, '_', '.', ':' or '-'",
+			})
+		}
+	}
+
+	var result *model.EventBatchResult
+	receivedAt := time.Now()
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		result = &model.EventBatchResult{Results: make([]model.EventResult, len(d.Events))}
+
+		for i, event := range d.Events {
+			outcome, err := s.track(ctx, accountId, event, receivedAt)
+
+			if err != nil {
+				return err
+			}
+
+			outcome.Index = i
+			result.Results[i] = outcome
+
+			switch outcome.Status {
+			case "accepted":
+				result.Accepted++
+			case "duplicate":
+				result.Duplicates++
+			default:
+				result.Rejected++
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// track stores one event and starts the workflows it triggers if it is new.
+func (s *EventService) track(ctx context.Context, accountId int, event model.Event, receivedAt time.Time) (model.EventResult, error) {
+	contact, err := s.findContact(ctx, accountId, event)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.EventResult{Status: "rejected", Error: errContactNotFound.Code}, nil
+	}
+
+	if err != nil {
+		return model.EventResult{}, err
+	}
+
+	occurredAt := receivedAt
+	if event.OccurredAt != nil {
+		occurredAt = *event.OccurredAt
+	}
+
+	created, err := s.eventRepository.RecordEvent(ctx, &model.ContactEvent{
+		AccountId:      accountId,
+		ContactId:      contact.ID,
+		Name:           event.Name,
+		Properties:     event.Properties,
+		IdempotencyKey: event.IdempotencyKey,
+		OccurredAt:     occurredAt,
+	})
+
+	if err != nil {
+		return model.EventResult{}, err
+	}
+
+	if !created {
+		return model.EventResult{Status: "duplicate"}, nil
+	}
+
+	if err := s.workflowService.EventReceived(ctx, accountId, event.Name, []int{contact.ID}); err != nil {
+		return model.EventResult{}, err
+	}
+
+	return model.EventResult{Status: "accepted"}, nil
+}
+
+// findContact looks up the contact of an event by its external id if it is given, and by email otherwise.
+func (s *EventService) findContact(ctx context.Context, accountId int, event model.Event) (*model.Contact, error) {
+	if externalId := strings.TrimSpace(event.ExternalId); externalId != "" {
+		return s.contactRepository.FindContactByExternalId(ctx, &model.Contact{AccountId: accountId, ExternalId: externalId})
+	}
+
+	return s.contactRepository.FindContactByEmail(ctx, &model.Contact{AccountId: accountId, Email: strings.TrimSpace(event.Email)})
+}
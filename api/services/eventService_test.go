@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/repository/memory"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubEvents stores events like contact_events: an idempotency key is used once per account.
+type stubEvents struct {
+	events []model.ContactEvent
+	// failName, when set, fails the recording of the events with this name.
+	failName string
+}
+
+func (r *stubEvents) RecordEvent(ctx context.Context, d *model.ContactEvent) (bool, error) {
+	if d.Name == r.failName {
+		return false, errors.New("database unavailable")
+	}
+
+	for _, event := range r.events {
+		if d.IdempotencyKey != "" && event.AccountId == d.AccountId && event.IdempotencyKey == d.IdempotencyKey {
+			return false, nil
+		}
+	}
+
+	r.events = append(r.events, *d)
+	return true, nil
+}
+
+// stubEventContacts finds the contacts of account 7 by email or external id.
+type stubEventContacts struct {
+	repository.ContactStore
+	contacts []model.Contact
+}
+
+func (r *stubEventContacts) FindContactByEmail(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	for _, contact := range r.contacts {
+		if contact.AccountId == d.AccountId && strings.EqualFold(contact.Email, d.Email) {
+			return &contact, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *stubEventContacts) FindContactByExternalId(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	for _, contact := range r.contacts {
+		if contact.AccountId == d.AccountId && contact.ExternalId == d.ExternalId {
+			return &contact, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// stubEventEnrollments records the contacts that events started workflows for.
+type stubEventEnrollments struct {
+	repository.WorkflowStore
+	enrolled []string
+}
+
+func (r *stubEventEnrollments) EnrollOnEvent(ctx context.Context, accountId int, event string, contactIds []int) (int, error) {
+	r.enrolled = append(r.enrolled, event)
+	return len(contactIds), nil
+}
+
+type eventServiceFixture struct {
+	service     *EventService
+	events      *stubEvents
+	enrollments *stubEventEnrollments
+	ctx         context.Context
+}
+
+func newEventServiceFixture() *eventServiceFixture {
+	events := &stubEvents{}
+	contacts := &stubEventContacts{contacts: []model.Contact{
+		{ID: 3, AccountId: 7, Email: "ada@example.com"},
+		{ID: 4, AccountId: 7, Email: "grace@example.com", ExternalId: "user-4"},
+		{ID: 5, AccountId: 8, Email: "mallory@example.com"},
+	}}
+	enrollments := &stubEventEnrollments{}
+	workflows := NewWorkflowService(enrollments, contacts, nil, nil, nil, nil, nil, nil, memory.NewTransactor())
+
+	return &eventServiceFixture{
+		service:     NewEventService(events, contacts, workflows, memory.NewTransactor()),
+		events:      events,
+		enrollments: enrollments,
+		ctx:         context.Background(),
+	}
+}
+
+func (f *eventServiceFixture) track(t *testing.T, events ...model.Event) *model.EventBatchResult {
+	t.Helper()
+
+	result, err := f.service.TrackEvents(f.ctx, 7, &model.EventBatch{Events: events})
+	if err != nil {
+		t.Fatalf("TrackEvents: %v", err)
+	}
+
+	return result
+}
+
+func TestTrackEventsSkipsReusedIdempotencyKeys(t *testing.T) {
+	f := newEventServiceFixture()
+
+	result := f.track(t,
+		model.Event{Name: "purchased", Email: "ada@example.com", IdempotencyKey: "order-1"},
+		model.Event{Name: "purchased", Email: "ada@example.com", IdempotencyKey: "order-1"},
+		model.Event{Name: "signed_in", Email: "ada@example.com"},
+		model.Event{Name: "signed_in", Email: "ada@example.com"},
+	)
+
+	if result.Accepted != 3 || result.Duplicates != 1 || result.Results[1].Status != "duplicate" {
+		t.Fatalf("expected the repeated key to be skipped within the batch, got %+v", result)
+	}
+
+	// a batch sent again is skipped as a whole, apart from the events without a key
+	result = f.track(t,
+		model.Event{Name: "purchased", Email: "ada@example.com", IdempotencyKey: "order-1"},
+		model.Event{Name: "purchased", Email: "ada@example.com", IdempotencyKey: "order-2"},
+	)
+
+	if result.Accepted != 1 || result.Duplicates != 1 || result.Results[0].Status != "duplicate" || result.Results[1].Status != "accepted" {
+		t.Fatalf("expected order-1 to be a duplicate and order-2 to be accepted, got %+v", result)
+	}
+
+	if len(f.events.events) != 4 || len(f.enrollments.enrolled) != 4 {
+		t.Fatalf("expected 4 events stored and 4 workflow triggers, got %d and %v", len(f.events.events), f.enrollments.enrolled)
+	}
+}
+
+func TestTrackEventsRejectsUnknownContactsWithoutFailingTheBatch(t *testing.T) {
+	f := newEventServiceFixture()
+	occurredAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	result := f.track(t,
+		model.Event{Name: "purchased", Email: "nobody@example.com"},
+		model.Event{Name: "purchased", ExternalId: " user-4 ", OccurredAt: &occurredAt},
+		model.Event{Name: "purchased", Email: "mallory@example.com"},
+		model.Event{Name: "purchased", Email: "ADA@example.com"},
+	)
+
+	if result.Accepted != 2 || result.Rejected != 2 || result.Duplicates != 0 {
+		t.Fatalf("expected 2 accepted and 2 rejected events, got %+v", result)
+	}
+
+	for _, i := range []int{0, 2} {
+		if got := result.Results[i]; got.Index != i || got.Status != "rejected" || got.Error != "contact_not_found" {
+			t.Errorf("result %d = %+v, want a contact_not_found rejection", i, got)
+		}
+	}
+
+	if len(f.events.events) != 2 || f.events.events[0].ContactId != 4 || f.events.events[1].ContactId != 3 {
+		t.Fatalf("expected the events of grace and ada to be stored, got %+v", f.events.events)
+	}
+
+	if !f.events.events[0].OccurredAt.Equal(occurredAt) || time.Since(f.events.events[1].OccurredAt) > time.Minute {
+		t.Fatalf("expected the given time or the time received, got %v and %v", f.events.events[0].OccurredAt, f.events.events[1].OccurredAt)
+	}
+}
+
+func TestTrackEventsFailsTheBatchWhenAnEventCannotBeStored(t *testing.T) {
+	f := newEventServiceFixture()
+	f.events.failName = "broken"
+
+	_, err := f.service.TrackEvents(f.ctx, 7, &model.EventBatch{Events: []model.Event{
+		{Name: "purchased", Email: "ada@example.com"},
+		{Name: "broken", Email: "ada@example.com"},
+	}})
+	if err == nil || err.Error() != "database unavailable" {
+		t.Fatalf("expected the storage error, got %v", err)
+	}
+}
+
+func TestTrackEventsValidatesNames(t *testing.T) {
+	f := newEventServiceFixture()
+
+	_, err := f.service.TrackEvents(f.ctx, 7, &model.EventBatch{Events: []model.Event{
+		{Name: "purchased", Email: "ada@example.com"},
+		{Name: "bought it!", Email: "ada@example.com"},
+	}})
+
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) || len(appErr.Fields) != 1 || appErr.Fields[0].Field != "events[1].name" {
+		t.Fatalf("expected a validation error on events[1].name, got %v", err)
+	}
+
+	if len(f.events.events) != 0 {
+		t.Fatalf("an invalid batch stored events: %+v", f.events.events)
+	}
+}
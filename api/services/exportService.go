@@ -0,0 +1,507 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/export"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// directExportLimit is the most rows an export streamed in the response may have. Larger exports run in
+	// the background, so that they do not depend on one request staying open.
+	directExportLimit = 10000
+	// exportsPerRun bounds how many background exports one worker run takes on.
+	exportsPerRun = 5
+	// exportHistorySize is how many of its latest exports an account sees.
+	exportHistorySize = 100
+	// exportHeartbeatInterval is how often a running export shows that its instance is alive; an export
+	// without a heartbeat for a few intervals is handed to another instance.
+	exportHeartbeatInterval = time.Minute
+	// exportReleaseTimeout bounds putting an interrupted export back in the queue during a shutdown.
+	exportReleaseTimeout = 5 * time.Second
+)
+
+// ExportService exports contacts and suppression entries as CSV or JSON lines. Small exports are streamed in
+// the response; others run in the background and are downloaded through a time-limited link. Every export
+// is recorded in the audit log.
+type ExportService struct {
+	exportRepository  repository.ExportStore
+	auditRepository   repository.AuditStore
+	contactRepository repository.ContactStore
+	listRepository    repository.ListStore
+	segmentService    *SegmentService
+	jwtManager        *utils.JWTManager
+	appURL            string
+	dir               string
+	linkTTL           time.Duration
+}
+
+func NewExportService(exportRepo repository.ExportStore, auditRepo repository.AuditStore, contactRepo repository.ContactStore, listRepo repository.ListStore, segmentService *SegmentService, jwtManager *utils.JWTManager, appURL string, dir string, linkTTL time.Duration) *ExportService {
+	return &ExportService{
+		exportRepository:  exportRepo,
+		auditRepository:   auditRepo,
+		contactRepository: contactRepo,
+		listRepository:    listRepo,
+		segmentService:    segmentService,
+		jwtManager:        jwtManager,
+		appURL:            appURL,
+		dir:               dir,
+		linkTTL:           linkTTL,
+	}
+}
+
+// prepare validates the request of an export and fills in the default columns.
+func (s *ExportService) prepare(ctx context.Context, d *model.Export) error {
+	err := utils.ValidateData(&d.ExportRequest)
+
+	if err != nil {
+		return err
+	}
+
+	if len(d.Columns) == 0 {
+		d.Columns = export.Columns(d.Type)
+	}
+
+	if err := export.ValidateColumns(d.Type, d.Columns); err != nil {
+		return err
+	}
+
+	_, err = s.rules(ctx, d)
+
+	return err
+}
+
+// rules returns the rules selecting the contacts of an export of contacts, a list or a segment.
+func (s *ExportService) rules(ctx context.Context, d *model.Export) ([]segment.Rule, error) {
+	switch d.Type {
+	case "list":
+		list, err := s.listRepository.FindListByUUID(ctx, &model.ContactList{UUID: d.ListUUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return nil, whenNoRows(err, errListNotFound)
+		}
+
+		return []segment.Rule{{Type: "list", Operator: "in", Value: list.UUID}}, nil
+	case "segment":
+		found, err := s.segmentService.GetSegment(ctx, &model.Segment{UUID: d.SegmentUUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []segment.Rule{found.Rules}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (s *ExportService) count(ctx context.Context, d *model.Export) (int, error) {
+	if d.Type == "suppression" {
+		return s.exportRepository.CountSuppressions(ctx, d.AccountId)
+	}
+
+	rules, err := s.rules(ctx, d)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return s.contactRepository.CountMatching(ctx, d.AccountId, rules...)
+}
+
+// write streams the rows of an export to w and returns how many there were.
+func (s *ExportService) write(ctx context.Context, d *model.Export, w io.Writer) (int, error) {
+	writer, err := export.NewWriter(w, d.Format, d.Columns)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if d.Type == "suppression" {
+		err = s.exportRepository.StreamSuppressions(ctx, d.AccountId, func(entry *model.Suppression) error {
+			return writer.Write(export.SuppressionValues(entry, d.Columns))
+		})
+	} else {
+		var rules []segment.Rule
+		rules, err = s.rules(ctx, d)
+
+		if err != nil {
+			return 0, err
+		}
+
+		err = s.exportRepository.StreamContacts(ctx, d.AccountId, func(contact *model.Contact) error {
+			return writer.Write(export.ContactValues(contact, d.Columns))
+		}, rules...)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return 0, err
+	}
+
+	return writer.Rows(), nil
+}
+
+func (s *ExportService) audit(ctx context.Context, d *model.Export, action string, rows int) error {
+	details := map[string]any{
+		"type":    d.Type,
+		"format":  d.Format,
+		"columns": d.Columns,
+		"rows":    rows,
+	}
+
+	if d.ListUUID != "" {
+		details["list_uuid"] = d.ListUUID
+	}
+
+	if d.SegmentUUID != "" {
+		details["segment_uuid"] = d.SegmentUUID
+	}
+
+	return s.auditRepository.RecordAuditEvent(ctx, &model.AuditEvent{
+		AccountId: d.AccountId,
+		Action:    action,
+		Target:    d.UUID,
+		Details:   details,
+		IP:        d.IP,
+		UserAgent: d.UserAgent,
+	})
+}
+
+// PrepareDownload checks an export that is to be streamed in the response with WriteDownload and records it
+// in the audit log. Exports of more than directExportLimit rows are refused.
+func (s *ExportService) PrepareDownload(ctx context.Context, d *model.Export) error {
+	err := s.prepare(ctx, d)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.count(ctx, d)
+
+	if err != nil {
+		return err
+	}
+
+	if rows > directExportLimit {
+		return errExportTooLarge
+	}
+
+	d.UUID = uuid.New().String()
+
+	return s.audit(ctx, d, "export.downloaded", rows)
+}
+
+// WriteDownload streams an export checked by PrepareDownload to w.
+func (s *ExportService) WriteDownload(ctx context.Context, d *model.Export, w io.Writer) error {
+	_, err := s.write(ctx, d, w)
+
+	return err
+}
+
+// CreateExport queues an export to run in the background.
+func (s *ExportService) CreateExport(ctx context.Context, d *model.Export) (*model.Export, error) {
+	err := s.prepare(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.UUID = uuid.New().String()
+	d.Status = "pending"
+
+	created, err := s.exportRepository.CreateExport(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.audit(ctx, created, "export.requested", 0); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// withLink sets the download URL of a completed export whose link has not expired.
+func (s *ExportService) withLink(d *model.Export) (*model.Export, error) {
+	if d.Status != "completed" || d.ExpiresAt == nil || !d.ExpiresAt.After(time.Now()) {
+		return d, nil
+	}
+
+	token, err := s.jwtManager.ExportTokenEncode(d.UUID, d.AccountId, *d.ExpiresAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.DownloadURL = fmt.Sprintf("%s/api/v1/public/exports/download?token=%s", s.appURL, url.QueryEscape(token))
+
+	return d, nil
+}
+
+func (s *ExportService) ListExports(ctx context.Context, accountId int) ([]model.Export, error) {
+	exports, err := s.exportRepository.FindExports(ctx, accountId, exportHistorySize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range exports {
+		if _, err := s.withLink(&exports[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return exports, nil
+}
+
+func (s *ExportService) GetExport(ctx context.Context, d *model.Export) (*model.Export, error) {
+	found, err := s.exportRepository.FindExportByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errExportNotFound)
+	}
+
+	return s.withLink(found)
+}
+
+// OpenExportFile opens the file of the export a download link was issued for and records the download in the
+// audit log. The caller has to close the file.
+func (s *ExportService) OpenExportFile(ctx context.Context, token string, ip string, userAgent string) (*model.Export, *os.File, error) {
+	exportUUID, accountId, err := s.jwtManager.ExportTokenDecode(token)
+
+	if err != nil {
+		return nil, nil, errInvalidExportToken.Wrap(err)
+	}
+
+	found, err := s.exportRepository.FindExportByUUID(ctx, &model.Export{UUID: exportUUID, AccountId: accountId})
+
+	if err != nil {
+		return nil, nil, whenNoRows(err, errExportNotFound)
+	}
+
+	if found.Status != "completed" || found.ExpiresAt == nil || !found.ExpiresAt.After(time.Now()) {
+		return nil, nil, errExportUnavailable
+	}
+
+	file, err := os.Open(found.FilePath)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, errExportUnavailable.Wrap(err)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found.IP = ip
+	found.UserAgent = userAgent
+
+	if err := s.audit(ctx, found, "export.file_downloaded", found.Rows); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return found, file, nil
+}
+
+// ProcessExports runs pending background exports and returns how many it ran. Each export is claimed by
+// one instance only, so every instance can run the worker.
+func (s *ExportService) ProcessExports(ctx context.Context) (int, error) {
+	for processed := 0; processed < exportsPerRun; processed++ {
+		claimed, err := s.exportRepository.ClaimExport(ctx)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return processed, nil
+		}
+
+		if err != nil {
+			return processed, err
+		}
+
+		err = s.runWithHeartbeat(ctx, claimed)
+
+		// an export interrupted by a shutdown is not its own fault, so it goes back to the queue for the next
+		// instance; ctx is done, so the release needs a context of its own
+		if err != nil && ctx.Err() != nil {
+			releaseCtx, cancel := context.WithTimeout(context.Background(), exportReleaseTimeout)
+			releaseErr := s.exportRepository.ReleaseExport(releaseCtx, claimed.ID)
+			cancel()
+
+			slog.WarnContext(ctx, "export interrupted", "export", claimed.UUID, "error", err)
+
+			return processed, releaseErr
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "export failed", "export", claimed.UUID, "error", err)
+
+			message := "export failed"
+			var appErr *apperrors.Error
+			if errors.As(err, &appErr) {
+				message = appErr.Message
+			}
+
+			if err := s.exportRepository.FailExport(ctx, claimed.ID, message); err != nil {
+				return processed, err
+			}
+		}
+	}
+
+	return exportsPerRun, nil
+}
+
+// runWithHeartbeat runs a claimed export while touching it every exportHeartbeatInterval, so that other
+// instances do not take it over however long it runs.
+func (s *ExportService) runWithHeartbeat(ctx context.Context, d *model.Export) error {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(exportHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.exportRepository.TouchExport(ctx, d.ID); err != nil {
+					slog.WarnContext(ctx, "export heartbeat failed", "export", d.UUID, "error", err)
+				}
+			}
+		}
+	}()
+
+	err := s.run(ctx, d)
+
+	close(done)
+	<-stopped
+
+	return err
+}
+
+// run writes the file of a claimed export. The file is written under a temporary name and renamed once
+// complete, so a download never sees a partial file.
+func (s *ExportService) run(ctx context.Context, d *model.Export) error {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, d.UUID+"-*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	rows, err := s.write(ctx, d, tmp)
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, d.UUID+"."+d.Format)
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.linkTTL)
+	d.Rows = rows
+	d.FilePath = path
+	d.ExpiresAt = &expiresAt
+
+	return s.exportRepository.CompleteExport(ctx, d)
+}
+
+// DeleteAccountsFiles deletes the export files of accounts that are being purged, including the files of
+// expired exports that CleanupExports has not removed yet and those of exports still being written.
+func (s *ExportService) DeleteAccountsFiles(ctx context.Context, accountIds []int) error {
+	exports, err := s.exportRepository.FindAccountsExports(ctx, accountIds)
+
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		partial, err := filepath.Glob(filepath.Join(s.dir, export.UUID+"-*.tmp"))
+
+		if err != nil {
+			return err
+		}
+
+		for _, path := range append(partial, filepath.Join(s.dir, export.UUID+"."+export.Format)) {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CleanupExports expires the exports whose link has run out and deletes their files, as well as files left
+// behind by deleted accounts. It returns how many exports expired.
+func (s *ExportService) CleanupExports(ctx context.Context) (int, error) {
+	expired, err := s.exportRepository.ExpireExports(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(s.dir)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return expired, nil
+	}
+
+	if err != nil {
+		return expired, err
+	}
+
+	// files are kept a little longer than their link, so one that is being downloaded is not pulled away
+	cutoff := time.Now().Add(-s.linkTTL - time.Hour)
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+
+		if err != nil || entry.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/config"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/utils"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubExports holds the exports of the tests. Methods they do not need are left to the embedded nil interface
+// and panic if called.
+type stubExports struct {
+	repository.ExportStore
+	exports []model.Export
+	// stream, when set, produces the contacts of StreamContacts.
+	stream   func(ctx context.Context, fn func(*model.Contact) error) error
+	released []int
+	failed   []int
+}
+
+func (r *stubExports) find(id int) *model.Export {
+	for i := range r.exports {
+		if r.exports[i].ID == id {
+			return &r.exports[i]
+		}
+	}
+
+	return nil
+}
+
+func (r *stubExports) FindExportByUUID(ctx context.Context, d *model.Export) (*model.Export, error) {
+	for _, export := range r.exports {
+		if export.UUID == d.UUID && export.AccountId == d.AccountId {
+			return &export, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (r *stubExports) ClaimExport(ctx context.Context) (*model.Export, error) {
+	for i := range r.exports {
+		if r.exports[i].Status == "pending" {
+			r.exports[i].Status = "running"
+			export := r.exports[i]
+			return &export, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (r *stubExports) TouchExport(ctx context.Context, exportId int) error {
+	return nil
+}
+
+func (r *stubExports) ReleaseExport(ctx context.Context, exportId int) error {
+	r.find(exportId).Status = "pending"
+	r.released = append(r.released, exportId)
+	return nil
+}
+
+func (r *stubExports) CompleteExport(ctx context.Context, d *model.Export) error {
+	export := r.find(d.ID)
+	export.Status = "completed"
+	export.Rows = d.Rows
+	export.FilePath = d.FilePath
+	export.ExpiresAt = d.ExpiresAt
+	return nil
+}
+
+func (r *stubExports) FailExport(ctx context.Context, exportId int, message string) error {
+	r.find(exportId).Status = "failed"
+	r.failed = append(r.failed, exportId)
+	return nil
+}
+
+func (r *stubExports) StreamContacts(ctx context.Context, accountId int, fn func(*model.Contact) error, rules ...segment.Rule) error {
+	return r.stream(ctx, fn)
+}
+
+type recordedAudit struct {
+	events []model.AuditEvent
+}
+
+func (r *recordedAudit) RecordAuditEvent(ctx context.Context, d *model.AuditEvent) error {
+	r.events = append(r.events, *d)
+	return nil
+}
+
+func newExportJWTManager() *utils.JWTManager {
+	return utils.NewJWTManager(config.AuthConfig{
+		JWTSecret: "test-secret-that-is-long-enough-for-hs256",
+		TokenTTL:  time.Hour,
+	})
+}
+
+// pendingExport returns a queued export of the email addresses of the contacts of account 7.
+func pendingExport() model.Export {
+	return model.Export{ID: 1, UUID: "pending-export", AccountId: 7, ExportRequest: model.ExportRequest{Type: "contacts", Format: "csv", Columns: []string{"email"}}, Status: "pending"}
+}
+
+func (r *stubExports) FindAccountsExports(ctx context.Context, accountIds []int) ([]model.Export, error) {
+	var exports []model.Export
+	for _, export := range r.exports {
+		for _, id := range accountIds {
+			if export.AccountId == id {
+				exports = append(exports, export)
+			}
+		}
+	}
+
+	return exports, nil
+}
+
+func writeFile(t *testing.T, dir string, name string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("email\n"), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+
+	return path
+}
+
+func TestDeleteAccountsFilesRemovesTheExportFilesOfPurgedAccounts(t *testing.T) {
+	dir := t.TempDir()
+	exports := &stubExports{exports: []model.Export{
+		{ID: 1, UUID: "completed-export", AccountId: 7, ExportRequest: model.ExportRequest{Format: "csv"}, Status: "completed"},
+		{ID: 2, UUID: "expired-export", AccountId: 7, ExportRequest: model.ExportRequest{Format: "jsonl"}, Status: "expired"},
+		{ID: 3, UUID: "running-export", AccountId: 7, ExportRequest: model.ExportRequest{Format: "csv"}, Status: "running"},
+		{ID: 4, UUID: "other-export", AccountId: 8, ExportRequest: model.ExportRequest{Format: "csv"}, Status: "completed"},
+	}}
+
+	deleted := []string{
+		writeFile(t, dir, "completed-export.csv"),
+		writeFile(t, dir, "expired-export.jsonl"),
+		writeFile(t, dir, "running-export-12345.tmp"),
+	}
+	kept := writeFile(t, dir, "other-export.csv")
+
+	service := NewExportService(exports, nil, nil, nil, nil, nil, "https://app.example.com", dir, time.Hour)
+
+	if err := service.DeleteAccountsFiles(context.Background(), []int{7}); err != nil {
+		t.Fatalf("DeleteAccountsFiles: %v", err)
+	}
+
+	for _, path := range deleted {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be deleted, got %v", filepath.Base(path), err)
+		}
+	}
+
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("the files of other accounts must be kept: %v", err)
+	}
+}
+
+func TestExportLinksExpire(t *testing.T) {
+	dir := t.TempDir()
+	expiresAt := time.Now().Add(time.Hour)
+	exports := &stubExports{exports: []model.Export{
+		{ID: 1, UUID: "completed-export", AccountId: 7, ExportRequest: model.ExportRequest{Format: "csv"}, Status: "completed", FilePath: writeFile(t, dir, "completed-export.csv"), ExpiresAt: &expiresAt},
+	}}
+	audit := &recordedAudit{}
+	jwtManager := newExportJWTManager()
+	service := NewExportService(exports, audit, nil, nil, nil, jwtManager, "https://app.example.com", dir, time.Hour)
+	ctx := context.Background()
+
+	found, err := service.GetExport(ctx, &model.Export{UUID: "completed-export", AccountId: 7})
+	if err != nil {
+		t.Fatalf("GetExport: %v", err)
+	}
+
+	if !strings.HasPrefix(found.DownloadURL, "https://app.example.com/api/v1/public/exports/download?token=") {
+		t.Fatalf("unexpected download URL %q", found.DownloadURL)
+	}
+
+	link, err := url.Parse(found.DownloadURL)
+	if err != nil {
+		t.Fatalf("invalid download URL %q: %v", found.DownloadURL, err)
+	}
+	token := link.Query().Get("token")
+
+	_, file, err := service.OpenExportFile(ctx, token, "203.0.113.7", "curl")
+	if err != nil {
+		t.Fatalf("OpenExportFile: %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+
+	if string(content) != "email\n" {
+		t.Fatalf("unexpected file content %q", content)
+	}
+
+	if len(audit.events) != 1 || audit.events[0].Action != "export.file_downloaded" || audit.events[0].IP != "203.0.113.7" {
+		t.Fatalf("expected the download in the audit log, got %+v", audit.events)
+	}
+
+	// once the link ran out, the export is listed without a link and the link no longer works
+	expired := time.Now().Add(-time.Minute)
+	exports.exports[0].ExpiresAt = &expired
+
+	found, err = service.GetExport(ctx, &model.Export{UUID: "completed-export", AccountId: 7})
+	if err != nil || found.DownloadURL != "" {
+		t.Fatalf("expected an expired export without a download URL, got %+v, %v", found, err)
+	}
+
+	if _, _, err := service.OpenExportFile(ctx, token, "203.0.113.7", "curl"); !apperrors.Is(err, "export_unavailable") {
+		t.Fatalf("expected an export_unavailable error, got %v", err)
+	}
+
+	expiredToken, err := jwtManager.ExportTokenEncode("completed-export", 7, expired)
+	if err != nil {
+		t.Fatalf("ExportTokenEncode: %v", err)
+	}
+
+	if _, _, err := service.OpenExportFile(ctx, expiredToken, "203.0.113.7", "curl"); !apperrors.Is(err, "invalid_export_token") {
+		t.Fatalf("expected an invalid_export_token error, got %v", err)
+	}
+
+	if len(audit.events) != 1 {
+		t.Fatalf("refused downloads were recorded: %+v", audit.events)
+	}
+}
+
+func TestProcessExportsCompletesExports(t *testing.T) {
+	dir := t.TempDir()
+	exports := &stubExports{
+		exports: []model.Export{pendingExport()},
+		stream: func(ctx context.Context, fn func(*model.Contact) error) error {
+			for _, email := range []string{"ada@example.com", "grace@example.com"} {
+				if err := fn(&model.Contact{Email: email}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	service := NewExportService(exports, nil, nil, nil, nil, nil, "https://app.example.com", dir, time.Hour)
+
+	processed, err := service.ProcessExports(context.Background())
+	if err != nil || processed != 1 {
+		t.Fatalf("ProcessExports = %d, %v", processed, err)
+	}
+
+	export := exports.exports[0]
+	if export.Status != "completed" || export.Rows != 2 || export.FilePath != filepath.Join(dir, "pending-export.csv") {
+		t.Fatalf("unexpected completed export: %+v", export)
+	}
+
+	if ttl := time.Until(*export.ExpiresAt); ttl < 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("the link expires in %v, want an hour", ttl)
+	}
+
+	content, err := os.ReadFile(export.FilePath)
+	if err != nil || string(content) != "email\nada@example.com\ngrace@example.com\n" {
+		t.Fatalf("unexpected export file %q, %v", content, err)
+	}
+}
+
+func TestProcessExportsRequeuesExportsInterruptedByAShutdown(t *testing.T) {
+	dir := t.TempDir()
+	ctx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	exports := &stubExports{
+		exports: []model.Export{pendingExport()},
+		stream: func(ctx context.Context, fn func(*model.Contact) error) error {
+			if err := fn(&model.Contact{Email: "ada@example.com"}); err != nil {
+				return err
+			}
+			shutdown()
+			return ctx.Err()
+		},
+	}
+	service := NewExportService(exports, nil, nil, nil, nil, nil, "https://app.example.com", dir, time.Hour)
+
+	processed, err := service.ProcessExports(ctx)
+	if err != nil || processed != 0 {
+		t.Fatalf("ProcessExports = %d, %v", processed, err)
+	}
+
+	if exports.exports[0].Status != "pending" || len(exports.released) != 1 || len(exports.failed) != 0 {
+		t.Fatalf("expected the export to go back to the queue, got %+v, released %v, failed %v", exports.exports[0], exports.released, exports.failed)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("the interrupted export left files behind: %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/feedback"
+	"email-marketing-service/api/metrics"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"errors"
+	"log/slog"
+	"maps"
+	"strings"
+)
+
+// FeedbackService applies the bounce and complaint reports received by the SMTP server. A report is matched
+// to the message it is about by the Message-ID recorded when the message was sent; reports about unknown
+// messages are dropped, so that nobody can unsubscribe contacts by sending made-up reports.
+type FeedbackService struct {
+	messageEventRepository repository.MessageEventStore
+	contactService         *ContactService
+	quotaService           *QuotaService
+	transactor             database.Transactor
+}
+
+func NewFeedbackService(messageEventRepo repository.MessageEventStore, contactSvc *ContactService, quotaSvc *QuotaService, transactor database.Transactor) *FeedbackService {
+	return &FeedbackService{
+		messageEventRepository: messageEventRepo,
+		contactService:         contactSvc,
+		quotaService:           quotaSvc,
+		transactor:             transactor,
+	}
+}
+
+// HandleMessage applies a message received by the SMTP server. Messages that are not reports, such as
+// auto-replies, are dropped. An error means the report could not be stored and should be sent again.
+func (s *FeedbackService) HandleMessage(ctx context.Context, from string, to []string, data []byte) error {
+	report, err := feedback.Parse(bytes.NewReader(data))
+
+	if err != nil {
+		slog.InfoContext(ctx, "dropped a received message that is not a report", "from", from, "error", err)
+		return nil
+	}
+
+	messageId, _, _ := strings.Cut(report.MessageId, "@")
+
+	sent, err := s.messageEventRepository.FindSentMessage(ctx, messageId)
+
+	if errors.Is(err, sql.ErrNoRows) || messageId == "" {
+		slog.InfoContext(ctx, "dropped a report about an unknown message", "from", from, "type", report.Type, "message_id", report.MessageId)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if report.Type == feedback.TypeComplaint {
+		recorded, err := s.record(ctx, sent, "complained", map[string]any{
+			"message_id":    messageId,
+			"feedback_type": report.FeedbackType,
+		})
+
+		if recorded {
+			metrics.MailComplained(s.tier(ctx, sent.AccountId))
+		}
+
+		return err
+	}
+
+	for _, recipient := range report.Recipients {
+		if recipient.Action != "failed" || !strings.EqualFold(recipient.Email, sent.ContactEmail) {
+			continue
+		}
+
+		bounceType := "soft"
+		if recipient.Hard() {
+			bounceType = "hard"
+		}
+
+		recorded, err := s.record(ctx, sent, "bounced", map[string]any{
+			"message_id": messageId,
+			"type":       bounceType,
+			"status":     recipient.Status,
+			"diagnostic": recipient.Diagnostic,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if recorded {
+			metrics.MailBounced(s.tier(ctx, sent.AccountId), bounceType)
+		}
+	}
+
+	return nil
+}
+
+// RecordOpen records that the message with the given message id was opened, which is reported by the mail
+// client loading the tracking image of the message. Only the first open of a message is recorded, and opens
+// of unknown messages are dropped.
+func (s *FeedbackService) RecordOpen(ctx context.Context, messageId string) error {
+	sent, err := s.messageEventRepository.FindSentMessage(ctx, messageId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// the open is described like the message, so that it can be told which workflow step it is about
+	data := maps.Clone(sent.Data)
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["message_id"] = messageId
+
+	_, err = s.messageEventRepository.RecordMessageEvent(ctx, &model.MessageEvent{
+		AccountId: sent.AccountId,
+		ContactId: sent.ContactId,
+		Type:      "opened",
+		Data:      data,
+	})
+
+	return err
+}
+
+// tier returns the plan of an account for the metrics, or none when it cannot be found.
+func (s *FeedbackService) tier(ctx context.Context, accountId int) string {
+	quota, err := s.quotaService.AccountQuota(ctx, accountId)
+
+	if err != nil {
+		slog.WarnContext(ctx, "failed to find the plan of an account", "account", accountId, "error", err)
+		return metrics.TierNone
+	}
+
+	return quota.Plan
+}
+
+// record stores a bounce or complaint about a sent message. Complaints and hard bounces unsubscribe the
+// contact, since mailing them again hurts the reputation of every account sending through the service. It
+// reports whether the event is new.
+func (s *FeedbackService) record(ctx context.Context, sent *model.MessageEvent, eventType string, data map[string]any) (bool, error) {
+	var recorded bool
+
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		recorded, err = s.messageEventRepository.RecordMessageEvent(ctx, &model.MessageEvent{
+			AccountId: sent.AccountId,
+			ContactId: sent.ContactId,
+			Type:      eventType,
+			Data:      data,
+		})
+
+		if err != nil || !recorded {
+			return err
+		}
+
+		if eventType == "bounced" && data["type"] != "hard" {
+			return nil
+		}
+
+		status := "unsubscribed"
+
+		_, err = s.contactService.UpdateContact(ctx, &model.UpdateContact{
+			UUID:      sent.ContactUUID,
+			AccountId: sent.AccountId,
+			Status:    &status,
+			Source:    eventType,
+		})
+
+		if errors.Is(err, errContactNotFound) {
+			return nil
+		}
+
+		return err
+	})
+
+	return recorded && err == nil, err
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"testing"
+)
+
+// stubMessageEvents keeps the message events of a test in memory. Like the unique index of message_events, it
+// records an open of a message once.
+type stubMessageEvents struct {
+	repository.MessageEventStore
+	sent     map[string]*model.MessageEvent
+	recorded []model.MessageEvent
+}
+
+func (s *stubMessageEvents) FindSentMessage(ctx context.Context, messageId string) (*model.MessageEvent, error) {
+	sent, ok := s.sent[messageId]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return sent, nil
+}
+
+func (s *stubMessageEvents) RecordMessageEvent(ctx context.Context, d *model.MessageEvent) (bool, error) {
+	for _, event := range s.recorded {
+		if event.ContactId == d.ContactId && event.Type == d.Type && event.Data["message_id"] == d.Data["message_id"] {
+			return false, nil
+		}
+	}
+	s.recorded = append(s.recorded, *d)
+	return true, nil
+}
+
+func TestRecordOpen(t *testing.T) {
+	events := &stubMessageEvents{sent: map[string]*model.MessageEvent{
+		"message-1": {AccountId: 7, ContactId: 3, Type: "sent", Data: map[string]any{"message_id": "message-1", "workflow_uuid": "flow", "step_id": "welcome"}},
+	}}
+	service := NewFeedbackService(events, nil, nil, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := service.RecordOpen(ctx, "message-1"); err != nil {
+			t.Fatalf("RecordOpen: %v", err)
+		}
+	}
+
+	if err := service.RecordOpen(ctx, "unknown-message"); err != nil {
+		t.Fatalf("RecordOpen of an unknown message: %v", err)
+	}
+
+	if len(events.recorded) != 1 {
+		t.Fatalf("recorded %d events, want one open: %+v", len(events.recorded), events.recorded)
+	}
+
+	opened := events.recorded[0]
+	if opened.Type != "opened" || opened.AccountId != 7 || opened.ContactId != 3 || opened.Data["step_id"] != "welcome" {
+		t.Fatalf("unexpected open: %+v", opened)
+	}
+
+	if events.sent["message-1"].Data["message_id"] != "message-1" || len(events.sent["message-1"].Data) != 3 {
+		t.Fatalf("recording the open changed the sent event: %+v", events.sent["message-1"])
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/metrics"
+	"email-marketing-service/api/repository"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	// mailsPerBatch bounds how many mails one call of ProcessMailQueue sends.
+	mailsPerBatch = 100
+	// mailSendLease is how long a claimed mail is left to the instance sending it. A mail whose outcome was not
+	// recorded by then, e.g. because the instance stopped, is sent again.
+	mailSendLease = 10 * time.Minute
+	// mailRetryDelay is how long a mail that failed to send waits before its first retry; the wait doubles
+	// with every attempt.
+	mailRetryDelay = 5 * time.Minute
+	// mailMaxAttempts is how many times a mail is tried before it is given up.
+	mailMaxAttempts = 5
+	// mailQuotaDelay is how long a mail waits when the account's message quota can never allow it, e.g. a
+	// quota of zero, before it is checked again.
+	mailQuotaDelay = time.Hour
+)
+
+// MailQueueService sends the marketing mail queued by workflows. Mail is taken from the account's message
+// quota when it is sent, and handed back when the send fails; failed sends are tried again a few times.
+type MailQueueService struct {
+	mailQueueRepository repository.MailQueueStore
+	quotaService        *QuotaService
+	mailer              custom.Mailer
+}
+
+func NewMailQueueService(mailQueueRepo repository.MailQueueStore, quotaSvc *QuotaService, mailer custom.Mailer) *MailQueueService {
+	return &MailQueueService{
+		mailQueueRepository: mailQueueRepo,
+		quotaService:        quotaSvc,
+		mailer:              mailer,
+	}
+}
+
+// ReportQueueDepth sets the mail queue depth metric of every plan to the number of mails waiting to be sent.
+func (s *MailQueueService) ReportQueueDepth(ctx context.Context) error {
+	counts, err := s.mailQueueRepository.CountQueuedMail(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for plan, count := range counts {
+		metrics.SetQueueDepth(plan, count)
+	}
+
+	return nil
+}
+
+// ProcessMailQueue sends the queued mails that are due and returns how many it took from the queue. Each
+// mail is claimed by one instance at a time, so every instance can run the worker.
+func (s *MailQueueService) ProcessMailQueue(ctx context.Context) (int, error) {
+	for processed := 0; processed < mailsPerBatch; processed++ {
+		found, err := s.sendDueMail(ctx)
+
+		if err != nil || !found {
+			return processed, err
+		}
+	}
+
+	return mailsPerBatch, nil
+}
+
+// sendDueMail sends the mail that is due the longest and reports whether there was one. A mail is recorded as
+// sent once the SMTP server accepted it, so it may go out twice if the outcome cannot be stored.
+func (s *MailQueueService) sendDueMail(ctx context.Context) (bool, error) {
+	mail, err := s.mailQueueRepository.ClaimDueMail(ctx, mailSendLease)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	// contacts that unsubscribed since the mail was queued do not get it
+	if mail.ContactStatus != "subscribed" {
+		return true, s.mailQueueRepository.DropMail(ctx, mail.ID)
+	}
+
+	quota, err := s.quotaService.AccountQuota(ctx, mail.AccountId)
+
+	if err != nil {
+		return false, err
+	}
+
+	// a mail over the account's message quota waits until the quota allows it
+	err = s.quotaService.ReserveMessages(ctx, mail.AccountId, 1)
+
+	var exceeded *QuotaExceededError
+	if errors.As(err, &exceeded) {
+		metrics.MailDeferred(quota.Plan)
+		at := time.Now().Add(mailQuotaDelay)
+		if exceeded.RetryAfter >= 0 {
+			at = time.Now().Add(exceeded.RetryAfter)
+		}
+		return true, s.mailQueueRepository.DeferMail(ctx, mail.ID, at)
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	sendErr := s.mailer.MarketingMail(ctx, mail.Email, mail.Subject, mail.Body, mail.PreferencesLink, mail.MessageId, quota.Plan)
+
+	if sendErr == nil {
+		return true, s.mailQueueRepository.CompleteMail(ctx, mail.ID)
+	}
+
+	// the mail did not go out, so it must not count against the quota
+	if err := s.quotaService.RefundMessages(ctx, mail.AccountId, 1); err != nil {
+		slog.ErrorContext(ctx, "failed to refund the message quota", "account", mail.AccountId, "error", err)
+	}
+
+	if mail.Attempts >= mailMaxAttempts {
+		metrics.MailFailed(quota.Plan)
+		slog.ErrorContext(ctx, "gave up a queued mail", "mail", mail.ID, "attempts", mail.Attempts, "error", sendErr)
+
+		return true, s.mailQueueRepository.FailMail(ctx, mail.ID, sendErr.Error())
+	}
+
+	metrics.MailDeferred(quota.Plan)
+	slog.WarnContext(ctx, "queued mail failed, it will be tried again", "mail", mail.ID, "attempts", mail.Attempts, "error", sendErr)
+
+	return true, s.mailQueueRepository.RetryMail(ctx, mail.ID, time.Now().Add(mailRetryDelay<<(mail.Attempts-1)), sendErr.Error())
+}
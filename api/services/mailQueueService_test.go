@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/ratelimit"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/repository/memory"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubMailQueue keeps the mail queue of a test in memory, with the statuses of mail_queue.
+type stubMailQueue struct {
+	repository.MailQueueStore
+	mu     sync.Mutex
+	nextId int64
+	mails  map[int64]*model.QueuedMail
+	// statuses are the contact statuses returned with claimed mails; contacts not listed are subscribed.
+	statuses map[int]string
+	// sent are the ids of the mails recorded as sent.
+	sent []int64
+}
+
+func newStubMailQueue() *stubMailQueue {
+	return &stubMailQueue{mails: map[int64]*model.QueuedMail{}, statuses: map[int]string{}}
+}
+
+func (q *stubMailQueue) EnqueueMail(ctx context.Context, d *model.QueuedMail) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextId++
+	d.ID = q.nextId
+	d.Status = "queued"
+	d.NextAttemptAt = time.Now()
+	d.CreatedAt = time.Now()
+
+	mail := *d
+	q.mails[d.ID] = &mail
+
+	return nil
+}
+
+func (q *stubMailQueue) ClaimDueMail(ctx context.Context, lease time.Duration) (*model.QueuedMail, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due *model.QueuedMail
+	for _, mail := range q.mails {
+		if mail.Status == "queued" && !mail.NextAttemptAt.After(time.Now()) && (due == nil || mail.NextAttemptAt.Before(due.NextAttemptAt)) {
+			due = mail
+		}
+	}
+
+	if due == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	due.Attempts++
+	due.NextAttemptAt = time.Now().Add(lease)
+
+	claimed := *due
+	claimed.ContactStatus = "subscribed"
+	if status, ok := q.statuses[due.ContactId]; ok {
+		claimed.ContactStatus = status
+	}
+
+	return &claimed, nil
+}
+
+func (q *stubMailQueue) CompleteMail(ctx context.Context, id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.mails, id)
+	q.sent = append(q.sent, id)
+
+	return nil
+}
+
+func (q *stubMailQueue) DeferMail(ctx context.Context, id int64, at time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.mails[id].Attempts--
+	q.mails[id].NextAttemptAt = at
+
+	return nil
+}
+
+func (q *stubMailQueue) RetryMail(ctx context.Context, id int64, at time.Time, message string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.mails[id].NextAttemptAt = at
+	q.mails[id].Error = message
+
+	return nil
+}
+
+func (q *stubMailQueue) FailMail(ctx context.Context, id int64, message string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.mails[id].Status = "failed"
+	q.mails[id].Error = message
+
+	return nil
+}
+
+func (q *stubMailQueue) DropMail(ctx context.Context, id int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.mails, id)
+
+	return nil
+}
+
+// queued returns the mails left in the queue in the order they were queued.
+func (q *stubMailQueue) queued() []model.QueuedMail {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	mails := []model.QueuedMail{}
+	for _, mail := range q.mails {
+		mails = append(mails, *mail)
+	}
+	sort.Slice(mails, func(i, j int) bool { return mails[i].ID < mails[j].ID })
+
+	return mails
+}
+
+// makeDue lets the mails of the queue be claimed right away, as if their wait had passed.
+func (q *stubMailQueue) makeDue() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, mail := range q.mails {
+		mail.NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+type mailQueueFixture struct {
+	service *MailQueueService
+	queue   *stubMailQueue
+	quotas  *QuotaService
+	mailer  *custom.MemoryMailer
+	ctx     context.Context
+}
+
+func newMailQueueFixture(messagesPerHour int) *mailQueueFixture {
+	queue := newStubMailQueue()
+	quotas := NewQuotaService(memory.NewQuotaRepository(model.AccountQuota{
+		Plan:            "free",
+		MessagesPerHour: messagesPerHour,
+		MessagesPerDay:  100,
+	}), ratelimit.NewMemoryLimiter())
+	mailer := custom.NewMemoryMailer()
+
+	return &mailQueueFixture{
+		service: NewMailQueueService(queue, quotas, mailer),
+		queue:   queue,
+		quotas:  quotas,
+		mailer:  mailer,
+		ctx:     context.Background(),
+	}
+}
+
+func (f *mailQueueFixture) enqueue(t *testing.T, contactId int, email string) *model.QueuedMail {
+	t.Helper()
+
+	mail := &model.QueuedMail{
+		AccountId:       7,
+		ContactId:       contactId,
+		Email:           email,
+		Subject:         "Welcome",
+		Body:            "<p>Welcome</p>",
+		PreferencesLink: "https://app.example.com/api/v1/public/preferences?token=t",
+		MessageId:       "message-" + email,
+	}
+	if err := f.queue.EnqueueMail(f.ctx, mail); err != nil {
+		t.Fatalf("EnqueueMail: %v", err)
+	}
+
+	return mail
+}
+
+func (f *mailQueueFixture) process(t *testing.T) int {
+	t.Helper()
+
+	processed, err := f.service.ProcessMailQueue(f.ctx)
+	if err != nil {
+		t.Fatalf("ProcessMailQueue: %v", err)
+	}
+
+	return processed
+}
+
+func TestProcessMailQueueSendsQueuedMail(t *testing.T) {
+	f := newMailQueueFixture(10)
+
+	mail := f.enqueue(t, 3, "ada@example.com")
+
+	if processed := f.process(t); processed != 1 {
+		t.Fatalf("processed %d mails, want 1", processed)
+	}
+
+	sent, ok := f.mailer.Last("marketing")
+	if !ok || sent.Email != "ada@example.com" || sent.MessageId != mail.MessageId {
+		t.Fatalf("unexpected mail sent: %+v", sent)
+	}
+
+	if len(f.queue.sent) != 1 || f.queue.sent[0] != mail.ID || len(f.queue.queued()) != 0 {
+		t.Fatalf("expected the mail to be recorded as sent and leave the queue, got sent %v and queue %+v", f.queue.sent, f.queue.queued())
+	}
+
+	// the mail took one message of the quota
+	var exceeded *QuotaExceededError
+	if err := f.quotas.ReserveMessages(f.ctx, 7, 10); !errors.As(err, &exceeded) {
+		t.Fatalf("expected the sent mail to count against the quota, got %v", err)
+	}
+}
+
+func TestProcessMailQueueRetriesFailedSends(t *testing.T) {
+	f := newMailQueueFixture(10)
+	f.mailer.Err = errors.New("smtp server unavailable")
+
+	f.enqueue(t, 3, "ada@example.com")
+
+	for attempt := 1; attempt < mailMaxAttempts; attempt++ {
+		f.process(t)
+
+		queued := f.queue.queued()
+		if len(queued) != 1 || queued[0].Status != "queued" || queued[0].Attempts != attempt || queued[0].Error != "smtp server unavailable" {
+			t.Fatalf("attempt %d: expected the mail to wait for a retry, got %+v", attempt, queued)
+		}
+
+		wait := time.Until(queued[0].NextAttemptAt)
+		if want := mailRetryDelay << (attempt - 1); wait < want-time.Minute || wait > want {
+			t.Fatalf("attempt %d: retry in %v, want about %v", attempt, wait, want)
+		}
+
+		f.queue.makeDue()
+	}
+
+	f.process(t)
+
+	queued := f.queue.queued()
+	if len(queued) != 1 || queued[0].Status != "failed" || queued[0].Attempts != mailMaxAttempts {
+		t.Fatalf("expected the mail to be given up after %d attempts, got %+v", mailMaxAttempts, queued)
+	}
+
+	if f.process(t) != 0 || len(f.queue.sent) != 0 {
+		t.Fatal("a failed mail was tried again")
+	}
+
+	// none of the failed attempts kept a message of the quota
+	if err := f.quotas.ReserveMessages(f.ctx, 7, 10); err != nil {
+		t.Fatalf("expected failed sends to be refunded, got %v", err)
+	}
+}
+
+func TestProcessMailQueueDefersMailOverTheQuota(t *testing.T) {
+	f := newMailQueueFixture(1)
+
+	f.enqueue(t, 3, "ada@example.com")
+	f.enqueue(t, 4, "grace@example.com")
+
+	if processed := f.process(t); processed != 2 {
+		t.Fatalf("processed %d mails, want 2", processed)
+	}
+
+	if len(f.mailer.Sent()) != 1 || len(f.queue.sent) != 1 {
+		t.Fatalf("expected one mail within the hourly quota to be sent, got %+v", f.mailer.Sent())
+	}
+
+	queued := f.queue.queued()
+	if len(queued) != 1 || queued[0].Attempts != 0 || !queued[0].NextAttemptAt.After(time.Now().Add(30*time.Minute)) {
+		t.Fatalf("expected the other mail to wait for the quota without using an attempt, got %+v", queued)
+	}
+}
+
+func TestProcessMailQueueDropsMailToUnsubscribedContacts(t *testing.T) {
+	f := newMailQueueFixture(10)
+	f.queue.statuses[3] = "unsubscribed"
+
+	f.enqueue(t, 3, "ada@example.com")
+	f.process(t)
+
+	if len(f.mailer.Sent()) != 0 || len(f.queue.sent) != 0 || len(f.queue.queued()) != 0 {
+		t.Fatalf("expected the mail to be dropped unsent, got sent %+v and queue %+v", f.mailer.Sent(), f.queue.queued())
+	}
+
+	if err := f.quotas.ReserveMessages(f.ctx, 7, 10); err != nil {
+		t.Fatalf("a dropped mail took from the quota: %v", err)
+	}
+}
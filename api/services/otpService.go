@@ -1,22 +1,50 @@
 package services
 
 import (
+	"context"
 	"email-marketing-service/api/model"
 	"email-marketing-service/api/repository"
+	"fmt"
+	"time"
 )
 
+// The flows an OTP can be mailed for. An OTP only works in the flow it was created for.
+const (
+	OTPPurposeVerifyEmail    = "verify_email"
+	OTPPurposeResetPassword  = "reset_password"
+	OTPPurposeChangeEmail    = "change_email"
+	OTPPurposeCancelDeletion = "cancel_deletion"
+)
+
+// otpLifetimes is how long the OTP of each purpose works. Account deletion can be cancelled for the whole
+// grace period.
+var otpLifetimes = map[string]time.Duration{
+	OTPPurposeVerifyEmail:    24 * time.Hour,
+	OTPPurposeResetPassword:  time.Hour,
+	OTPPurposeChangeEmail:    time.Hour,
+	OTPPurposeCancelDeletion: accountDeletionGracePeriod,
+}
+
 type OTPService struct {
-	otpRepository *repository.OTPRepository
+	otpRepository repository.OTPStore
 }
 
-func NewOTPService(otpRepo *repository.OTPRepository) *OTPService {
+func NewOTPService(otpRepo repository.OTPStore) *OTPService {
 	return &OTPService{
 		otpRepository: otpRepo,
 	}
 }
 
-func (s *OTPService) CreateOTP(d *model.OTP) error {
-	err := s.otpRepository.CreateOTP(d)
+// CreateOTP stores an OTP for d.Purpose, which expires after the lifetime of the purpose.
+func (s *OTPService) CreateOTP(ctx context.Context, d *model.OTP) error {
+	lifetime, ok := otpLifetimes[d.Purpose]
+	if !ok {
+		return fmt.Errorf("unknown otp purpose %q", d.Purpose)
+	}
+
+	d.ExpiresAt = time.Now().Add(lifetime)
+
+	err := s.otpRepository.CreateOTP(ctx, d)
 	if err != nil {
 		return err
 	}
@@ -24,18 +52,19 @@ func (s *OTPService) CreateOTP(d *model.OTP) error {
 	return nil
 }
 
-func (s *OTPService) RetrieveOTP(d *model.OTP) (*model.OTP, error) {
-	otpData, err := s.otpRepository.FindOTP(d)
+// RetrieveOTP returns the unexpired OTP with the token of d that was created for d.Purpose.
+func (s *OTPService) RetrieveOTP(ctx context.Context, d *model.OTP) (*model.OTP, error) {
+	otpData, err := s.otpRepository.FindOTP(ctx, d)
 
 	if err != nil {
-		return nil, err
+		return nil, whenNoRows(err, errOTPNotFound)
 	}
 
 	return otpData, err
 }
 
-func (s *OTPService) DeleteOTP(id int) error {
-	err := s.otpRepository.DeleteOTP(id)
+func (s *OTPService) DeleteOTP(ctx context.Context, id int) error {
+	err := s.otpRepository.DeleteOTP(ctx, id)
 
 	if err != nil {
 		return err
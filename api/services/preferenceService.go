@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/utils"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// preferenceLinkTTL is how long a preference center link stays valid. Links are put in every campaign,
+// so they have to outlive the time people keep emails around.
+const preferenceLinkTTL = 180 * 24 * time.Hour
+
+// PreferenceService backs the preference center, where contacts open a signed link to choose the topics and
+// lists they receive, pause their emails or change their name without signing in.
+type PreferenceService struct {
+	contactRepository  repository.ContactStore
+	listRepository     repository.ListStore
+	topicRepository    repository.TopicStore
+	activityRepository repository.ActivityStore
+	jwtManager         *utils.JWTManager
+	appURL             string
+	transactor         database.Transactor
+}
+
+func NewPreferenceService(contactRepo repository.ContactStore, listRepo repository.ListStore, topicRepo repository.TopicStore, activityRepo repository.ActivityStore, jwtManager *utils.JWTManager, appURL string, transactor database.Transactor) *PreferenceService {
+	return &PreferenceService{
+		contactRepository:  contactRepo,
+		listRepository:     listRepo,
+		topicRepository:    topicRepo,
+		activityRepository: activityRepo,
+		jwtManager:         jwtManager,
+		appURL:             appURL,
+		transactor:         transactor,
+	}
+}
+
+// PreferenceLink returns a link to the preference center of a contact, to be put in the emails sent to it.
+func (s *PreferenceService) PreferenceLink(ctx context.Context, d *model.Contact) (*model.PreferenceLink, error) {
+	contact, err := s.contactRepository.FindContactByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errContactNotFound)
+	}
+
+	expiresAt := time.Now().Add(preferenceLinkTTL)
+
+	token, err := s.jwtManager.PreferencesTokenEncode(contact.UUID, contact.AccountId, expiresAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.PreferenceLink{
+		Link:      fmt.Sprintf("%s/api/v1/public/preferences?token=%s", s.appURL, url.QueryEscape(token)),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *PreferenceService) contact(ctx context.Context, token string) (*model.Contact, error) {
+	contactUUID, accountId, err := s.jwtManager.PreferencesTokenDecode(token)
+
+	if err != nil {
+		return nil, errInvalidPreferencesToken.Wrap(err)
+	}
+
+	contact, err := s.contactRepository.FindContactByUUID(ctx, &model.Contact{UUID: contactUUID, AccountId: accountId})
+
+	if err != nil {
+		return nil, whenNoRows(err, errContactNotFound)
+	}
+
+	return contact, nil
+}
+
+// GetPreferences returns the preferences of the contact the token was issued for.
+func (s *PreferenceService) GetPreferences(ctx context.Context, token string) (*model.Preferences, error) {
+	contact, err := s.contact(ctx, token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.preferences(ctx, contact)
+}
+
+func (s *PreferenceService) preferences(ctx context.Context, contact *model.Contact) (*model.Preferences, error) {
+	topics, err := s.topicRepository.FindTopicPreferences(ctx, contact.AccountId, contact.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	lists, err := s.listRepository.FindContactLists(ctx, contact.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	snoozedUntil := contact.SnoozedUntil
+	if snoozedUntil != nil && !snoozedUntil.After(time.Now()) {
+		snoozedUntil = nil
+	}
+
+	return &model.Preferences{
+		Email:        contact.Email,
+		FirstName:    contact.FirstName,
+		LastName:     contact.LastName,
+		Status:       contact.Status,
+		SnoozedUntil: snoozedUntil,
+		Topics:       topics,
+		Lists:        lists,
+	}, nil
+}
+
+// UpdatePreferences stores the choices of the contact the token was issued for and returns its preferences.
+func (s *PreferenceService) UpdatePreferences(ctx context.Context, d *model.UpdatePreferences) (*model.Preferences, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contact, err := s.contact(ctx, d.Token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if d.FirstName != nil || d.LastName != nil || d.Unsubscribe {
+			before := *contact
+
+			if d.FirstName != nil {
+				contact.FirstName = strings.TrimSpace(*d.FirstName)
+			}
+
+			if d.LastName != nil {
+				contact.LastName = strings.TrimSpace(*d.LastName)
+			}
+
+			if d.Unsubscribe {
+				contact.Status = "unsubscribed"
+			}
+
+			updated, err := s.contactRepository.UpdateContact(ctx, contact)
+
+			if err != nil {
+				return whenNoRows(err, errContactNotFound)
+			}
+
+			if err := recordChanges(ctx, s.activityRepository, &before, updated, "preference_center"); err != nil {
+				return err
+			}
+
+			contact = updated
+		}
+
+		if d.Topics != nil {
+			err := s.topicRepository.SetTopicOptOuts(ctx, contact.AccountId, contact.ID, d.Topics)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if d.Lists != nil {
+			err := s.listRepository.KeepContactLists(ctx, contact.ID, d.Lists)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if d.SnoozeDays != nil {
+			var until *time.Time
+
+			if *d.SnoozeDays > 0 {
+				t := time.Now().AddDate(0, 0, *d.SnoozeDays)
+				until = &t
+			}
+
+			contact.SnoozedUntil = until
+
+			return s.contactRepository.SetSnooze(ctx, contact.ID, until)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.preferences(ctx, contact)
+}
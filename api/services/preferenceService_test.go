@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/config"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/repository/memory"
+	"email-marketing-service/api/utils"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubPreferenceContacts holds one contact, found only by its own uuid and account.
+type stubPreferenceContacts struct {
+	repository.ContactStore
+	contact model.Contact
+}
+
+func (r *stubPreferenceContacts) FindContactByUUID(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	if d.UUID != r.contact.UUID || d.AccountId != r.contact.AccountId {
+		return nil, sql.ErrNoRows
+	}
+	contact := r.contact
+	return &contact, nil
+}
+
+func (r *stubPreferenceContacts) UpdateContact(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	r.contact = *d
+	contact := r.contact
+	return &contact, nil
+}
+
+func (r *stubPreferenceContacts) SetSnooze(ctx context.Context, contactId int, until *time.Time) error {
+	r.contact.SnoozedUntil = until
+	return nil
+}
+
+// stubPreferenceTopics keeps the topics of the account and the ones the contact opted out of.
+type stubPreferenceTopics struct {
+	repository.TopicStore
+	topics   []model.Topic
+	optedOut map[string]bool
+}
+
+func (r *stubPreferenceTopics) FindTopicPreferences(ctx context.Context, accountId int, contactId int) ([]model.TopicPreference, error) {
+	preferences := []model.TopicPreference{}
+	for _, topic := range r.topics {
+		preferences = append(preferences, model.TopicPreference{UUID: topic.UUID, Name: topic.Name, Subscribed: !r.optedOut[topic.UUID]})
+	}
+	return preferences, nil
+}
+
+func (r *stubPreferenceTopics) SetTopicOptOuts(ctx context.Context, accountId int, contactId int, subscribedUUIDs []string) error {
+	r.optedOut = map[string]bool{}
+	for _, topic := range r.topics {
+		r.optedOut[topic.UUID] = !slices.Contains(subscribedUUIDs, topic.UUID)
+	}
+	return nil
+}
+
+// stubPreferenceLists keeps the lists the contact is on.
+type stubPreferenceLists struct {
+	repository.ListStore
+	lists []model.ListPreference
+}
+
+func (r *stubPreferenceLists) FindContactLists(ctx context.Context, contactId int) ([]model.ListPreference, error) {
+	return r.lists, nil
+}
+
+func (r *stubPreferenceLists) KeepContactLists(ctx context.Context, contactId int, listUUIDs []string) error {
+	r.lists = slices.DeleteFunc(r.lists, func(list model.ListPreference) bool { return !slices.Contains(listUUIDs, list.UUID) })
+	return nil
+}
+
+type preferenceServiceFixture struct {
+	service    *PreferenceService
+	contacts   *stubPreferenceContacts
+	topics     *stubPreferenceTopics
+	lists      *stubPreferenceLists
+	activities *recordedActivities
+	jwtManager *utils.JWTManager
+	ctx        context.Context
+}
+
+func newPreferenceServiceFixture() *preferenceServiceFixture {
+	f := &preferenceServiceFixture{
+		contacts: &stubPreferenceContacts{contact: model.Contact{ID: 3, UUID: "contact-uuid", AccountId: 7, Email: "ada@example.com", FirstName: "Ada", Status: "subscribed"}},
+		topics: &stubPreferenceTopics{topics: []model.Topic{
+			{UUID: "news", Name: "News"},
+			{UUID: "offers", Name: "Offers"},
+		}},
+		lists: &stubPreferenceLists{lists: []model.ListPreference{
+			{UUID: "weekly", Name: "Weekly"},
+			{UUID: "product", Name: "Product"},
+		}},
+		activities: &recordedActivities{},
+		jwtManager: utils.NewJWTManager(config.AuthConfig{
+			JWTSecret: "test-secret-that-is-long-enough-for-hs256",
+			TokenTTL:  time.Hour,
+		}),
+		ctx: context.Background(),
+	}
+	f.service = NewPreferenceService(f.contacts, f.lists, f.topics, f.activities, f.jwtManager, "https://app.example.com", memory.NewTransactor())
+
+	return f
+}
+
+// token returns the token of the preference link of the contact.
+func (f *preferenceServiceFixture) token(t *testing.T) string {
+	t.Helper()
+
+	link, err := f.service.PreferenceLink(f.ctx, &model.Contact{UUID: "contact-uuid", AccountId: 7})
+	if err != nil {
+		t.Fatalf("PreferenceLink: %v", err)
+	}
+
+	if !strings.HasPrefix(link.Link, "https://app.example.com/api/v1/public/preferences?token=") {
+		t.Fatalf("unexpected preference link %q", link.Link)
+	}
+
+	if ttl := time.Until(link.ExpiresAt); ttl < preferenceLinkTTL-time.Minute || ttl > preferenceLinkTTL {
+		t.Fatalf("the link expires in %v, want %v", ttl, preferenceLinkTTL)
+	}
+
+	parsed, err := url.Parse(link.Link)
+	if err != nil {
+		t.Fatalf("invalid preference link %q: %v", link.Link, err)
+	}
+
+	return parsed.Query().Get("token")
+}
+
+func TestPreferenceLinkOpensThePreferencesOfTheContact(t *testing.T) {
+	f := newPreferenceServiceFixture()
+
+	preferences, err := f.service.GetPreferences(f.ctx, f.token(t))
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+
+	if preferences.Email != "ada@example.com" || len(preferences.Topics) != 2 || !preferences.Topics[0].Subscribed || len(preferences.Lists) != 2 {
+		t.Fatalf("unexpected preferences: %+v", preferences)
+	}
+
+	_, err = f.service.PreferenceLink(f.ctx, &model.Contact{UUID: "contact-uuid", AccountId: 8})
+	if !apperrors.Is(err, "contact_not_found") {
+		t.Fatalf("expected a link for another account's contact to fail with contact_not_found, got %v", err)
+	}
+}
+
+func TestGetPreferencesRejectsInvalidTokens(t *testing.T) {
+	f := newPreferenceServiceFixture()
+
+	expired, err := f.jwtManager.PreferencesTokenEncode("contact-uuid", 7, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("PreferencesTokenEncode: %v", err)
+	}
+
+	export, err := f.jwtManager.ExportTokenEncode("contact-uuid", 7, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ExportTokenEncode: %v", err)
+	}
+
+	otherAccount, err := f.jwtManager.PreferencesTokenEncode("contact-uuid", 8, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("PreferencesTokenEncode: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  string
+	}{
+		{name: "garbage", token: "not-a-token", code: "invalid_preferences_token"},
+		{name: "expired", token: expired, code: "invalid_preferences_token"},
+		{name: "another kind of token", token: export, code: "invalid_preferences_token"},
+		{name: "contact of another account", token: otherAccount, code: "contact_not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.GetPreferences(f.ctx, tt.token); !apperrors.Is(err, tt.code) {
+				t.Fatalf("expected a %s error, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	f := newPreferenceServiceFixture()
+	token := f.token(t)
+
+	firstName := "  Grace "
+	snoozeDays := 30
+
+	preferences, err := f.service.UpdatePreferences(f.ctx, &model.UpdatePreferences{
+		Token:      token,
+		FirstName:  &firstName,
+		Topics:     []string{"news"},
+		Lists:      []string{"product"},
+		SnoozeDays: &snoozeDays,
+	})
+	if err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+
+	if preferences.FirstName != "Grace" || preferences.Status != "subscribed" {
+		t.Fatalf("unexpected contact in the preferences: %+v", preferences)
+	}
+
+	if !preferences.Topics[0].Subscribed || preferences.Topics[1].Subscribed {
+		t.Fatalf("expected news to be kept and offers to be opted out of, got %+v", preferences.Topics)
+	}
+
+	if len(preferences.Lists) != 1 || preferences.Lists[0].UUID != "product" {
+		t.Fatalf("expected the contact to stay on product only, got %+v", preferences.Lists)
+	}
+
+	if preferences.SnoozedUntil == nil || time.Until(*preferences.SnoozedUntil) < 29*24*time.Hour {
+		t.Fatalf("expected emails to be paused for 30 days, got %v", preferences.SnoozedUntil)
+	}
+
+	// a pause of zero days ends the pause, and fields that are not sent are left as they are
+	snoozeDays = 0
+
+	preferences, err = f.service.UpdatePreferences(f.ctx, &model.UpdatePreferences{Token: token, SnoozeDays: &snoozeDays})
+	if err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+
+	if preferences.SnoozedUntil != nil || preferences.FirstName != "Grace" || len(preferences.Lists) != 1 || preferences.Topics[1].Subscribed {
+		t.Fatalf("unexpected preferences after ending the pause: %+v", preferences)
+	}
+}
+
+func TestUpdatePreferencesUnsubscribes(t *testing.T) {
+	f := newPreferenceServiceFixture()
+
+	preferences, err := f.service.UpdatePreferences(f.ctx, &model.UpdatePreferences{Token: f.token(t), Unsubscribe: true})
+	if err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+
+	if preferences.Status != "unsubscribed" || f.contacts.contact.Status != "unsubscribed" {
+		t.Fatalf("expected the contact to be unsubscribed, got %+v", preferences)
+	}
+
+	if len(f.activities.activities) != 1 {
+		t.Fatalf("expected the unsubscribe in the timeline, got %+v", f.activities.activities)
+	}
+
+	if activity := f.activities.activities[0]; activity.Type != "unsubscribed" || activity.Data["source"] != "preference_center" {
+		t.Fatalf("unexpected activity: %+v", activity)
+	}
+}
+
+func TestUpdatePreferencesValidates(t *testing.T) {
+	f := newPreferenceServiceFixture()
+
+	snoozeDays := 366
+
+	_, err := f.service.UpdatePreferences(f.ctx, &model.UpdatePreferences{Token: f.token(t), SnoozeDays: &snoozeDays})
+	if !apperrors.Is(err, "validation_failed") {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	if f.contacts.contact.SnoozedUntil != nil {
+		t.Fatal("an invalid update paused the emails of the contact")
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/metrics"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/ratelimit"
+	"email-marketing-service/api/repository"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+)
+
+// QuotaExceededError is returned when an account has used up one of its quotas.
+type QuotaExceededError struct {
+	Quota      string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	if e.RetryAfter < 0 {
+		return fmt.Sprintf("%s quota exceeded", e.Quota)
+	}
+	return fmt.Sprintf("%s quota exceeded, retry in %d seconds", e.Quota, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// publicSubmissionLimit bounds how often one IP address can submit signup forms and other public endpoints.
+var publicSubmissionLimit = ratelimit.Limit{Rate: 1.0 / 60, Burst: 10}
+
+// QuotaService enforces the per-account request rate on the HTTP layer and the message quotas of the send pipeline.
+type QuotaService struct {
+	quotaRepository repository.QuotaStore
+	limiter         ratelimit.Limiter
+}
+
+func NewQuotaService(quotaRepo repository.QuotaStore, limiter ratelimit.Limiter) *QuotaService {
+	return &QuotaService{
+		quotaRepository: quotaRepo,
+		limiter:         limiter,
+	}
+}
+
+func (s *QuotaService) AccountQuota(ctx context.Context, userId int) (*model.AccountQuota, error) {
+	return s.quotaRepository.FindAccountQuota(ctx, userId)
+}
+
+// AllowRequest takes one request token from the account's bucket.
+func (s *QuotaService) AllowRequest(ctx context.Context, userId int) (ratelimit.Result, error) {
+	quota, err := s.quotaRepository.FindAccountQuota(ctx, userId)
+
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	metrics.SetTier(ctx, quota.Plan)
+
+	limit := ratelimit.Limit{Rate: quota.RequestsPerSecond, Burst: quota.RequestBurst}
+
+	return s.limiter.Allow(ctx, "req:account:"+strconv.Itoa(userId), limit, 1)
+}
+
+// AllowPublicSubmission takes one token from the bucket of the IP address a public submission came from.
+func (s *QuotaService) AllowPublicSubmission(ctx context.Context, ip string) (ratelimit.Result, error) {
+	return s.limiter.Allow(ctx, "public:ip:"+ip, publicSubmissionLimit, 1)
+}
+
+// ReserveMessages takes n messages from the account's daily and hourly send quotas.
+// The send pipeline must call it before queueing messages and refuse them on error.
+func (s *QuotaService) ReserveMessages(ctx context.Context, userId int, n int) error {
+	quota, err := s.quotaRepository.FindAccountQuota(ctx, userId)
+
+	if err != nil {
+		return err
+	}
+
+	account := strconv.Itoa(userId)
+
+	// the daily bucket is checked first since it is the one most likely to run dry for a long time
+	dayResult, err := s.limiter.Allow(ctx, "msg:day:"+account, ratelimit.PerDay(quota.MessagesPerDay), n)
+
+	if err != nil {
+		return err
+	}
+
+	if !dayResult.Allowed {
+		return &QuotaExceededError{Quota: "daily message", RetryAfter: dayResult.RetryAfter}
+	}
+
+	hourResult, err := s.limiter.Allow(ctx, "msg:hour:"+account, ratelimit.PerHour(quota.MessagesPerHour), n)
+
+	if err == nil && hourResult.Allowed {
+		return nil
+	}
+
+	// the messages were not sent, so they must not count against the daily quota either
+	if refundErr := s.limiter.Refund(ctx, "msg:day:"+account, ratelimit.PerDay(quota.MessagesPerDay), n); refundErr != nil {
+		slog.ErrorContext(ctx, "failed to refund the daily message quota", "account", userId, "error", refundErr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return &QuotaExceededError{Quota: "hourly message", RetryAfter: hourResult.RetryAfter}
+}
+
+// RefundMessages hands n messages reserved with ReserveMessages back to the account's daily and hourly send
+// quotas, when they were not sent after all.
+func (s *QuotaService) RefundMessages(ctx context.Context, userId int, n int) error {
+	quota, err := s.quotaRepository.FindAccountQuota(ctx, userId)
+
+	if err != nil {
+		return err
+	}
+
+	account := strconv.Itoa(userId)
+
+	if err := s.limiter.Refund(ctx, "msg:day:"+account, ratelimit.PerDay(quota.MessagesPerDay), n); err != nil {
+		return err
+	}
+
+	return s.limiter.Refund(ctx, "msg:hour:"+account, ratelimit.PerHour(quota.MessagesPerHour), n)
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/ratelimit"
+	"email-marketing-service/api/repository/memory"
+	"errors"
+	"testing"
+)
+
+func TestReserveMessagesRefundsDailyQuotaWhenHourlyQuotaIsExceeded(t *testing.T) {
+	ctx := context.Background()
+	service := NewQuotaService(memory.NewQuotaRepository(model.AccountQuota{
+		Plan:            "free",
+		MessagesPerHour: 2,
+		MessagesPerDay:  3,
+	}), ratelimit.NewMemoryLimiter())
+
+	if err := service.ReserveMessages(ctx, 1, 2); err != nil {
+		t.Fatalf("reserve within quota: %v", err)
+	}
+
+	// the hourly quota is used up, so the daily one must keep its last message
+	for i := 0; i < 3; i++ {
+		var exceeded *QuotaExceededError
+		if err := service.ReserveMessages(ctx, 1, 1); !errors.As(err, &exceeded) || exceeded.Quota != "hourly message" {
+			t.Fatalf("attempt %d: expected the hourly quota to be exceeded, got %v", i, err)
+		}
+	}
+
+	if err := service.ReserveMessages(ctx, 2, 2); err != nil {
+		t.Fatalf("quotas must be kept per account: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/utils"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// segmentSampleSize is how many matching contacts a preview shows.
+const segmentSampleSize = 10
+
+// SegmentService manages saved segments, which select contacts by a rule tree evaluated when used.
+type SegmentService struct {
+	segmentRepository repository.SegmentStore
+	contactRepository repository.ContactStore
+	transactor        database.Transactor
+}
+
+func NewSegmentService(segmentRepo repository.SegmentStore, contactRepo repository.ContactStore, transactor database.Transactor) *SegmentService {
+	return &SegmentService{
+		segmentRepository: segmentRepo,
+		contactRepository: contactRepo,
+		transactor:        transactor,
+	}
+}
+
+func (s *SegmentService) validate(d *model.Segment) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+
+	return segment.Validate(d.Rules)
+}
+
+func (s *SegmentService) CreateSegment(ctx context.Context, d *model.Segment) (*model.Segment, error) {
+	err := s.validate(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.UUID = uuid.New().String()
+
+	return s.segmentRepository.CreateSegment(ctx, d)
+}
+
+func (s *SegmentService) ListSegments(ctx context.Context, accountId int) ([]model.Segment, error) {
+	return s.segmentRepository.FindSegments(ctx, accountId)
+}
+
+func (s *SegmentService) GetSegment(ctx context.Context, d *model.Segment) (*model.Segment, error) {
+	found, err := s.segmentRepository.FindSegmentByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errSegmentNotFound)
+	}
+
+	return found, nil
+}
+
+func (s *SegmentService) UpdateSegment(ctx context.Context, d *model.Segment) (*model.Segment, error) {
+	err := s.validate(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.segmentRepository.UpdateSegment(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errSegmentNotFound)
+	}
+
+	return updated, nil
+}
+
+// DeleteSegment deletes a segment unless it is the audience of a campaign.
+func (s *SegmentService) DeleteSegment(ctx context.Context, d *model.Segment) error {
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		found, err := s.GetSegment(ctx, d)
+
+		if err != nil {
+			return err
+		}
+
+		inUse, err := s.segmentRepository.CheckIfSegmentInUse(ctx, found.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if inUse {
+			return errSegmentInUse
+		}
+
+		return s.segmentRepository.DeleteSegment(ctx, found.ID)
+	})
+}
+
+// Preview counts the contacts of accountId matching every one of rules and returns the newest of them.
+func (s *SegmentService) Preview(ctx context.Context, accountId int, rules ...segment.Rule) (*model.SegmentPreview, error) {
+	count, err := s.contactRepository.CountMatching(ctx, accountId, rules...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sample, err := s.contactRepository.FindMatching(ctx, segmentSampleSize, accountId, rules...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SegmentPreview{Count: count, Sample: sample}, nil
+}
+
+// PreviewSegment previews a saved segment.
+func (s *SegmentService) PreviewSegment(ctx context.Context, d *model.Segment) (*model.SegmentPreview, error) {
+	found, err := s.GetSegment(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Preview(ctx, found.AccountId, found.Rules)
+}
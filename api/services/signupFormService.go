@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxFormTextLength is the longest value accepted for a text field of a signup form.
+const maxFormTextLength = 500
+
+// SignupFormService manages hosted signup forms and handles their submissions.
+type SignupFormService struct {
+	signupFormRepository repository.SignupFormStore
+	listRepository       repository.ListStore
+	subscriptionService  *SubscriptionService
+}
+
+func NewSignupFormService(signupFormRepo repository.SignupFormStore, listRepo repository.ListStore, subscriptionSvc *SubscriptionService) *SignupFormService {
+	return &SignupFormService{
+		signupFormRepository: signupFormRepo,
+		listRepository:       listRepo,
+		subscriptionService:  subscriptionSvc,
+	}
+}
+
+// validate checks the form and looks up its list.
+func (s *SignupFormService) validate(ctx context.Context, d *model.SignupForm) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+
+	err = validateFormFields(d.Fields)
+
+	if err != nil {
+		return err
+	}
+
+	list, err := s.listRepository.FindListByUUID(ctx, &model.ContactList{UUID: d.ListUUID, AccountId: d.AccountId})
+
+	if err != nil {
+		return whenNoRows(err, errListNotFound)
+	}
+
+	d.ListId = list.ID
+
+	return nil
+}
+
+// validateFormFields checks that every field maps to a distinct attribute that can hold its value.
+func validateFormFields(fields []model.FormField) error {
+	seen := map[string]bool{}
+
+	for i, field := range fields {
+		path := fmt.Sprintf("fields[%d].attribute", i)
+
+		switch {
+		case field.Attribute == "email" || field.Attribute == "_hp":
+			return apperrors.NewValidation("invalid_form_fields", "email is part of every form", apperrors.FieldError{Field: path, Rule: "reserved", Message: field.Attribute + " can not be used as a field"})
+		case !segment.ValidAttributeKey(field.Attribute):
+			return apperrors.NewValidation("invalid_form_fields", "field attribute is not a valid attribute key", apperrors.FieldError{Field: path, Rule: "format", Message: "attribute must be letters, digits, '_', '.' or '-'"})
+		case seen[field.Attribute]:
+			return apperrors.NewValidation("invalid_form_fields", "fields must map to different attributes", apperrors.FieldError{Field: path, Rule: "unique", Message: "attribute is used by another field"})
+		case (field.Attribute == "firstname" || field.Attribute == "lastname") && field.Type != "text":
+			return apperrors.NewValidation("invalid_form_fields", "name fields must be text", apperrors.FieldError{Field: fmt.Sprintf("fields[%d].type", i), Rule: "oneof", Message: "type must be text"})
+		}
+
+		seen[field.Attribute] = true
+	}
+
+	return nil
+}
+
+func (s *SignupFormService) CreateSignupForm(ctx context.Context, d *model.SignupForm) (*model.SignupForm, error) {
+	err := s.validate(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.UUID = uuid.New().String()
+	d.PublicId = strings.ReplaceAll(uuid.New().String(), "-", "")
+
+	return s.signupFormRepository.CreateSignupForm(ctx, d)
+}
+
+func (s *SignupFormService) ListSignupForms(ctx context.Context, accountId int) ([]model.SignupForm, error) {
+	return s.signupFormRepository.FindSignupForms(ctx, accountId)
+}
+
+func (s *SignupFormService) GetSignupForm(ctx context.Context, d *model.SignupForm) (*model.SignupForm, error) {
+	form, err := s.signupFormRepository.FindSignupFormByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errSignupFormNotFound)
+	}
+
+	return form, nil
+}
+
+// GetPublicSignupForm returns the form served on the hosted page with the given public id.
+func (s *SignupFormService) GetPublicSignupForm(ctx context.Context, publicId string) (*model.SignupForm, error) {
+	form, err := s.signupFormRepository.FindSignupFormByPublicId(ctx, publicId)
+
+	if err != nil {
+		return nil, whenNoRows(err, errSignupFormNotFound)
+	}
+
+	return form, nil
+}
+
+func (s *SignupFormService) UpdateSignupForm(ctx context.Context, d *model.SignupForm) (*model.SignupForm, error) {
+	err := s.validate(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.signupFormRepository.UpdateSignupForm(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errSignupFormNotFound)
+	}
+
+	return s.GetSignupForm(ctx, d)
+}
+
+func (s *SignupFormService) DeleteSignupForm(ctx context.Context, d *model.SignupForm) error {
+	err := s.signupFormRepository.DeleteSignupForm(ctx, d)
+
+	return whenNoRows(err, errSignupFormNotFound)
+}
+
+// Submit signs the visitor up to the form's list and returns what to show them next. Submissions that
+// filled in the honeypot are dropped but answered like any other, so that bots do not learn to avoid it.
+func (s *SignupFormService) Submit(ctx context.Context, d *model.FormSubmission) (*model.FormSubmitted, error) {
+	form, err := s.GetPublicSignupForm(ctx, d.FormPublicId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Honeypot != "" {
+		return submitted(form), nil
+	}
+
+	err = utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	subscribe, err := formSubscription(form, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := s.listRepository.FindListByUUID(ctx, &model.ContactList{UUID: form.ListUUID, AccountId: form.AccountId})
+
+	if err != nil {
+		return nil, whenNoRows(err, errListNotFound)
+	}
+
+	err = s.subscriptionService.SubscribeToList(ctx, list, subscribe, form.DoubleOptIn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return submitted(form), nil
+}
+
+func submitted(form *model.SignupForm) *model.FormSubmitted {
+	message := form.SuccessMessage
+
+	if message == "" && form.DoubleOptIn {
+		message = "Thank you for signing up. Please check your inbox to confirm your subscription."
+	} else if message == "" {
+		message = "Thank you for signing up."
+	}
+
+	return &model.FormSubmitted{Message: message, RedirectURL: form.RedirectURL}
+}
+
+// formSubscription maps the submitted values to the contact's name and attributes. Values of fields the
+// form does not have are ignored.
+func formSubscription(form *model.SignupForm, d *model.FormSubmission) (*model.Subscribe, error) {
+	subscribe := &model.Subscribe{
+		Email:      d.Email,
+		Attributes: map[string]any{},
+		Source:     "form:" + form.UUID,
+		IP:         d.IP,
+		UserAgent:  d.UserAgent,
+	}
+
+	var invalid []apperrors.FieldError
+
+	for _, field := range form.Fields {
+		path := "fields." + field.Attribute
+
+		value, present, err := formValue(field, d.Fields[field.Attribute])
+
+		if err != nil {
+			invalid = append(invalid, apperrors.FieldError{Field: path, Rule: field.Type, Message: fmt.Sprintf("%s %s", field.Label, err)})
+			continue
+		}
+
+		if !present {
+			if field.Required {
+				invalid = append(invalid, apperrors.FieldError{Field: path, Rule: "required", Message: field.Label + " is required"})
+			}
+			continue
+		}
+
+		switch field.Attribute {
+		case "firstname":
+			subscribe.FirstName = value.(string)
+		case "lastname":
+			subscribe.LastName = value.(string)
+		default:
+			subscribe.Attributes[field.Attribute] = value
+		}
+	}
+
+	if len(invalid) > 0 {
+		return nil, apperrors.NewValidation("validation_failed", "one or more fields are invalid", invalid...)
+	}
+
+	return subscribe, nil
+}
+
+// formValue converts a submitted value to the type of field. Browsers post every value as text, JSON clients
+// may send numbers and booleans. present is false for empty values and unchecked checkboxes.
+func formValue(field model.FormField, value any) (any, bool, error) {
+	if value == nil || value == "" {
+		return nil, false, nil
+	}
+
+	switch field.Type {
+	case "number":
+		switch v := value.(type) {
+		case float64:
+			return v, true, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("must be a number")
+			}
+			return n, true, nil
+		}
+		return nil, false, fmt.Errorf("must be a number")
+	case "date":
+		v, ok := value.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("must be a date")
+		}
+		if _, err := time.Parse(time.DateOnly, v); err != nil {
+			return nil, false, fmt.Errorf("must be a date formatted as YYYY-MM-DD")
+		}
+		return v, true, nil
+	case "checkbox":
+		switch v := value.(type) {
+		case bool:
+			return v, v, nil
+		case string:
+			checked := v == "on" || v == "true" || v == "1"
+			return checked, checked, nil
+		}
+		return nil, false, fmt.Errorf("must be checked or unchecked")
+	default:
+		v, ok := value.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("must be text")
+		}
+		v = strings.TrimSpace(v)
+		if utf8.RuneCountInString(v) > maxFormTextLength {
+			return nil, false, fmt.Errorf("must be at most %d characters long", maxFormTextLength)
+		}
+		return v, v != "", nil
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/model"
+	"reflect"
+	"testing"
+)
+
+func TestFormSubscription(t *testing.T) {
+	form := &model.SignupForm{
+		UUID: "form-uuid",
+		Fields: []model.FormField{
+			{Attribute: "firstname", Label: "First name", Type: "text"},
+			{Attribute: "employees", Label: "Employees", Type: "number"},
+			{Attribute: "birthday", Label: "Birthday", Type: "date"},
+			{Attribute: "terms", Label: "Terms", Type: "checkbox", Required: true},
+		},
+	}
+
+	subscribe, err := formSubscription(form, &model.FormSubmission{
+		Email:  "ada@example.com",
+		Fields: map[string]any{"firstname": " Ada ", "employees": "12", "birthday": "1815-12-10", "terms": "on", "unknown": "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{"employees": 12.0, "birthday": "1815-12-10", "terms": true}
+	if subscribe.FirstName != "Ada" || !reflect.DeepEqual(subscribe.Attributes, want) || subscribe.Source != "form:form-uuid" {
+		t.Fatalf("formSubscription = %+v, want firstname Ada and attributes %v", subscribe, want)
+	}
+
+	_, err = formSubscription(form, &model.FormSubmission{
+		Email:  "ada@example.com",
+		Fields: map[string]any{"employees": "many", "birthday": "10/12/1815"},
+	})
+
+	appErr := apperrors.From(err)
+	if appErr.Kind != apperrors.Validation || len(appErr.Fields) != 3 {
+		t.Fatalf("formSubscription error = %+v, want errors for employees, birthday and terms", appErr)
+	}
+}
+
+func TestValidateFormFields(t *testing.T) {
+	cases := []struct {
+		fields []model.FormField
+		valid  bool
+	}{
+		{[]model.FormField{{Attribute: "company", Type: "text"}, {Attribute: "lastname", Type: "text"}}, true},
+		{[]model.FormField{{Attribute: "email", Type: "text"}}, false},
+		{[]model.FormField{{Attribute: "has space", Type: "text"}}, false},
+		{[]model.FormField{{Attribute: "plan", Type: "text"}, {Attribute: "plan", Type: "number"}}, false},
+		{[]model.FormField{{Attribute: "firstname", Type: "checkbox"}}, false},
+	}
+
+	for _, c := range cases {
+		if err := validateFormFields(c.fields); (err == nil) != c.valid {
+			t.Errorf("validateFormFields(%+v) = %v, want valid %v", c.fields, err, c.valid)
+		}
+	}
+}
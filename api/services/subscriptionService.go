@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// subscriptionTokenTTL is how long a confirmation link stays valid after it is sent.
+	subscriptionTokenTTL = 7 * 24 * time.Hour
+	// resendConfirmationAfter keeps repeated signups from flooding an address with confirmation mails.
+	resendConfirmationAfter = 10 * time.Minute
+)
+
+// SubscriptionService handles signups through public forms with double opt-in: a signup only creates a
+// pending contact and a consent record, and the contact joins the list once it confirms through the mailed link.
+type SubscriptionService struct {
+	contactRepository  repository.ContactStore
+	listRepository     repository.ListStore
+	consentRepository  repository.ConsentStore
+	activityRepository repository.ActivityStore
+	mailer             custom.Mailer
+	jwtManager         *utils.JWTManager
+	appURL             string
+	transactor         database.Transactor
+	listJoinedHooks    []ListJoinedHook
+}
+
+func NewSubscriptionService(contactRepo repository.ContactStore, listRepo repository.ListStore, consentRepo repository.ConsentStore, activityRepo repository.ActivityStore, mailer custom.Mailer, jwtManager *utils.JWTManager, appURL string, transactor database.Transactor) *SubscriptionService {
+	return &SubscriptionService{
+		contactRepository:  contactRepo,
+		listRepository:     listRepo,
+		consentRepository:  consentRepo,
+		activityRepository: activityRepo,
+		mailer:             mailer,
+		jwtManager:         jwtManager,
+		appURL:             appURL,
+		transactor:         transactor,
+	}
+}
+
+// OnListJoined registers a hook run whenever a confirmed signup adds a contact to a list. Hooks must be
+// registered before the service is used.
+func (s *SubscriptionService) OnListJoined(hook ListJoinedHook) {
+	s.listJoinedHooks = append(s.listJoinedHooks, hook)
+}
+
+// Subscribe records a signup to the list with the given public id and mails a confirmation link.
+func (s *SubscriptionService) Subscribe(ctx context.Context, d *model.Subscribe) error {
+	list, err := s.listRepository.FindListByPublicId(ctx, d.ListPublicId)
+
+	if err != nil {
+		return whenNoRows(err, errListNotFound)
+	}
+
+	return s.SubscribeToList(ctx, list, d, true)
+}
+
+// SubscribeToList records a signup to list. With doubleOptIn a confirmation link is mailed and the contact
+// only joins the list once it confirms; otherwise the signup counts as confirmed right away. It succeeds the
+// same way whether or not the address is known, so that a form does not reveal who is on a list. Contacts
+// that exist already keep their names and attributes, and contacts that unsubscribed always have to confirm.
+func (s *SubscriptionService) SubscribeToList(ctx context.Context, list *model.ContactList, d *model.Subscribe, doubleOptIn bool) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	d.Email = strings.ToLower(strings.TrimSpace(d.Email))
+
+	status := "subscribed"
+	if doubleOptIn {
+		status = "pending"
+	}
+
+	if d.Attributes == nil {
+		d.Attributes = map[string]any{}
+	}
+
+	// the consent is only kept if the mail could be sent, otherwise the throttle would hold back a retry
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		contact, err := s.contactRepository.FindContactByEmail(ctx, &model.Contact{AccountId: list.AccountId, Email: d.Email})
+
+		if errors.Is(err, sql.ErrNoRows) {
+			contact, err = s.contactRepository.CreateContact(ctx, &model.Contact{
+				UUID:       uuid.New().String(),
+				AccountId:  list.AccountId,
+				Email:      d.Email,
+				FirstName:  d.FirstName,
+				LastName:   d.LastName,
+				Attributes: d.Attributes,
+				Status:     status,
+			})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// anyone can type an address into a form, so only the contact can take back its unsubscription
+		if contact.Status == "unsubscribed" {
+			doubleOptIn = true
+		}
+
+		if contact.Status == "subscribed" {
+			member, err := s.listRepository.CheckIfContactInList(ctx, list.ID, contact.ID)
+
+			if err != nil || member {
+				return err
+			}
+		}
+
+		consent := &model.SubscriptionConsent{
+			UUID:      uuid.New().String(),
+			AccountId: list.AccountId,
+			ContactId: contact.ID,
+			ListId:    list.ID,
+			Source:    d.Source,
+			IP:        d.IP,
+			UserAgent: d.UserAgent,
+		}
+
+		if !doubleOptIn {
+			consent.ConfirmedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			consent.ConfirmedIP = sql.NullString{String: d.IP, Valid: true}
+
+			return s.confirm(ctx, consent, true)
+		}
+
+		latest, err := s.consentRepository.FindLatestConsent(ctx, contact.ID, list.ID)
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if latest != nil && !latest.ConfirmedAt.Valid && time.Since(latest.RequestedAt) < resendConfirmationAfter {
+			return nil
+		}
+
+		_, err = s.consentRepository.CreateConsent(ctx, consent)
+
+		if err != nil {
+			return err
+		}
+
+		token, err := s.jwtManager.SubscriptionTokenEncode(consent.UUID, time.Now().Add(subscriptionTokenTTL))
+
+		if err != nil {
+			return err
+		}
+
+		return s.mailer.SubscriptionConfirmationMail(ctx, d.Email, list.Name, s.confirmationLink(token))
+	})
+}
+
+// ConfirmSubscription confirms the signup the link was sent for: the contact is marked subscribed and added
+// to the list. Opening the link again has no further effect.
+func (s *SubscriptionService) ConfirmSubscription(ctx context.Context, d *model.ConfirmSubscription) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	consentUUID, err := s.jwtManager.SubscriptionTokenDecode(d.Token)
+
+	if err != nil {
+		return errInvalidSubscriptionToken.Wrap(err)
+	}
+
+	consent, err := s.consentRepository.FindConsentByUUID(ctx, consentUUID)
+
+	if err != nil {
+		return whenNoRows(err, errSubscriptionNotFound)
+	}
+
+	if consent.ConfirmedAt.Valid {
+		return nil
+	}
+
+	consent.ConfirmedAt = sql.NullTime{
+		Time:  time.Now(),
+		Valid: true,
+	}
+	consent.ConfirmedIP = sql.NullString{
+		String: d.IP,
+		Valid:  true,
+	}
+
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		return s.confirm(ctx, consent, false)
+	})
+}
+
+// confirm stores the confirmation of consent, creating the consent first if it is new, and subscribes the
+// contact to the list. It must be called inside a transaction.
+func (s *SubscriptionService) confirm(ctx context.Context, consent *model.SubscriptionConsent, create bool) error {
+	var err error
+
+	if create {
+		_, err = s.consentRepository.CreateConsent(ctx, consent)
+	} else {
+		err = s.consentRepository.ConfirmConsent(ctx, consent)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	changed, err := s.contactRepository.MarkSubscribed(ctx, consent.ContactId)
+
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		err = s.activityRepository.RecordActivity(ctx, &model.ContactActivity{
+			AccountId: consent.AccountId,
+			ContactId: consent.ContactId,
+			Type:      "subscribed",
+			Data:      map[string]any{"source": "signup", "form": consent.Source},
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	added, err := s.listRepository.AddContactById(ctx, consent.ListId, consent.ContactId)
+
+	if err != nil || !added {
+		return err
+	}
+
+	list := &model.ContactList{ID: consent.ListId, AccountId: consent.AccountId}
+
+	for _, hook := range s.listJoinedHooks {
+		if err := hook(ctx, list, []int{consent.ContactId}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) confirmationLink(token string) string {
+	return fmt.Sprintf("%s/confirm-subscription?token=%s", s.appURL, url.QueryEscape(token))
+}
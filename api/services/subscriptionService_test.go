@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/config"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/repository/memory"
+	"email-marketing-service/api/utils"
+	"testing"
+	"time"
+)
+
+// signupContacts holds one existing contact. Methods a signup does not need are left to the embedded nil
+// interface and panic if called.
+type signupContacts struct {
+	repository.ContactStore
+	contact    *model.Contact
+	subscribed bool
+}
+
+func (r *signupContacts) FindContactByEmail(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	return r.contact, nil
+}
+
+func (r *signupContacts) MarkSubscribed(ctx context.Context, contactId int) (bool, error) {
+	r.subscribed = true
+	return true, nil
+}
+
+type signupConsents struct {
+	repository.ConsentStore
+	created []model.SubscriptionConsent
+}
+
+func (r *signupConsents) CreateConsent(ctx context.Context, d *model.SubscriptionConsent) (*model.SubscriptionConsent, error) {
+	r.created = append(r.created, *d)
+	return d, nil
+}
+
+func (r *signupConsents) FindLatestConsent(ctx context.Context, contactId int, listId int) (*model.SubscriptionConsent, error) {
+	return nil, sql.ErrNoRows
+}
+
+func TestSingleOptInDoesNotResubscribeUnsubscribedContacts(t *testing.T) {
+	contacts := &signupContacts{contact: &model.Contact{ID: 3, AccountId: 7, Email: "ada@example.com", Status: "unsubscribed"}}
+	consents := &signupConsents{}
+	mailer := custom.NewMemoryMailer()
+	jwtManager := utils.NewJWTManager(config.AuthConfig{
+		JWTSecret: "test-secret-that-is-long-enough-for-hs256",
+		TokenTTL:  time.Hour,
+	})
+
+	service := NewSubscriptionService(contacts, nil, consents, &recordedActivities{}, mailer, jwtManager, "https://app.example.com", memory.NewTransactor())
+
+	list := &model.ContactList{ID: 5, AccountId: 7, Name: "News"}
+	err := service.SubscribeToList(context.Background(), list, &model.Subscribe{Email: "ada@example.com"}, false)
+	if err != nil {
+		t.Fatalf("SubscribeToList: %v", err)
+	}
+
+	if contacts.subscribed {
+		t.Fatal("a single opt-in signup resubscribed a contact that unsubscribed")
+	}
+
+	if len(consents.created) != 1 || consents.created[0].ConfirmedAt.Valid {
+		t.Fatalf("expected one unconfirmed consent, got %+v", consents.created)
+	}
+
+	if _, ok := mailer.Last("subscription_confirmation"); !ok {
+		t.Fatal("no confirmation mail was sent")
+	}
+}
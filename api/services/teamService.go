@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// invitationTTL is how long an invite link stays valid after it is sent.
+const invitationTTL = 7 * 24 * time.Hour
+
+// Roles a user can have in an account. Viewers can read everything, members can also make changes, and
+// admins can also manage the team. The owner is the user the account belongs to and can do everything.
+const (
+	RoleViewer = "viewer"
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleMember: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+type accountAccessKey struct{}
+
+// WithAccountAccess returns a copy of ctx carrying the account a request acts on.
+func WithAccountAccess(ctx context.Context, access *model.AccountAccess) context.Context {
+	return context.WithValue(ctx, accountAccessKey{}, access)
+}
+
+// AccountAccessFrom returns the account stored in ctx by WithAccountAccess.
+func AccountAccessFrom(ctx context.Context) (*model.AccountAccess, bool) {
+	access, ok := ctx.Value(accountAccessKey{}).(*model.AccountAccess)
+	return access, ok
+}
+
+// TeamService manages the members of an organization and what they may do in it. The account owner and
+// its admins invite members and revoke invitations.
+type TeamService struct {
+	invitationRepository repository.InvitationStore
+	teamMemberRepository repository.TeamMemberStore
+	userRepository       repository.UserStore
+	userService          *UserService
+	mailer               custom.Mailer
+	jwtManager           *utils.JWTManager
+	appURL               string
+	transactor           database.Transactor
+}
+
+func NewTeamService(invitationRepo repository.InvitationStore, teamMemberRepo repository.TeamMemberStore, userRepo repository.UserStore, userSvc *UserService, mailer custom.Mailer, jwtManager *utils.JWTManager, appURL string, transactor database.Transactor) *TeamService {
+	return &TeamService{
+		invitationRepository: invitationRepo,
+		teamMemberRepository: teamMemberRepo,
+		userRepository:       userRepo,
+		userService:          userSvc,
+		mailer:               mailer,
+		jwtManager:           jwtManager,
+		appURL:               appURL,
+		transactor:           transactor,
+	}
+}
+
+// Authorize returns the access of a user to an account if the user owns it or is a member of it with at
+// least the given role.
+func (s *TeamService) Authorize(ctx context.Context, userId int, accountId int, role string) (*model.AccountAccess, error) {
+	access := &model.AccountAccess{
+		AccountId: accountId,
+		UserId:    userId,
+		Role:      RoleOwner,
+	}
+
+	if userId != accountId {
+		member, err := s.teamMemberRepository.FindTeamMember(ctx, accountId, userId)
+
+		if err != nil {
+			return nil, whenNoRows(err, errNotTeamMember)
+		}
+
+		access.Role = member.Role
+	}
+
+	if roleRank[access.Role] < roleRank[role] {
+		return nil, errRoleForbidden
+	}
+
+	return access, nil
+}
+
+func (s *TeamService) InviteMember(ctx context.Context, d *model.Invitation) (*model.Invitation, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.Email = strings.ToLower(strings.TrimSpace(d.Email))
+
+	inviter, err := s.userRepository.FindUserById(ctx, &model.User{ID: d.InvitedBy})
+
+	if err != nil {
+		return nil, whenNoRows(err, errUserNotFound)
+	}
+
+	if strings.EqualFold(inviter.Email, d.Email) {
+		return nil, errInviteSelf
+	}
+
+	if d.InvitedBy != d.AccountId {
+		owner, err := s.userRepository.FindUserById(ctx, &model.User{ID: d.AccountId})
+
+		if err != nil {
+			return nil, whenNoRows(err, errUserNotFound)
+		}
+
+		if strings.EqualFold(owner.Email, d.Email) {
+			return nil, errInviteOwner
+		}
+	}
+
+	pending, err := s.invitationRepository.CheckIfPendingInvitationExists(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pending {
+		return nil, errInvitationPending
+	}
+
+	d.UUID = uuid.New().String()
+	d.ExpiresAt = time.Now().Add(invitationTTL)
+
+	token, err := s.jwtManager.InviteTokenEncode(d.UUID, d.ExpiresAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the invitation is only kept if the mail could be sent, otherwise it would block inviting the address again
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := s.invitationRepository.CreateInvitation(ctx, d)
+
+		if err != nil {
+			return err
+		}
+
+		return s.mailer.InvitationMail(ctx, d.Email, inviter.UserName, d.Role, s.invitationLink(token))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (s *TeamService) ListPendingInvitations(ctx context.Context, accountId int) ([]model.Invitation, error) {
+	return s.invitationRepository.FindPendingInvitations(ctx, accountId)
+}
+
+func (s *TeamService) RevokeInvitation(ctx context.Context, d *model.Invitation) error {
+	d.RevokedAt = sql.NullTime{
+		Time:  time.Now(),
+		Valid: true,
+	}
+
+	err := s.invitationRepository.RevokeInvitation(ctx, d)
+
+	if errors.Is(err, repository.ErrNoPendingInvitation) {
+		return errInvitationNotFound
+	}
+
+	return err
+}
+
+// AcceptInvitation links the invited email to the inviting account. The email has to be given along with
+// the token, so that a forwarded link is not enough to join. If no user exists for the email yet, one is
+// created from the supplied details and marked as verified, since the invite link proves the address.
+func (s *TeamService) AcceptInvitation(ctx context.Context, d *model.AcceptInvitation) (*model.TeamMember, error) {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	invitationUUID, err := s.jwtManager.InviteTokenDecode(d.Token)
+
+	if err != nil {
+		return nil, errInvalidInviteToken.Wrap(err)
+	}
+
+	invitation, err := s.invitationRepository.FindInvitationByUUID(ctx, &model.Invitation{UUID: invitationUUID})
+
+	if err != nil {
+		return nil, whenNoRows(err, errInvitationNotFound)
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(d.Email), invitation.Email) {
+		return nil, errInvitationEmail
+	}
+
+	if invitation.RevokedAt.Valid {
+		return nil, errInvitationRevoked
+	}
+
+	if invitation.AcceptedAt.Valid {
+		return nil, errInvitationAccepted
+	}
+
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, errInvitationExpired
+	}
+
+	var member *model.TeamMember
+
+	// creating the user, accepting the invitation and adding the membership succeed or fail together
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		userExists, err := s.userRepository.CheckIfEmailAlreadyExists(ctx, &model.User{Email: invitation.Email})
+
+		if err != nil {
+			return err
+		}
+
+		var userId int
+
+		if userExists {
+			user, err := s.userRepository.FindUserByEmail(ctx, &model.User{Email: invitation.Email})
+
+			if err != nil {
+				return err
+			}
+
+			userId = user.ID
+		} else {
+			user, err := s.userService.CreateVerifiedUser(ctx, &model.User{
+				FirstName:  d.FirstName,
+				MiddleName: d.MiddleName,
+				LastName:   d.LastName,
+				UserName:   d.UserName,
+				Email:      invitation.Email,
+				Password:   d.Password,
+			})
+
+			if err != nil {
+				return err
+			}
+
+			userId = user.ID
+		}
+
+		invitation.AcceptedAt = sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		}
+
+		err = s.invitationRepository.AcceptInvitation(ctx, invitation)
+
+		if errors.Is(err, repository.ErrInvitationNotPending) {
+			return errInvitationNotPending
+		}
+
+		if err != nil {
+			return err
+		}
+
+		member, err = s.teamMemberRepository.AddTeamMember(ctx, &model.TeamMember{
+			AccountId: invitation.AccountId,
+			UserId:    userId,
+			Role:      invitation.Role,
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (s *TeamService) invitationLink(token string) string {
+	return fmt.Sprintf("%s/accept-invite?token=%s", s.appURL, url.QueryEscape(token))
+}
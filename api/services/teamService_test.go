@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/config"
+	"email-marketing-service/api/custom"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository/memory"
+	"email-marketing-service/api/utils"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type teamServiceFixture struct {
+	service     *TeamService
+	users       *UserService
+	invitations *memory.InvitationRepository
+	members     *memory.TeamMemberRepository
+	mailer      *custom.MemoryMailer
+	jwtManager  *utils.JWTManager
+	ctx         context.Context
+	owner       *model.User
+}
+
+func newTeamServiceFixture(t *testing.T) *teamServiceFixture {
+	t.Helper()
+
+	users := memory.NewUserRepository()
+	otps := memory.NewOTPRepository()
+	invitations := memory.NewInvitationRepository()
+	members := memory.NewTeamMemberRepository()
+	mailer := custom.NewMemoryMailer()
+	jwtManager := utils.NewJWTManager(config.AuthConfig{
+		JWTSecret: "test-secret-that-is-long-enough-for-hs256",
+		TokenTTL:  time.Hour,
+	})
+	transactor := memory.NewTransactor(users, otps, invitations, members)
+	userService := NewUserService(users, NewOTPService(otps), mailer, jwtManager, transactor)
+
+	f := &teamServiceFixture{
+		service:     NewTeamService(invitations, members, users, userService, mailer, jwtManager, "https://app.example.com", transactor),
+		users:       userService,
+		invitations: invitations,
+		members:     members,
+		mailer:      mailer,
+		jwtManager:  jwtManager,
+		ctx:         context.Background(),
+	}
+	f.owner = f.createUser(t, "owner@example.com")
+
+	return f
+}
+
+func (f *teamServiceFixture) createUser(t *testing.T, email string) *model.User {
+	t.Helper()
+
+	user, err := f.users.CreateVerifiedUser(f.ctx, &model.User{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		UserName:  "ada",
+		Email:     email,
+		Password:  []byte("s3cret-password"),
+	})
+	if err != nil {
+		t.Fatalf("CreateVerifiedUser: %v", err)
+	}
+
+	return user
+}
+
+// invite sends an invitation from the owner and returns the token of its link.
+func (f *teamServiceFixture) invite(t *testing.T, email string, role string) string {
+	t.Helper()
+
+	_, err := f.service.InviteMember(f.ctx, &model.Invitation{
+		AccountId: f.owner.ID,
+		InvitedBy: f.owner.ID,
+		Email:     email,
+		Role:      role,
+	})
+	if err != nil {
+		t.Fatalf("InviteMember: %v", err)
+	}
+
+	mail, ok := f.mailer.Last("invitation")
+	if !ok || mail.Email != email {
+		t.Fatalf("unexpected invitation mail: %+v", mail)
+	}
+
+	link, err := url.Parse(mail.Link)
+	if err != nil {
+		t.Fatalf("invalid invitation link %q: %v", mail.Link, err)
+	}
+
+	return link.Query().Get("token")
+}
+
+func (f *teamServiceFixture) accept(token string, email string) (*model.TeamMember, error) {
+	return f.service.AcceptInvitation(f.ctx, &model.AcceptInvitation{
+		Token:     token,
+		Email:     email,
+		FirstName: "Grace",
+		LastName:  "Hopper",
+		UserName:  "grace",
+		Password:  []byte("another-password"),
+	})
+}
+
+func TestInviteMember(t *testing.T) {
+	f := newTeamServiceFixture(t)
+
+	f.invite(t, "grace@example.com", RoleMember)
+
+	pending, err := f.service.ListPendingInvitations(f.ctx, f.owner.ID)
+	if err != nil || len(pending) != 1 || pending[0].Email != "grace@example.com" {
+		t.Fatalf("expected one pending invitation, got %+v, %v", pending, err)
+	}
+
+	_, err = f.service.InviteMember(f.ctx, &model.Invitation{AccountId: f.owner.ID, InvitedBy: f.owner.ID, Email: "Grace@example.com", Role: RoleViewer})
+	if !apperrors.Is(err, "invitation_already_pending") {
+		t.Fatalf("expected an invitation_already_pending error, got %v", err)
+	}
+
+	_, err = f.service.InviteMember(f.ctx, &model.Invitation{AccountId: f.owner.ID, InvitedBy: f.owner.ID, Email: "owner@example.com", Role: RoleAdmin})
+	if !apperrors.Is(err, "cannot_invite_self") {
+		t.Fatalf("expected a cannot_invite_self error, got %v", err)
+	}
+}
+
+func TestAdminsCanNotInviteTheOwner(t *testing.T) {
+	f := newTeamServiceFixture(t)
+
+	admin, err := f.accept(f.invite(t, "grace@example.com", RoleAdmin), "grace@example.com")
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+
+	_, err = f.service.InviteMember(f.ctx, &model.Invitation{AccountId: f.owner.ID, InvitedBy: admin.UserId, Email: "owner@example.com", Role: RoleViewer})
+	if !apperrors.Is(err, "cannot_invite_owner") {
+		t.Fatalf("expected a cannot_invite_owner error, got %v", err)
+	}
+}
+
+func TestAcceptInvitationCreatesAVerifiedMember(t *testing.T) {
+	f := newTeamServiceFixture(t)
+
+	token := f.invite(t, "grace@example.com", RoleViewer)
+
+	member, err := f.accept(token, "grace@example.com")
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+
+	if member.AccountId != f.owner.ID || member.Role != RoleViewer {
+		t.Fatalf("unexpected membership: %+v", member)
+	}
+
+	access, err := f.service.Authorize(f.ctx, member.UserId, f.owner.ID, RoleViewer)
+	if err != nil || access.Role != RoleViewer {
+		t.Fatalf("expected viewer access, got %+v, %v", access, err)
+	}
+
+	_, err = f.accept(token, "grace@example.com")
+	if !apperrors.Is(err, "invitation_already_accepted") {
+		t.Fatalf("expected an invitation_already_accepted error, got %v", err)
+	}
+}
+
+func TestAcceptInvitationLinksExistingUsers(t *testing.T) {
+	f := newTeamServiceFixture(t)
+
+	grace := f.createUser(t, "grace@example.com")
+
+	member, err := f.accept(f.invite(t, "grace@example.com", RoleMember), "grace@example.com")
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+
+	if member.UserId != grace.ID {
+		t.Fatalf("member user = %d, want the existing user %d", member.UserId, grace.ID)
+	}
+}
+
+func TestAcceptInvitationRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, f *teamServiceFixture) string
+		email string
+		code  string
+	}{
+		{
+			name: "wrong email",
+			setup: func(t *testing.T, f *teamServiceFixture) string {
+				return f.invite(t, "grace@example.com", RoleMember)
+			},
+			email: "mallory@example.com",
+			code:  "invitation_email_mismatch",
+		},
+		{
+			name: "revoked",
+			setup: func(t *testing.T, f *teamServiceFixture) string {
+				token := f.invite(t, "grace@example.com", RoleMember)
+				pending, _ := f.service.ListPendingInvitations(f.ctx, f.owner.ID)
+				if err := f.service.RevokeInvitation(f.ctx, &model.Invitation{UUID: pending[0].UUID, AccountId: f.owner.ID}); err != nil {
+					t.Fatalf("RevokeInvitation: %v", err)
+				}
+				return token
+			},
+			email: "grace@example.com",
+			code:  "invitation_revoked",
+		},
+		{
+			name: "expired",
+			setup: func(t *testing.T, f *teamServiceFixture) string {
+				_, err := f.invitations.CreateInvitation(f.ctx, &model.Invitation{
+					UUID:      "expired-invitation",
+					AccountId: f.owner.ID,
+					Email:     "grace@example.com",
+					Role:      RoleMember,
+					ExpiresAt: time.Now().Add(-time.Minute),
+				})
+				if err != nil {
+					t.Fatalf("CreateInvitation: %v", err)
+				}
+				token, err := f.jwtManager.InviteTokenEncode("expired-invitation", time.Now().Add(time.Hour))
+				if err != nil {
+					t.Fatalf("InviteTokenEncode: %v", err)
+				}
+				return token
+			},
+			email: "grace@example.com",
+			code:  "invitation_expired",
+		},
+		{
+			name: "invalid token",
+			setup: func(t *testing.T, f *teamServiceFixture) string {
+				return "not-a-token"
+			},
+			email: "grace@example.com",
+			code:  "invalid_invitation_token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTeamServiceFixture(t)
+
+			_, err := f.accept(tt.setup(t, f), tt.email)
+			if !apperrors.Is(err, tt.code) {
+				t.Fatalf("expected a %s error, got %v", tt.code, err)
+			}
+
+			if _, err := f.members.FindTeamMember(f.ctx, f.owner.ID, 2); err == nil {
+				t.Fatal("a rejected invitation added a member")
+			}
+		})
+	}
+}
+
+func TestRevokeInvitation(t *testing.T) {
+	f := newTeamServiceFixture(t)
+
+	f.invite(t, "grace@example.com", RoleMember)
+	pending, _ := f.service.ListPendingInvitations(f.ctx, f.owner.ID)
+	uuid := pending[0].UUID
+
+	err := f.service.RevokeInvitation(f.ctx, &model.Invitation{UUID: uuid, AccountId: f.owner.ID + 1})
+	if !apperrors.Is(err, "invitation_not_found") {
+		t.Fatalf("expected another account's revoke to fail with invitation_not_found, got %v", err)
+	}
+
+	if err := f.service.RevokeInvitation(f.ctx, &model.Invitation{UUID: uuid, AccountId: f.owner.ID}); err != nil {
+		t.Fatalf("RevokeInvitation: %v", err)
+	}
+
+	pending, _ = f.service.ListPendingInvitations(f.ctx, f.owner.ID)
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending invitations, got %+v", pending)
+	}
+
+	err = f.service.RevokeInvitation(f.ctx, &model.Invitation{UUID: uuid, AccountId: f.owner.ID})
+	if !apperrors.Is(err, "invitation_not_found") {
+		t.Fatalf("expected revoking twice to fail with invitation_not_found, got %v", err)
+	}
+
+	// the address can be invited again once the invitation is revoked
+	f.invite(t, "grace@example.com", RoleViewer)
+}
+
+func TestAuthorize(t *testing.T) {
+	f := newTeamServiceFixture(t)
+
+	viewer, err := f.accept(f.invite(t, "grace@example.com", RoleViewer), "grace@example.com")
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	outsider := f.createUser(t, "mallory@example.com")
+
+	tests := []struct {
+		name   string
+		userId int
+		role   string
+		want   string
+		code   string
+	}{
+		{name: "owner", userId: f.owner.ID, role: RoleAdmin, want: RoleOwner},
+		{name: "viewer reads", userId: viewer.UserId, role: RoleViewer, want: RoleViewer},
+		{name: "viewer writes", userId: viewer.UserId, role: RoleMember, code: "insufficient_role"},
+		{name: "viewer manages the team", userId: viewer.UserId, role: RoleAdmin, code: "insufficient_role"},
+		{name: "not a member", userId: outsider.ID, role: RoleViewer, code: "not_team_member"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, err := f.service.Authorize(f.ctx, tt.userId, f.owner.ID, tt.role)
+
+			if tt.code != "" {
+				if !apperrors.Is(err, tt.code) {
+					t.Fatalf("expected a %s error, got %v", tt.code, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+
+			if access.AccountId != f.owner.ID || access.UserId != tt.userId || access.Role != tt.want {
+				t.Fatalf("unexpected access: %+v", access)
+			}
+		})
+	}
+}
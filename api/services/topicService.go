@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/utils"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// TopicService manages the topics campaigns are sent under and contacts choose from in the preference center.
+type TopicService struct {
+	topicRepository repository.TopicStore
+	transactor      database.Transactor
+}
+
+func NewTopicService(topicRepo repository.TopicStore, transactor database.Transactor) *TopicService {
+	return &TopicService{
+		topicRepository: topicRepo,
+		transactor:      transactor,
+	}
+}
+
+func (s *TopicService) validate(ctx context.Context, d *model.Topic) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+	d.Description = strings.TrimSpace(d.Description)
+
+	taken, err := s.topicRepository.CheckIfTopicNameTaken(ctx, d)
+
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return errTopicExists
+	}
+
+	return nil
+}
+
+func (s *TopicService) CreateTopic(ctx context.Context, d *model.Topic) (*model.Topic, error) {
+	d.UUID = uuid.New().String()
+
+	err := s.validate(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.topicRepository.CreateTopic(ctx, d)
+}
+
+func (s *TopicService) ListTopics(ctx context.Context, accountId int) ([]model.Topic, error) {
+	return s.topicRepository.FindTopics(ctx, accountId)
+}
+
+func (s *TopicService) GetTopic(ctx context.Context, d *model.Topic) (*model.Topic, error) {
+	topic, err := s.topicRepository.FindTopicByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errTopicNotFound)
+	}
+
+	return topic, nil
+}
+
+func (s *TopicService) UpdateTopic(ctx context.Context, d *model.Topic) (*model.Topic, error) {
+	err := s.validate(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.topicRepository.UpdateTopic(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errTopicNotFound)
+	}
+
+	return updated, nil
+}
+
+// DeleteTopic deletes a topic and the opt-outs of it, unless a campaign is sent under it.
+func (s *TopicService) DeleteTopic(ctx context.Context, d *model.Topic) error {
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		topic, err := s.GetTopic(ctx, d)
+
+		if err != nil {
+			return err
+		}
+
+		inUse, err := s.topicRepository.CheckIfTopicInUse(ctx, topic.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if inUse {
+			return errTopicInUse
+		}
+
+		return s.topicRepository.DeleteTopic(ctx, topic.ID)
+	})
+}
@@ -0,0 +1,475 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/database"
+	"email-marketing-service/api/metrics"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/segment"
+	"email-marketing-service/api/utils"
+	"email-marketing-service/api/workflow"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// workflowRunsPerBatch bounds how many runs one call of ProcessWorkflows advances.
+	workflowRunsPerBatch = 100
+	// maxStepsPerAdvance stops a run that goes through many steps without waiting, e.g. a branch looping
+	// back; it goes on after workflowLoopDelay.
+	maxStepsPerAdvance = 20
+	workflowLoopDelay  = time.Minute
+	// workflowRetryDelay is how long a run whose step failed waits before it is tried again.
+	workflowRetryDelay = 5 * time.Minute
+	// workflowRunsShown is how many of the newest runs of a workflow are listed.
+	workflowRunsShown = 100
+)
+
+// WorkflowService manages workflows and moves their runs along. Runs are stored, so they survive restarts,
+// and each is advanced by one instance at a time.
+type WorkflowService struct {
+	workflowRepository  repository.WorkflowStore
+	contactRepository   repository.ContactStore
+	listRepository      repository.ListStore
+	topicRepository     repository.TopicStore
+	mailQueueRepository repository.MailQueueStore
+	contactService      *ContactService
+	preferenceService   *PreferenceService
+	quotaService        *QuotaService
+	transactor          database.Transactor
+}
+
+func NewWorkflowService(workflowRepo repository.WorkflowStore, contactRepo repository.ContactStore, listRepo repository.ListStore, topicRepo repository.TopicStore, mailQueueRepo repository.MailQueueStore, contactSvc *ContactService, preferenceSvc *PreferenceService, quotaSvc *QuotaService, transactor database.Transactor) *WorkflowService {
+	return &WorkflowService{
+		workflowRepository:  workflowRepo,
+		contactRepository:   contactRepo,
+		listRepository:      listRepo,
+		topicRepository:     topicRepo,
+		mailQueueRepository: mailQueueRepo,
+		contactService:      contactSvc,
+		preferenceService:   preferenceSvc,
+		quotaService:        quotaSvc,
+		transactor:          transactor,
+	}
+}
+
+func (s *WorkflowService) validate(ctx context.Context, d *model.Workflow) error {
+	err := utils.ValidateData(d)
+
+	if err != nil {
+		return err
+	}
+
+	d.Name = strings.TrimSpace(d.Name)
+
+	if d.Status == "" {
+		d.Status = "draft"
+	}
+
+	workflow.AssignIDs(d.Steps)
+
+	if err := workflow.Validate(d.Trigger, d.Steps); err != nil {
+		return err
+	}
+
+	if d.Trigger.Type == "list_joined" {
+		_, err := s.listRepository.FindListByUUID(ctx, &model.ContactList{UUID: d.Trigger.ListUUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return whenNoRows(err, errListNotFound)
+		}
+	}
+
+	for _, step := range d.Steps {
+		if step.TopicUUID == "" {
+			continue
+		}
+
+		_, err := s.topicRepository.FindTopicByUUID(ctx, &model.Topic{UUID: step.TopicUUID, AccountId: d.AccountId})
+
+		if err != nil {
+			return whenNoRows(err, errTopicNotFound)
+		}
+	}
+
+	return nil
+}
+
+func (s *WorkflowService) CreateWorkflow(ctx context.Context, d *model.Workflow) (*model.Workflow, error) {
+	err := s.validate(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.UUID = uuid.New().String()
+
+	return s.workflowRepository.CreateWorkflow(ctx, d)
+}
+
+func (s *WorkflowService) ListWorkflows(ctx context.Context, accountId int) ([]model.Workflow, error) {
+	return s.workflowRepository.FindWorkflows(ctx, accountId)
+}
+
+func (s *WorkflowService) GetWorkflow(ctx context.Context, d *model.Workflow) (*model.Workflow, error) {
+	found, err := s.workflowRepository.FindWorkflowByUUID(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errWorkflowNotFound)
+	}
+
+	return found, nil
+}
+
+// UpdateWorkflow replaces a workflow. Runs keep their position by step id, so steps that are kept should
+// keep their ids; a run at a step that was removed fails.
+func (s *WorkflowService) UpdateWorkflow(ctx context.Context, d *model.Workflow) (*model.Workflow, error) {
+	err := s.validate(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.workflowRepository.UpdateWorkflow(ctx, d)
+
+	if err != nil {
+		return nil, whenNoRows(err, errWorkflowNotFound)
+	}
+
+	return updated, nil
+}
+
+func (s *WorkflowService) DeleteWorkflow(ctx context.Context, d *model.Workflow) error {
+	err := s.workflowRepository.DeleteWorkflow(ctx, d)
+
+	return whenNoRows(err, errWorkflowNotFound)
+}
+
+// ListRuns returns the newest runs of a workflow.
+func (s *WorkflowService) ListRuns(ctx context.Context, d *model.Workflow) ([]model.WorkflowRun, error) {
+	found, err := s.GetWorkflow(ctx, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.workflowRepository.FindRuns(ctx, found.ID, workflowRunsShown)
+}
+
+// ListJoined starts the workflows triggered by contacts joining a list. It is meant to be registered as a
+// list joined hook, so that the runs start in the transaction adding the contacts.
+func (s *WorkflowService) ListJoined(ctx context.Context, list *model.ContactList, contactIds []int) error {
+	_, err := s.workflowRepository.EnrollOnListJoined(ctx, list.ID, contactIds)
+
+	return err
+}
+
+// TagAdded starts the workflows triggered by tags added to contacts. It is meant to be registered as a tag
+// added hook.
+func (s *WorkflowService) TagAdded(ctx context.Context, added []model.TaggedContact) error {
+	byTag := map[int][]int{}
+	tags := map[int]model.TaggedContact{}
+
+	for _, tagged := range added {
+		byTag[tagged.TagId] = append(byTag[tagged.TagId], tagged.ContactId)
+		tags[tagged.TagId] = tagged
+	}
+
+	for tagId, contactIds := range byTag {
+		tag := tags[tagId]
+
+		if _, err := s.workflowRepository.EnrollOnTagAdded(ctx, tag.AccountId, tag.Tag, contactIds); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EventReceived starts the workflows of the account triggered by a custom event for the given contacts.
+func (s *WorkflowService) EventReceived(ctx context.Context, accountId int, event string, contactIds []int) error {
+	_, err := s.workflowRepository.EnrollOnEvent(ctx, accountId, event, contactIds)
+
+	return err
+}
+
+// StartAnniversaries starts the date_anniversary workflows of the contacts whose date is today and returns
+// how many runs it started. Runs start once a year, so it can be called as often as needed.
+func (s *WorkflowService) StartAnniversaries(ctx context.Context) (int, error) {
+	return s.workflowRepository.EnrollAnniversaries(ctx)
+}
+
+// ProcessWorkflows advances the runs that are due and returns how many it advanced. Each run is advanced in
+// a transaction of its own that locks it, so every instance can run the worker.
+func (s *WorkflowService) ProcessWorkflows(ctx context.Context) (int, error) {
+	for processed := 0; processed < workflowRunsPerBatch; processed++ {
+		advanced, err := s.advanceDueRun(ctx)
+
+		if err != nil || !advanced {
+			return processed, err
+		}
+	}
+
+	return workflowRunsPerBatch, nil
+}
+
+// advanceDueRun advances the run that is due the longest and reports whether there was one. The mails of the
+// send steps are queued in the transaction that stores the run, so a step is mailed once, and the mail queue
+// sends them.
+func (s *WorkflowService) advanceDueRun(ctx context.Context) (bool, error) {
+	var claimed model.WorkflowRun
+	var queued int
+	var stepErr error
+
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		run, err := s.workflowRepository.ClaimDueRun(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		claimed = *run
+
+		// the steps run in a savepoint so that a failed step, and the mails it queued, are undone while its
+		// retry is still recorded under the lock of the claim; otherwise another instance could claim the
+		// run in between and queue its mails again
+		stepErr = database.WithinSavepoint(ctx, "workflow_step", func(ctx context.Context) error {
+			queued, err = s.advance(ctx, run)
+
+			if err != nil {
+				return err
+			}
+
+			return s.workflowRepository.UpdateRun(ctx, run)
+		})
+
+		if stepErr != nil {
+			queued = 0
+
+			return s.workflowRepository.RetryRun(ctx, &claimed, time.Now().Add(workflowRetryDelay), stepErr.Error())
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, sql.ErrNoRows) && claimed.ID == 0 {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if stepErr != nil {
+		slog.ErrorContext(ctx, "workflow step failed", "run", claimed.ID, "step", claimed.StepId, "error", stepErr)
+
+		return true, nil
+	}
+
+	if queued > 0 {
+		metrics.MailQueued(s.tier(ctx, claimed.AccountId), queued)
+	}
+
+	return true, nil
+}
+
+// tier returns the plan of an account for the metrics, or none when it cannot be found.
+func (s *WorkflowService) tier(ctx context.Context, accountId int) string {
+	quota, err := s.quotaService.AccountQuota(ctx, accountId)
+
+	if err != nil {
+		slog.WarnContext(ctx, "failed to find the plan of an account", "account", accountId, "error", err)
+		return metrics.TierNone
+	}
+
+	return quota.Plan
+}
+
+// advance runs the steps of a run from its current step until it has to wait or ends, updates its position
+// and status, and returns how many mails it queued. It must be called inside a transaction.
+func (s *WorkflowService) advance(ctx context.Context, run *model.WorkflowRun) (int, error) {
+	flow, err := s.workflowRepository.FindWorkflowById(ctx, run.WorkflowId)
+
+	if err != nil {
+		return 0, err
+	}
+
+	contact, err := s.contactRepository.FindContactByUUID(ctx, &model.Contact{UUID: run.ContactUUID, AccountId: run.AccountId})
+
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	now := time.Now()
+	run.Error = ""
+
+	for i := 0; i < maxStepsPerAdvance; i++ {
+		if run.StepId == "" {
+			run.Status = "completed"
+			return queued, nil
+		}
+
+		// contacts that unsubscribed leave their workflows
+		if contact.Status != "subscribed" {
+			run.Status = "exited"
+			return queued, nil
+		}
+
+		step, ok := workflow.Find(flow.Steps, run.StepId)
+
+		if !ok {
+			run.Status = "failed"
+			run.Error = fmt.Sprintf("step %s no longer exists", run.StepId)
+			return queued, nil
+		}
+
+		next := workflow.Next(flow.Steps, step.ID)
+
+		switch step.Type {
+		case "wait":
+			run.StepId = next
+			run.NextRunAt = now.Add(step.Delay())
+			return queued, nil
+		case "wait_until":
+			run.StepId = next
+
+			if until := step.WaitUntil(now); until.After(now) {
+				run.NextRunAt = until
+				return queued, nil
+			}
+		case "send":
+			// snoozed contacts get the mail once their pause ends
+			if contact.SnoozedUntil != nil && contact.SnoozedUntil.After(now) {
+				run.NextRunAt = *contact.SnoozedUntil
+				return queued, nil
+			}
+
+			// contacts that opted out of the topic of the mail go on without it
+			if step.TopicUUID != "" {
+				optedOut, err := s.topicRepository.CheckIfOptedOut(ctx, contact.ID, step.TopicUUID)
+
+				if err != nil {
+					return 0, err
+				}
+
+				if optedOut {
+					run.StepId = next
+					continue
+				}
+			}
+
+			mail, err := s.render(ctx, contact, step)
+
+			if err != nil {
+				return 0, err
+			}
+
+			mail.Data = map[string]any{"workflow_uuid": flow.UUID, "step_id": step.ID, "subject": mail.Subject}
+
+			if err := s.mailQueueRepository.EnqueueMail(ctx, mail); err != nil {
+				return 0, err
+			}
+
+			queued++
+			run.StepId = next
+		case "branch":
+			matches, err := s.contactRepository.CountMatching(ctx, run.AccountId,
+				segment.Rule{Type: "field", Field: "email", Operator: "eq", Value: contact.Email}, *step.Condition)
+
+			if err != nil {
+				return 0, err
+			}
+
+			target := step.Else
+			if matches > 0 {
+				target = step.Then
+			}
+
+			if target == "" {
+				target = next
+			}
+
+			run.StepId = target
+		case "add_tag":
+			_, err := s.contactService.TagContacts(ctx, run.AccountId, &model.ContactTags{Contacts: []string{contact.UUID}, Tags: []string{step.Tag}})
+
+			if err != nil {
+				return 0, err
+			}
+
+			run.StepId = next
+		case "remove_tag":
+			_, err := s.contactService.UntagContacts(ctx, run.AccountId, &model.ContactTags{Contacts: []string{contact.UUID}, Tags: []string{step.Tag}})
+
+			if err != nil {
+				return 0, err
+			}
+
+			run.StepId = next
+		case "update_attribute":
+			contact, err = s.contactService.UpdateContact(ctx, &model.UpdateContact{
+				UUID:       contact.UUID,
+				AccountId:  contact.AccountId,
+				Attributes: map[string]any{step.Attribute: step.Value},
+				Source:     "workflow",
+			})
+
+			if err != nil {
+				return 0, err
+			}
+
+			run.StepId = next
+		case "exit":
+			run.Status = "exited"
+			return queued, nil
+		}
+	}
+
+	run.NextRunAt = now.Add(workflowLoopDelay)
+
+	return queued, nil
+}
+
+// render fills in the merge tags of a send step for a contact. Values put in the body are HTML escaped.
+func (s *WorkflowService) render(ctx context.Context, contact *model.Contact, step workflow.Step) (*model.QueuedMail, error) {
+	link, err := s.preferenceService.PreferenceLink(ctx, contact)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{
+		"email":           contact.Email,
+		"firstname":       contact.FirstName,
+		"lastname":        contact.LastName,
+		"preferences_url": link.Link,
+	}
+
+	for key, value := range contact.Attributes {
+		values["attributes."+key] = fmt.Sprint(value)
+	}
+
+	escaped := make(map[string]string, len(values))
+	for key, value := range values {
+		escaped[key] = html.EscapeString(value)
+	}
+
+	return &model.QueuedMail{
+		AccountId:       contact.AccountId,
+		ContactId:       contact.ID,
+		Email:           contact.Email,
+		Subject:         workflow.Render(step.Subject, values),
+		Body:            workflow.Render(step.Body, escaped),
+		PreferencesLink: link.Link,
+		MessageId:       uuid.New().String(),
+	}, nil
+}
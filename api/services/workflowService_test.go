@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"email-marketing-service/api/config"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/ratelimit"
+	"email-marketing-service/api/repository"
+	"email-marketing-service/api/repository/memory"
+	"email-marketing-service/api/utils"
+	"email-marketing-service/api/workflow"
+	"testing"
+	"time"
+)
+
+// Methods the tests do not need are left to the embedded nil interfaces and panic if called.
+
+type stubWorkflows struct {
+	repository.WorkflowStore
+	flow *model.Workflow
+}
+
+func (r *stubWorkflows) FindWorkflowById(ctx context.Context, workflowId int) (*model.Workflow, error) {
+	return r.flow, nil
+}
+
+type stubContacts struct {
+	repository.ContactStore
+	contact *model.Contact
+}
+
+func (r *stubContacts) FindContactByUUID(ctx context.Context, d *model.Contact) (*model.Contact, error) {
+	return r.contact, nil
+}
+
+type stubTopicOptOuts struct {
+	repository.TopicStore
+	optedOut map[string]bool
+}
+
+func (r *stubTopicOptOuts) CheckIfOptedOut(ctx context.Context, contactId int, topicUUID string) (bool, error) {
+	return r.optedOut[topicUUID], nil
+}
+
+func TestWorkflowSendsSkipTopicsTheContactOptedOutOf(t *testing.T) {
+	workflows := &stubWorkflows{flow: &model.Workflow{
+		ID:   1,
+		UUID: "workflow-uuid",
+		Steps: []workflow.Step{
+			{ID: "offers", Type: "send", Subject: "Offers", Body: "<p>Offers</p>", TopicUUID: "offers-topic"},
+			{ID: "news", Type: "send", Subject: "News", Body: "<p>News</p>", TopicUUID: "news-topic"},
+			{ID: "plain", Type: "send", Subject: "Welcome", Body: "<p>Welcome</p>"},
+		},
+	}}
+	contacts := &stubContacts{contact: &model.Contact{ID: 3, UUID: "contact-uuid", AccountId: 7, Email: "ada@example.com", Status: "subscribed"}}
+	topics := &stubTopicOptOuts{optedOut: map[string]bool{"offers-topic": true}}
+	jwtManager := utils.NewJWTManager(config.AuthConfig{
+		JWTSecret: "test-secret-that-is-long-enough-for-hs256",
+		TokenTTL:  time.Hour,
+	})
+	quotas := NewQuotaService(memory.NewQuotaRepository(model.AccountQuota{
+		Plan:            "free",
+		MessagesPerHour: 100,
+		MessagesPerDay:  100,
+	}), ratelimit.NewMemoryLimiter())
+	preferences := NewPreferenceService(contacts, nil, topics, nil, jwtManager, "https://app.example.com", memory.NewTransactor())
+
+	queue := newStubMailQueue()
+
+	service := NewWorkflowService(workflows, contacts, nil, topics, queue, nil, preferences, quotas, memory.NewTransactor())
+
+	run := &model.WorkflowRun{ID: 1, WorkflowId: 1, AccountId: 7, ContactId: 3, ContactUUID: "contact-uuid", Status: "active", StepId: "offers"}
+
+	queued, err := service.advance(context.Background(), run)
+	if err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+
+	mails := queue.queued()
+	if queued != 2 || len(mails) != 2 || mails[0].Subject != "News" || mails[1].Subject != "Welcome" {
+		t.Fatalf("expected the news and welcome mails to be queued, got %d: %+v", queued, mails)
+	}
+
+	if mails[0].Data["step_id"] != "news" || mails[1].Data["step_id"] != "plain" || mails[0].Data["workflow_uuid"] != "workflow-uuid" {
+		t.Fatalf("expected the mails to name their workflow steps, got %v and %v", mails[0].Data, mails[1].Data)
+	}
+
+	// the quota is taken when the mail queue sends the mails, so a step that is undone keeps none of it
+	if err := quotas.ReserveMessages(context.Background(), 7, 100); err != nil {
+		t.Fatalf("queueing the mails took from the quota: %v", err)
+	}
+
+	if run.Status != "completed" {
+		t.Fatalf("run status = %s, want completed", run.Status)
+	}
+}
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"email-marketing-service/api/apperrors"
 	"encoding/json"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 )
 
 type ApiResponse struct {
@@ -17,14 +21,36 @@ func (r *ApiResponse) SuccessResponse(w http.ResponseWriter, statusCode int, pay
 	r.sendJSONResponse(w, statusCode, successResponse)
 }
 
-func (r *ApiResponse) ErrorResponse(w http.ResponseWriter, payload interface{}) {
+// ErrorResponse responds with the status, code and message of err. Errors that are not an
+// *apperrors.Error are logged and reported to the client as a generic internal error.
+//
+// "code" keeps its legacy values ("3", or "4" for rate limiting); clients should switch on "error",
+// which holds the stable error code.
+func (r *ApiResponse) ErrorResponse(w http.ResponseWriter, req *http.Request, err error) {
+	appErr := apperrors.From(err)
+
+	if appErr.Kind == apperrors.Internal {
+		slog.ErrorContext(req.Context(), "request failed", "error", err)
+	}
+
+	code := "3"
+	if appErr.Kind == apperrors.RateLimited {
+		code = "4"
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+
 	errorResponse := map[string]interface{}{
-		"code":    "3",
+		"code":    code,
 		"message": "failed",
-		"payload": payload,
+		"error":   appErr.Code,
+		"payload": appErr.Message,
+	}
+
+	if len(appErr.Fields) > 0 {
+		errorResponse["details"] = appErr.Fields
 	}
 
-	r.sendJSONResponse(w, 400, errorResponse)
+	r.sendJSONResponse(w, appErr.Kind.Status(), errorResponse)
 }
 
 func (r *ApiResponse) sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
@@ -1,17 +1,100 @@
 package utils
 
 import (
+	"email-marketing-service/api/apperrors"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
 )
 
-func DecodeRequestBody(r *http.Request, v interface{}) error {
-	err := json.NewDecoder(r.Body).Decode(v)
+// maxRequestBodySize is the largest JSON body accepted by DecodeAndValidate.
+const maxRequestBodySize = 1 << 20
+
+// DecodeAndValidate decodes the JSON request body into v and validates it. The body must be sent as
+// application/json, may not exceed maxRequestBodySize, must hold a single JSON value and may only
+// contain fields v knows about. The returned error is an *apperrors.Error ready to be sent to the client.
+func DecodeAndValidate(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return apperrors.New(apperrors.UnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/json")
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return decodeError(err)
+		}
+		return apperrors.New(apperrors.BadRequest, "malformed_json", "request body must contain a single JSON object")
+	}
+
+	return ValidateData(v)
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return err
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeError turns an encoding/json error into a client error that points at the offending field or position.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return apperrors.New(apperrors.BadRequest, "empty_body", "request body must not be empty")
+
+	case errors.As(err, &maxBytesErr):
+		return apperrors.New(apperrors.PayloadTooLarge, "body_too_large", fmt.Sprintf("request body must not be larger than %d bytes", maxBytesErr.Limit))
+
+	case errors.As(err, &syntaxErr):
+		return apperrors.New(apperrors.BadRequest, "malformed_json", fmt.Sprintf("request body contains malformed JSON at position %d", syntaxErr.Offset)).Wrap(err)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apperrors.New(apperrors.BadRequest, "malformed_json", "request body contains malformed JSON").Wrap(err)
+
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return apperrors.New(apperrors.BadRequest, "invalid_body", "request body must be a JSON object").Wrap(err)
+
+	case errors.As(err, &typeErr):
+		appErr := apperrors.New(apperrors.BadRequest, "invalid_field_type", "request body contains a field of the wrong type").Wrap(err)
+		appErr.Fields = []apperrors.FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type.Kind()),
+		}}
+		return appErr
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for this case
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		appErr := apperrors.New(apperrors.BadRequest, "unknown_field", fmt.Sprintf("request body contains unknown field %q", field)).Wrap(err)
+		appErr.Fields = []apperrors.FieldError{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: fmt.Sprintf("%s is not a known field", field),
+		}}
+		return appErr
+
+	default:
+		return apperrors.New(apperrors.BadRequest, "invalid_body", "request body could not be decoded").Wrap(err)
 	}
-	return nil
 }
 
 func EncodeToJson(v interface{}) {
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"email-marketing-service/api/apperrors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type decodeTarget struct {
+	Email string `json:"email" validate:"required,email"`
+	Age   int    `json:"age"`
+}
+
+func decode(contentType string, body string) (*decodeTarget, error) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	var target decodeTarget
+	err := DecodeAndValidate(httptest.NewRecorder(), r, &target)
+	return &target, err
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"missing content type", "", `{"email":"ada@example.com"}`, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{"wrong content type", "text/plain", `{"email":"ada@example.com"}`, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{"empty body", "application/json", ``, http.StatusBadRequest, "empty_body"},
+		{"malformed json", "application/json", `{"email":`, http.StatusBadRequest, "malformed_json"},
+		{"syntax error", "application/json", `{"email" "x"}`, http.StatusBadRequest, "malformed_json"},
+		{"trailing data", "application/json", `{"email":"ada@example.com"} {}`, http.StatusBadRequest, "malformed_json"},
+		{"unknown field", "application/json", `{"email":"ada@example.com","admin":true}`, http.StatusBadRequest, "unknown_field"},
+		{"wrong type", "application/json", `{"email":"ada@example.com","age":"old"}`, http.StatusBadRequest, "invalid_field_type"},
+		{"not an object", "application/json", `["ada@example.com"]`, http.StatusBadRequest, "invalid_body"},
+		{"too large", "application/json", `{"email":"` + strings.Repeat("a", maxRequestBodySize) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large"},
+		{"invalid field", "application/json", `{"email":"not-an-email"}`, http.StatusUnprocessableEntity, "validation_failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decode(tt.contentType, tt.body)
+
+			appErr := apperrors.From(err)
+			if appErr.Kind.Status() != tt.status || appErr.Code != tt.code {
+				t.Fatalf("got %d %s (%v), want %d %s", appErr.Kind.Status(), appErr.Code, err, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestDecodeAndValidateAcceptsValidBody(t *testing.T) {
+	target, err := decode("application/json; charset=utf-8", `{"email":"ada@example.com","age":36}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if target.Email != "ada@example.com" || target.Age != 36 {
+		t.Fatalf("unexpected result %+v", target)
+	}
+}
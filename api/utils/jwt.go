@@ -1,29 +1,41 @@
 package utils
 
 import (
+	"email-marketing-service/api/config"
+	"fmt"
 	"github.com/golang-jwt/jwt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 )
 
-var key = os.Getenv("JWT_KEY")
+// JWTManager signs and verifies the tokens issued by the application with the configured secret.
+type JWTManager struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewJWTManager(cfg config.AuthConfig) *JWTManager {
+	return &JWTManager{
+		secret: []byte(cfg.JWTSecret),
+		ttl:    cfg.TokenTTL,
+	}
+}
 
-func JWTEncode(userId int, username string, email string) (string, error) {
-	LoadEnv()
+func (m *JWTManager) Encode(userId int, username string, email string) (string, error) {
 	// Create a new token object with claims
 	claims := jwt.MapClaims{
 		"sub":      userId,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
-		"username": username,                              // Include username claim
-		"email":    email,                                 // Include email claim
+		"iat":      time.Now().Unix(),
+		"exp":      time.Now().Add(m.ttl).Unix(),
+		"username": username, // Include username claim
+		"email":    email,    // Include email claim
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// Sign the token with a secret key
 
-	tokenString, err := token.SignedString([]byte(key))
+	tokenString, err := token.SignedString(m.secret)
 	if err != nil {
 		return "", err
 	}
@@ -31,6 +43,26 @@ func JWTEncode(userId int, username string, email string) (string, error) {
 	return tokenString, nil
 }
 
+// Decode verifies the signature and expiry of a token and returns its claims.
+func (m *JWTManager) Decode(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return m.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid jwt claims")
+	}
+
+	return claims, nil
+}
+
 func ExtractTokenFromHeader(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -45,4 +77,114 @@ func ExtractTokenFromHeader(r *http.Request) string {
 	return tokenParts[1]
 }
 
+// InviteTokenEncode signs an invitation link token that carries the invitation uuid and expires with it.
+func (m *JWTManager) InviteTokenEncode(invitationUUID string, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"typ": "invite",
+		"inv": invitationUUID,
+		"exp": expiresAt.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString(m.secret)
+}
+
+// InviteTokenDecode verifies an invitation link token and returns the invitation uuid.
+func (m *JWTManager) InviteTokenDecode(tokenString string) (string, error) {
+	claims, err := m.Decode(tokenString)
+	if err != nil || claims["typ"] != "invite" {
+		return "", fmt.Errorf("invalid or expired invitation token")
+	}
+
+	invitationUUID, ok := claims["inv"].(string)
+	if !ok || invitationUUID == "" {
+		return "", fmt.Errorf("invalid invitation token")
+	}
+
+	return invitationUUID, nil
+}
+
+// SubscriptionTokenEncode signs the link token of a subscription confirmation mail that carries the consent uuid.
+func (m *JWTManager) SubscriptionTokenEncode(consentUUID string, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"typ": "subscribe",
+		"csn": consentUUID,
+		"exp": expiresAt.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString(m.secret)
+}
+
+// SubscriptionTokenDecode verifies a subscription confirmation token and returns the consent uuid.
+func (m *JWTManager) SubscriptionTokenDecode(tokenString string) (string, error) {
+	claims, err := m.Decode(tokenString)
+	if err != nil || claims["typ"] != "subscribe" {
+		return "", fmt.Errorf("invalid or expired subscription token")
+	}
+
+	consentUUID, ok := claims["csn"].(string)
+	if !ok || consentUUID == "" {
+		return "", fmt.Errorf("invalid subscription token")
+	}
+
+	return consentUUID, nil
+}
+
+// PreferencesTokenEncode signs the token of a preference center link of a contact.
+func (m *JWTManager) PreferencesTokenEncode(contactUUID string, accountId int, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"typ": "preferences",
+		"cnt": contactUUID,
+		"acc": accountId,
+		"exp": expiresAt.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString(m.secret)
+}
+
+// PreferencesTokenDecode verifies a preference center token and returns the contact uuid and account id.
+func (m *JWTManager) PreferencesTokenDecode(tokenString string) (string, int, error) {
+	claims, err := m.Decode(tokenString)
+	if err != nil || claims["typ"] != "preferences" {
+		return "", 0, fmt.Errorf("invalid or expired preferences token")
+	}
+
+	contactUUID, ok := claims["cnt"].(string)
+	accountId, okAccount := claims["acc"].(float64)
+	if !ok || !okAccount || contactUUID == "" {
+		return "", 0, fmt.Errorf("invalid preferences token")
+	}
+
+	return contactUUID, int(accountId), nil
+}
+
+// ExportTokenEncode signs the download link token of a background export.
+func (m *JWTManager) ExportTokenEncode(exportUUID string, accountId int, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"typ": "export",
+		"xpt": exportUUID,
+		"acc": accountId,
+		"exp": expiresAt.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
+	return token.SignedString(m.secret)
+}
+
+// ExportTokenDecode verifies an export download token and returns the export uuid and account id.
+func (m *JWTManager) ExportTokenDecode(tokenString string) (string, int, error) {
+	claims, err := m.Decode(tokenString)
+	if err != nil || claims["typ"] != "export" {
+		return "", 0, fmt.Errorf("invalid or expired export token")
+	}
+
+	exportUUID, ok := claims["xpt"].(string)
+	accountId, okAccount := claims["acc"].(float64)
+	if !ok || !okAccount || exportUUID == "" {
+		return "", 0, fmt.Errorf("invalid export token")
+	}
+
+	return exportUUID, int(accountId), nil
+}
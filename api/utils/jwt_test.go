@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"email-marketing-service/api/config"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionToken(t *testing.T) {
+	m := NewJWTManager(config.AuthConfig{JWTSecret: "secret", TokenTTL: time.Hour})
+
+	token, err := m.SubscriptionTokenEncode("consent-uuid", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consentUUID, err := m.SubscriptionTokenDecode(token)
+	if err != nil || consentUUID != "consent-uuid" {
+		t.Fatalf("SubscriptionTokenDecode() = %q, %v; want consent-uuid", consentUUID, err)
+	}
+
+	expired, _ := m.SubscriptionTokenEncode("consent-uuid", time.Now().Add(-time.Minute))
+	if _, err := m.SubscriptionTokenDecode(expired); err == nil {
+		t.Error("expired token was accepted")
+	}
+
+	invite, _ := m.InviteTokenEncode("consent-uuid", time.Now().Add(time.Hour))
+	if _, err := m.SubscriptionTokenDecode(invite); err == nil {
+		t.Error("invitation token was accepted as a subscription token")
+	}
+}
+
+func TestPreferencesToken(t *testing.T) {
+	m := NewJWTManager(config.AuthConfig{JWTSecret: "secret", TokenTTL: time.Hour})
+
+	token, err := m.PreferencesTokenEncode("contact-uuid", 42, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contactUUID, accountId, err := m.PreferencesTokenDecode(token)
+	if err != nil || contactUUID != "contact-uuid" || accountId != 42 {
+		t.Fatalf("PreferencesTokenDecode() = %q, %d, %v; want contact-uuid, 42", contactUUID, accountId, err)
+	}
+
+	subscription, _ := m.SubscriptionTokenEncode("contact-uuid", time.Now().Add(time.Hour))
+	if _, _, err := m.PreferencesTokenDecode(subscription); err == nil {
+		t.Error("subscription token was accepted as a preferences token")
+	}
+}
@@ -1,34 +1,103 @@
 package utils
 
 import (
+	"context"
+	"email-marketing-service/api/config"
+	"email-marketing-service/api/metrics"
+	"log/slog"
+	"net"
+	"net/mail"
+	"strconv"
+	"time"
+
 	"gopkg.in/gomail.v2"
-	"os"
 )
 
-func SendMail(subject string, email string, message string) error {
+// Mail is a message sent with SendMessage.
+type Mail struct {
+	To      string
+	Subject string
+	HTML    string
+	// Headers are added to the message, e.g. a Message-ID to recognize the bounces of the message by.
+	Headers map[string]string
+	// Tier labels the metrics of the mail; mail sent by the application itself leaves it empty.
+	Tier string
+}
+
+// SendMail delivers an HTML message. The outcome is logged with the request ID carried by ctx,
+// so that a failed send can be traced back to the request or job that triggered it.
+func SendMail(ctx context.Context, cfg config.MailConfig, subject string, email string, message string) error {
+	return SendMessage(ctx, cfg, Mail{To: email, Subject: subject, HTML: message})
+}
 
-	LoadEnv()
-	// Mailtrap SMTP server settings
-	smtpHost := "sandbox.smtp.mailtrap.io"
-	smtpPort := 2525
-	smtpUsername := os.Getenv("MAIL_USERNAME")
-	smtpPassword := os.Getenv("MAIL_PASSWORD")
+// SendMessage delivers m. Its envelope sender is the configured return path, so that bounces reach the
+// service's SMTP server.
+func SendMessage(ctx context.Context, cfg config.MailConfig, m Mail) error {
 
 	// Create a new email message
 	msg := gomail.NewMessage()
-	msg.SetHeader("From", "sender@example.com")
-	msg.SetHeader("To", email)
-	msg.SetHeader("Subject", subject)
-	msg.SetBody("text/html", message)
+	msg.SetHeader("From", cfg.From)
+	msg.SetHeader("To", m.To)
+	msg.SetHeader("Subject", m.Subject)
+	for name, value := range m.Headers {
+		msg.SetHeader(name, value)
+	}
+	msg.SetBody("text/html", m.HTML)
+
+	tier := m.Tier
+	if tier == "" {
+		tier = metrics.TierTransactional
+	}
+
+	returnPath := cfg.ReturnPath
+	if returnPath == "" {
+		returnPath = cfg.From
+	}
+
+	// the envelope takes bare addresses, while From may carry a display name
+	if address, err := mail.ParseAddress(returnPath); err == nil {
+		returnPath = address.Address
+	}
 
 	// Initialize the SMTP sender
-	d := gomail.NewDialer(smtpHost, smtpPort, smtpUsername, smtpPassword)
+	d := gomail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password)
 
-	// Send the email
-	if err := d.DialAndSend(msg); err != nil {
+	start := time.Now()
+
+	// Connect separately from sending so that the dial latency can be measured on its own
+	sender, err := d.Dial()
+	metrics.ObserveSMTPDial(time.Since(start))
+
+	if err == nil {
+		err = sender.Send(returnPath, []string{m.To}, msg)
+		sender.Close()
+	}
+
+	if err != nil {
+		// marketing mail is tried again by the mail queue, which counts it as failed once it gives up
+		if m.Tier == "" {
+			metrics.MailFailed(tier)
+		}
+		slog.ErrorContext(ctx, "mail delivery failed", "subject", m.Subject, "smtp_host", cfg.Host, "latency_ms", time.Since(start).Milliseconds(), "error", err)
 		return err
 	}
 
+	metrics.MailSent(tier)
+	slog.InfoContext(ctx, "mail sent", "subject", m.Subject, "latency_ms", time.Since(start).Milliseconds())
+
 	return nil
 
 }
+
+// CheckSMTP reports whether the SMTP server accepts TCP connections. It does not authenticate,
+// so it is cheap enough for health checks.
+func CheckSMTP(ctx context.Context, cfg config.MailConfig) error {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
@@ -1,30 +1,71 @@
 package utils
 
 import (
+	"email-marketing-service/api/apperrors"
+	"errors"
 	"fmt"
-	"github.com/go-playground/validator/v10"
+	"reflect"
 	"strings"
+
+	"github.com/go-playground/validator/v10"
 )
 
-func ValidateData(v interface{}) error {
-	validate := validator.New()
+var validate = newValidator()
 
-	err := validate.Struct(v)
+func newValidator() *validator.Validate {
+	v := validator.New()
 
-	if err != nil {
-		validationErrors, ok := err.(validator.ValidationErrors)
-		if !ok {
-			// Handle unexpected validation error type
-			return fmt.Errorf("unexpected validation error: %v", err)
+	// report fields by the name clients send them under
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
 		}
+		return name
+	})
 
-		// Construct an error message with validation errors
-		var errorMsgs []string
-		for _, e := range validationErrors {
-			errorMsgs = append(errorMsgs, fmt.Sprintf("%s: %s", e.Field(), e.Tag()))
-		}
-		return fmt.Errorf("validation errors: %s", strings.Join(errorMsgs, "; "))
+	return v
+}
+
+// ValidateData returns an apperrors.Validation error listing every field that failed validation.
+func ValidateData(v interface{}) error {
+	err := validate.Struct(v)
+
+	if err == nil {
+		return nil
 	}
 
-	return nil // No validation errors
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		// nil or non-struct input; nothing a client can fix
+		return apperrors.NewValidation("invalid_request_body", "invalid request body").Wrap(err)
+	}
+
+	fields := make([]apperrors.FieldError, 0, len(validationErrors))
+	for _, e := range validationErrors {
+		fields = append(fields, apperrors.FieldError{
+			Field:   e.Field(),
+			Rule:    e.Tag(),
+			Message: fieldMessage(e),
+		})
+	}
+
+	return apperrors.NewValidation("validation_failed", "one or more fields are invalid", fields...)
+}
+
+func fieldMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", e.Field())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", e.Field())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s characters long", e.Field(), e.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters long", e.Field(), e.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", e.Field(), e.Param())
+	default:
+		return fmt.Sprintf("%s is invalid", e.Field())
+	}
 }
@@ -0,0 +1,298 @@
+// Package workflow defines the triggers and steps of automation workflows, such as drip sequences.
+//
+// A workflow starts a run for a contact when its trigger fires:
+//
+//	{"type": "list_joined", "list_uuid": "<list uuid>"}
+//	{"type": "tag_added", "tag": "trial"}
+//	{"type": "event", "event": "signed_up"}
+//	{"type": "date_anniversary", "attribute": "birthday"}
+//
+// and the run goes through the steps in order. A branch jumps to the step named by then or else; a step
+// without an id gets "step-<position>":
+//
+//	{"type": "wait", "days": 2}
+//	{"type": "wait_until", "time": "09:00", "timezone": "Europe/Paris"}
+//	{"type": "send", "subject": "Welcome {{firstname}}", "body": "<p>...</p>"}
+//	{"type": "branch", "condition": {"type": "tag", "operator": "has", "value": "paid"}, "then": "thanks"}
+//	{"type": "add_tag", "tag": "onboarded"}
+//	{"type": "update_attribute", "attribute": "stage", "value": "active"}
+//	{"type": "exit"}
+package workflow
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/segment"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxSteps keeps workflows at a size that can be reviewed at a glance.
+	maxSteps = 50
+	// maxWait is the longest a single wait step may pause a run.
+	maxWait = 365 * 24 * time.Hour
+	// maxBodySize bounds the body of a send step.
+	maxBodySize = 100 * 1024
+)
+
+var stepIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+type Trigger struct {
+	// Type is list_joined, tag_added, event or date_anniversary.
+	Type     string `json:"type"`
+	ListUUID string `json:"list_uuid,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Event    string `json:"event,omitempty"`
+	// Attribute holds the date of a date_anniversary trigger as 2006-01-02 or an RFC 3339 timestamp. The run
+	// starts on the same month and day every year.
+	Attribute string `json:"attribute,omitempty"`
+}
+
+type Step struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+
+	// Days, Hours and Minutes add up to the pause of a wait step.
+	Days    int `json:"days,omitempty"`
+	Hours   int `json:"hours,omitempty"`
+	Minutes int `json:"minutes,omitempty"`
+
+	// A wait_until step waits for the moment At, or for the next Time of day (15:04) in Timezone.
+	At       string `json:"at,omitempty"`
+	Time     string `json:"time,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+
+	// Subject and Body of a send step may contain merge tags such as {{firstname}}.
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body,omitempty"`
+	// TopicUUID puts a send step under a topic; contacts that opted out of the topic skip it.
+	TopicUUID string `json:"topic_uuid,omitempty"`
+
+	// A branch continues with the step Then when the contact matches Condition and with Else otherwise.
+	// Either may be empty to continue with the next step.
+	Condition *segment.Rule `json:"condition,omitempty"`
+	Then      string        `json:"then,omitempty"`
+	Else      string        `json:"else,omitempty"`
+
+	// Tag is added or removed by add_tag and remove_tag.
+	Tag string `json:"tag,omitempty"`
+
+	// Attribute is set to Value by update_attribute, or removed if Value is null.
+	Attribute string `json:"attribute,omitempty"`
+	Value     any    `json:"value,omitempty"`
+}
+
+// Delay returns the pause of a wait step.
+func (s Step) Delay() time.Duration {
+	return time.Duration(s.Days)*24*time.Hour + time.Duration(s.Hours)*time.Hour + time.Duration(s.Minutes)*time.Minute
+}
+
+// WaitUntil returns when a wait_until step lets a run go on, which is in the past if it may go on right away.
+func (s Step) WaitUntil(now time.Time) time.Time {
+	if s.At != "" {
+		at, _ := time.Parse(time.RFC3339, s.At)
+		return at
+	}
+
+	location := time.UTC
+	if s.Timezone != "" {
+		location, _ = time.LoadLocation(s.Timezone)
+	}
+
+	clock, _ := time.Parse("15:04", s.Time)
+	local := now.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, location)
+
+	if next.Before(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+// AssignIDs gives the steps without an id one derived from their position.
+func AssignIDs(steps []Step) {
+	for i := range steps {
+		if steps[i].ID == "" {
+			steps[i].ID = "step-" + strconv.Itoa(i+1)
+		}
+	}
+}
+
+// Find returns the step with the given id.
+func Find(steps []Step, id string) (Step, bool) {
+	for _, step := range steps {
+		if step.ID == id {
+			return step, true
+		}
+	}
+	return Step{}, false
+}
+
+// Next returns the id of the step following the step with the given id, or "" after the last step.
+func Next(steps []Step, id string) string {
+	for i, step := range steps {
+		if step.ID == id && i+1 < len(steps) {
+			return steps[i+1].ID
+		}
+	}
+	return ""
+}
+
+// Validate checks a trigger and the steps, whose ids must have been assigned.
+func Validate(trigger Trigger, steps []Step) error {
+	if err := validateTrigger(trigger); err != nil {
+		return err
+	}
+
+	if len(steps) == 0 {
+		return invalid("steps", "required", "a workflow needs at least one step")
+	}
+
+	if len(steps) > maxSteps {
+		return invalid("steps", "max", fmt.Sprintf("a workflow can have at most %d steps", maxSteps))
+	}
+
+	for i, step := range steps {
+		path := fmt.Sprintf("steps[%d]", i)
+
+		if !stepIdPattern.MatchString(step.ID) {
+			return invalid(path+".id", "format", "id must be made of letters, digits, '_' or '-'")
+		}
+
+		for _, other := range steps[:i] {
+			if other.ID == step.ID {
+				return invalid(path+".id", "unique", fmt.Sprintf("id %s is used by another step", step.ID))
+			}
+		}
+	}
+
+	for i, step := range steps {
+		if err := validateStep(step, fmt.Sprintf("steps[%d]", i), steps); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateTrigger(trigger Trigger) error {
+	switch trigger.Type {
+	case "list_joined":
+		if trigger.ListUUID == "" {
+			return invalid("trigger.list_uuid", "required", "list_uuid is required")
+		}
+	case "tag_added":
+		if trigger.Tag == "" || len(trigger.Tag) > 50 {
+			return invalid("trigger.tag", "required", "tag is required and at most 50 characters long")
+		}
+	case "event":
+		if !segment.ValidEventName(trigger.Event) {
+			return invalid("trigger.event", "format", "event must be a name of letters, digits, '_', '.', ':' or '-'")
+		}
+	case "date_anniversary":
+		if !segment.ValidAttributeKey(trigger.Attribute) {
+			return invalid("trigger.attribute", "format", "attribute must be an attribute key of letters, digits, '_', '.' or '-'")
+		}
+	default:
+		return invalid("trigger.type", "oneof", "type must be one of: list_joined, tag_added, event, date_anniversary")
+	}
+
+	return nil
+}
+
+func validateStep(step Step, path string, steps []Step) error {
+	switch step.Type {
+	case "wait":
+		if step.Days < 0 || step.Hours < 0 || step.Minutes < 0 {
+			return invalid(path, "min", "days, hours and minutes must not be negative")
+		}
+		if delay := step.Delay(); delay <= 0 || delay > maxWait {
+			return invalid(path, "range", "a wait must be longer than zero and at most 365 days")
+		}
+	case "wait_until":
+		return validateWaitUntil(step, path)
+	case "send":
+		if step.Subject == "" || len(step.Subject) > 255 {
+			return invalid(path+".subject", "required", "subject is required and at most 255 characters long")
+		}
+		if step.Body == "" || len(step.Body) > maxBodySize {
+			return invalid(path+".body", "required", "body is required and at most 100 KB")
+		}
+	case "branch":
+		if step.Condition == nil {
+			return invalid(path+".condition", "required", "condition is required")
+		}
+		if err := segment.Validate(*step.Condition); err != nil {
+			return err
+		}
+		for field, target := range map[string]string{"then": step.Then, "else": step.Else} {
+			if _, ok := Find(steps, target); target != "" && !ok {
+				return invalid(path+"."+field, "exists", fmt.Sprintf("%s must be the id of a step", field))
+			}
+		}
+	case "add_tag", "remove_tag":
+		if step.Tag == "" || len(step.Tag) > 50 {
+			return invalid(path+".tag", "required", "tag is required and at most 50 characters long")
+		}
+	case "update_attribute":
+		if !segment.ValidAttributeKey(step.Attribute) {
+			return invalid(path+".attribute", "format", "attribute must be an attribute key of letters, digits, '_', '.' or '-'")
+		}
+		switch step.Value.(type) {
+		case nil, string, float64, bool:
+		default:
+			return invalid(path+".value", "type", "value must be a string, number, boolean or null")
+		}
+	case "exit":
+	default:
+		return invalid(path+".type", "oneof", "type must be one of: wait, wait_until, send, branch, add_tag, remove_tag, update_attribute, exit")
+	}
+
+	return nil
+}
+
+func validateWaitUntil(step Step, path string) error {
+	if (step.At == "") == (step.Time == "") {
+		return invalid(path, "required", "a wait_until step needs either at or time")
+	}
+
+	if step.At != "" {
+		if _, err := time.Parse(time.RFC3339, step.At); err != nil {
+			return invalid(path+".at", "datetime", "at must be an RFC 3339 timestamp")
+		}
+		return nil
+	}
+
+	if _, err := time.Parse("15:04", step.Time); err != nil {
+		return invalid(path+".time", "format", "time must be a time of day such as 09:00")
+	}
+
+	if step.Timezone != "" {
+		if _, err := time.LoadLocation(step.Timezone); err != nil {
+			return invalid(path+".timezone", "timezone", "timezone must be an IANA time zone such as Europe/Paris")
+		}
+	}
+
+	return nil
+}
+
+func invalid(field string, rule string, message string) error {
+	return apperrors.NewValidation("invalid_workflow", "workflow is invalid", apperrors.FieldError{
+		Field:   field,
+		Rule:    rule,
+		Message: message,
+	})
+}
+
+// mergeTag matches {{name}} in the subject and body of a send step.
+var mergeTag = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Render replaces the merge tags of text with values. Tags without a value are removed.
+func Render(text string, values map[string]string) string {
+	return mergeTag.ReplaceAllStringFunc(text, func(tag string) string {
+		return values[mergeTag.FindStringSubmatch(tag)[1]]
+	})
+}
@@ -0,0 +1,103 @@
+package workflow
+
+import (
+	"email-marketing-service/api/apperrors"
+	"email-marketing-service/api/segment"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	trigger := Trigger{Type: "tag_added", Tag: "trial"}
+
+	tests := []struct {
+		name    string
+		trigger Trigger
+		steps   []Step
+		field   string
+	}{
+		{
+			name:    "valid",
+			trigger: trigger,
+			steps: []Step{
+				{Type: "send", Subject: "Hi {{firstname}}", Body: "<p>Welcome</p>"},
+				{Type: "wait", Days: 2},
+				{Type: "branch", Condition: &segment.Rule{Type: "tag", Operator: "has", Value: "paid"}, Then: "done"},
+				{Type: "update_attribute", Attribute: "stage", Value: "nurtured"},
+				{ID: "done", Type: "exit"},
+			},
+		},
+		{name: "unknown trigger", trigger: Trigger{Type: "opened"}, steps: []Step{{Type: "exit"}}, field: "trigger.type"},
+		{name: "list without uuid", trigger: Trigger{Type: "list_joined"}, steps: []Step{{Type: "exit"}}, field: "trigger.list_uuid"},
+		{name: "no steps", trigger: trigger, field: "steps"},
+		{name: "duplicate id", trigger: trigger, steps: []Step{{ID: "a", Type: "exit"}, {ID: "a", Type: "exit"}}, field: "steps[1].id"},
+		{name: "empty wait", trigger: trigger, steps: []Step{{Type: "wait"}}, field: "steps[0]"},
+		{name: "wait_until with both", trigger: trigger, steps: []Step{{Type: "wait_until", At: "2030-01-01T09:00:00Z", Time: "09:00"}}, field: "steps[0]"},
+		{name: "unknown timezone", trigger: trigger, steps: []Step{{Type: "wait_until", Time: "09:00", Timezone: "Mars/Olympus"}}, field: "steps[0].timezone"},
+		{name: "branch to unknown step", trigger: trigger, steps: []Step{{Type: "branch", Condition: &segment.Rule{Type: "snoozed", Operator: "is"}, Else: "nowhere"}}, field: "steps[0].else"},
+		{name: "object value", trigger: trigger, steps: []Step{{Type: "update_attribute", Attribute: "plan", Value: map[string]any{}}}, field: "steps[0].value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AssignIDs(tt.steps)
+			err := Validate(tt.trigger, tt.steps)
+
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var appErr *apperrors.Error
+			if !errors.As(err, &appErr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if len(appErr.Fields) != 1 || appErr.Fields[0].Field != tt.field {
+				t.Fatalf("expected a problem with %s, got %+v", tt.field, appErr.Fields)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	steps := []Step{{Type: "wait", Days: 1}, {ID: "mail", Type: "send"}, {Type: "exit"}}
+	AssignIDs(steps)
+
+	if got := Next(steps, "step-1"); got != "mail" {
+		t.Errorf("Next(step-1) = %q, want mail", got)
+	}
+	if got := Next(steps, "step-3"); got != "" {
+		t.Errorf("Next(step-3) = %q, want the end", got)
+	}
+}
+
+func TestWaitUntil(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	step := Step{Type: "wait_until", Time: "09:00", Timezone: "Europe/Paris"}
+
+	morning := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	if got, want := step.WaitUntil(morning), time.Date(2024, 5, 1, 9, 0, 0, 0, paris); !got.Equal(want) {
+		t.Errorf("WaitUntil(%v) = %v, want %v", morning, got, want)
+	}
+
+	evening := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	if got, want := step.WaitUntil(evening), time.Date(2024, 5, 2, 9, 0, 0, 0, paris); !got.Equal(want) {
+		t.Errorf("WaitUntil(%v) = %v, want %v", evening, got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	values := map[string]string{"firstname": "Ada", "attributes.plan": "pro"}
+
+	got := Render("Hi {{firstname}}, your {{ attributes.plan }} plan{{unknown}}", values)
+	if want := "Hi Ada, your pro plan"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+}
@@ -1,55 +1,143 @@
 package main
 
 import (
+	"context"
+	"email-marketing-service/api/config"
 	"email-marketing-service/api/database"
+	"email-marketing-service/api/health"
+	"email-marketing-service/api/lifecycle"
+	"email-marketing-service/api/logging"
+	"email-marketing-service/api/metrics"
+	"email-marketing-service/api/middleware"
 	"email-marketing-service/api/routes"
+	"email-marketing-service/api/utils"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 )
 
-func enableCORS(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		// If the request method is OPTIONS, just return a 200 status (pre-flight request)
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+func main() {
 
-		// Call the actual handler
-		handler.ServeHTTP(w, r)
-	})
-}
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(2)
+	}
 
-func main() {
+	slog.SetDefault(logging.New(cfg.Log, os.Stdout))
 
 	// Initialize the database connection
-	dbConn, err := database.InitDB()
+	dbConn, err := database.InitDB(cfg.Database)
 	if err != nil {
-		fmt.Println("Failed to connect to the database")
+		slog.Error("failed to connect to the database", "error", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		err := runMigrateCommand(dbConn, args[1:])
+		dbConn.Close()
+		if err != nil {
+			slog.Error("migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "quota" {
+		err := runQuotaCommand(dbConn, args[1:])
+		dbConn.Close()
+		if err != nil {
+			slog.Error("quota command failed", "error", err)
+			os.Exit(1)
+		}
 		return
 	}
-	defer dbConn.Close()
+
+	if cfg.App.AutoMigrate {
+		applied, err := database.MigrateUp(dbConn)
+		if err != nil {
+			slog.Error("failed to migrate the database", "error", err)
+			dbConn.Close()
+			os.Exit(1)
+		}
+		slog.Info("applied migrations", "count", applied)
+	}
+
+	metrics.RegisterDB(dbConn, cfg.Database.Name)
 
 	r := mux.NewRouter()
+	r.Use(middleware.RequestID, middleware.AccessLog, metrics.Middleware)
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// The dashboard API only accepts the configured origins, while public endpoints such as tracking
+	// pixels and signup forms are embedded on any site and never need credentials.
+	dashboardCORS := middleware.NewCORS(middleware.CORSPolicy{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Request-ID", middleware.AccountHeader},
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		MaxAge:           cfg.CORS.MaxAge,
+	})
+	publicCORS := middleware.NewCORS(middleware.CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"Content-Type"},
+		ExposedHeaders: cfg.CORS.ExposedHeaders,
+		MaxAge:         cfg.CORS.MaxAge,
+	})
+
+	// the public group is registered first, otherwise the "/api/v1" prefix would match its paths
+	publicRouter := r.PathPrefix("/api/v1/public").Subrouter()
+	publicRouter.Use(publicCORS.Middleware)
+	publicRouter.Use(middleware.Timeout(cfg.HTTP.RequestTimeout))
 
-	// Create a subrouter with the "/api/v1" prefix
 	apiV1Router := r.PathPrefix("/api/v1").Subrouter()
-	apiV1Router.Use(enableCORS)
-	routes.RegisterRoutes(apiV1Router)
-	http.Handle("/", r)
+	apiV1Router.Use(dashboardCORS.Middleware)
+	apiV1Router.Use(middleware.Timeout(cfg.HTTP.RequestTimeout))
+
+	workers := routes.RegisterRoutes(apiV1Router, publicRouter, dbConn, cfg)
+
+	// preflight handlers check the routes registered above, so they go last
+	publicCORS.HandlePreflight(publicRouter)
+	dashboardCORS.HandlePreflight(apiV1Router)
 
-	// Define the port
-	port := 9000
+	checker := health.NewChecker()
+	checker.Add("database", dbConn.PingContext)
+	checker.Add("migrations", func(ctx context.Context) error {
+		return database.CheckMigrations(ctx, dbConn)
+	})
+	checker.Add("smtp", func(ctx context.Context) error {
+		return utils.CheckSMTP(ctx, cfg.Mail)
+	})
+	for _, worker := range workers {
+		if w, ok := worker.(interface{ Check(context.Context) error }); ok {
+			checker.Add("worker: "+worker.Name(), w.Check)
+		}
+	}
+	r.HandleFunc("/healthz", checker.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", checker.Readiness).Methods("GET")
 
-	// Start the server
-	fmt.Printf("Server started on port %d\n", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), r))
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.HTTP.Port),
+		Handler:      r,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+
+	// The HTTP server is registered first so that it stops taking requests before the workers stop,
+	// and the database pool is closed last, once nothing can use it anymore.
+	app := lifecycle.New(cfg.App.ShutdownTimeout, cfg.App.ShutdownDrainDelay)
+	app.AddHTTPServer(fmt.Sprintf("HTTP server on port %d", cfg.HTTP.Port), server)
+	app.Add(workers...)
+	app.OnShutdown(checker.SetShuttingDown)
+	app.OnClose(dbConn.Close)
+
+	if err := app.Run(); err != nil {
+		slog.Error("application stopped with errors", "error", err)
+		os.Exit(1)
+	}
 }
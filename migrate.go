@@ -0,0 +1,53 @@
+package main
+
+import (
+	"database/sql"
+	"email-marketing-service/api/database"
+	"fmt"
+	"strconv"
+)
+
+// runMigrateCommand handles `migrate [up | down [steps] | status]`.
+func runMigrateCommand(db *sql.DB, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+
+		reverted, err := database.MigrateDown(db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d migrations\n", reverted)
+
+	case "status":
+		version, pending, err := database.MigrationVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Current version: %d, pending migrations: %d\n", version, pending)
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+	}
+
+	return nil
+}
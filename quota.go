@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"email-marketing-service/api/model"
+	"email-marketing-service/api/repository"
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// runQuotaCommand handles `quota show <user id>`, `quota set <user id> [flags]` and `quota reset <user id>`.
+func runQuotaCommand(db *sql.DB, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: quota show|set|reset <user id> [flags]")
+	}
+
+	userId, err := strconv.Atoi(args[1])
+	if err != nil || userId < 1 {
+		return fmt.Errorf("invalid user id %q", args[1])
+	}
+
+	ctx := context.Background()
+	quotaRepo := repository.NewQuotaRepository(db)
+
+	switch args[0] {
+	case "show":
+
+	case "set":
+		override, err := parseQuotaOverride(userId, args[2:])
+		if err != nil {
+			return err
+		}
+
+		if err := quotaRepo.SaveAccountQuota(ctx, override); err != nil {
+			return err
+		}
+
+	case "reset":
+		if err := quotaRepo.DeleteAccountQuota(ctx, userId); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown quota command %q, expected show, set or reset", args[0])
+	}
+
+	quota, err := quotaRepo.FindAccountQuota(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Plan: %s, requests per second: %g, request burst: %d, messages per hour: %d, messages per day: %d\n",
+		quota.Plan, quota.RequestsPerSecond, quota.RequestBurst, quota.MessagesPerHour, quota.MessagesPerDay)
+
+	return nil
+}
+
+// parseQuotaOverride reads the flags of `quota set`. Limits that are not given fall back to the plan's.
+func parseQuotaOverride(userId int, args []string) (*model.QuotaOverride, error) {
+	override := &model.QuotaOverride{UserId: userId}
+
+	flags := flag.NewFlagSet("quota set", flag.ContinueOnError)
+	flags.StringVar(&override.Plan, "plan", repository.DefaultPlan, "plan of the account")
+	flags.Func("requests-per-second", "requests per second", func(value string) error {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("must be a number of at least 0")
+		}
+		override.RequestsPerSecond = &n
+		return nil
+	})
+	intFlag := func(name string, target **int) {
+		flags.Func(name, name, func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("must be a whole number of at least 0")
+			}
+			*target = &n
+			return nil
+		})
+	}
+	intFlag("request-burst", &override.RequestBurst)
+	intFlag("messages-per-hour", &override.MessagesPerHour)
+	intFlag("messages-per-day", &override.MessagesPerDay)
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return override, nil
+}
@@ -1 +1,406 @@
-package smtpserver
\ No newline at end of file
+// Package smtpserver receives mail over SMTP. The service points the return path of the mail it sends at
+// this server, so that the bounces and complaints mailbox providers send back reach it.
+//
+// The server speaks the subset of SMTP needed to accept messages (RFC 5321): it does not relay, authenticate
+// or offer TLS, and hands every accepted message to a Handler.
+package smtpserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// commandTimeout is how long a client may take to send a command or a line of a message.
+	commandTimeout = 5 * time.Minute
+	// maxRecipients bounds the recipients of one message.
+	maxRecipients = 100
+	// handlerTimeout bounds how long a Handler may take with one message.
+	handlerTimeout = time.Minute
+)
+
+// Handler receives the messages accepted by the server. An error makes the server answer with a temporary
+// failure, so the sender tries again later.
+type Handler interface {
+	HandleMessage(ctx context.Context, from string, to []string, data []byte) error
+}
+
+// Server is a lifecycle component accepting messages on an address.
+type Server struct {
+	addr            string
+	domain          string
+	maxMessageBytes int
+	handler         Handler
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	closing  bool
+	sessions map[*session]struct{}
+}
+
+// New returns a server listening on addr that greets clients as domain and refuses messages larger than
+// maxMessageBytes.
+func New(addr string, domain string, maxMessageBytes int, handler Handler) *Server {
+	return &Server{
+		addr:            addr,
+		domain:          domain,
+		maxMessageBytes: maxMessageBytes,
+		handler:         handler,
+		sessions:        make(map[*session]struct{}),
+	}
+}
+
+func (s *Server) Name() string {
+	return "SMTP server on " + s.addr
+}
+
+// Addr returns the address the server listens on, which differs from the configured one for port 0.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *Server) Start() error {
+	// Listen before returning so that a port already in use fails the start instead of the run.
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return nil
+}
+
+// Check reports an error once the server stopped accepting connections. It is meant for readiness checks.
+func (s *Server) Check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil || s.closing {
+		return fmt.Errorf("%s is not running", s.Name())
+	}
+
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closing := s.closing
+			s.closing = true
+			s.mu.Unlock()
+
+			if !closing {
+				slog.Error("SMTP server stopped accepting connections", "error", err)
+			}
+			return
+		}
+
+		sess := &session{server: s, conn: conn, text: textproto.NewConn(conn)}
+
+		s.mu.Lock()
+		s.sessions[sess] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			sess.serve()
+
+			s.mu.Lock()
+			delete(s.sessions, sess)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Stop stops accepting connections and closes the idle ones. Messages being received are finished unless
+// ctx is done first, in which case their connections are closed and the senders retry later.
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	for sess := range s.sessions {
+		sess.interruptIfIdle()
+	}
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		for sess := range s.sessions {
+			sess.conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+type session struct {
+	server *Server
+	conn   net.Conn
+	text   *textproto.Conn
+
+	// idle is set while waiting for a command, when the session can be closed without losing a message.
+	// It is guarded by server.mu.
+	idle bool
+
+	helo string
+	from string
+	to   []string
+}
+
+// interruptIfIdle makes a session waiting for a command stop reading. The caller must hold server.mu.
+func (sess *session) interruptIfIdle() {
+	if sess.idle {
+		sess.conn.SetReadDeadline(time.Now())
+	}
+}
+
+func (sess *session) serve() {
+	defer sess.text.Close()
+
+	sess.reply(220, sess.server.domain+" ESMTP ready")
+
+	for {
+		line, ok := sess.readCommand()
+		if !ok {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			sess.hello(strings.ToUpper(verb), strings.TrimSpace(arg))
+		case "MAIL":
+			sess.mail(arg)
+		case "RCPT":
+			sess.rcpt(arg)
+		case "DATA":
+			sess.data()
+		case "RSET":
+			sess.reset()
+			sess.reply(250, "OK")
+		case "NOOP":
+			sess.reply(250, "OK")
+		case "VRFY":
+			sess.reply(252, "Cannot verify the user")
+		case "QUIT":
+			sess.reply(221, "Bye")
+			return
+		default:
+			sess.reply(502, "Command not implemented")
+		}
+	}
+}
+
+// readCommand waits for the next command. It returns false when the connection should be closed.
+func (sess *session) readCommand() (string, bool) {
+	server := sess.server
+
+	server.mu.Lock()
+	if server.closing {
+		server.mu.Unlock()
+		sess.reply(421, server.domain+" shutting down")
+		return "", false
+	}
+	sess.idle = true
+	server.mu.Unlock()
+
+	sess.conn.SetReadDeadline(time.Now().Add(commandTimeout))
+	line, err := sess.text.ReadLine()
+
+	server.mu.Lock()
+	sess.idle = false
+	closing := server.closing
+	server.mu.Unlock()
+
+	if err != nil {
+		if closing {
+			sess.conn.SetWriteDeadline(time.Now().Add(time.Second))
+			sess.reply(421, server.domain+" shutting down")
+		}
+		return "", false
+	}
+
+	return line, true
+}
+
+func (sess *session) hello(verb string, domain string) {
+	if domain == "" {
+		sess.reply(501, "Domain name required")
+		return
+	}
+
+	sess.helo = domain
+	sess.reset()
+
+	if verb == "HELO" {
+		sess.reply(250, sess.server.domain)
+		return
+	}
+
+	sess.reply(250, sess.server.domain, "SIZE "+strconv.Itoa(sess.server.maxMessageBytes), "8BITMIME")
+}
+
+func (sess *session) mail(arg string) {
+	if sess.helo == "" {
+		sess.reply(503, "Send HELO or EHLO first")
+		return
+	}
+
+	if sess.from != "" {
+		sess.reply(503, "Sender already given")
+		return
+	}
+
+	address, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		sess.reply(501, "Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	for _, param := range strings.Fields(params) {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(key, "SIZE") {
+			if size, err := strconv.Atoi(value); err == nil && size > sess.server.maxMessageBytes {
+				sess.reply(552, "Message too large")
+				return
+			}
+		}
+	}
+
+	// the null reverse path <> is what bounces and most reports are sent with
+	if address == "" {
+		address = "<>"
+	}
+
+	sess.from = address
+	sess.reply(250, "OK")
+}
+
+func (sess *session) rcpt(arg string) {
+	if sess.from == "" {
+		sess.reply(503, "Send MAIL first")
+		return
+	}
+
+	address, _, ok := parsePath(arg, "TO:")
+	if !ok || address == "" {
+		sess.reply(501, "Syntax: RCPT TO:<address>")
+		return
+	}
+
+	if len(sess.to) >= maxRecipients {
+		sess.reply(452, "Too many recipients")
+		return
+	}
+
+	sess.to = append(sess.to, address)
+	sess.reply(250, "OK")
+}
+
+func (sess *session) data() {
+	if len(sess.to) == 0 {
+		sess.reply(503, "Send RCPT first")
+		return
+	}
+
+	sess.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	sess.conn.SetReadDeadline(time.Now().Add(commandTimeout))
+
+	reader := sess.text.DotReader()
+	data, err := io.ReadAll(io.LimitReader(reader, int64(sess.server.maxMessageBytes)+1))
+	if err != nil {
+		// the client is gone or stalled in the middle of the message, so nothing it sends can be trusted
+		sess.conn.Close()
+		return
+	}
+
+	from, to := sess.from, sess.to
+	sess.reset()
+
+	if len(data) > sess.server.maxMessageBytes {
+		// the rest of the message has to be read before the client listens to the reply
+		io.Copy(io.Discard, reader)
+		sess.reply(552, "Message too large")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), handlerTimeout)
+	defer cancel()
+
+	if err := sess.server.handler.HandleMessage(ctx, from, to, data); err != nil {
+		slog.ErrorContext(ctx, "failed to handle a received message", "from", from, "error", err)
+		sess.reply(451, "Requested action aborted: local error in processing")
+		return
+	}
+
+	sess.reply(250, "OK: message accepted")
+}
+
+func (sess *session) reset() {
+	sess.from = ""
+	sess.to = nil
+}
+
+// reply sends a reply with one or more lines.
+func (sess *session) reply(code int, lines ...string) {
+	w := sess.text.Writer.W
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		fmt.Fprintf(w, "%d%s%s\r\n", code, separator, line)
+	}
+	w.Flush()
+}
+
+// parsePath parses the argument of MAIL or RCPT, such as "FROM:<user@example.com> SIZE=100", into the
+// address and the parameters after it.
+func parsePath(arg string, prefix string) (string, string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", "", false
+	}
+
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", "", false
+	}
+
+	return rest[1:end], strings.TrimSpace(rest[end+1:]), true
+}
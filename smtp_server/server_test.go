@@ -0,0 +1,126 @@
+package smtpserver
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type received struct {
+	from string
+	to   []string
+	data string
+}
+
+type recordingHandler struct {
+	mu       sync.Mutex
+	messages []received
+	err      error
+}
+
+func (h *recordingHandler) HandleMessage(ctx context.Context, from string, to []string, data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return h.err
+	}
+
+	h.messages = append(h.messages, received{from: from, to: to, data: string(data)})
+	return nil
+}
+
+func startServer(t *testing.T, handler Handler) *Server {
+	t.Helper()
+
+	server := New("127.0.0.1:0", "bounces.example.com", 1024, handler)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Stop(ctx)
+	})
+
+	return server
+}
+
+func TestServerAcceptsMessages(t *testing.T) {
+	handler := &recordingHandler{}
+	server := startServer(t, handler)
+
+	msg := "Subject: Delivery Status Notification\r\n\r\nHello\r\n.leading dot\r\n"
+
+	err := smtp.SendMail(server.Addr().String(), nil, "", []string{"bounces@bounces.example.com"}, []byte(msg))
+	if err != nil {
+		t.Fatalf("SendMail: %v", err)
+	}
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+
+	if len(handler.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(handler.messages))
+	}
+
+	got := handler.messages[0]
+	if got.from != "<>" || len(got.to) != 1 || got.to[0] != "bounces@bounces.example.com" {
+		t.Fatalf("unexpected envelope %+v", got)
+	}
+
+	if got.data != strings.ReplaceAll(msg, "\r\n", "\n") {
+		t.Fatalf("unexpected data %q", got.data)
+	}
+}
+
+func TestServerRefusesLargeMessages(t *testing.T) {
+	server := startServer(t, &recordingHandler{})
+
+	err := smtp.SendMail(server.Addr().String(), nil, "sender@example.com", []string{"bounces@bounces.example.com"}, []byte(strings.Repeat("x", 2048)))
+	if err == nil || !strings.Contains(err.Error(), "552") {
+		t.Fatalf("expected a 552 error, got %v", err)
+	}
+}
+
+func TestServerReportsHandlerErrorsAsTemporary(t *testing.T) {
+	server := startServer(t, &recordingHandler{err: errors.New("database unavailable")})
+
+	err := smtp.SendMail(server.Addr().String(), nil, "", []string{"bounces@bounces.example.com"}, []byte("Subject: x\r\n\r\ny\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "451") {
+		t.Fatalf("expected a 451 error, got %v", err)
+	}
+}
+
+func TestStopClosesIdleConnections(t *testing.T) {
+	server := New("127.0.0.1:0", "bounces.example.com", 1024, &recordingHandler{})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	client, err := smtp.Dial(server.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Hello("client.example.com"); err != nil {
+		t.Fatalf("Hello: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := server.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if err := client.Noop(); err == nil {
+		t.Fatal("the idle connection should have been closed")
+	}
+}